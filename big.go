@@ -0,0 +1,244 @@
+// Copyright 2024 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package floatx
+
+import (
+	"math"
+	"math/big"
+)
+
+// FromBigFloat returns x rounded to T as selected by opts and the exceptions
+// raised. x is rounded once, exactly.
+//
+// opts can be nil to round to nearest even without saturation.
+// DenormalsAreZero is ignored.
+//
+// A nil x, as returned by BigFloat for NaN, is converted to the positive
+// quiet NaN and raises FlagInvalid.
+func FromBigFloat[T Float](x *big.Float, opts *ConvertOptions) (T, Flags) {
+	var o ConvertOptions
+	if opts != nil {
+		o = *opts
+	}
+	var z T
+	dst, _ := toBits(z)
+	if x == nil {
+		return FromBits[T](dst.nan()), FlagInvalid
+	}
+	r, flags := dst.roundBigFloat(x, o.Rounding, o.Saturate)
+	r, flags = o.flush(dst, r, flags)
+	return FromBits[T](r), flags
+}
+
+// FromRat returns x rounded to T as selected by opts and the exceptions
+// raised. x is rounded once, exactly.
+//
+// opts can be nil to round to nearest even without saturation.
+// DenormalsAreZero is ignored.
+//
+// A nil x, as returned by Rat for NaN and infinities, is converted to the
+// positive quiet NaN and raises FlagInvalid.
+func FromRat[T Float](x *big.Rat, opts *ConvertOptions) (T, Flags) {
+	var o ConvertOptions
+	if opts != nil {
+		o = *opts
+	}
+	var z T
+	dst, _ := toBits(z)
+	if x == nil {
+		return FromBits[T](dst.nan()), FlagInvalid
+	}
+	r, flags := dst.roundRat(x, o.Rounding, o.Saturate)
+	r, flags = o.flush(dst, r, flags)
+	return FromBits[T](r), flags
+}
+
+// F32
+
+// BigFloat returns the exact value of f with the precision of F32, or nil for
+// NaN.
+func (f F32) BigFloat() *big.Float {
+	return f32Format.bigFloat(math.Float32bits(float32(f)))
+}
+
+// Rat returns the exact value of f, or nil for NaN and infinities.
+func (f F32) Rat() *big.Rat {
+	return f32Format.rat(math.Float32bits(float32(f)))
+}
+
+// BF16
+
+// BigFloat returns the exact value of b with the precision of BF16, or nil for
+// NaN.
+func (b BF16) BigFloat() *big.Float {
+	return bf16Format.bigFloat(uint32(b))
+}
+
+// Rat returns the exact value of b, or nil for NaN and infinities.
+func (b BF16) Rat() *big.Rat {
+	return bf16Format.rat(uint32(b))
+}
+
+// F16
+
+// BigFloat returns the exact value of f with the precision of F16, or nil for
+// NaN.
+func (f F16) BigFloat() *big.Float {
+	return f16Format.bigFloat(uint32(f))
+}
+
+// Rat returns the exact value of f, or nil for NaN and infinities.
+func (f F16) Rat() *big.Rat {
+	return f16Format.rat(uint32(f))
+}
+
+// F8E4M3
+
+// BigFloat returns the exact value of f with the precision of F8E4M3, or nil
+// for NaN.
+func (f F8E4M3) BigFloat() *big.Float {
+	return f8e4m3Format.bigFloat(uint32(f))
+}
+
+// Rat returns the exact value of f, or nil for NaN and infinities.
+func (f F8E4M3) Rat() *big.Rat {
+	return f8e4m3Format.rat(uint32(f))
+}
+
+// F8E4M3Fn
+
+// BigFloat returns the exact value of f with the precision of F8E4M3Fn, or nil
+// for NaN.
+func (f F8E4M3Fn) BigFloat() *big.Float {
+	return f8e4m3fnFormat.bigFloat(uint32(f))
+}
+
+// Rat returns the exact value of f, or nil for NaN.
+func (f F8E4M3Fn) Rat() *big.Rat {
+	return f8e4m3fnFormat.rat(uint32(f))
+}
+
+// F8E5M2
+
+// BigFloat returns the exact value of f with the precision of F8E5M2, or nil
+// for NaN.
+func (f F8E5M2) BigFloat() *big.Float {
+	return f8e5m2Format.bigFloat(uint32(f))
+}
+
+// Rat returns the exact value of f, or nil for NaN and infinities.
+func (f F8E5M2) Rat() *big.Rat {
+	return f8e5m2Format.rat(uint32(f))
+}
+
+// Internal
+
+var half = big.NewFloat(0.5)
+
+func (f *format) bigFloat(bits uint32) *big.Float {
+	if f.isNaN(bits) {
+		return nil
+	}
+	z := new(big.Float).SetPrec(f.mantissaBits + 1)
+	if f.isInf(bits) {
+		return z.SetInf(bits&f.signBit() != 0)
+	}
+	m, e := f.mantExp(bits)
+	z.SetMantExp(z.SetInt64(m), e)
+	if m == 0 && bits&f.signBit() != 0 {
+		z.Neg(z)
+	}
+	return z
+}
+
+func (f *format) rat(bits uint32) *big.Rat {
+	if f.isNaN(bits) || f.isInf(bits) {
+		return nil
+	}
+	m, e := f.mantExp(bits)
+	n := big.NewInt(m)
+	if e >= 0 {
+		return new(big.Rat).SetInt(n.Lsh(n, uint(e)))
+	}
+	return new(big.Rat).SetFrac(n, new(big.Int).Lsh(big.NewInt(1), uint(-e)))
+}
+
+// mantExp returns the finite value encoded by bits as m × 2^e, straight from
+// the bit fields.
+func (f *format) mantExp(bits uint32) (m int64, e int) {
+	biased := int((bits & f.exponentMask()) >> f.mantissaBits)
+	m = int64(bits & f.mantissaMask())
+	if biased == 0 {
+		// Subnormals have the same quantum as the smallest normals.
+		biased = 1
+	} else {
+		m |= 1 << f.mantissaBits
+	}
+	if bits&f.signBit() != 0 {
+		m = -m
+	}
+	return m, biased - f.bias() - int(f.mantissaBits)
+}
+
+// roundBigFloat is like round for a *big.Float.
+func (f *format) roundBigFloat(x *big.Float, mode RoundingMode, saturate bool) (uint32, Flags) {
+	if x.IsInf() || x.Sign() == 0 {
+		v, _ := x.Float64()
+		return f.round(v, mode, saturate)
+	}
+	biased, e := f.quantum(x.MantExp(nil) - 1)
+	// Count of quanta. Changing the exponent is exact.
+	y := new(big.Float).SetMantExp(new(big.Float).Abs(x), int(f.mantissaBits)-e)
+	q, _ := y.Uint64()
+	frac := new(big.Float).Sub(y, new(big.Float).SetUint64(q))
+	return f.roundQuanta(x.Signbit(), biased, quanta(q, frac.Sign(), frac.Cmp(half)), mode, saturate)
+}
+
+// roundRat is like round for a *big.Rat.
+func (f *format) roundRat(x *big.Rat, mode RoundingMode, saturate bool) (uint32, Flags) {
+	if x.Sign() == 0 {
+		return 0, 0
+	}
+	n := new(big.Int).Abs(x.Num())
+	d := new(big.Int).Set(x.Denom())
+	// Find e so that 2^e <= n/d < 2^(e+1).
+	e := n.BitLen() - d.BitLen()
+	if e >= 0 {
+		if n.Cmp(new(big.Int).Lsh(d, uint(e))) < 0 {
+			e--
+		}
+	} else if new(big.Int).Lsh(n, uint(-e)).Cmp(d) < 0 {
+		e--
+	}
+	biased, e := f.quantum(e)
+	// Count of quanta as n/d.
+	if s := int(f.mantissaBits) - e; s >= 0 {
+		n.Lsh(n, uint(s))
+	} else {
+		d.Lsh(d, uint(-s))
+	}
+	q, r := n.QuoRem(n, d, new(big.Int))
+	fracSign := r.Sign()
+	halfCmp := r.Lsh(r, 1).Cmp(d)
+	return f.roundQuanta(x.Sign() < 0, biased, quanta(q.Uint64(), fracSign, halfCmp), mode, saturate)
+}
+
+// quanta returns q plus a fraction that rounds like the exact fractional part
+// whose sign is fracSign and whose comparison with 0.5 is halfCmp.
+//
+// q is small enough for the result to be exact.
+func quanta(q uint64, fracSign, halfCmp int) float64 {
+	x := float64(q)
+	switch {
+	case fracSign == 0:
+		return x
+	case halfCmp < 0:
+		return x + 0.25
+	case halfCmp == 0:
+		return x + 0.5
+	default:
+		return x + 0.75
+	}
+}
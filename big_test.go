@@ -0,0 +1,306 @@
+// Copyright 2024 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package floatx_test
+
+import (
+	"math"
+	"math/big"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/maruel/floatx"
+)
+
+func Test_FromRat_All(t *testing.T) {
+	t.Run("BF16", func(t *testing.T) { testBigFrom(t, slices.Collect(floatx.All[floatx.BF16]())) })
+	t.Run("F16", func(t *testing.T) { testBigFrom(t, slices.Collect(floatx.All[floatx.F16]())) })
+	t.Run("F8E4M3", func(t *testing.T) { testBigFrom(t, slices.Collect(floatx.All[floatx.F8E4M3]())) })
+	t.Run("F8E4M3Fn", func(t *testing.T) { testBigFrom(t, slices.Collect(floatx.All[floatx.F8E4M3Fn]())) })
+	t.Run("F8E5M2", func(t *testing.T) { testBigFrom(t, slices.Collect(floatx.All[floatx.F8E5M2]())) })
+}
+
+func testBigFrom[From floatx.Float](t *testing.T, src []From) {
+	t.Run("BF16", func(t *testing.T) { testBig[floatx.BF16](t, src) })
+	t.Run("F16", func(t *testing.T) { testBig[floatx.F16](t, src) })
+	t.Run("F8E4M3", func(t *testing.T) { testBig[floatx.F8E4M3](t, src) })
+	t.Run("F8E4M3Fn", func(t *testing.T) { testBig[floatx.F8E4M3Fn](t, src) })
+	t.Run("F8E5M2", func(t *testing.T) { testBig[floatx.F8E5M2](t, src) })
+}
+
+// testBig verifies Convert, FromRat and FromBigFloat against big.Float
+// rounding of the exact values.
+func testBig[To, From floatx.Float](t *testing.T, src []From) {
+	for _, s := range src {
+		b := s.BigFloat()
+		r := s.Rat()
+		if b == nil {
+			continue
+		}
+		for _, mode := range roundingModes {
+			for _, saturate := range []bool{false, true} {
+				opts := floatx.ConvertOptions{Rounding: mode, Saturate: saturate}
+				want, wantFlags := floatx.ConvertFlags[To](s, &opts)
+				if got := floatx.Convert[To](s, &opts); got != want {
+					t.Fatalf("Convert(%s, %s, %t) = %#x; want %#x", text(b), mode, saturate, got.Bits(), want.Bits())
+				}
+				if exact, flags := roundBig[To](b, mode, saturate); !sameBig(want.BigFloat(), exact) || flags != wantFlags {
+					t.Fatalf("ConvertFlags(%s, %s, %t) = %#x, %s; want %s, %s", text(b), mode, saturate, want.Bits(), wantFlags, text(exact), flags)
+				}
+				if saturate && wantFlags&floatx.FlagOverflow == 0 {
+					// Saturate only affects the values overflowing.
+					continue
+				}
+				if got, flags := floatx.FromBigFloat[To](b, &opts); got != want || flags != wantFlags {
+					t.Fatalf("FromBigFloat(%s, %s, %t) = %#x, %s; want %#x, %s", text(b), mode, saturate, got.Bits(), flags, want.Bits(), wantFlags)
+				}
+				if r == nil {
+					// big.Rat has no infinity.
+					continue
+				}
+				if b.Sign() == 0 {
+					// big.Rat has no -0.
+					want = floatx.FromBits[To](0)
+				}
+				if got, flags := floatx.FromRat[To](r, &opts); got != want || flags != wantFlags {
+					t.Fatalf("FromRat(%s, %s, %t) = %#x, %s; want %#x, %s", r, mode, saturate, got.Bits(), flags, want.Bits(), wantFlags)
+				}
+			}
+		}
+	}
+}
+
+// layout is the encoding of a type, spelled out independently of the package.
+type layout struct {
+	exponentBits, mantissaBits int
+	// finite is true for the types without infinity, which use the largest
+	// encoding for NaN.
+	finite bool
+}
+
+func layoutOf[T floatx.Float]() layout {
+	var z T
+	switch any(z).(type) {
+	case floatx.F32:
+		return layout{8, 23, false}
+	case floatx.BF16:
+		return layout{8, 7, false}
+	case floatx.F16:
+		return layout{5, 10, false}
+	case floatx.F8E4M3:
+		return layout{4, 3, false}
+	case floatx.F8E4M3Fn:
+		return layout{4, 3, true}
+	default:
+		return layout{5, 2, false}
+	}
+}
+
+// roundBig rounds the non-NaN x to T with big.Float and returns the exact
+// result, or nil for NaN, and the exceptions raised.
+func roundBig[T floatx.Float](x *big.Float, mode floatx.RoundingMode, saturate bool) (*big.Float, floatx.Flags) {
+	l := layoutOf[T]()
+	prec := uint(l.mantissaBits + 1)
+	bias := 1<<(l.exponentBits-1) - 1
+	emin := 1 - bias
+	// The largest finite value.
+	maxFinite := new(big.Float).SetMantExp(big.NewFloat(2-math.Ldexp(1, -l.mantissaBits)), bias)
+	if l.finite {
+		maxFinite.SetMantExp(big.NewFloat(2-math.Ldexp(1, 1-l.mantissaBits)), bias+1)
+	}
+	if x.IsInf() {
+		if l.finite {
+			if saturate {
+				return neg(maxFinite, x.Signbit()), floatx.FlagInvalid
+			}
+			return nil, floatx.FlagInvalid
+		}
+		return new(big.Float).Set(x), 0
+	}
+	z := new(big.Float).SetMode(big.RoundingMode(mode)).SetPrec(prec)
+	smallest := new(big.Float).SetMantExp(big.NewFloat(1), emin)
+	if new(big.Float).Abs(x).Cmp(smallest) >= 0 {
+		z.Set(x)
+	} else {
+		// Adding 2^emin with the same sign moves x to a binade whose quantum
+		// is the subnormals' quantum, so the rounding happens at the right
+		// place. Removing it is exact.
+		offset := neg(smallest, x.Signbit())
+		z.Add(x, offset)
+		z.Sub(z, offset)
+		if z.Sign() == 0 {
+			z = neg(z, x.Signbit())
+		}
+	}
+	if new(big.Float).Abs(z).Cmp(maxFinite) > 0 {
+		flags := floatx.FlagOverflow | floatx.FlagInexact
+		towardZero := mode == floatx.RoundTowardZero ||
+			(mode == floatx.RoundTowardNegative && !x.Signbit()) ||
+			(mode == floatx.RoundTowardPositive && x.Signbit())
+		switch {
+		case saturate || towardZero:
+			return neg(maxFinite, x.Signbit()), flags
+		case l.finite:
+			return nil, flags
+		default:
+			return new(big.Float).SetInf(x.Signbit()), flags
+		}
+	}
+	if x.Cmp(z) == 0 {
+		return z, 0
+	}
+	if new(big.Float).Abs(z).Cmp(smallest) < 0 {
+		return z, floatx.FlagUnderflow | floatx.FlagInexact
+	}
+	return z, floatx.FlagInexact
+}
+
+// neg returns a copy of x with the sign of negative.
+func neg(x *big.Float, negative bool) *big.Float {
+	z := new(big.Float).Abs(x)
+	if negative {
+		z.Neg(z)
+	}
+	return z
+}
+
+// sameBig returns true if a and b are both nil or have the same value and
+// sign.
+func sameBig(a, b *big.Float) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Cmp(b) == 0 && a.Signbit() == b.Signbit()
+}
+
+func text(x *big.Float) string {
+	if x == nil {
+		return "NaN"
+	}
+	return x.Text('p', 0)
+}
+
+func Test_FromRat_SpotCheck(t *testing.T) {
+	inexact := floatx.FlagInexact
+	// Slightly above halfway between 1 and the next BF16, which a float64
+	// can't represent.
+	aboveHalf := new(big.Rat).Add(big.NewRat(257, 256), new(big.Rat).SetFrac(big.NewInt(1), new(big.Int).Lsh(big.NewInt(1), 100)))
+	googol, _ := new(big.Int).SetString("1"+strings.Repeat("0", 100), 10)
+	data := []struct {
+		x     *big.Rat
+		mode  floatx.RoundingMode
+		want  floatx.BF16
+		flags floatx.Flags
+	}{
+		{big.NewRat(1, 1), floatx.RoundNearestEven, 0x3F80, 0},
+		{big.NewRat(-3, 2), floatx.RoundNearestEven, 0xBFC0, 0},
+		{big.NewRat(1, 3), floatx.RoundNearestEven, 0x3EAB, inexact},
+		{big.NewRat(5, 3), floatx.RoundNearestEven, 0x3FD5, inexact},
+		{big.NewRat(1, 3), floatx.RoundTowardZero, 0x3EAA, inexact},
+		{big.NewRat(-1, 3), floatx.RoundTowardPositive, 0xBEAA, inexact},
+		{big.NewRat(-1, 3), floatx.RoundTowardNegative, 0xBEAB, inexact},
+		// Exactly halfway between 1 and the next value rounds to even.
+		{big.NewRat(257, 256), floatx.RoundNearestEven, 0x3F80, inexact},
+		{aboveHalf, floatx.RoundNearestEven, 0x3F81, inexact},
+		{new(big.Rat).SetInt(googol), floatx.RoundNearestEven, 0x7F80, floatx.FlagOverflow | inexact},
+		{new(big.Rat).SetInt(googol), floatx.RoundTowardZero, 0x7F7F, floatx.FlagOverflow | inexact},
+		{new(big.Rat).SetFrac(big.NewInt(1), googol), floatx.RoundNearestEven, 0, floatx.FlagUnderflow | inexact},
+		{new(big.Rat).SetFrac(big.NewInt(1), googol), floatx.RoundAwayFromZero, 1, floatx.FlagUnderflow | inexact},
+	}
+	for i, l := range data {
+		opts := floatx.ConvertOptions{Rounding: l.mode}
+		if got, flags := floatx.FromRat[floatx.BF16](l.x, &opts); got != l.want || flags != l.flags {
+			t.Errorf("#%d: FromRat(%s, %s) = %#x, %s; want %#x, %s", i, l.x, l.mode, got, flags, l.want, l.flags)
+		}
+		b := new(big.Float).SetPrec(1000).SetRat(l.x)
+		if got, flags := floatx.FromBigFloat[floatx.BF16](b, &opts); got != l.want || flags != l.flags {
+			t.Errorf("#%d: FromBigFloat(%s, %s) = %#x, %s; want %#x, %s", i, l.x, l.mode, got, flags, l.want, l.flags)
+		}
+	}
+	if got, _ := floatx.FromRat[floatx.F8E4M3Fn](big.NewRat(1000, 1), &floatx.ConvertOptions{Saturate: true}); got != 0x7E {
+		t.Fatalf("%#x", got)
+	}
+	if got, flags := floatx.FromRat[floatx.F16](big.NewRat(1, 1<<20), &floatx.ConvertOptions{FlushToZero: true}); got != 0 || flags != floatx.FlagUnderflow|floatx.FlagInexact {
+		t.Fatalf("%#x %s", got, flags)
+	}
+	if got, _ := floatx.FromRat[floatx.F32](big.NewRat(1, 3), nil); float32(got) != float32(1.0/3) {
+		t.Fatal(got)
+	}
+}
+
+func Test_FromBigFloat_SpotCheck(t *testing.T) {
+	huge := new(big.Float).SetMantExp(big.NewFloat(1), 1<<30)
+	if got, flags := floatx.FromBigFloat[floatx.F8E5M2](huge, nil); got != 0x7C || flags != floatx.FlagOverflow|floatx.FlagInexact {
+		t.Fatalf("%#x %s", got, flags)
+	}
+	tiny := new(big.Float).SetMantExp(big.NewFloat(-1), -1<<30)
+	if got, flags := floatx.FromBigFloat[floatx.F8E5M2](tiny, nil); got != 0x80 || flags != floatx.FlagUnderflow|floatx.FlagInexact {
+		t.Fatalf("%#x %s", got, flags)
+	}
+	if got, flags := floatx.FromBigFloat[floatx.F8E5M2](tiny, &floatx.ConvertOptions{Rounding: floatx.RoundTowardNegative}); got != 0x81 || flags != floatx.FlagUnderflow|floatx.FlagInexact {
+		t.Fatalf("%#x %s", got, flags)
+	}
+	inf := new(big.Float).SetInf(true)
+	if got, flags := floatx.FromBigFloat[floatx.F8E4M3Fn](inf, nil); got != 0xFF || flags != floatx.FlagInvalid {
+		t.Fatalf("%#x %s", got, flags)
+	}
+	negZero := new(big.Float).Neg(new(big.Float))
+	if got, flags := floatx.FromBigFloat[floatx.BF16](negZero, nil); got != 0x8000 || flags != 0 {
+		t.Fatalf("%#x %s", got, flags)
+	}
+}
+
+func Test_FromBig_NaN(t *testing.T) {
+	if got, flags := floatx.FromBigFloat[floatx.BF16](nil, nil); got != 0x7FC0 || flags != floatx.FlagInvalid {
+		t.Fatalf("%#x %s", got, flags)
+	}
+	if got, flags := floatx.FromRat[floatx.F8E4M3Fn](nil, nil); got != 0x7F || flags != floatx.FlagInvalid {
+		t.Fatalf("%#x %s", got, flags)
+	}
+	// NaN round trips through nil.
+	nan := floatx.F16(0xFE01)
+	if got, flags := floatx.FromBigFloat[floatx.F16](nan.BigFloat(), nil); !got.IsNaN() || flags != floatx.FlagInvalid {
+		t.Fatalf("%#x %s", got, flags)
+	}
+	if got, flags := floatx.FromRat[floatx.F8E5M2](nan.Rat(), &floatx.ConvertOptions{Saturate: true}); !got.IsNaN() || flags != floatx.FlagInvalid {
+		t.Fatalf("%#x %s", got, flags)
+	}
+	if got, flags := floatx.FromBigFloat[floatx.F8E4M3Fn](floatx.F8E4M3Fn(0xFF).BigFloat(), nil); got != 0x7F || flags != floatx.FlagInvalid {
+		t.Fatalf("%#x %s", got, flags)
+	}
+}
+
+func Test_BigFloat(t *testing.T) {
+	if b := floatx.BF16(0x3F81).BigFloat(); b.Prec() != 8 || b.Text('p', 0) != "0x.81p+1" {
+		t.Fatal(b.Prec(), b.Text('p', 0))
+	}
+	if b := floatx.F8E5M2(0xFC).BigFloat(); !b.IsInf() || !b.Signbit() {
+		t.Fatal(b)
+	}
+	if b := floatx.F8E4M3Fn(0x80).BigFloat(); b.Sign() != 0 || !b.Signbit() {
+		t.Fatal(b)
+	}
+	if floatx.F16(0x7E00).BigFloat() != nil || floatx.F16(0x7C00).Rat() != nil || floatx.F8E4M3Fn(0x7F).Rat() != nil {
+		t.Fatal("expected nil")
+	}
+	if r := floatx.F8E4M3(0x01).Rat(); r.Cmp(big.NewRat(1, 512)) != 0 {
+		t.Fatal(r)
+	}
+	if r := floatx.F32(math.SmallestNonzeroFloat32).Rat(); r.Cmp(new(big.Rat).SetFrac(big.NewInt(1), new(big.Int).Lsh(big.NewInt(1), 149))) != 0 {
+		t.Fatal(r)
+	}
+	if b := floatx.F32(1.5).BigFloat(); b.Prec() != 24 || b.String() != "1.5" {
+		t.Fatal(b)
+	}
+	if b := floatx.F8E4M3(0x38).BigFloat(); b.Prec() != 4 || b.String() != "1" {
+		t.Fatal(b)
+	}
+	if b := floatx.F8E5M2(0x3C).Rat(); b.RatString() != "1" {
+		t.Fatal(b)
+	}
+	if b := floatx.F16(0x3C00).Rat(); b.RatString() != "1" || floatx.F8E4M3Fn(0x38).Rat().RatString() != "1" || floatx.BF16(0x3F80).Rat().RatString() != "1" {
+		t.Fatal(b)
+	}
+}
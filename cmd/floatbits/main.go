@@ -0,0 +1,329 @@
+// Copyright 2024 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+// floatbits explains the encoding of a value in one of the floatx types.
+//
+// Given a type and either a value or a hexadecimal bit pattern, it prints the
+// encoding, its components, the neighbouring representable values, the size
+// of the unit in the last place and the value converted to every other type.
+//
+// With -table, it prints every encoding of a type as Markdown or CSV.
+//
+// Usage:
+//
+//	floatbits F8E4M3Fn 300
+//	floatbits torch.bfloat16 0x7f80
+//	floatbits -table md F8E5M2
+package main
+
+import (
+	"encoding/binary"
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/maruel/floatx"
+)
+
+// dtypes are all the supported types, in display order.
+var dtypes = []floatx.DType{
+	floatx.DTypeF32,
+	floatx.DTypeBF16,
+	floatx.DTypeF16,
+	floatx.DTypeF8E4M3,
+	floatx.DTypeF8E4M3Fn,
+	floatx.DTypeF8E5M2,
+}
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout, os.Stderr); err != nil {
+		if !errors.Is(err, flag.ErrHelp) {
+			fmt.Fprintf(os.Stderr, "floatbits: %s\n", err)
+		}
+		os.Exit(1)
+	}
+}
+
+func run(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("floatbits", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "usage: floatbits <type> <value|0xbits>\n       floatbits -table md|csv <type>\n\n")
+		fs.PrintDefaults()
+	}
+	table := fs.String("table", "", "print every encoding of the type as md (Markdown) or csv")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	want := 2
+	if *table != "" {
+		want = 1
+	}
+	if fs.NArg() != want {
+		fs.Usage()
+		return errors.New("unexpected arguments")
+	}
+	d, ok := floatx.LookupDType(fs.Arg(0))
+	if !ok {
+		return fmt.Errorf("unknown type %q", fs.Arg(0))
+	}
+	if *table != "" {
+		return printTable(stdout, d, *table)
+	}
+	bits, err := parse(d, fs.Arg(1))
+	if err != nil {
+		return err
+	}
+	printValue(stdout, d, bits, fs.Arg(1))
+	return nil
+}
+
+// parse returns the encoding of s, which is either a bit pattern prefixed with
+// "0x" or a value rounded to the nearest encoding.
+func parse(d floatx.DType, s string) (uint32, error) {
+	info := d.Info()
+	if h, ok := strings.CutPrefix(strings.ToLower(s), "0x"); ok {
+		v, err := strconv.ParseUint(h, 16, info.Bits)
+		if err != nil {
+			return 0, fmt.Errorf("invalid %d bits pattern %q", info.Bits, s)
+		}
+		return uint32(v), nil
+	}
+	// Parse the decimal value exactly so it is rounded only once. Rounding it
+	// to float32 first would round values close to a tie the wrong way.
+	if r, ok := new(big.Rat).SetString(s); ok {
+		bits := fromRat(d, r)
+		if r.Sign() == 0 && strings.HasPrefix(s, "-") {
+			// big.Rat has no -0.
+			bits |= 1 << (info.Bits - 1)
+		}
+		return bits, nil
+	}
+	// Infinities, NaN and exponents too large for big.Rat.
+	v, err := strconv.ParseFloat(s, 32)
+	if err != nil && !errors.Is(err, strconv.ErrRange) {
+		return 0, fmt.Errorf("invalid value %q", s)
+	}
+	return encode(d, float32(v)), nil
+}
+
+// fromRat returns the encoding nearest to r.
+func fromRat(d floatx.DType, r *big.Rat) uint32 {
+	switch d {
+	case floatx.DTypeF32:
+		return roundRat[floatx.F32](r)
+	case floatx.DTypeBF16:
+		return roundRat[floatx.BF16](r)
+	case floatx.DTypeF16:
+		return roundRat[floatx.F16](r)
+	case floatx.DTypeF8E4M3:
+		return roundRat[floatx.F8E4M3](r)
+	case floatx.DTypeF8E4M3Fn:
+		return roundRat[floatx.F8E4M3Fn](r)
+	default:
+		return roundRat[floatx.F8E5M2](r)
+	}
+}
+
+func roundRat[T floatx.Float](r *big.Rat) uint32 {
+	v, _ := floatx.FromRat[T](r, nil)
+	return v.Bits()
+}
+
+func printValue(w io.Writer, d floatx.DType, bits uint32, input string) {
+	info := d.Info()
+	v := decode(d, bits)
+	sign, exponent, mantissa := components(d, bits)
+	tw := tabwriter.NewWriter(w, 0, 0, 1, ' ', 0)
+	fmt.Fprintf(tw, "input:\t%s\n", input)
+	fmt.Fprintf(tw, "type:\t%s (%d bits: 1 sign, %d exponent, %d mantissa)\n", d, info.Bits, info.ExponentBits, info.MantissaBits)
+	fmt.Fprintf(tw, "bits:\t%s %s\n", hex(d, bits), binaryString(d, bits))
+	fmt.Fprintf(tw, "components:\tsign=%d exponent=%d mantissa=%d\n", sign, exponent, mantissa)
+	fmt.Fprintf(tw, "value:\t%s (%s)\n", format(v), class(d, v))
+	if !math.IsNaN(float64(v)) {
+		fmt.Fprintf(tw, "next down:\t%s\n", neighbour(d, bits, false))
+		fmt.Fprintf(tw, "next up:\t%s\n", neighbour(d, bits, true))
+		if !math.IsInf(float64(v), 0) {
+			_, _, ulp := steps(d, bits)
+			fmt.Fprintf(tw, "ulp:\t%s\n", format(ulp))
+		}
+	}
+	_ = tw.Flush()
+	fmt.Fprintf(w, "\nas other types:\n")
+	tw = tabwriter.NewWriter(w, 0, 0, 1, ' ', 0)
+	for _, o := range dtypes {
+		if o == d {
+			continue
+		}
+		b := encode(o, v)
+		r := decode(o, b)
+		fmt.Fprintf(tw, "  %s:\t%s\t%s", o, hex(o, b), format(r))
+		switch {
+		case math.IsNaN(float64(r)) && math.IsNaN(float64(v)):
+		case math.IsNaN(float64(r)) || math.IsInf(float64(r), 0) && !math.IsInf(float64(v), 0):
+			fmt.Fprintf(tw, "\toverflow")
+		case r == 0 && v != 0:
+			fmt.Fprintf(tw, "\tunderflow")
+		case r != v:
+			fmt.Fprintf(tw, "\tinexact")
+		}
+		fmt.Fprintf(tw, "\n")
+	}
+	_ = tw.Flush()
+}
+
+func printTable(w io.Writer, d floatx.DType, kind string) error {
+	info := d.Info()
+	if info.Bits > 16 {
+		return fmt.Errorf("%s has too many values to print", d)
+	}
+	header := []string{"bits", "binary", "sign", "exponent", "mantissa", "value", "class"}
+	row := func(bits uint32) []string {
+		v := decode(d, bits)
+		sign, exponent, mantissa := components(d, bits)
+		return []string{
+			hex(d, bits), binaryString(d, bits),
+			strconv.Itoa(int(sign)), strconv.Itoa(int(exponent)), strconv.Itoa(int(mantissa)),
+			format(v), class(d, v),
+		}
+	}
+	n := uint32(1) << info.Bits
+	switch kind {
+	case "md":
+		fmt.Fprintf(w, "| %s |\n", strings.Join(header, " | "))
+		fmt.Fprintf(w, "|%s\n", strings.Repeat(" --- |", len(header)))
+		for bits := range n {
+			fmt.Fprintf(w, "| %s |\n", strings.Join(row(bits), " | "))
+		}
+		return nil
+	case "csv":
+		c := csv.NewWriter(w)
+		_ = c.Write(header)
+		for bits := range n {
+			_ = c.Write(row(bits))
+		}
+		c.Flush()
+		return c.Error()
+	default:
+		return fmt.Errorf("unknown table format %q; expected md or csv", kind)
+	}
+}
+
+// decode returns the value of an encoding.
+func decode(d floatx.DType, bits uint32) float32 {
+	var b [4]byte
+	binary.LittleEndian.PutUint32(b[:], bits)
+	return d.Info().Decode(b[:])
+}
+
+// encode returns the encoding nearest to v.
+func encode(d floatx.DType, v float32) uint32 {
+	var b [4]byte
+	d.Info().Put(b[:], v)
+	return binary.LittleEndian.Uint32(b[:])
+}
+
+// components returns the sign, exponent and mantissa of an encoding.
+func components(d floatx.DType, bits uint32) (uint8, uint8, uint32) {
+	switch d {
+	case floatx.DTypeF32:
+		return floatx.F32(math.Float32frombits(bits)).Components()
+	case floatx.DTypeBF16:
+		s, e, m := floatx.BF16(bits).Components()
+		return s, e, uint32(m)
+	case floatx.DTypeF16:
+		s, e, m := floatx.F16(bits).Components()
+		return s, e, uint32(m)
+	case floatx.DTypeF8E4M3:
+		s, e, m := floatx.F8E4M3(bits).Components()
+		return s, e, uint32(m)
+	case floatx.DTypeF8E4M3Fn:
+		s, e, m := floatx.F8E4M3Fn(bits).Components()
+		return s, e, uint32(m)
+	default:
+		s, e, m := floatx.F8E5M2(bits).Components()
+		return s, e, uint32(m)
+	}
+}
+
+// neighbour returns the next representable value above or below the encoding.
+func neighbour(d floatx.DType, bits uint32, up bool) string {
+	down, next, _ := steps(d, bits)
+	if !up {
+		next = down
+	}
+	if next == bits || math.IsNaN(float64(decode(d, next))) {
+		return "none"
+	}
+	return hex(d, next) + " " + format(decode(d, next))
+}
+
+// steps returns the encodings of NextDown and NextUp, and the ULP.
+func steps(d floatx.DType, bits uint32) (down, up uint32, ulp float32) {
+	switch d {
+	case floatx.DTypeF32:
+		return step[floatx.F32](bits)
+	case floatx.DTypeBF16:
+		return step[floatx.BF16](bits)
+	case floatx.DTypeF16:
+		return step[floatx.F16](bits)
+	case floatx.DTypeF8E4M3:
+		return step[floatx.F8E4M3](bits)
+	case floatx.DTypeF8E4M3Fn:
+		return step[floatx.F8E4M3Fn](bits)
+	default:
+		return step[floatx.F8E5M2](bits)
+	}
+}
+
+// stepper is a type with NextUp, NextDown and ULP methods.
+type stepper[T any] interface {
+	floatx.Float
+	NextUp() T
+	NextDown() T
+	ULP() float32
+}
+
+func step[T stepper[T]](bits uint32) (down, up uint32, ulp float32) {
+	v := floatx.FromBits[T](bits)
+	return v.NextDown().Bits(), v.NextUp().Bits(), v.ULP()
+}
+
+func class(d floatx.DType, v float32) string {
+	switch a := math.Abs(float64(v)); {
+	case math.IsNaN(a):
+		return "NaN"
+	case math.IsInf(a, 0):
+		return "infinity"
+	case a == 0:
+		return "zero"
+	case a < float64(d.Info().SmallestNormal):
+		return "subnormal"
+	default:
+		return "normal"
+	}
+}
+
+func format(v float32) string {
+	return strconv.FormatFloat(float64(v), 'g', -1, 32)
+}
+
+func hex(d floatx.DType, bits uint32) string {
+	return fmt.Sprintf("0x%0*X", d.Info().Bits/4, bits)
+}
+
+// binaryString returns the bits as sign, exponent and mantissa groups.
+func binaryString(d floatx.DType, bits uint32) string {
+	info := d.Info()
+	s := fmt.Sprintf("%0*b", info.Bits, bits)
+	return s[:1] + " " + s[1:1+info.ExponentBits] + " " + s[1+info.ExponentBits:]
+}
@@ -0,0 +1,210 @@
+// Copyright 2024 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"strings"
+	"testing"
+)
+
+func Test_Value(t *testing.T) {
+	data := []struct {
+		args []string
+		want []string
+		not  []string
+	}{
+		{
+			[]string{"F8E4M3Fn", "300"},
+			[]string{
+				"bits:       0x79 0 1111 001\n",
+				"components: sign=0 exponent=15 mantissa=1\n",
+				"value:      288 (normal)\n",
+				"next down:  0x78 256\n",
+				"next up:    0x7A 320\n",
+				"ulp:        32\n",
+				"  F8E4M3: 0x78       +Inf overflow\n",
+				"  F8E5M2: 0x5C       256  inexact\n",
+				"  F16:    0x5C80     288\n",
+			},
+			[]string{"F8E4M3Fn:"},
+		},
+		{
+			[]string{"F8E4M3Fn", "0x7e"},
+			[]string{"value:      448 (normal)\n", "next up:    none\n", "ulp:        32\n"},
+			nil,
+		},
+		{
+			[]string{"F8E4M3Fn", "-1e50"},
+			[]string{"bits:       0xFF 1 1111 111\n", "value:      NaN (NaN)\n", "  F32:    0xFFC00000 NaN\n"},
+			[]string{"next", "ulp"},
+		},
+		{
+			[]string{"torch.bfloat16", "0x7F80"},
+			[]string{
+				"value:      +Inf (infinity)\n",
+				"next down:  0x7F7F 3.3895314e+38\n",
+				"next up:    none\n",
+				"  F8E4M3Fn: 0x7F       NaN overflow\n",
+				"  F8E5M2:   0x7C       +Inf\n",
+			},
+			[]string{"ulp"},
+		},
+		{
+			[]string{"F8E4M3", "0x80"},
+			[]string{
+				"components: sign=1 exponent=0 mantissa=0\n",
+				"value:      -0 (zero)\n",
+				"next down:  0x81 -0.001953125\n",
+				"next up:    0x01 0.001953125\n",
+			},
+			nil,
+		},
+		{
+			[]string{"F32", "1e-40"},
+			[]string{
+				"bits:       0x000116C2 0 00000000 00000010001011011000010\n",
+				"value:      1e-40 (subnormal)\n",
+				"next down:  0x000116C1 9.9998e-41\n",
+				"ulp:        1e-45\n",
+				"  BF16:     0x0001 9.1835e-41 inexact\n",
+				"  F16:      0x0000 0          underflow\n",
+			},
+			nil,
+		},
+		{
+			[]string{"F16", "-2"},
+			[]string{"components: sign=1 exponent=16 mantissa=0\n", "next down:  0xC001 -2.0019531\n", "next up:    0xBFFF -1.9990234\n"},
+			nil,
+		},
+		{
+			// Just above the tie between 1 and 1.0078125, which rounding to
+			// float32 first would turn into a tie rounded to even.
+			[]string{"BF16", "1.00390625000000001"},
+			[]string{"bits:       0x3F81 ", "value:      1.0078125 (normal)\n"},
+			nil,
+		},
+		{
+			[]string{"BF16", "1.00390625"},
+			[]string{"bits:       0x3F80 "},
+			nil,
+		},
+		{
+			[]string{"F16", "-0.0"},
+			[]string{"bits:       0x8000 ", "value:      -0 (zero)\n"},
+			nil,
+		},
+		{
+			[]string{"F8E4M3", "240"},
+			[]string{"bits:       0x77 "},
+			nil,
+		},
+		{
+			[]string{"F8E5M2", "-3.5"},
+			[]string{"bits:       0xC3 "},
+			nil,
+		},
+		{
+			[]string{"F16", "-inf"},
+			[]string{"bits:       0xFC00 "},
+			nil,
+		},
+		{
+			[]string{"F8E5M2", "1e1000000000"},
+			[]string{"bits:       0x7C "},
+			nil,
+		},
+		{
+			[]string{"F8E5M2", "0x7B"},
+			[]string{"value:      57344 (normal)\n", "next up:    0x7C +Inf\n", "ulp:        8192\n"},
+			nil,
+		},
+	}
+	for i, l := range data {
+		stdout := bytes.Buffer{}
+		if err := run(l.args, &stdout, &bytes.Buffer{}); err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		for _, w := range l.want {
+			if !strings.Contains(stdout.String(), w) {
+				t.Errorf("#%d: missing %q in:\n%s", i, w, stdout.String())
+			}
+		}
+		for _, w := range l.not {
+			if strings.Contains(stdout.String(), w) {
+				t.Errorf("#%d: unexpected %q in:\n%s", i, w, stdout.String())
+			}
+		}
+	}
+}
+
+func Test_Table(t *testing.T) {
+	stdout := bytes.Buffer{}
+	if err := run([]string{"-table", "md", "F8E4M3Fn"}, &stdout, &bytes.Buffer{}); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(stdout.String(), "\n"), "\n")
+	if len(lines) != 2+256 {
+		t.Fatal(len(lines))
+	}
+	want := []string{
+		"| bits | binary | sign | exponent | mantissa | value | class |",
+		"| --- | --- | --- | --- | --- | --- | --- |",
+		"| 0x00 | 0 0000 000 | 0 | 0 | 0 | 0 | zero |",
+		"| 0x01 | 0 0000 001 | 0 | 0 | 1 | 0.001953125 | subnormal |",
+	}
+	for i, w := range want {
+		if lines[i] != w {
+			t.Errorf("line %d: got %q; want %q", i, lines[i], w)
+		}
+	}
+	if l := lines[len(lines)-1]; l != "| 0xFF | 1 1111 111 | 1 | 15 | 7 | NaN | NaN |" {
+		t.Fatal(l)
+	}
+
+	stdout.Reset()
+	if err := run([]string{"-table", "csv", "float16"}, &stdout, &bytes.Buffer{}); err != nil {
+		t.Fatal(err)
+	}
+	lines = strings.Split(strings.TrimSuffix(stdout.String(), "\n"), "\n")
+	if len(lines) != 1+65536 || lines[0] != "bits,binary,sign,exponent,mantissa,value,class" || lines[0x7C00+1] != "0x7C00,0 11111 0000000000,0,31,0,+Inf,infinity" {
+		t.Fatal(lines[0], lines[0x7C00+1])
+	}
+	if err := run([]string{"-table", "csv", "F8E5M2"}, &failWriter{}, &bytes.Buffer{}); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func Test_Errors(t *testing.T) {
+	data := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"F16"}, "unexpected arguments"},
+		{[]string{"-table", "md", "F16", "1"}, "unexpected arguments"},
+		{[]string{"F64", "1"}, `unknown type "F64"`},
+		{[]string{"F16", "one"}, `invalid value "one"`},
+		{[]string{"F16", "0x10000"}, `invalid 16 bits pattern "0x10000"`},
+		{[]string{"-table", "md", "F32"}, "F32 has too many values to print"},
+		{[]string{"-table", "html", "F16"}, `unknown table format "html"; expected md or csv`},
+	}
+	for i, l := range data {
+		if err := run(l.args, &bytes.Buffer{}, &bytes.Buffer{}); err == nil || err.Error() != l.want {
+			t.Errorf("#%d: %v; want %q", i, err, l.want)
+		}
+	}
+	stderr := bytes.Buffer{}
+	if err := run([]string{"-h"}, &bytes.Buffer{}, &stderr); !errors.Is(err, flag.ErrHelp) || !strings.Contains(stderr.String(), "usage: floatbits") {
+		t.Fatal(err, stderr.String())
+	}
+}
+
+type failWriter struct{}
+
+func (failWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("failed")
+}
@@ -0,0 +1,122 @@
+// Copyright 2024 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"math/bits"
+	"strconv"
+
+	"github.com/maruel/floatx"
+)
+
+// tolerance is the accepted difference between two values.
+type tolerance struct {
+	atol float64
+	rtol float64
+	ulp  uint64
+}
+
+// stats is the difference between two tensors.
+type stats struct {
+	name        string
+	want        floatx.DType
+	got         floatx.DType
+	values      int
+	maxAbsErr   float64
+	maxRelErr   float64
+	maxULP      uint64
+	nanMismatch int
+	infMismatch int
+	failed      int
+	// histogram counts the ULP distances: histogram[0] is the number of
+	// identical values and histogram[i] the number of distances in
+	// [2^(i-1), 2^i). F32 has 2^32 encodings.
+	histogram [34]int
+}
+
+// compare compares two tensors of the same shape.
+func compare(w, g *tensor, t *tolerance) *stats {
+	wi := w.dtype.Info()
+	gi := g.dtype.Info()
+	ws := wi.Bits / 8
+	gs := gi.Bits / 8
+	s := &stats{name: w.name, want: w.dtype, got: g.dtype, values: len(w.data) / ws}
+	for i := range s.values {
+		a := float64(wi.Decode(w.data[i*ws:]))
+		b := float64(gi.Decode(g.data[i*gs:]))
+		if !s.add(a, b, &wi, t) {
+			s.failed++
+		}
+	}
+	return s
+}
+
+// add records the difference between a and b, which is measured in ULP of
+// the want dtype, and returns true if it is tolerated.
+func (s *stats) add(a, b float64, want *floatx.DTypeInfo, t *tolerance) bool {
+	switch {
+	case math.IsNaN(a) || math.IsNaN(b):
+		if math.IsNaN(a) != math.IsNaN(b) {
+			s.nanMismatch++
+			return false
+		}
+		return true
+	case math.IsInf(a, 0) || math.IsInf(b, 0):
+		if a != b {
+			s.infMismatch++
+			return false
+		}
+		s.histogram[0]++
+		return true
+	}
+	d := math.Abs(a - b)
+	s.maxAbsErr = max(s.maxAbsErr, d)
+	if a != 0 {
+		s.maxRelErr = max(s.maxRelErr, d/math.Abs(a))
+	}
+	u := ulpDistance(want, float32(a), float32(b))
+	s.maxULP = max(s.maxULP, u)
+	s.histogram[bits.Len64(u)]++
+	return d <= t.atol+t.rtol*math.Abs(a) || u <= t.ulp
+}
+
+// ulpDistance returns the number of values of the dtype between a and b.
+//
+// b is first rounded to the nearest value of the dtype.
+func ulpDistance(d *floatx.DTypeInfo, a, b float32) uint64 {
+	switch d.DType {
+	case floatx.DTypeF32:
+		return uint64(floatx.F32(a).ULPDistance(floatx.F32(b)))
+	case floatx.DTypeBF16:
+		return uint64(floatx.BF16FromFloat32(a).ULPDistance(floatx.BF16FromFloat32(b)))
+	case floatx.DTypeF16:
+		return uint64(floatx.F16FromFloat32(a).ULPDistance(floatx.F16FromFloat32(b)))
+	case floatx.DTypeF8E4M3:
+		return uint64(floatx.F8E4M3FromFloat32(a).ULPDistance(floatx.F8E4M3FromFloat32(b)))
+	case floatx.DTypeF8E4M3Fn:
+		return uint64(floatx.F8E4M3FnFromFloat32(a).ULPDistance(floatx.F8E4M3FnFromFloat32(b)))
+	default:
+		return uint64(floatx.F8E5M2FromFloat32(a).ULPDistance(floatx.F8E5M2FromFloat32(b)))
+	}
+}
+
+func (s *stats) printHistogram(w io.Writer) {
+	for i, n := range s.histogram {
+		if n == 0 {
+			continue
+		}
+		var r string
+		switch i {
+		case 0, 1:
+			r = strconv.Itoa(i)
+		default:
+			r = fmt.Sprintf("%d-%d", uint64(1)<<(i-1), uint64(1)<<i-1)
+		}
+		fmt.Fprintf(w, "  %9s: %d\n", r, n)
+	}
+}
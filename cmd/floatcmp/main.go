@@ -0,0 +1,203 @@
+// Copyright 2024 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+// floatcmp compares the tensors of two .safetensors, .npy or .npz files.
+//
+// For each tensor present in both files, it prints the largest absolute,
+// relative and ULP errors, and the number of NaN and infinity mismatches. The
+// ULP distance is measured in the dtype of the tensor in the first file.
+//
+// An element is within tolerance when |a-b| <= atol + rtol*|a| or when its
+// ULP distance is at most -ulp. NaN matches NaN.
+//
+// The exit code is 0 when all the tensors are within tolerance, 1 when they
+// are not and 2 on error.
+//
+// Usage:
+//
+//	floatcmp -rtol 1e-3 -ulp 2 -hist want.safetensors got.safetensors
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/maruel/floatx"
+	"github.com/maruel/floatx/npy"
+	"github.com/maruel/floatx/safetensors"
+)
+
+// errTolerance is returned when the files differ more than tolerated.
+var errTolerance = errors.New("tensors differ")
+
+// tensor is a tensor loaded from any of the supported files.
+type tensor struct {
+	name string
+	// dtype is 0 for the types not in floatx.
+	dtype floatx.DType
+	// rawDType is the name of the type in the file.
+	rawDType string
+	shape    []int
+	// fortran is true when data is stored in column-major order.
+	fortran bool
+	data    []byte
+}
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout, os.Stderr); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(2)
+		}
+		fmt.Fprintf(os.Stderr, "floatcmp: %s\n", err)
+		if errors.Is(err, errTolerance) {
+			os.Exit(1)
+		}
+		os.Exit(2)
+	}
+}
+
+func run(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("floatcmp", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "usage: floatcmp [flags] <want> <got>\n\n")
+		fs.PrintDefaults()
+	}
+	var t tolerance
+	fs.Float64Var(&t.atol, "atol", 0, "absolute tolerance")
+	fs.Float64Var(&t.rtol, "rtol", 0, "tolerance relative to the value in the first file")
+	fs.Uint64Var(&t.ulp, "ulp", 0, "tolerance in units in the last place of the first file's dtype")
+	match := fs.String("match", "", "only compare the tensors whose name matches this regexp")
+	hist := fs.Bool("hist", false, "print the histogram of the ULP distances of each tensor")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return errors.New("expected two files")
+	}
+	var re *regexp.Regexp
+	if *match != "" {
+		var err error
+		if re, err = regexp.Compile(*match); err != nil {
+			return err
+		}
+	}
+	// A .npy file contains a single array, which is named after the first file
+	// so it matches the array in the second file.
+	npyName := strings.TrimSuffix(filepath.Base(fs.Arg(0)), filepath.Ext(fs.Arg(0)))
+	want, err := load(fs.Arg(0), npyName)
+	if err != nil {
+		return err
+	}
+	got, err := load(fs.Arg(1), npyName)
+	if err != nil {
+		return err
+	}
+	var all []*stats
+	var problems []string
+	for _, w := range want {
+		if re != nil && !re.MatchString(w.name) {
+			continue
+		}
+		i := slices.IndexFunc(got, func(g *tensor) bool { return g.name == w.name })
+		if i == -1 {
+			problems = append(problems, fmt.Sprintf("%s: missing in %s", w.name, fs.Arg(1)))
+			continue
+		}
+		g := got[i]
+		switch {
+		case !slices.Equal(w.shape, g.shape):
+			problems = append(problems, fmt.Sprintf("%s: shape %v != %v", w.name, w.shape, g.shape))
+		case w.fortran != g.fortran:
+			problems = append(problems, fmt.Sprintf("%s: memory order differs", w.name))
+		case w.dtype == 0 || g.dtype == 0:
+			if w.rawDType != g.rawDType || string(w.data) != string(g.data) {
+				problems = append(problems, fmt.Sprintf("%s: %s data differs", w.name, w.rawDType))
+			}
+		default:
+			all = append(all, compare(w, g, &t))
+		}
+	}
+	for _, g := range got {
+		if (re == nil || re.MatchString(g.name)) && !slices.ContainsFunc(want, func(w *tensor) bool { return w.name == g.name }) {
+			problems = append(problems, fmt.Sprintf("%s: missing in %s", g.name, fs.Arg(0)))
+		}
+	}
+	failed := len(problems)
+	tw := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(tw, "tensor\tdtype\tvalues\tmax abs err\tmax rel err\tmax ulp\tNaN mismatch\tInf mismatch\tfailed\t\n")
+	for _, s := range all {
+		dtype := s.want.String()
+		if s.got != s.want {
+			dtype += "/" + s.got.String()
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%g\t%g\t%d\t%d\t%d\t%d\t\n", s.name, dtype, s.values, s.maxAbsErr, s.maxRelErr, s.maxULP, s.nanMismatch, s.infMismatch, s.failed)
+		if s.failed != 0 {
+			failed++
+		}
+	}
+	_ = tw.Flush()
+	if *hist {
+		for _, s := range all {
+			fmt.Fprintf(stdout, "\n%s ULP distances:\n", s.name)
+			s.printHistogram(stdout)
+		}
+	}
+	for _, p := range problems {
+		fmt.Fprintf(stdout, "%s\n", p)
+	}
+	if failed != 0 {
+		return fmt.Errorf("%w: %d tensors out of tolerance", errTolerance, failed)
+	}
+	return nil
+}
+
+// load loads all the tensors in a file.
+func load(name, npyName string) ([]*tensor, error) {
+	switch filepath.Ext(name) {
+	case ".safetensors":
+		f, err := safetensors.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		out := make([]*tensor, 0, len(f.Tensors))
+		for _, t := range f.Tensors {
+			d, _ := t.FloatxDType()
+			out = append(out, &tensor{name: t.Name, dtype: d, rawDType: t.DType, shape: t.Shape, data: t.Data})
+		}
+		return out, nil
+	case ".npy":
+		a, err := npy.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		return []*tensor{fromArray(npyName, a)}, nil
+	case ".npz":
+		arrays, err := npy.ReadNPZFile(name)
+		if err != nil {
+			return nil, err
+		}
+		out := make([]*tensor, 0, len(arrays))
+		for n, a := range arrays {
+			out = append(out, fromArray(n, a))
+		}
+		slices.SortFunc(out, func(a, b *tensor) int { return strings.Compare(a.name, b.name) })
+		return out, nil
+	default:
+		return nil, fmt.Errorf("unsupported file %q; expected .safetensors, .npy or .npz", name)
+	}
+}
+
+func fromArray(name string, a *npy.Array) *tensor {
+	return &tensor{name: name, dtype: a.DType, rawDType: a.DType.String(), shape: a.Shape, fortran: a.FortranOrder, data: a.Data}
+}
@@ -0,0 +1,261 @@
+// Copyright 2024 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"flag"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/maruel/floatx"
+	"github.com/maruel/floatx/npy"
+	"github.com/maruel/floatx/safetensors"
+)
+
+func Test_Equal(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.safetensors")
+	b := filepath.Join(dir, "b.safetensors")
+	tensors := []safetensors.Tensor{
+		{Name: "x", DType: "F32", Shape: []int{3}, Data: f32s(1, float32(math.NaN()), float32(math.Inf(-1)))},
+		{Name: "ids", DType: "I64", Shape: []int{1}, Data: []byte{1, 0, 0, 0, 0, 0, 0, 0}},
+	}
+	writeSafetensors(t, a, tensors...)
+	writeSafetensors(t, b, tensors...)
+	stdout := bytes.Buffer{}
+	if err := run([]string{"-hist", a, b}, &stdout, &bytes.Buffer{}); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(stdout.String(), "\n")
+	if f := strings.Fields(lines[1]); !slices.Equal(f, []string{"x", "F32", "3", "0", "0", "0", "0", "0", "0"}) {
+		t.Fatal(stdout.String())
+	}
+	if !strings.Contains(stdout.String(), "\nx ULP distances:\n          0: 2\n") {
+		t.Fatal(stdout.String())
+	}
+}
+
+func Test_Differ(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.safetensors")
+	b := filepath.Join(dir, "b.safetensors")
+	const e = 1.0 / (1 << 23)
+	writeSafetensors(t, a,
+		safetensors.Tensor{Name: "x", DType: "F32", Shape: []int{4}, Data: f32s(1, 2, 0, -1)},
+		safetensors.Tensor{Name: "special", DType: "F16", Shape: []int{3}, Data: f16s(1, float32(math.NaN()), float32(math.Inf(1)))},
+		safetensors.Tensor{Name: "shape", DType: "F32", Shape: []int{1}, Data: f32s(1)},
+		safetensors.Tensor{Name: "ids", DType: "I64", Shape: []int{1}, Data: []byte{1, 0, 0, 0, 0, 0, 0, 0}},
+		safetensors.Tensor{Name: "only_a", DType: "F32", Shape: []int{1}, Data: f32s(1)},
+	)
+	writeSafetensors(t, b,
+		safetensors.Tensor{Name: "x", DType: "F32", Shape: []int{4}, Data: f32s(1+e, 2+8*e, -0.0, -1-6*e)},
+		safetensors.Tensor{Name: "special", DType: "F16", Shape: []int{3}, Data: f16s(float32(math.NaN()), 1, float32(math.Inf(-1)))},
+		safetensors.Tensor{Name: "shape", DType: "F32", Shape: []int{1, 1}, Data: f32s(1)},
+		safetensors.Tensor{Name: "ids", DType: "I64", Shape: []int{1}, Data: []byte{2, 0, 0, 0, 0, 0, 0, 0}},
+		safetensors.Tensor{Name: "only_b", DType: "F32", Shape: []int{1}, Data: f32s(1)},
+	)
+	stdout := bytes.Buffer{}
+	err := run([]string{"-hist", a, b}, &stdout, &bytes.Buffer{})
+	if !errors.Is(err, errTolerance) || err.Error() != "tensors differ: 6 tensors out of tolerance" {
+		t.Fatal(err)
+	}
+	lines := strings.Split(stdout.String(), "\n")
+	if f := strings.Fields(lines[1]); !slices.Equal(f, []string{"x", "F32", "4", "9.5367431640625e-07", "7.152557373046875e-07", "6", "0", "0", "3"}) {
+		t.Fatal(stdout.String())
+	}
+	if f := strings.Fields(lines[2]); !slices.Equal(f, []string{"special", "F16", "3", "0", "0", "0", "2", "1", "3"}) {
+		t.Fatal(stdout.String())
+	}
+	for _, w := range []string{
+		"\nx ULP distances:\n          0: 1\n          1: 1\n        4-7: 2\n",
+		"\nspecial ULP distances:\n",
+		"\nshape: shape [1] != [1 1]\n",
+		"\nids: I64 data differs\n",
+		"\nonly_a: missing in " + b + "\n",
+		"\nonly_b: missing in " + a + "\n",
+	} {
+		if !strings.Contains(stdout.String(), w) {
+			t.Errorf("missing %q in:\n%s", w, stdout.String())
+		}
+	}
+
+	// Tolerances.
+	stdout.Reset()
+	if err := run([]string{"-ulp", "6", "-match", "^x$", a, b}, &stdout, &bytes.Buffer{}); err != nil {
+		t.Fatal(err, stdout.String())
+	}
+	if err := run([]string{"-ulp", "5", "-match", "^x$", a, b}, &stdout, &bytes.Buffer{}); !errors.Is(err, errTolerance) {
+		t.Fatal(err)
+	}
+	if err := run([]string{"-atol", "1e-6", "-match", "^x$", a, b}, &stdout, &bytes.Buffer{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := run([]string{"-rtol", "8e-7", "-match", "^x$", a, b}, &stdout, &bytes.Buffer{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := run([]string{"-rtol", "7e-7", "-match", "^x$", a, b}, &stdout, &bytes.Buffer{}); !errors.Is(err, errTolerance) {
+		t.Fatal(err)
+	}
+}
+
+func Test_MixedDTypes(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.safetensors")
+	b := filepath.Join(dir, "b.npz")
+	writeSafetensors(t, a, safetensors.Tensor{Name: "x", DType: "BF16", Shape: []int{2}, Data: bf16s(1, 3)})
+	arrays := map[string]*npy.Array{
+		"x": {DType: floatx.DTypeF32, Shape: []int{2}, Data: f32s(1.003, 3.01)},
+		"y": {DType: floatx.DTypeF32, Shape: []int{1}, Data: f32s(1)},
+	}
+	buf := bytes.Buffer{}
+	if err := npy.WriteNPZ(&buf, arrays, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(b, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	stdout := bytes.Buffer{}
+	if err := run([]string{"-ulp", "1", "-match", "x", a, b}, &stdout, &bytes.Buffer{}); err != nil {
+		t.Fatal(err, stdout.String())
+	}
+	// 1.003 rounds to 1 in BF16 and 3.01 to the value after 3.
+	if f := strings.Fields(strings.Split(stdout.String(), "\n")[1]); f[1] != "BF16/F32" || f[5] != "1" {
+		t.Fatal(stdout.String())
+	}
+	if err := run([]string{b, b}, &stdout, &bytes.Buffer{}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_NPY(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "want.npy")
+	b := filepath.Join(dir, "got.npy")
+	c := filepath.Join(dir, "fortran.npy")
+	writeNPY(t, a, &npy.Array{DType: floatx.DTypeF16, Shape: []int{2, 2}, Data: f16s(1, 2, 3, 4)})
+	writeNPY(t, b, &npy.Array{DType: floatx.DTypeF16, Shape: []int{2, 2}, Data: f16s(1, 2, 3, 4)})
+	writeNPY(t, c, &npy.Array{DType: floatx.DTypeF16, Shape: []int{2, 2}, FortranOrder: true, Data: f16s(1, 3, 2, 4)})
+	stdout := bytes.Buffer{}
+	if err := run([]string{a, b}, &stdout, &bytes.Buffer{}); err != nil {
+		t.Fatal(err)
+	}
+	if f := strings.Fields(strings.Split(stdout.String(), "\n")[1]); f[0] != "want" || f[1] != "F16" {
+		t.Fatal(stdout.String())
+	}
+	stdout.Reset()
+	if err := run([]string{a, c}, &stdout, &bytes.Buffer{}); !errors.Is(err, errTolerance) || !strings.Contains(stdout.String(), "want: memory order differs") {
+		t.Fatal(err, stdout.String())
+	}
+}
+
+func Test_ULPDistance(t *testing.T) {
+	data := []struct {
+		d    floatx.DType
+		a, b float32
+		want uint64
+	}{
+		{floatx.DTypeF32, 0, float32(math.Copysign(0, -1)), 0},
+		{floatx.DTypeF32, math.SmallestNonzeroFloat32, -math.SmallestNonzeroFloat32, 2},
+		{floatx.DTypeF32, -math.MaxFloat32, math.MaxFloat32, 2 * 0x7F7FFFFF},
+		{floatx.DTypeF8E4M3Fn, 448, -448, 2 * 0x7E},
+		{floatx.DTypeF8E4M3Fn, 1, 1.07, 1},
+		{floatx.DTypeBF16, 1, 2, 128},
+		{floatx.DTypeF16, -1, 1, 2 * 0x3C00},
+		{floatx.DTypeF8E4M3, 1, 2, 8},
+		{floatx.DTypeF8E5M2, 1, 2, 4},
+	}
+	for i, l := range data {
+		info := l.d.Info()
+		if got := ulpDistance(&info, l.a, l.b); got != l.want {
+			t.Errorf("#%d: %d; want %d", i, got, l.want)
+		}
+		if got := ulpDistance(&info, l.b, l.a); got != l.want {
+			t.Errorf("#%d: %d; want %d", i, got, l.want)
+		}
+	}
+	s := stats{}
+	s.histogram[33] = 1
+	buf := bytes.Buffer{}
+	s.printHistogram(&buf)
+	if buf.String() != "  4294967296-8589934591: 1\n" {
+		t.Fatalf("%q", buf.String())
+	}
+}
+
+func Test_Errors(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.safetensors")
+	writeSafetensors(t, a, safetensors.Tensor{Name: "x", DType: "F32", Shape: []int{1}, Data: f32s(1)})
+	data := []struct {
+		args []string
+		want string
+	}{
+		{[]string{a}, "expected two files"},
+		{[]string{"-match", "(", a, a}, "error parsing regexp: missing closing ): `(`"},
+		{[]string{a, "b.gguf"}, `unsupported file "b.gguf"; expected .safetensors, .npy or .npz`},
+		{[]string{filepath.Join(dir, "missing.safetensors"), a}, "no such file or directory"},
+		{[]string{a, filepath.Join(dir, "missing.npy")}, "no such file or directory"},
+		{[]string{a, filepath.Join(dir, "missing.npz")}, "no such file or directory"},
+	}
+	for i, l := range data {
+		err := run(l.args, &bytes.Buffer{}, &bytes.Buffer{})
+		if err == nil || errors.Is(err, errTolerance) || !strings.Contains(err.Error(), l.want) {
+			t.Errorf("#%d: %v; want %q", i, err, l.want)
+		}
+	}
+	stderr := bytes.Buffer{}
+	if err := run([]string{"-h"}, &bytes.Buffer{}, &stderr); !errors.Is(err, flag.ErrHelp) || !strings.Contains(stderr.String(), "usage: floatcmp") {
+		t.Fatal(err, stderr.String())
+	}
+}
+
+func writeSafetensors(t *testing.T, name string, tensors ...safetensors.Tensor) {
+	t.Helper()
+	buf := bytes.Buffer{}
+	if err := safetensors.Write(&buf, nil, tensors); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(name, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeNPY(t *testing.T, name string, a *npy.Array) {
+	t.Helper()
+	if err := npy.WriteFile(name, a); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func f32s(v ...float32) []byte {
+	b := make([]byte, 0, 4*len(v))
+	for _, x := range v {
+		b = binary.LittleEndian.AppendUint32(b, math.Float32bits(x))
+	}
+	return b
+}
+
+func bf16s(v ...float32) []byte {
+	var b []byte
+	for _, x := range v {
+		b = floatx.AppendBF16(b, floatx.BF16FromFloat32(x))
+	}
+	return b
+}
+
+func f16s(v ...float32) []byte {
+	var b []byte
+	for _, x := range v {
+		b = floatx.AppendF16(b, floatx.F16FromFloat32(x))
+	}
+	return b
+}
@@ -0,0 +1,127 @@
+// Copyright 2024 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package main
+
+import (
+	"math"
+
+	"github.com/maruel/floatx"
+)
+
+var roundings = map[string]floatx.RoundingMode{
+	"nearest": floatx.RoundNearestEven,
+	"zero":    floatx.RoundTowardZero,
+	"up":      floatx.RoundTowardPositive,
+	"down":    floatx.RoundTowardNegative,
+}
+
+// stats is the precision lost converting one tensor.
+type stats struct {
+	name   string
+	from   floatx.DType
+	to     floatx.DType
+	values int
+	// scale is the value the stored values must be multiplied by.
+	scale     float32
+	maxAbsErr float64
+	maxRelErr float64
+	// overflow counts the finite values that became infinite or NaN, or were
+	// saturated.
+	overflow int
+	// underflow counts the non-zero values that became zero.
+	underflow int
+	// nan counts the NaN values.
+	nan int
+}
+
+// convert converts the little endian values in b from one dtype to c.to.
+func convert(b []byte, from floatx.DType, c *config) ([]byte, *stats) {
+	src := from.Info()
+	dst := c.to.Info()
+	n := len(b) * 8 / src.Bits
+	s := &stats{from: from, to: c.to, values: n, scale: 1}
+	if c.scale {
+		var amax float32
+		for i := range n {
+			v := src.Decode(b[i*src.Bits/8:])
+			if a := float32(math.Abs(float64(v))); a > amax && isFinite(a) {
+				amax = a
+			}
+		}
+		if amax != 0 {
+			s.scale = amax / dst.Max
+		}
+	}
+	out := make([]byte, n*dst.Bits/8)
+	for i := range n {
+		v := src.Decode(b[i*src.Bits/8:])
+		o := out[i*dst.Bits/8 : (i+1)*dst.Bits/8]
+		r, overflow := encode(o, v/s.scale, c)
+		s.add(float64(v), float64(r*s.scale), overflow)
+	}
+	return out, s
+}
+
+// encode stores v in o converted to c.to as selected by c and returns the
+// stored value.
+//
+// overflow is true when the finite value v was too large for the destination.
+func encode(o []byte, v float32, c *config) (r float32, overflow bool) {
+	opts := floatx.ConvertOptions{Rounding: c.round, Saturate: c.saturate}
+	var bits uint32
+	var flags floatx.Flags
+	switch c.to {
+	case floatx.DTypeF32:
+		bits, flags = convertTo[floatx.F32](v, &opts)
+	case floatx.DTypeBF16:
+		bits, flags = convertTo[floatx.BF16](v, &opts)
+	case floatx.DTypeF16:
+		bits, flags = convertTo[floatx.F16](v, &opts)
+	case floatx.DTypeF8E4M3:
+		bits, flags = convertTo[floatx.F8E4M3](v, &opts)
+	case floatx.DTypeF8E4M3Fn:
+		bits, flags = convertTo[floatx.F8E4M3Fn](v, &opts)
+	default:
+		bits, flags = convertTo[floatx.F8E5M2](v, &opts)
+	}
+	for i := range o {
+		o[i] = byte(bits >> (8 * i))
+	}
+	return c.to.Info().Decode(o), flags&floatx.FlagOverflow != 0
+}
+
+// convertTo returns the encoding of v converted to T and the exceptions
+// raised.
+func convertTo[T floatx.Float](v float32, opts *floatx.ConvertOptions) (uint32, floatx.Flags) {
+	r, flags := floatx.ConvertFlags[T](floatx.F32(v), opts)
+	return r.Bits(), flags
+}
+
+func isFinite(v float32) bool {
+	return !math.IsNaN(float64(v)) && !math.IsInf(float64(v), 0)
+}
+
+// add records the conversion of v to r.
+func (s *stats) add(v, r float64, overflow bool) {
+	switch {
+	case math.IsNaN(v):
+		s.nan++
+		return
+	case math.IsInf(v, 0):
+		return
+	case overflow:
+		s.overflow++
+		if math.IsNaN(r) || math.IsInf(r, 0) {
+			return
+		}
+	case r == 0 && v != 0:
+		s.underflow++
+	}
+	d := math.Abs(r - v)
+	s.maxAbsErr = max(s.maxAbsErr, d)
+	if v != 0 {
+		s.maxRelErr = max(s.maxRelErr, d/math.Abs(v))
+	}
+}
@@ -0,0 +1,188 @@
+// Copyright 2024 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+// floatconv converts the floating point tensors of a .safetensors or .npy
+// file to another dtype.
+//
+// It prints the precision lost by each converted tensor. Tensors that are not
+// floating point or not selected by -match are copied unchanged.
+//
+// Usage:
+//
+//	floatconv -to BF16 -o out.safetensors in.safetensors
+//	floatconv -to F8_E4M3 -scale -saturate -match 'weight$' -n in.safetensors
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/maruel/floatx"
+	"github.com/maruel/floatx/npy"
+	"github.com/maruel/floatx/safetensors"
+)
+
+// safetensorsNames are the safetensors names of the dtypes it supports.
+var safetensorsNames = map[floatx.DType]string{
+	floatx.DTypeF32:      "F32",
+	floatx.DTypeBF16:     "BF16",
+	floatx.DTypeF16:      "F16",
+	floatx.DTypeF8E4M3Fn: "F8_E4M3",
+	floatx.DTypeF8E5M2:   "F8_E5M2",
+}
+
+// config is the conversion selected on the command line.
+type config struct {
+	to       floatx.DType
+	round    floatx.RoundingMode
+	saturate bool
+	scale    bool
+	match    *regexp.Regexp
+}
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout, os.Stderr); err != nil {
+		if !errors.Is(err, flag.ErrHelp) {
+			fmt.Fprintf(os.Stderr, "floatconv: %s\n", err)
+		}
+		os.Exit(1)
+	}
+}
+
+func run(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("floatconv", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "usage: floatconv -to <dtype> [flags] (-o <out> | -n) <in.safetensors|in.npy>\n\n")
+		fs.PrintDefaults()
+	}
+	to := fs.String("to", "", "destination dtype, e.g. BF16, float16 or F8_E4M3")
+	round := fs.String("round", "nearest", "rounding: nearest (ties to even), zero, up or down")
+	saturate := fs.Bool("saturate", false, "convert values too large for the destination to its largest finite value instead of infinity or NaN")
+	scale := fs.Bool("scale", false, "scale each tensor so its largest value maps to the largest finite value of the destination, storing the dequantization scale as a F32 tensor named <name>_scale (safetensors only)")
+	match := fs.String("match", "", "only convert the tensors whose name matches this regexp")
+	out := fs.String("o", "", "output file")
+	dryRun := fs.Bool("n", false, "dry run: only print the precision loss")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("expected one input file")
+	}
+	if (*out == "") == !*dryRun {
+		return errors.New("specify exactly one of -o or -n")
+	}
+	c := config{saturate: *saturate, scale: *scale}
+	var ok bool
+	if c.to, ok = floatx.LookupDType(*to); !ok {
+		return fmt.Errorf("unknown dtype %q", *to)
+	}
+	if c.round, ok = roundings[*round]; !ok {
+		return fmt.Errorf("unknown rounding %q", *round)
+	}
+	if *match != "" {
+		var err error
+		if c.match, err = regexp.Compile(*match); err != nil {
+			return err
+		}
+	}
+	in := fs.Arg(0)
+	var stats []*stats
+	var err error
+	switch {
+	case strings.HasSuffix(in, ".safetensors"):
+		stats, err = convertSafetensors(in, *out, &c)
+	case strings.HasSuffix(in, ".npy"):
+		stats, err = convertNPY(in, *out, &c)
+	default:
+		err = fmt.Errorf("unsupported file %q; expected .safetensors or .npy", in)
+	}
+	if err != nil {
+		return err
+	}
+	printStats(stdout, stats)
+	return nil
+}
+
+func convertSafetensors(in, out string, c *config) ([]*stats, error) {
+	name, ok := safetensorsNames[c.to]
+	if !ok {
+		return nil, fmt.Errorf("safetensors doesn't support %s", c.to)
+	}
+	f, err := safetensors.ReadFile(in)
+	if err != nil {
+		return nil, err
+	}
+	var all []*stats
+	tensors := make([]safetensors.Tensor, 0, len(f.Tensors))
+	for _, t := range f.Tensors {
+		from, ok := t.FloatxDType()
+		if !ok || from == c.to || c.match != nil && !c.match.MatchString(t.Name) {
+			tensors = append(tensors, t)
+			continue
+		}
+		data, s := convert(t.Data, from, c)
+		s.name = t.Name
+		all = append(all, s)
+		tensors = append(tensors, safetensors.Tensor{Name: t.Name, DType: name, Shape: t.Shape, Data: data})
+		if c.scale {
+			scaleName := t.Name + "_scale"
+			if f.Tensor(scaleName) != nil {
+				return nil, fmt.Errorf("tensor %q already exists", scaleName)
+			}
+			b := make([]byte, 4)
+			floatx.DTypeF32.Info().Put(b, s.scale)
+			tensors = append(tensors, safetensors.Tensor{Name: scaleName, DType: "F32", Shape: []int{}, Data: b})
+		}
+	}
+	if out == "" {
+		return all, nil
+	}
+	w, err := os.Create(out)
+	if err != nil {
+		return nil, err
+	}
+	if err = safetensors.Write(w, f.Metadata, tensors); err != nil {
+		_ = w.Close()
+		return nil, err
+	}
+	return all, w.Close()
+}
+
+func convertNPY(in, out string, c *config) ([]*stats, error) {
+	if c.scale {
+		return nil, errors.New("-scale is only supported with safetensors")
+	}
+	a, err := npy.ReadFile(in)
+	if err != nil {
+		return nil, err
+	}
+	var all []*stats
+	if a.DType != c.to && (c.match == nil || c.match.MatchString(in)) {
+		data, s := convert(a.Data, a.DType, c)
+		s.name = in
+		all = append(all, s)
+		a = &npy.Array{DType: c.to, Shape: a.Shape, FortranOrder: a.FortranOrder, Data: data}
+	}
+	if out == "" {
+		return all, nil
+	}
+	return all, npy.WriteFile(out, a)
+}
+
+func printStats(w io.Writer, all []*stats) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(tw, "tensor\tfrom\tto\tvalues\tscale\tmax abs err\tmax rel err\toverflow\tunderflow\tNaN\t\n")
+	for _, s := range all {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%g\t%g\t%g\t%d\t%d\t%d\t\n", s.name, s.from, s.to, s.values, s.scale, s.maxAbsErr, s.maxRelErr, s.overflow, s.underflow, s.nan)
+	}
+	_ = tw.Flush()
+}
@@ -0,0 +1,392 @@
+// Copyright 2024 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"flag"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/maruel/floatx"
+	"github.com/maruel/floatx/npy"
+	"github.com/maruel/floatx/safetensors"
+)
+
+func Test_Safetensors(t *testing.T) {
+	dir := t.TempDir()
+	in := filepath.Join(dir, "in.safetensors")
+	writeSafetensors(t, in,
+		safetensors.Tensor{Name: "w", DType: "F32", Shape: []int{4}, Data: f32s(1, -2.5, 1+1.0/512, 3e38)},
+		safetensors.Tensor{Name: "ids", DType: "I64", Shape: []int{1}, Data: []byte{1, 0, 0, 0, 0, 0, 0, 0}},
+		safetensors.Tensor{Name: "b", DType: "BF16", Shape: []int{1}, Data: []byte{0x80, 0x3F}},
+	)
+	out := filepath.Join(dir, "out.safetensors")
+	stdout := mustRun(t, "-to", "torch.bfloat16", "-o", out, in)
+	if r := row(t, stdout, 1); !slices.Equal(r[:5], []string{"w", "F32", "BF16", "4", "1"}) || r[7] != "0" {
+		t.Fatal(stdout)
+	}
+	f, err := safetensors.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := names(f); !slices.Equal(got, []string{"w", "ids", "b"}) {
+		t.Fatal(got)
+	}
+	w := f.Tensor("w")
+	if w.DType != "BF16" || !slices.Equal(w.Shape, []int{4}) {
+		t.Fatal(w)
+	}
+	got, err := w.Float32s()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []float32{1, -2.5, 1, floatx.BF16FromFloat32(3e38).Float32()}; !slices.Equal(got, want) {
+		t.Fatal(got, want)
+	}
+	if ids := f.Tensor("ids"); ids.DType != "I64" || ids.Data[0] != 1 {
+		t.Fatal(ids)
+	}
+	if f.Metadata["format"] != "pt" {
+		t.Fatal(f.Metadata)
+	}
+}
+
+func Test_Overflow(t *testing.T) {
+	dir := t.TempDir()
+	in := filepath.Join(dir, "in.safetensors")
+	writeSafetensors(t, in, safetensors.Tensor{Name: "x", DType: "BF16", Shape: []int{3}, Data: bf16s(1e5, -1e5, 1)})
+	out := filepath.Join(dir, "out.safetensors")
+	stdout := mustRun(t, "-to", "F16", "-o", out, in)
+	if r := row(t, stdout, 1); !slices.Equal(r[7:], []string{"2", "0", "0"}) {
+		t.Fatal(stdout)
+	}
+	if got := readF32s(t, out, "x"); !math.IsInf(float64(got[0]), 1) || !math.IsInf(float64(got[1]), -1) || got[2] != 1 {
+		t.Fatal(got)
+	}
+	mustRun(t, "-to", "F16", "-saturate", "-o", out, in)
+	if got := readF32s(t, out, "x"); !slices.Equal(got, []float32{65504, -65504, 1}) {
+		t.Fatal(got)
+	}
+}
+
+func Test_Scale(t *testing.T) {
+	dir := t.TempDir()
+	in := filepath.Join(dir, "in.safetensors")
+	writeSafetensors(t, in,
+		safetensors.Tensor{Name: "x", DType: "F16", Shape: []int{3}, Data: f16s(896, -448, float32(math.Inf(1)))},
+		safetensors.Tensor{Name: "zero", DType: "F16", Shape: []int{1}, Data: f16s(0)},
+	)
+	out := filepath.Join(dir, "out.safetensors")
+	mustRun(t, "-to", "F8_E4M3", "-scale", "-saturate", "-o", out, in)
+	f, err := safetensors.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := names(f); !slices.Equal(got, []string{"x", "x_scale", "zero", "zero_scale"}) {
+		t.Fatal(got)
+	}
+	x := f.Tensor("x")
+	if x.DType != "F8_E4M3" || !bytes.Equal(x.Data[:2], []byte{0x7E, 0xF6}) {
+		t.Fatalf("%#v", x)
+	}
+	if got := readF32s(t, out, "x_scale"); !slices.Equal(got, []float32{2}) || len(f.Tensor("x_scale").Shape) != 0 {
+		t.Fatal(got)
+	}
+	if got := readF32s(t, out, "zero_scale"); !slices.Equal(got, []float32{1}) {
+		t.Fatal(got)
+	}
+}
+
+func Test_Match(t *testing.T) {
+	dir := t.TempDir()
+	in := filepath.Join(dir, "in.safetensors")
+	writeSafetensors(t, in,
+		safetensors.Tensor{Name: "a.weight", DType: "F32", Shape: []int{1}, Data: f32s(1)},
+		safetensors.Tensor{Name: "a.bias", DType: "F32", Shape: []int{1}, Data: f32s(1)},
+	)
+	out := filepath.Join(dir, "out.safetensors")
+	stdout := mustRun(t, "-to", "F16", "-match", `weight$`, "-o", out, in)
+	if strings.Contains(stdout, "bias") {
+		t.Fatal(stdout)
+	}
+	f, err := safetensors.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Tensor("a.weight").DType != "F16" || f.Tensor("a.bias").DType != "F32" {
+		t.Fatal(f.Tensors)
+	}
+}
+
+func Test_DryRun(t *testing.T) {
+	dir := t.TempDir()
+	in := filepath.Join(dir, "in.safetensors")
+	writeSafetensors(t, in, safetensors.Tensor{Name: "x", DType: "F32", Shape: []int{2}, Data: f32s(1e-10, float32(math.NaN()))})
+	stdout := mustRun(t, "-to", "F8_E5M2", "-n", in)
+	if r := row(t, stdout, 1); !slices.Equal(r[7:], []string{"0", "1", "1"}) {
+		t.Fatal(stdout)
+	}
+	if e, _ := os.ReadDir(dir); len(e) != 1 {
+		t.Fatal(e)
+	}
+}
+
+func Test_NPY(t *testing.T) {
+	dir := t.TempDir()
+	in := filepath.Join(dir, "in.npy")
+	a := &npy.Array{DType: floatx.DTypeF32, Shape: []int{2, 1}, FortranOrder: true, Data: f32s(1, 1.0/3)}
+	if err := npy.WriteFile(in, a); err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(dir, "out.npy")
+	stdout := mustRun(t, "-to", "float8_e4m3", "-o", out, in)
+	if r := row(t, stdout, 1); !slices.Equal(r[:4], []string{in, "F32", "F8E4M3", "2"}) {
+		t.Fatal(stdout)
+	}
+	b, err := npy.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.DType != floatx.DTypeF8E4M3 || !slices.Equal(b.Shape, a.Shape) || !b.FortranOrder {
+		t.Fatal(b)
+	}
+	if got, _ := b.Float32s(); !slices.Equal(got, []float32{1, 0.34375}) {
+		t.Fatal(got)
+	}
+	// Not matching copies the file.
+	stdout = mustRun(t, "-to", "BF16", "-match", "nope", "-o", out, in)
+	if strings.Contains(stdout, "in.npy") {
+		t.Fatal(stdout)
+	}
+	if b, err = npy.ReadFile(out); err != nil || b.DType != floatx.DTypeF32 {
+		t.Fatal(b, err)
+	}
+	mustRun(t, "-to", "BF16", "-n", in)
+}
+
+func Test_Rounding(t *testing.T) {
+	const e = 1.0 / 128
+	dir := t.TempDir()
+	in := filepath.Join(dir, "in.safetensors")
+	writeSafetensors(t, in, safetensors.Tensor{Name: "x", DType: "F32", Shape: []int{3}, Data: f32s(1+e/4, -1-e/4, 1)})
+	out := filepath.Join(dir, "out.safetensors")
+	data := []struct {
+		round string
+		want  []float32
+	}{
+		{"nearest", []float32{1, -1, 1}},
+		{"zero", []float32{1, -1, 1}},
+		{"up", []float32{1 + e, -1, 1}},
+		{"down", []float32{1, -1 - e, 1}},
+	}
+	for _, l := range data {
+		t.Run(l.round, func(t *testing.T) {
+			mustRun(t, "-to", "BF16", "-round", l.round, "-o", out, in)
+			if got := readF32s(t, out, "x"); !slices.Equal(got, l.want) {
+				t.Fatal(got)
+			}
+		})
+	}
+}
+
+func Test_Encode(t *testing.T) {
+	const e = 1.0 / 1024
+	inf := float32(math.Inf(1))
+	data := []struct {
+		round    floatx.RoundingMode
+		saturate bool
+		to       floatx.DType
+		v        float32
+		want     float32
+		overflow bool
+	}{
+		{floatx.RoundNearestEven, false, floatx.DTypeF16, 1 + e/4, 1, false},
+		{floatx.RoundTowardPositive, false, floatx.DTypeF16, 1 + e/4, 1 + e, false},
+		{floatx.RoundTowardPositive, false, floatx.DTypeF16, -1 - e/4, -1, false},
+		{floatx.RoundTowardNegative, false, floatx.DTypeF16, 1 + e/4, 1, false},
+		{floatx.RoundTowardNegative, false, floatx.DTypeF16, 1 + 3*e/4, 1, false},
+		{floatx.RoundTowardNegative, false, floatx.DTypeF16, -1 - e/4, -1 - e, false},
+		{floatx.RoundTowardZero, false, floatx.DTypeF16, 1 + 3*e/4, 1, false},
+		{floatx.RoundTowardZero, false, floatx.DTypeF16, -1 - 3*e/4, -1, false},
+		{floatx.RoundTowardPositive, false, floatx.DTypeF16, 1e-10, 1.0 / (1 << 24), false},
+		{floatx.RoundTowardNegative, false, floatx.DTypeF16, -1e-10, -1.0 / (1 << 24), false},
+		{floatx.RoundNearestEven, false, floatx.DTypeF16, 1e5, inf, true},
+		{floatx.RoundTowardZero, false, floatx.DTypeF16, 1e5, 65504, true},
+		{floatx.RoundTowardPositive, false, floatx.DTypeF16, -1e5, -65504, true},
+		{floatx.RoundTowardNegative, false, floatx.DTypeF16, 1e5, 65504, true},
+		{floatx.RoundNearestEven, true, floatx.DTypeF16, -1e5, -65504, true},
+		{floatx.RoundTowardPositive, false, floatx.DTypeF16, 65505, inf, true},
+		{floatx.RoundTowardPositive, true, floatx.DTypeF16, 65505, 65504, true},
+		{floatx.RoundTowardPositive, false, floatx.DTypeF16, inf, inf, false},
+		{floatx.RoundTowardPositive, false, floatx.DTypeF8E4M3Fn, 449, float32(math.NaN()), true},
+		{floatx.RoundTowardPositive, true, floatx.DTypeF8E4M3Fn, 449, 448, true},
+		{floatx.RoundNearestEven, true, floatx.DTypeF8E4M3Fn, float32(math.NaN()), float32(math.NaN()), false},
+		{floatx.RoundTowardPositive, false, floatx.DTypeF32, 1, 1, false},
+	}
+	for i, l := range data {
+		c := config{to: l.to, round: l.round, saturate: l.saturate}
+		o := make([]byte, l.to.Size())
+		got, overflow := encode(o, l.v, &c)
+		if !(got == l.want || math.IsNaN(float64(got)) && math.IsNaN(float64(l.want))) || overflow != l.overflow {
+			t.Errorf("#%d: encode(%g) = %g, %t; want %g, %t", i, l.v, got, overflow, l.want, l.overflow)
+		}
+		if d := l.to.Info().Decode(o); !(d == got || math.IsNaN(float64(d)) && math.IsNaN(float64(got))) {
+			t.Errorf("#%d: stored %g, returned %g", i, d, got)
+		}
+	}
+}
+
+func Test_Stats(t *testing.T) {
+	s := stats{}
+	s.add(1, 1.5, false)
+	s.add(math.Inf(1), math.Inf(1), false)
+	s.add(1e5, math.Inf(1), true)
+	s.add(-1e5, -65504, true)
+	s.add(1e-10, 0, false)
+	s.add(0, 0, false)
+	s.add(math.NaN(), math.NaN(), false)
+	want := stats{maxAbsErr: 1e5 - 65504, maxRelErr: 1, overflow: 2, underflow: 1, nan: 1}
+	if s != want {
+		t.Fatalf("%+v", s)
+	}
+}
+
+func Test_Errors(t *testing.T) {
+	dir := t.TempDir()
+	st := filepath.Join(dir, "in.safetensors")
+	writeSafetensors(t, st,
+		safetensors.Tensor{Name: "x", DType: "F32", Shape: []int{1}, Data: f32s(1)},
+		safetensors.Tensor{Name: "x_scale", DType: "F32", Shape: []int{1}, Data: f32s(1)},
+	)
+	np := filepath.Join(dir, "in.npy")
+	if err := npy.WriteFile(np, &npy.Array{DType: floatx.DTypeF32, Shape: []int{1}, Data: f32s(1)}); err != nil {
+		t.Fatal(err)
+	}
+	bad := filepath.Join(dir, "missing", "out")
+	data := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"-to", "F16", "-n"}, "expected one input file"},
+		{[]string{"-to", "F16", st}, "specify exactly one of -o or -n"},
+		{[]string{"-to", "F16", "-n", "-o", "x", st}, "specify exactly one of -o or -n"},
+		{[]string{"-to", "F64", "-n", st}, `unknown dtype "F64"`},
+		{[]string{"-to", "F16", "-round", "odd", "-n", st}, `unknown rounding "odd"`},
+		{[]string{"-to", "F16", "-match", "(", "-n", st}, "error parsing regexp: missing closing ): `(`"},
+		{[]string{"-to", "F16", "-n", "in.gguf"}, `unsupported file "in.gguf"; expected .safetensors or .npy`},
+		{[]string{"-to", "F8E4M3", "-n", st}, "safetensors doesn't support F8E4M3"},
+		{[]string{"-to", "F16", "-n", filepath.Join(dir, "missing.safetensors")}, "no such file or directory"},
+		{[]string{"-to", "F16", "-n", filepath.Join(dir, "missing.npy")}, "no such file or directory"},
+		{[]string{"-to", "F16", "-scale", "-n", st}, `tensor "x_scale" already exists`},
+		{[]string{"-to", "F16", "-scale", "-n", np}, "-scale is only supported with safetensors"},
+		{[]string{"-to", "F16", "-o", bad, st}, "no such file or directory"},
+		{[]string{"-to", "F16", "-o", bad, np}, "no such file or directory"},
+	}
+	for i, l := range data {
+		err := run(l.args, &bytes.Buffer{}, &bytes.Buffer{})
+		if err == nil || !strings.Contains(err.Error(), l.want) {
+			t.Errorf("#%d: %v; want %q", i, err, l.want)
+		}
+	}
+	stderr := bytes.Buffer{}
+	if err := run([]string{"-h"}, &bytes.Buffer{}, &stderr); !errors.Is(err, flag.ErrHelp) || !strings.Contains(stderr.String(), "usage: floatconv") {
+		t.Fatal(err, stderr.String())
+	}
+}
+
+func Test_Write_Error(t *testing.T) {
+	dir := t.TempDir()
+	in := filepath.Join(dir, "in.safetensors")
+	writeSafetensors(t, in, safetensors.Tensor{Name: "x", DType: "F32", Shape: []int{1}, Data: f32s(1)})
+	if err := run([]string{"-to", "F16", "-o", "/dev/full", in}, &bytes.Buffer{}, &bytes.Buffer{}); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func mustRun(t *testing.T, args ...string) string {
+	t.Helper()
+	stdout := bytes.Buffer{}
+	stderr := bytes.Buffer{}
+	if err := run(args, &stdout, &stderr); err != nil {
+		t.Fatal(err, stderr.String())
+	}
+	return stdout.String()
+}
+
+// row returns the fields of line i of the output.
+func row(t *testing.T, stdout string, i int) []string {
+	t.Helper()
+	lines := strings.Split(strings.TrimSpace(stdout), "\n")
+	if i >= len(lines) {
+		t.Fatalf("missing line %d:\n%s", i, stdout)
+	}
+	if f := strings.Fields(lines[0]); f[0] != "tensor" {
+		t.Fatal(stdout)
+	}
+	return strings.Fields(lines[i])
+}
+
+func writeSafetensors(t *testing.T, name string, tensors ...safetensors.Tensor) {
+	t.Helper()
+	buf := bytes.Buffer{}
+	if err := safetensors.Write(&buf, map[string]string{"format": "pt"}, tensors); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(name, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readF32s(t *testing.T, name, tensor string) []float32 {
+	t.Helper()
+	f, err := safetensors.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := f.Tensor(tensor).Float32s()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func names(f *safetensors.File) []string {
+	var out []string
+	for _, t := range f.Tensors {
+		out = append(out, t.Name)
+	}
+	return out
+}
+
+func f32s(v ...float32) []byte {
+	b := make([]byte, 0, 4*len(v))
+	for _, x := range v {
+		b = binary.LittleEndian.AppendUint32(b, math.Float32bits(x))
+	}
+	return b
+}
+
+func bf16s(v ...float32) []byte {
+	var b []byte
+	for _, x := range v {
+		b = floatx.AppendBF16(b, floatx.BF16FromFloat32(x))
+	}
+	return b
+}
+
+func f16s(v ...float32) []byte {
+	var b []byte
+	for _, x := range v {
+		b = floatx.AppendF16(b, floatx.F16FromFloat32(x))
+	}
+	return b
+}
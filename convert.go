@@ -0,0 +1,233 @@
+// Copyright 2024 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package floatx
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// RoundingMode selects how a value that cannot be represented exactly is
+// rounded.
+//
+// The zero value is RoundNearestEven, the IEEE 754 default used by the
+// XFromFloat32 functions.
+type RoundingMode uint8
+
+// Supported rounding modes. They match the ones of math/big.
+const (
+	// RoundNearestEven rounds to the nearest value, ties to the value with an
+	// even mantissa.
+	RoundNearestEven RoundingMode = iota
+	// RoundNearestAway rounds to the nearest value, ties away from zero.
+	RoundNearestAway
+	// RoundTowardZero truncates.
+	RoundTowardZero
+	// RoundAwayFromZero rounds to the value of larger magnitude.
+	RoundAwayFromZero
+	// RoundTowardNegative rounds toward -Inf.
+	RoundTowardNegative
+	// RoundTowardPositive rounds toward +Inf.
+	RoundTowardPositive
+)
+
+// String returns the name of the constant, e.g. "RoundNearestEven".
+func (r RoundingMode) String() string {
+	switch r {
+	case RoundNearestEven:
+		return "RoundNearestEven"
+	case RoundNearestAway:
+		return "RoundNearestAway"
+	case RoundTowardZero:
+		return "RoundTowardZero"
+	case RoundAwayFromZero:
+		return "RoundAwayFromZero"
+	case RoundTowardNegative:
+		return "RoundTowardNegative"
+	case RoundTowardPositive:
+		return "RoundTowardPositive"
+	default:
+		return "RoundingMode(" + strconv.Itoa(int(r)) + ")"
+	}
+}
+
+// ConvertOptions controls Convert. The zero value rounds to nearest even
+// without saturation and keeps subnormal values, like the XFromFloat32
+// functions.
+type ConvertOptions struct {
+	Rounding RoundingMode
+	// Saturate converts the finite values too large for the destination to its
+	// largest finite value instead of infinity, or NaN for F8E4M3Fn. It also
+	// converts infinities to the largest finite value of F8E4M3Fn instead of
+	// NaN, like the ONNX Cast operator with saturate=1.
+	Saturate bool
+	// DenormalsAreZero (DAZ) reads the subnormal source values as zero of the
+	// same sign. Use Convert to F32 to decode this way.
+	DenormalsAreZero bool
+	// FlushToZero (FTZ) converts the results that would be subnormal to zero
+	// of the same sign, raising FlagUnderflow and FlagInexact.
+	FlushToZero bool
+	// NaN selects the encoding of converted NaNs. The zero value keeps the
+	// sign.
+	NaN NaNPolicy
+}
+
+// Flags is a set of IEEE 754 exceptions raised by a conversion.
+type Flags uint8
+
+// Exceptions that can be raised by a conversion.
+const (
+	// FlagInvalid is raised when an infinity is converted to F8E4M3Fn, which
+	// has no infinity. The result is NaN, or the largest finite value when
+	// saturating. It is also raised when the source is a signaling NaN.
+	FlagInvalid Flags = 1 << iota
+	// FlagOverflow is raised when a finite value rounded with an unbounded
+	// exponent is larger than the largest finite value of the destination.
+	FlagOverflow
+	// FlagUnderflow is raised when an inexact result is subnormal or zero.
+	FlagUnderflow
+	// FlagInexact is raised when the result differs from the source value.
+	// Converting NaN is never inexact even if the payload is lost.
+	FlagInexact
+)
+
+// String returns the names of the flags set separated by "|", e.g.
+// "Overflow|Inexact", or "0" if none is set.
+func (f Flags) String() string {
+	if f == 0 {
+		return "0"
+	}
+	var names []string
+	for i, n := range [...]string{"Invalid", "Overflow", "Underflow", "Inexact"} {
+		if f&(1<<i) != 0 {
+			names = append(names, n)
+		}
+	}
+	if rest := f &^ (FlagInvalid | FlagOverflow | FlagUnderflow | FlagInexact); rest != 0 {
+		names = append(names, "0x"+strconv.FormatUint(uint64(rest), 16))
+	}
+	return strings.Join(names, "|")
+}
+
+// Convert converts v to another type with a single rounding.
+//
+// opts can be nil to round to nearest even without saturation. The sign of NaN
+// is kept but not its payload unless the NaN policy is NaNPreservePayload.
+//
+// The destination type is specified explicitly and the source type is
+// inferred:
+//
+//	f := floatx.Convert[floatx.F8E4M3Fn](floatx.F16(0x3C00), nil)
+func Convert[To, From Float](v From, opts *ConvertOptions) To {
+	r, _ := ConvertFlags[To](v, opts)
+	return r
+}
+
+// ConvertFlags is like Convert and also returns the exceptions raised by the
+// conversion.
+func ConvertFlags[To, From Float](v From, opts *ConvertOptions) (To, Flags) {
+	src, bits := toBits(v)
+	var o ConvertOptions
+	if opts != nil {
+		o = *opts
+	}
+	var to To
+	dst, _ := toBits(to)
+	if src.isNaN(bits) {
+		var flags Flags
+		if src.isSignalingNaN(bits) {
+			flags = FlagInvalid
+		}
+		return FromBits[To](dst.convertNaN(src, bits, o.NaN)), flags
+	}
+	if o.DenormalsAreZero && src.isSubnormal(bits) {
+		bits &= src.signBit()
+	}
+	r, flags := dst.round(src.toFloat64(bits), o.Rounding, o.Saturate)
+	r, flags = o.flush(dst, r, flags)
+	return FromBits[To](r), flags
+}
+
+// ConvertSlice converts the values of src into dst, which must be at least as
+// long as src.
+//
+// It returns the union of the exceptions raised by the conversions. Use
+// ConvertFlags to count the values raising an exception.
+func ConvertSlice[To, From Float](dst []To, src []From, opts *ConvertOptions) Flags {
+	_ = dst[:len(src)]
+	var flags Flags
+	for i, v := range src {
+		var f Flags
+		dst[i], f = ConvertFlags[To](v, opts)
+		flags |= f
+	}
+	return flags
+}
+
+// Internal
+
+// round returns x, a positive value, rounded to an integer. negative is the
+// sign of the original value.
+func (r RoundingMode) round(x float64, negative bool) float64 {
+	switch r {
+	case RoundNearestAway:
+		return math.Round(x)
+	case RoundTowardZero:
+		return math.Trunc(x)
+	case RoundAwayFromZero:
+		return math.Ceil(x)
+	case RoundTowardNegative, RoundTowardPositive:
+		if r.awayFromZero(negative) {
+			return math.Ceil(x)
+		}
+		return math.Trunc(x)
+	default:
+		return math.RoundToEven(x)
+	}
+}
+
+// awayFromZero returns true if values too large to be represented are rounded
+// to infinity.
+func (r RoundingMode) awayFromZero(negative bool) bool {
+	switch r {
+	case RoundTowardZero:
+		return false
+	case RoundTowardNegative:
+		return negative
+	case RoundTowardPositive:
+		return !negative
+	default:
+		return true
+	}
+}
+
+// flush applies FlushToZero to the encoding bits in f.
+func (o *ConvertOptions) flush(f *format, bits uint32, flags Flags) (uint32, Flags) {
+	if o.FlushToZero && f.isSubnormal(bits) {
+		return bits & f.signBit(), flags | FlagUnderflow | FlagInexact
+	}
+	return bits, flags
+}
+
+// toBits returns the format and the encoding of v.
+func toBits[T Float](v T) (*format, uint32) {
+	var f *format
+	switch any(v).(type) {
+	case F32:
+		f = &f32Format
+	case BF16:
+		f = &bf16Format
+	case F16:
+		f = &f16Format
+	case F8E4M3:
+		f = &f8e4m3Format
+	case F8E4M3Fn:
+		f = &f8e4m3fnFormat
+	default:
+		f = &f8e5m2Format
+	}
+	return f, v.Bits()
+}
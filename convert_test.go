@@ -0,0 +1,512 @@
+// Copyright 2024 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package floatx_test
+
+import (
+	"encoding/binary"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"sort"
+	"testing"
+
+	"github.com/maruel/floatx"
+)
+
+var roundingModes = []floatx.RoundingMode{
+	floatx.RoundNearestEven,
+	floatx.RoundNearestAway,
+	floatx.RoundTowardZero,
+	floatx.RoundAwayFromZero,
+	floatx.RoundTowardNegative,
+	floatx.RoundTowardPositive,
+}
+
+func Test_Convert_All(t *testing.T) {
+	t.Run("BF16", func(t *testing.T) { testConvertFrom(t, slices.Collect(floatx.All[floatx.BF16]())) })
+	t.Run("F16", func(t *testing.T) { testConvertFrom(t, slices.Collect(floatx.All[floatx.F16]())) })
+	t.Run("F8E4M3", func(t *testing.T) { testConvertFrom(t, slices.Collect(floatx.All[floatx.F8E4M3]())) })
+	t.Run("F8E4M3Fn", func(t *testing.T) { testConvertFrom(t, slices.Collect(floatx.All[floatx.F8E4M3Fn]())) })
+	t.Run("F8E5M2", func(t *testing.T) { testConvertFrom(t, slices.Collect(floatx.All[floatx.F8E5M2]())) })
+	t.Run("F32", func(t *testing.T) {
+		r := rand.New(rand.NewPCG(1, 2))
+		src := make([]floatx.F32, 1<<16)
+		for i := range src {
+			src[i] = floatx.F32(math.Float32frombits(r.Uint32()))
+		}
+		// Values near the largest finite values of the smaller types.
+		for _, v := range []float32{65504, 65519.996, 65520, 240, 247.99998, 248, 448, 464, 464.00003, 480, 57344, 61440, 61440.004} {
+			src = append(src, floatx.F32(v), floatx.F32(-v))
+		}
+		testConvertFrom(t, src)
+	})
+}
+
+func Test_Convert_SpotCheck(t *testing.T) {
+	inf := float32(math.Inf(1))
+	const inexact = floatx.FlagInexact
+	const overflow = floatx.FlagOverflow | floatx.FlagInexact
+	const underflow = floatx.FlagUnderflow | floatx.FlagInexact
+	data := []struct {
+		v        float32
+		mode     floatx.RoundingMode
+		saturate bool
+		want     floatx.F8E4M3Fn
+		flags    floatx.Flags
+	}{
+		{1, floatx.RoundNearestEven, false, 0x38, 0},
+		{1.0625, floatx.RoundNearestEven, false, 0x38, inexact},
+		{1.0625, floatx.RoundNearestAway, false, 0x39, inexact},
+		{1.1875, floatx.RoundNearestEven, false, 0x3A, inexact},
+		{1.01, floatx.RoundTowardPositive, false, 0x39, inexact},
+		{-1.01, floatx.RoundTowardPositive, false, 0xB8, inexact},
+		{-1.01, floatx.RoundTowardNegative, false, 0xB9, inexact},
+		{-1.01, floatx.RoundAwayFromZero, false, 0xB9, inexact},
+		{-1.01, floatx.RoundTowardZero, false, 0xB8, inexact},
+		{464, floatx.RoundNearestEven, false, 0x7E, inexact},
+		{464, floatx.RoundNearestAway, false, 0x7F, overflow},
+		{464, floatx.RoundNearestAway, true, 0x7E, overflow},
+		{1000, floatx.RoundTowardZero, false, 0x7E, overflow},
+		{1000, floatx.RoundTowardNegative, false, 0x7E, overflow},
+		{-1000, floatx.RoundTowardNegative, false, 0xFF, overflow},
+		{-1000, floatx.RoundTowardPositive, false, 0xFE, overflow},
+		{inf, floatx.RoundNearestEven, false, 0x7F, floatx.FlagInvalid},
+		{-inf, floatx.RoundNearestEven, true, 0xFE, floatx.FlagInvalid},
+		{inf, floatx.RoundTowardZero, false, 0x7F, floatx.FlagInvalid},
+		{1e-10, floatx.RoundTowardPositive, false, 0x01, underflow},
+		{-1e-10, floatx.RoundTowardPositive, false, 0x80, underflow},
+		{float32(math.NaN()), floatx.RoundNearestEven, true, 0x7F, 0},
+	}
+	for i, l := range data {
+		opts := floatx.ConvertOptions{Rounding: l.mode, Saturate: l.saturate}
+		got, flags := floatx.ConvertFlags[floatx.F8E4M3Fn](floatx.F32(l.v), &opts)
+		if got != l.want || flags != l.flags {
+			t.Errorf("#%d: Convert(%g, %s, %t) = %#x, %s; want %#x, %s", i, l.v, l.mode, l.saturate, got, flags, l.want, l.flags)
+		}
+	}
+	// Infinities are kept by the types that have them, even when saturating.
+	if got := floatx.Convert[floatx.F8E5M2](floatx.F16(0x7C00), &floatx.ConvertOptions{Saturate: true}); got != 0x7C {
+		t.Fatalf("%#x", got)
+	}
+	if got := floatx.Convert[floatx.F16](floatx.BF16FromFloat32(1e5), &floatx.ConvertOptions{Saturate: true}); got.Float32() != 65504 {
+		t.Fatal(got.Float32())
+	}
+	// The default is the same as the XFromFloat32 functions.
+	if got := floatx.Convert[floatx.BF16](floatx.F32(1.00390625), nil); got != floatx.BF16FromFloat32(1.00390625) {
+		t.Fatalf("%#x", got)
+	}
+	if got := floatx.Convert[floatx.F32](floatx.F8E5M2(0x01), nil); float32(got) != 1.0/(1<<16) {
+		t.Fatal(got)
+	}
+}
+
+func Test_ConvertSlice(t *testing.T) {
+	src := []floatx.BF16{0x3F80, 0xC000, 0x7F80}
+	dst := make([]floatx.F8E4M3Fn, 4)
+	if flags := floatx.ConvertSlice(dst, src, &floatx.ConvertOptions{Saturate: true}); flags != floatx.FlagInvalid {
+		t.Fatal(flags)
+	}
+	if dst[0] != 0x38 || dst[1] != 0xC0 || dst[2] != 0x7E || dst[3] != 0 {
+		t.Fatal(dst)
+	}
+	if flags := floatx.ConvertSlice(dst, []floatx.F32{1, 1e-10, 1000}, nil); flags != floatx.FlagOverflow|floatx.FlagUnderflow|floatx.FlagInexact {
+		t.Fatal(flags)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected panic")
+		}
+	}()
+	floatx.ConvertSlice(dst[:1], src, nil)
+}
+
+func Test_RoundingMode_String(t *testing.T) {
+	for i, want := range []string{"RoundNearestEven", "RoundNearestAway", "RoundTowardZero", "RoundAwayFromZero", "RoundTowardNegative", "RoundTowardPositive", "RoundingMode(6)"} {
+		if got := floatx.RoundingMode(i).String(); got != want {
+			t.Errorf("%d: %q; want %q", i, got, want)
+		}
+	}
+	// Unknown modes round to nearest even.
+	if got := floatx.Convert[floatx.F8E4M3Fn](floatx.F32(1.0625), &floatx.ConvertOptions{Rounding: 6}); got != 0x38 {
+		t.Fatalf("%#x", got)
+	}
+}
+
+func Test_Convert_FlushToZero(t *testing.T) {
+	t.Run("BF16", func(t *testing.T) { testFlushToZero[floatx.BF16](t, bf16TestData) })
+	t.Run("F16", func(t *testing.T) { testFlushToZero[floatx.F16](t, f16TestData) })
+	t.Run("F8E4M3", func(t *testing.T) { testFlushToZero[floatx.F8E4M3](t, f8E4M3TestData) })
+	t.Run("F8E4M3Fn", func(t *testing.T) { testFlushToZero[floatx.F8E4M3Fn](t, f8E4M3FnTestData) })
+	t.Run("F8E5M2", func(t *testing.T) { testFlushToZero[floatx.F8E5M2](t, f8E5M2TestData) })
+	t.Run("F32", func(t *testing.T) {
+		opts := floatx.ConvertOptions{DenormalsAreZero: true, FlushToZero: true}
+		if got := floatx.Convert[floatx.F32](floatx.F32(-1e-40), &opts); math.Float32bits(float32(got)) != 0x80000000 {
+			t.Fatal(got)
+		}
+		if got, flags := floatx.ConvertFlags[floatx.F32](floatx.F32(1e-40), &floatx.ConvertOptions{FlushToZero: true}); got != 0 || flags != floatx.FlagUnderflow|floatx.FlagInexact {
+			t.Fatal(got, flags)
+		}
+	})
+	// A F16 subnormal is a normal BF16 value, unless it is read as zero.
+	sub := floatx.F16(0x8001)
+	if got := floatx.Convert[floatx.BF16](sub, &floatx.ConvertOptions{FlushToZero: true}); got.Float32() != sub.Float32() {
+		t.Fatal(got)
+	}
+	if got := floatx.Convert[floatx.BF16](sub, &floatx.ConvertOptions{DenormalsAreZero: true}); got != 0x8000 {
+		t.Fatalf("%#x", got)
+	}
+}
+
+// testFlushToZero verifies that the subnormal encodings of the generated table
+// are decoded and encoded as zero of the same sign and that the other values
+// are unaffected.
+func testFlushToZero[T floatx.Float](t *testing.T, data []testData) {
+	daz := floatx.ConvertOptions{DenormalsAreZero: true}
+	ftz := floatx.ConvertOptions{FlushToZero: true}
+	var z T
+	signBit := uint32(1) << (dtypeOf(z).Info().Bits - 1)
+	for _, line := range data {
+		subnormal := line.Exponent == 0 && line.Mantissa != 0
+		isNaN := math.IsNaN(float64(line.F))
+		// The tables lose the sign of -0.
+		v := math.Copysign(float64(line.F), float64(1-2*int(line.Sign)))
+		want, wantBits := v, line.V
+		var wantFlags floatx.Flags
+		if subnormal {
+			want = math.Copysign(0, v)
+			wantBits &= signBit
+			wantFlags = floatx.FlagUnderflow | floatx.FlagInexact
+		}
+		got := float64(floatx.Convert[floatx.F32](T(line.V), &daz))
+		if got != want && !(isNaN && math.IsNaN(got)) || !isNaN && math.Signbit(got) != math.Signbit(want) {
+			t.Fatalf("DAZ %#x: %g; want %g", line.V, got, want)
+		}
+		if isNaN {
+			continue
+		}
+		r, flags := floatx.ConvertFlags[T](floatx.F32(v), &ftz)
+		if r.Bits() != wantBits || flags != wantFlags {
+			t.Fatalf("FTZ %g: %#x, %s; want %#x, %s", v, r.Bits(), flags, wantBits, wantFlags)
+		}
+	}
+}
+
+func Test_Convert_NaN_All(t *testing.T) {
+	t.Run("BF16", func(t *testing.T) { testConvertNaN(t, slices.Collect(floatx.All[floatx.BF16]())) })
+	t.Run("F16", func(t *testing.T) { testConvertNaN(t, slices.Collect(floatx.All[floatx.F16]())) })
+	t.Run("F8E4M3", func(t *testing.T) { testConvertNaN(t, slices.Collect(floatx.All[floatx.F8E4M3]())) })
+	t.Run("F8E4M3Fn", func(t *testing.T) { testConvertNaN(t, slices.Collect(floatx.All[floatx.F8E4M3Fn]())) })
+	t.Run("F8E5M2", func(t *testing.T) { testConvertNaN(t, slices.Collect(floatx.All[floatx.F8E5M2]())) })
+}
+
+// testConvertNaN verifies that the NaNs round-trip through F32 with
+// NaNPreservePayload like with Float32 and that the other policies return
+// quiet NaNs.
+func testConvertNaN[T floatx.Float](t *testing.T, values []T) {
+	payload := floatx.ConvertOptions{NaN: floatx.NaNPreservePayload}
+	for _, v := range values {
+		if !isNaN(v) {
+			continue
+		}
+		f, flags := floatx.ConvertFlags[floatx.F32](v, &payload)
+		if got, want := math.Float32bits(float32(f)), math.Float32bits(v.Float32()); got != want {
+			t.Fatalf("%#x: %#x; Float32() returned %#x", v, got, want)
+		}
+		if (flags == floatx.FlagInvalid) != isSignalingNaN(v) || flags&^floatx.FlagInvalid != 0 {
+			t.Fatalf("%#x: %s", v, flags)
+		}
+		if got := floatx.Convert[T](f, &payload); got != v {
+			t.Fatalf("%#x: round-tripped as %#x", v, got)
+		}
+		negative := v.Bits()>>(dtypeOf(v).Info().Bits-1) != 0
+		for _, policy := range []floatx.NaNPolicy{floatx.NaNPreserveSign, floatx.NaNCanonical} {
+			got := floatx.Convert[floatx.F16](v, &floatx.ConvertOptions{NaN: policy})
+			want := floatx.F16(0x7E00)
+			if policy == floatx.NaNPreserveSign && negative {
+				want |= 0x8000
+			}
+			if got != want || got.IsSignalingNaN() {
+				t.Fatalf("%#x: %s: %#x; want %#x", v, policy, got, want)
+			}
+		}
+	}
+}
+
+func Test_Convert_NaN_SpotCheck(t *testing.T) {
+	payload := floatx.ConvertOptions{NaN: floatx.NaNPreservePayload}
+	if got := floatx.Convert[floatx.F8E5M2](floatx.BF16(0x7F81), &payload); got != 0x7D || !got.IsSignalingNaN() {
+		t.Fatalf("%#x", got)
+	}
+	if got := floatx.Convert[floatx.BF16](floatx.F16(0x7E01), &payload); got != 0x7FC0 || got.IsSignalingNaN() {
+		t.Fatalf("%#x", got)
+	}
+	if got := floatx.Convert[floatx.F16](floatx.F32(math.Float32frombits(0xFFA00000)), &payload); got != 0xFD00 || !got.IsSignalingNaN() {
+		t.Fatalf("%#x", got)
+	}
+	if got := floatx.Convert[floatx.BF16](floatx.F8E4M3Fn(0xFF), &payload); got != 0xFFC0 {
+		t.Fatalf("%#x", got)
+	}
+	if got := floatx.Convert[floatx.F8E4M3Fn](floatx.F8E5M2(0xFD), &payload); got != 0xFF || got.IsSignalingNaN() {
+		t.Fatalf("%#x", got)
+	}
+	if got := floatx.Convert[floatx.F8E4M3](floatx.F16(0xFC01), &floatx.ConvertOptions{NaN: floatx.NaNCanonical}); got != 0x7C {
+		t.Fatalf("%#x", got)
+	}
+	if f := floatx.F8E4M3Fn(0xFF).Float32(); !math.IsNaN(float64(f)) || !math.Signbit(float64(f)) {
+		t.Fatal(f)
+	}
+	f := floatx.F32(math.Float32frombits(0x7F800001))
+	if !f.IsNaN() || !f.IsSignalingNaN() || floatx.F32(1).IsNaN() || floatx.F32(math.NaN()).IsSignalingNaN() {
+		t.Fatal("F32")
+	}
+	if !floatx.F8E4M3(0x79).IsSignalingNaN() || floatx.F8E4M3(0x78).IsNaN() || !floatx.F8E4M3(0x7C).IsNaN() {
+		t.Fatal("F8E4M3")
+	}
+	if !floatx.F8E4M3Fn(0x7F).IsNaN() || floatx.F8E4M3Fn(0x7F).IsSignalingNaN() || floatx.F8E4M3Fn(0x78).IsNaN() {
+		t.Fatal("F8E4M3Fn")
+	}
+}
+
+func Test_NaNPolicy_String(t *testing.T) {
+	for i, want := range []string{"NaNPreserveSign", "NaNCanonical", "NaNPreservePayload", "NaNPolicy(3)"} {
+		if got := floatx.NaNPolicy(i).String(); got != want {
+			t.Errorf("%d: %q; want %q", i, got, want)
+		}
+	}
+}
+
+func Test_Flags_String(t *testing.T) {
+	data := []struct {
+		f    floatx.Flags
+		want string
+	}{
+		{0, "0"},
+		{floatx.FlagInvalid, "Invalid"},
+		{floatx.FlagOverflow | floatx.FlagInexact, "Overflow|Inexact"},
+		{floatx.FlagUnderflow | floatx.FlagInexact, "Underflow|Inexact"},
+		{0x30 | floatx.FlagInvalid, "Invalid|0x30"},
+	}
+	for i, l := range data {
+		if got := l.f.String(); got != l.want {
+			t.Errorf("#%d: %q; want %q", i, got, l.want)
+		}
+	}
+}
+
+func testConvertFrom[From floatx.Float](t *testing.T, src []From) {
+	t.Run("F32", func(t *testing.T) { testConvert[floatx.F32](t, src) })
+	t.Run("BF16", func(t *testing.T) { testConvert[floatx.BF16](t, src) })
+	t.Run("F16", func(t *testing.T) { testConvert[floatx.F16](t, src) })
+	t.Run("F8E4M3", func(t *testing.T) { testConvert[floatx.F8E4M3](t, src) })
+	t.Run("F8E4M3Fn", func(t *testing.T) { testConvert[floatx.F8E4M3Fn](t, src) })
+	t.Run("F8E5M2", func(t *testing.T) { testConvert[floatx.F8E5M2](t, src) })
+}
+
+// testConvert verifies Convert against a reference that searches the
+// destination's table of values.
+func testConvert[To, From floatx.Float](t *testing.T, src []From) {
+	var to To
+	ref := newReference(dtypeOf(to))
+	for _, s := range src {
+		v := valueOf(s)
+		for _, mode := range roundingModes {
+			for _, saturate := range []bool{false, true} {
+				opts := floatx.ConvertOptions{Rounding: mode, Saturate: saturate}
+				r, flags := floatx.ConvertFlags[To](s, &opts)
+				got := r.Bits()
+				want, wantFlags := ref.convert(v, mode, saturate)
+				if isSignalingNaN(s) {
+					wantFlags = floatx.FlagInvalid
+				}
+				if got != want && !(ref.isNaN(got) && ref.isNaN(want) && got&ref.signBit == want&ref.signBit) {
+					t.Fatalf("Convert(%g, %s, %t) = %#x; want %#x", v, mode, saturate, got, want)
+				}
+				if flags != wantFlags {
+					t.Fatalf("Convert(%g, %s, %t) flags = %s; want %s", v, mode, saturate, flags, wantFlags)
+				}
+				if r2 := floatx.Convert[To](s, &opts); r2.Bits() != got {
+					t.Fatalf("Convert(%g, %s, %t) = %#x; ConvertFlags returned %#x", v, mode, saturate, r2.Bits(), got)
+				}
+			}
+		}
+	}
+}
+
+// reference converts values by searching in the sorted values of a type.
+type reference struct {
+	info floatx.DTypeInfo
+	// values are the positive finite values, indexed by their encoding.
+	values  []float64
+	signBit uint32
+	maxBits uint32
+}
+
+func newReference(d floatx.DType) *reference {
+	r := &reference{info: d.Info()}
+	r.signBit = 1 << (r.info.Bits - 1)
+	if d == floatx.DTypeF32 {
+		// Only used for exact conversions.
+		return r
+	}
+	for bits := uint32(0); ; bits++ {
+		v := r.decode(bits)
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			break
+		}
+		r.values = append(r.values, v)
+	}
+	r.maxBits = uint32(len(r.values) - 1)
+	return r
+}
+
+func (r *reference) decode(bits uint32) float64 {
+	var b [4]byte
+	binary.LittleEndian.PutUint32(b[:], bits)
+	return float64(r.info.Decode(b[:]))
+}
+
+func (r *reference) isNaN(bits uint32) bool {
+	return math.IsNaN(r.decode(bits))
+}
+
+func (r *reference) nan(sign uint32) uint32 {
+	for bits := r.maxBits + 1; ; bits++ {
+		if r.isNaN(bits) {
+			return sign | bits
+		}
+	}
+}
+
+func (r *reference) convert(v float64, mode floatx.RoundingMode, saturate bool) (uint32, floatx.Flags) {
+	var sign uint32
+	negative := math.Signbit(v)
+	if negative {
+		sign = r.signBit
+	}
+	if r.info.DType == floatx.DTypeF32 {
+		// All the other types are exactly representable.
+		if math.IsNaN(v) {
+			return sign | 0x7FC00000, 0
+		}
+		return math.Float32bits(float32(v)), 0
+	}
+	switch {
+	case math.IsNaN(v):
+		return r.nan(sign), 0
+	case math.IsInf(v, 0):
+		switch {
+		case r.info.HasInf:
+			return sign | (r.maxBits + 1), 0
+		case saturate:
+			return sign | r.maxBits, floatx.FlagInvalid
+		default:
+			return r.nan(sign), floatx.FlagInvalid
+		}
+	}
+	a := math.Abs(v)
+	// lo is the largest value <= a. hi is the next one, which is virtual
+	// beyond the largest finite value.
+	lo := uint32(sort.Search(len(r.values), func(i int) bool { return r.values[i] > a }) - 1)
+	if r.values[lo] == a {
+		return sign | lo, 0
+	}
+	hi := lo + 1
+	hiValue := r.values[r.maxBits] + float64(r.info.Epsilon)*math.Pow(2, math.Floor(math.Log2(r.values[r.maxBits])))
+	if hi <= r.maxBits {
+		hiValue = r.values[hi]
+	}
+	var bits uint32
+	switch mode {
+	case floatx.RoundNearestEven, floatx.RoundNearestAway:
+		dl := a - r.values[lo]
+		dh := hiValue - a
+		switch {
+		case dl < dh:
+			bits = lo
+		case dh < dl:
+			bits = hi
+		case mode == floatx.RoundNearestAway || lo&1 != 0:
+			bits = hi
+		default:
+			bits = lo
+		}
+	case floatx.RoundTowardZero:
+		bits = lo
+	case floatx.RoundAwayFromZero:
+		bits = hi
+	case floatx.RoundTowardNegative:
+		bits = lo
+		if negative {
+			bits = hi
+		}
+	case floatx.RoundTowardPositive:
+		bits = hi
+		if negative {
+			bits = lo
+		}
+	}
+	// The value following the largest finite one is on the grid of the
+	// unbounded exponent so larger values overflow in every mode.
+	if bits > r.maxBits || a >= hiValue {
+		flags := floatx.FlagOverflow | floatx.FlagInexact
+		switch {
+		case saturate || bits <= r.maxBits:
+			bits = r.maxBits
+		case r.info.HasInf:
+			bits = r.maxBits + 1
+		default:
+			return r.nan(sign), flags
+		}
+		return sign | bits, flags
+	}
+	flags := floatx.FlagInexact
+	if r.values[bits] < float64(r.info.SmallestNormal) {
+		flags |= floatx.FlagUnderflow
+	}
+	return sign | bits, flags
+}
+
+func isNaN[T floatx.Float](v T) bool {
+	return any(v).(interface{ IsNaN() bool }).IsNaN()
+}
+
+func isSignalingNaN[T floatx.Float](v T) bool {
+	return any(v).(interface{ IsSignalingNaN() bool }).IsSignalingNaN()
+}
+
+func dtypeOf[T floatx.Float](v T) floatx.DType {
+	switch any(v).(type) {
+	case floatx.F32:
+		return floatx.DTypeF32
+	case floatx.BF16:
+		return floatx.DTypeBF16
+	case floatx.F16:
+		return floatx.DTypeF16
+	case floatx.F8E4M3:
+		return floatx.DTypeF8E4M3
+	case floatx.F8E4M3Fn:
+		return floatx.DTypeF8E4M3Fn
+	case floatx.F8E5M2:
+		return floatx.DTypeF8E5M2
+	}
+	panic("unreachable")
+}
+
+func valueOf[T floatx.Float](v T) float64 {
+	var b [4]byte
+	binary.LittleEndian.PutUint32(b[:], v.Bits())
+	return float64(dtypeOf(v).Info().Decode(b[:]))
+}
+
+var benchmarkResultF8E4M3Fn floatx.F8E4M3Fn
+
+func Benchmark_Convert_BF16_F8E4M3Fn(b *testing.B) {
+	var dummy floatx.F8E4M3Fn
+	opts := floatx.ConvertOptions{Saturate: true}
+	for i := range b.N {
+		dummy += floatx.Convert[floatx.F8E4M3Fn](floatx.BF16(uint16(i)), &opts)
+	}
+	benchmarkResultF8E4M3Fn = dummy
+}
@@ -0,0 +1,204 @@
+// Copyright 2024 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package floatx
+
+import (
+	"encoding/binary"
+	"math"
+	"strconv"
+)
+
+// DType identifies one of the types in this package.
+//
+// It is used by APIs that process values of a type chosen at runtime, like
+// Decoder and Encoder.
+type DType uint8
+
+// Supported data types.
+const (
+	DTypeF32 DType = iota + 1
+	DTypeBF16
+	DTypeF16
+	DTypeF8E4M3
+	DTypeF8E4M3Fn
+	DTypeF8E5M2
+)
+
+// String returns the name of the Go type, e.g. "BF16".
+func (d DType) String() string {
+	switch d {
+	case DTypeF32:
+		return "F32"
+	case DTypeBF16:
+		return "BF16"
+	case DTypeF16:
+		return "F16"
+	case DTypeF8E4M3:
+		return "F8E4M3"
+	case DTypeF8E4M3Fn:
+		return "F8E4M3Fn"
+	case DTypeF8E5M2:
+		return "F8E5M2"
+	default:
+		return "DType(" + strconv.Itoa(int(d)) + ")"
+	}
+}
+
+// Size returns the size of one value in bytes, or 0 if d is invalid.
+func (d DType) Size() int {
+	switch d {
+	case DTypeF32:
+		return 4
+	case DTypeBF16, DTypeF16:
+		return 2
+	case DTypeF8E4M3, DTypeF8E4M3Fn, DTypeF8E5M2:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// Info returns the descriptor of d, or the zero value if d is invalid.
+func (d DType) Info() DTypeInfo {
+	if int(d) < len(dtypeInfos) {
+		return dtypeInfos[d]
+	}
+	return DTypeInfo{}
+}
+
+// LookupDType returns the DType for a name used by Go, PyTorch, NumPy and
+// ml_dtypes, safetensors or ONNX, e.g. "BF16", "torch.bfloat16", "<f2",
+// "F8_E4M3" or "FLOAT8E4M3FN".
+//
+// Names are case sensitive. safetensors' "F8_E4M3" is F8E4M3Fn, like
+// torch.float8_e4m3fn, while ml_dtypes' "float8_e4m3" is F8E4M3. Use
+// LookupONNX for the numbers of ONNX TensorProto.DataType.
+func LookupDType(name string) (DType, bool) {
+	d, ok := dtypeNames[name]
+	return d, ok
+}
+
+// LookupONNX returns the DType for an ONNX TensorProto.DataType number, e.g.
+// 16 for BFLOAT16 or 17 for FLOAT8E4M3FN.
+//
+// It returns false for FLOAT8E4M3FNUZ (18) and FLOAT8E5M2FNUZ (20), which have
+// no DType since their exponent bias and NaN encoding differ, and for the
+// non floating point types.
+func LookupONNX(dataType int32) (DType, bool) {
+	d, ok := onnxDTypes[dataType]
+	return d, ok
+}
+
+// DTypeInfo describes a DType, like numpy.finfo.
+type DTypeInfo struct {
+	DType        DType
+	Bits         int
+	ExponentBits int
+	MantissaBits int
+	// HasInf is false for the types using the infinity encoding for NaN, like
+	// F8E4M3Fn.
+	HasInf bool
+	// Max is the largest finite value.
+	Max float32
+	// SmallestNormal is the smallest positive normal value.
+	SmallestNormal float32
+	// SmallestSubnormal is the smallest positive value.
+	SmallestSubnormal float32
+	// Epsilon is the difference between 1 and the next larger value.
+	Epsilon float32
+
+	// Decode decodes a little endian value.
+	Decode func(b []byte) float32
+	// Put encodes a little endian value, rounding to nearest even.
+	Put func(b []byte, v float32)
+}
+
+// dtypeNames maps the names used by the file formats and libraries.
+var dtypeNames = map[string]DType{
+	// Go types, as returned by DType.String(). "F32", "BF16" and "F16" are also
+	// the safetensors names.
+	"F32":      DTypeF32,
+	"BF16":     DTypeBF16,
+	"F16":      DTypeF16,
+	"F8E4M3":   DTypeF8E4M3,
+	"F8E4M3Fn": DTypeF8E4M3Fn,
+	"F8E5M2":   DTypeF8E5M2,
+	// safetensors.
+	"F8_E4M3": DTypeF8E4M3Fn,
+	"F8_E5M2": DTypeF8E5M2,
+	// torch.dtype.
+	"torch.float32":       DTypeF32,
+	"torch.float":         DTypeF32,
+	"torch.bfloat16":      DTypeBF16,
+	"torch.float16":       DTypeF16,
+	"torch.half":          DTypeF16,
+	"torch.float8_e4m3fn": DTypeF8E4M3Fn,
+	"torch.float8_e5m2":   DTypeF8E5M2,
+	// NumPy and ml_dtypes, which are also the torch.dtype names without the
+	// prefix.
+	"float32":       DTypeF32,
+	"f4":            DTypeF32,
+	"<f4":           DTypeF32,
+	"bfloat16":      DTypeBF16,
+	"float16":       DTypeF16,
+	"half":          DTypeF16,
+	"f2":            DTypeF16,
+	"<f2":           DTypeF16,
+	"float8_e4m3":   DTypeF8E4M3,
+	"float8_e4m3fn": DTypeF8E4M3Fn,
+	"float8_e5m2":   DTypeF8E5M2,
+	// ONNX TensorProto.DataType.
+	"FLOAT":        DTypeF32,
+	"BFLOAT16":     DTypeBF16,
+	"FLOAT16":      DTypeF16,
+	"FLOAT8E4M3FN": DTypeF8E4M3Fn,
+	"FLOAT8E5M2":   DTypeF8E5M2,
+}
+
+// onnxDTypes maps the ONNX TensorProto.DataType numbers.
+var onnxDTypes = map[int32]DType{
+	1:  DTypeF32,
+	10: DTypeF16,
+	16: DTypeBF16,
+	17: DTypeF8E4M3Fn,
+	19: DTypeF8E5M2,
+}
+
+var dtypeInfos = [...]DTypeInfo{
+	DTypeF32: newDTypeInfo(DTypeF32, &f32Format,
+		func(b []byte) float32 { return math.Float32frombits(binary.LittleEndian.Uint32(b)) },
+		func(b []byte, v float32) { binary.LittleEndian.PutUint32(b, math.Float32bits(v)) }),
+	DTypeBF16: newDTypeInfo(DTypeBF16, &bf16Format,
+		func(b []byte) float32 { return DecodeBF16(b).Float32() },
+		func(b []byte, v float32) { PutBF16(b, BF16FromFloat32(v)) }),
+	DTypeF16: newDTypeInfo(DTypeF16, &f16Format,
+		func(b []byte) float32 { return DecodeF16(b).Float32() },
+		func(b []byte, v float32) { PutF16(b, F16FromFloat32(v)) }),
+	DTypeF8E4M3: newDTypeInfo(DTypeF8E4M3, &f8e4m3Format,
+		func(b []byte) float32 { return F8E4M3(b[0]).Float32() },
+		func(b []byte, v float32) { b[0] = byte(F8E4M3FromFloat32(v)) }),
+	DTypeF8E4M3Fn: newDTypeInfo(DTypeF8E4M3Fn, &f8e4m3fnFormat,
+		func(b []byte) float32 { return F8E4M3Fn(b[0]).Float32() },
+		func(b []byte, v float32) { b[0] = byte(F8E4M3FnFromFloat32(v)) }),
+	DTypeF8E5M2: newDTypeInfo(DTypeF8E5M2, &f8e5m2Format,
+		func(b []byte) float32 { return F8E5M2(b[0]).Float32() },
+		func(b []byte, v float32) { b[0] = byte(F8E5M2FromFloat32(v)) }),
+}
+
+func newDTypeInfo(d DType, f *format, decode func(b []byte) float32, put func(b []byte, v float32)) DTypeInfo {
+	return DTypeInfo{
+		DType:             d,
+		Bits:              int(1 + f.exponentBits + f.mantissaBits),
+		ExponentBits:      int(f.exponentBits),
+		MantissaBits:      int(f.mantissaBits),
+		HasInf:            !f.finite,
+		Max:               float32(f.toFloat64(f.maxFinite())),
+		SmallestNormal:    float32(math.Ldexp(1, 1-f.bias())),
+		SmallestSubnormal: float32(math.Ldexp(1, 1-f.bias()-int(f.mantissaBits))),
+		Epsilon:           float32(math.Ldexp(1, -int(f.mantissaBits))),
+		Decode:            decode,
+		Put:               put,
+	}
+}
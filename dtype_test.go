@@ -0,0 +1,118 @@
+// Copyright 2024 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package floatx_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/maruel/floatx"
+)
+
+func Test_DType_Info(t *testing.T) {
+	data := []struct {
+		dtype                  floatx.DType
+		bits, exponent, mant   int
+		hasInf                 bool
+		max, normal, sub, epsi float32
+	}{
+		{floatx.DTypeF32, 32, 8, 23, true, math.MaxFloat32, 0x1p-126, 0x1p-149, 0x1p-23},
+		{floatx.DTypeBF16, 16, 8, 7, true, 0x1.FEp127, 0x1p-126, 0x1p-133, 0x1p-7},
+		{floatx.DTypeF16, 16, 5, 10, true, 65504, 0x1p-14, 0x1p-24, 0x1p-10},
+		{floatx.DTypeF8E4M3, 8, 4, 3, true, 240, 0x1p-6, 0x1p-9, 0x1p-3},
+		{floatx.DTypeF8E4M3Fn, 8, 4, 3, false, 448, 0x1p-6, 0x1p-9, 0x1p-3},
+		{floatx.DTypeF8E5M2, 8, 5, 2, true, 57344, 0x1p-14, 0x1p-16, 0x1p-2},
+	}
+	for _, line := range data {
+		t.Run(line.dtype.String(), func(t *testing.T) {
+			i := line.dtype.Info()
+			if i.DType != line.dtype || i.Bits != line.bits || i.Bits != 8*line.dtype.Size() || i.ExponentBits != line.exponent || i.MantissaBits != line.mant || i.HasInf != line.hasInf {
+				t.Fatalf("%+v", i)
+			}
+			if i.Max != line.max || i.SmallestNormal != line.normal || i.SmallestSubnormal != line.sub || i.Epsilon != line.epsi {
+				t.Fatalf("%+v", i)
+			}
+			b := make([]byte, line.dtype.Size())
+			for _, v := range []float32{0, 1, -2, i.Max, i.SmallestNormal, i.SmallestSubnormal, 1 + i.Epsilon} {
+				i.Put(b, v)
+				if got := i.Decode(b); got != v {
+					t.Fatalf("%v != %v", got, v)
+				}
+			}
+			// Rounds to nearest even.
+			i.Put(b, 1+i.Epsilon/2)
+			if got := i.Decode(b); got != 1 {
+				t.Fatal(got)
+			}
+		})
+	}
+	if i := floatx.DType(0).Info(); i.Bits != 0 || i.Decode != nil {
+		t.Fatalf("%+v", i)
+	}
+	if i := floatx.DType(200).Info(); i.Bits != 0 {
+		t.Fatalf("%+v", i)
+	}
+}
+
+func Test_LookupDType(t *testing.T) {
+	data := map[string]floatx.DType{
+		"F32":                 floatx.DTypeF32,
+		"torch.float":         floatx.DTypeF32,
+		"<f4":                 floatx.DTypeF32,
+		"FLOAT":               floatx.DTypeF32,
+		"BF16":                floatx.DTypeBF16,
+		"bfloat16":            floatx.DTypeBF16,
+		"torch.bfloat16":      floatx.DTypeBF16,
+		"BFLOAT16":            floatx.DTypeBF16,
+		"half":                floatx.DTypeF16,
+		"torch.half":          floatx.DTypeF16,
+		"FLOAT16":             floatx.DTypeF16,
+		"float8_e4m3":         floatx.DTypeF8E4M3,
+		"F8E4M3Fn":            floatx.DTypeF8E4M3Fn,
+		"F8_E4M3":             floatx.DTypeF8E4M3Fn,
+		"torch.float8_e4m3fn": floatx.DTypeF8E4M3Fn,
+		"FLOAT8E4M3FN":        floatx.DTypeF8E4M3Fn,
+		"F8_E5M2":             floatx.DTypeF8E5M2,
+		"float8_e5m2":         floatx.DTypeF8E5M2,
+		"FLOAT8E5M2":          floatx.DTypeF8E5M2,
+	}
+	for name, want := range data {
+		if got, ok := floatx.LookupDType(name); !ok || got != want {
+			t.Errorf("%q: want %s, got %s", name, want, got)
+		}
+	}
+	// The Go names round trip.
+	for d := floatx.DTypeF32; d <= floatx.DTypeF8E5M2; d++ {
+		if got, ok := floatx.LookupDType(d.String()); !ok || got != d {
+			t.Errorf("%s: got %s", d, got)
+		}
+	}
+	for _, name := range []string{"", "float64", "bf16", "FLOAT8E4M3FNUZ", ">f2"} {
+		if got, ok := floatx.LookupDType(name); ok {
+			t.Errorf("%q: got %s", name, got)
+		}
+	}
+}
+
+func Test_LookupONNX(t *testing.T) {
+	data := map[int32]floatx.DType{
+		1:  floatx.DTypeF32,
+		10: floatx.DTypeF16,
+		16: floatx.DTypeBF16,
+		17: floatx.DTypeF8E4M3Fn,
+		19: floatx.DTypeF8E5M2,
+	}
+	for n, want := range data {
+		if got, ok := floatx.LookupONNX(n); !ok || got != want {
+			t.Errorf("%d: want %s, got %s", n, want, got)
+		}
+	}
+	// UNDEFINED, DOUBLE, the FNUZ types and unknown numbers.
+	for _, n := range []int32{0, 11, 18, 20, -1, 1000} {
+		if got, ok := floatx.LookupONNX(n); ok {
+			t.Errorf("%d: got %s", n, got)
+		}
+	}
+}
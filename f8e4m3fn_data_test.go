@@ -6,260 +6,260 @@ import "math"
 
 // See floatx_test.go
 var f8E4M3FnTestData = []testData{
-	{0x00, 0, 0, 0, 0},
-	{0x01, 0.001953125, 0, 0, 1},
-	{0x02, 0.00390625, 0, 0, 2},
-	{0x03, 0.005859375, 0, 0, 3},
-	{0x04, 0.0078125, 0, 0, 4},
-	{0x05, 0.009765625, 0, 0, 5},
-	{0x06, 0.01171875, 0, 0, 6},
-	{0x07, 0.013671875, 0, 0, 7},
-	{0x08, 0.015625, 0, 1, 0},
-	{0x09, 0.017578125, 0, 1, 1},
-	{0x0a, 0.01953125, 0, 1, 2},
-	{0x0b, 0.021484375, 0, 1, 3},
-	{0x0c, 0.0234375, 0, 1, 4},
-	{0x0d, 0.025390625, 0, 1, 5},
-	{0x0e, 0.02734375, 0, 1, 6},
-	{0x0f, 0.029296875, 0, 1, 7},
-	{0x10, 0.03125, 0, 2, 0},
-	{0x11, 0.03515625, 0, 2, 1},
-	{0x12, 0.0390625, 0, 2, 2},
-	{0x13, 0.04296875, 0, 2, 3},
-	{0x14, 0.046875, 0, 2, 4},
-	{0x15, 0.05078125, 0, 2, 5},
-	{0x16, 0.0546875, 0, 2, 6},
-	{0x17, 0.05859375, 0, 2, 7},
-	{0x18, 0.0625, 0, 3, 0},
-	{0x19, 0.0703125, 0, 3, 1},
-	{0x1a, 0.078125, 0, 3, 2},
-	{0x1b, 0.0859375, 0, 3, 3},
-	{0x1c, 0.09375, 0, 3, 4},
-	{0x1d, 0.1015625, 0, 3, 5},
-	{0x1e, 0.109375, 0, 3, 6},
-	{0x1f, 0.1171875, 0, 3, 7},
-	{0x20, 0.125, 0, 4, 0},
-	{0x21, 0.140625, 0, 4, 1},
-	{0x22, 0.15625, 0, 4, 2},
-	{0x23, 0.171875, 0, 4, 3},
-	{0x24, 0.1875, 0, 4, 4},
-	{0x25, 0.203125, 0, 4, 5},
-	{0x26, 0.21875, 0, 4, 6},
-	{0x27, 0.234375, 0, 4, 7},
-	{0x28, 0.25, 0, 5, 0},
-	{0x29, 0.28125, 0, 5, 1},
-	{0x2a, 0.3125, 0, 5, 2},
-	{0x2b, 0.34375, 0, 5, 3},
-	{0x2c, 0.375, 0, 5, 4},
-	{0x2d, 0.40625, 0, 5, 5},
-	{0x2e, 0.4375, 0, 5, 6},
-	{0x2f, 0.46875, 0, 5, 7},
-	{0x30, 0.5, 0, 6, 0},
-	{0x31, 0.5625, 0, 6, 1},
-	{0x32, 0.625, 0, 6, 2},
-	{0x33, 0.6875, 0, 6, 3},
-	{0x34, 0.75, 0, 6, 4},
-	{0x35, 0.8125, 0, 6, 5},
-	{0x36, 0.875, 0, 6, 6},
-	{0x37, 0.9375, 0, 6, 7},
-	{0x38, 1, 0, 7, 0},
-	{0x39, 1.125, 0, 7, 1},
-	{0x3a, 1.25, 0, 7, 2},
-	{0x3b, 1.375, 0, 7, 3},
-	{0x3c, 1.5, 0, 7, 4},
-	{0x3d, 1.625, 0, 7, 5},
-	{0x3e, 1.75, 0, 7, 6},
-	{0x3f, 1.875, 0, 7, 7},
-	{0x40, 2, 0, 8, 0},
-	{0x41, 2.25, 0, 8, 1},
-	{0x42, 2.5, 0, 8, 2},
-	{0x43, 2.75, 0, 8, 3},
-	{0x44, 3, 0, 8, 4},
-	{0x45, 3.25, 0, 8, 5},
-	{0x46, 3.5, 0, 8, 6},
-	{0x47, 3.75, 0, 8, 7},
-	{0x48, 4, 0, 9, 0},
-	{0x49, 4.5, 0, 9, 1},
-	{0x4a, 5, 0, 9, 2},
-	{0x4b, 5.5, 0, 9, 3},
-	{0x4c, 6, 0, 9, 4},
-	{0x4d, 6.5, 0, 9, 5},
-	{0x4e, 7, 0, 9, 6},
-	{0x4f, 7.5, 0, 9, 7},
-	{0x50, 8, 0, 10, 0},
-	{0x51, 9, 0, 10, 1},
-	{0x52, 10, 0, 10, 2},
-	{0x53, 11, 0, 10, 3},
-	{0x54, 12, 0, 10, 4},
-	{0x55, 13, 0, 10, 5},
-	{0x56, 14, 0, 10, 6},
-	{0x57, 15, 0, 10, 7},
-	{0x58, 16, 0, 11, 0},
-	{0x59, 18, 0, 11, 1},
-	{0x5a, 20, 0, 11, 2},
-	{0x5b, 22, 0, 11, 3},
-	{0x5c, 24, 0, 11, 4},
-	{0x5d, 26, 0, 11, 5},
-	{0x5e, 28, 0, 11, 6},
-	{0x5f, 30, 0, 11, 7},
-	{0x60, 32, 0, 12, 0},
-	{0x61, 36, 0, 12, 1},
-	{0x62, 40, 0, 12, 2},
-	{0x63, 44, 0, 12, 3},
-	{0x64, 48, 0, 12, 4},
-	{0x65, 52, 0, 12, 5},
-	{0x66, 56, 0, 12, 6},
-	{0x67, 60, 0, 12, 7},
-	{0x68, 64, 0, 13, 0},
-	{0x69, 72, 0, 13, 1},
-	{0x6a, 80, 0, 13, 2},
-	{0x6b, 88, 0, 13, 3},
-	{0x6c, 96, 0, 13, 4},
-	{0x6d, 104, 0, 13, 5},
-	{0x6e, 112, 0, 13, 6},
-	{0x6f, 120, 0, 13, 7},
-	{0x70, 128, 0, 14, 0},
-	{0x71, 144, 0, 14, 1},
-	{0x72, 160, 0, 14, 2},
-	{0x73, 176, 0, 14, 3},
-	{0x74, 192, 0, 14, 4},
-	{0x75, 208, 0, 14, 5},
-	{0x76, 224, 0, 14, 6},
-	{0x77, 240, 0, 14, 7},
-	{0x78, 256, 0, 15, 0},
-	{0x79, 288, 0, 15, 1},
-	{0x7a, 320, 0, 15, 2},
-	{0x7b, 352, 0, 15, 3},
-	{0x7c, 384, 0, 15, 4},
-	{0x7d, 416, 0, 15, 5},
-	{0x7e, 448, 0, 15, 6},
-	{0x7f, float32(math.NaN()), 0, 15, 7},
-	{0x80, -0, 1, 0, 0},
-	{0x81, -0.001953125, 1, 0, 1},
-	{0x82, -0.00390625, 1, 0, 2},
-	{0x83, -0.005859375, 1, 0, 3},
-	{0x84, -0.0078125, 1, 0, 4},
-	{0x85, -0.009765625, 1, 0, 5},
-	{0x86, -0.01171875, 1, 0, 6},
-	{0x87, -0.013671875, 1, 0, 7},
-	{0x88, -0.015625, 1, 1, 0},
-	{0x89, -0.017578125, 1, 1, 1},
-	{0x8a, -0.01953125, 1, 1, 2},
-	{0x8b, -0.021484375, 1, 1, 3},
-	{0x8c, -0.0234375, 1, 1, 4},
-	{0x8d, -0.025390625, 1, 1, 5},
-	{0x8e, -0.02734375, 1, 1, 6},
-	{0x8f, -0.029296875, 1, 1, 7},
-	{0x90, -0.03125, 1, 2, 0},
-	{0x91, -0.03515625, 1, 2, 1},
-	{0x92, -0.0390625, 1, 2, 2},
-	{0x93, -0.04296875, 1, 2, 3},
-	{0x94, -0.046875, 1, 2, 4},
-	{0x95, -0.05078125, 1, 2, 5},
-	{0x96, -0.0546875, 1, 2, 6},
-	{0x97, -0.05859375, 1, 2, 7},
-	{0x98, -0.0625, 1, 3, 0},
-	{0x99, -0.0703125, 1, 3, 1},
-	{0x9a, -0.078125, 1, 3, 2},
-	{0x9b, -0.0859375, 1, 3, 3},
-	{0x9c, -0.09375, 1, 3, 4},
-	{0x9d, -0.1015625, 1, 3, 5},
-	{0x9e, -0.109375, 1, 3, 6},
-	{0x9f, -0.1171875, 1, 3, 7},
-	{0xa0, -0.125, 1, 4, 0},
-	{0xa1, -0.140625, 1, 4, 1},
-	{0xa2, -0.15625, 1, 4, 2},
-	{0xa3, -0.171875, 1, 4, 3},
-	{0xa4, -0.1875, 1, 4, 4},
-	{0xa5, -0.203125, 1, 4, 5},
-	{0xa6, -0.21875, 1, 4, 6},
-	{0xa7, -0.234375, 1, 4, 7},
-	{0xa8, -0.25, 1, 5, 0},
-	{0xa9, -0.28125, 1, 5, 1},
-	{0xaa, -0.3125, 1, 5, 2},
-	{0xab, -0.34375, 1, 5, 3},
-	{0xac, -0.375, 1, 5, 4},
-	{0xad, -0.40625, 1, 5, 5},
-	{0xae, -0.4375, 1, 5, 6},
-	{0xaf, -0.46875, 1, 5, 7},
-	{0xb0, -0.5, 1, 6, 0},
-	{0xb1, -0.5625, 1, 6, 1},
-	{0xb2, -0.625, 1, 6, 2},
-	{0xb3, -0.6875, 1, 6, 3},
-	{0xb4, -0.75, 1, 6, 4},
-	{0xb5, -0.8125, 1, 6, 5},
-	{0xb6, -0.875, 1, 6, 6},
-	{0xb7, -0.9375, 1, 6, 7},
-	{0xb8, -1, 1, 7, 0},
-	{0xb9, -1.125, 1, 7, 1},
-	{0xba, -1.25, 1, 7, 2},
-	{0xbb, -1.375, 1, 7, 3},
-	{0xbc, -1.5, 1, 7, 4},
-	{0xbd, -1.625, 1, 7, 5},
-	{0xbe, -1.75, 1, 7, 6},
-	{0xbf, -1.875, 1, 7, 7},
-	{0xc0, -2, 1, 8, 0},
-	{0xc1, -2.25, 1, 8, 1},
-	{0xc2, -2.5, 1, 8, 2},
-	{0xc3, -2.75, 1, 8, 3},
-	{0xc4, -3, 1, 8, 4},
-	{0xc5, -3.25, 1, 8, 5},
-	{0xc6, -3.5, 1, 8, 6},
-	{0xc7, -3.75, 1, 8, 7},
-	{0xc8, -4, 1, 9, 0},
-	{0xc9, -4.5, 1, 9, 1},
-	{0xca, -5, 1, 9, 2},
-	{0xcb, -5.5, 1, 9, 3},
-	{0xcc, -6, 1, 9, 4},
-	{0xcd, -6.5, 1, 9, 5},
-	{0xce, -7, 1, 9, 6},
-	{0xcf, -7.5, 1, 9, 7},
-	{0xd0, -8, 1, 10, 0},
-	{0xd1, -9, 1, 10, 1},
-	{0xd2, -10, 1, 10, 2},
-	{0xd3, -11, 1, 10, 3},
-	{0xd4, -12, 1, 10, 4},
-	{0xd5, -13, 1, 10, 5},
-	{0xd6, -14, 1, 10, 6},
-	{0xd7, -15, 1, 10, 7},
-	{0xd8, -16, 1, 11, 0},
-	{0xd9, -18, 1, 11, 1},
-	{0xda, -20, 1, 11, 2},
-	{0xdb, -22, 1, 11, 3},
-	{0xdc, -24, 1, 11, 4},
-	{0xdd, -26, 1, 11, 5},
-	{0xde, -28, 1, 11, 6},
-	{0xdf, -30, 1, 11, 7},
-	{0xe0, -32, 1, 12, 0},
-	{0xe1, -36, 1, 12, 1},
-	{0xe2, -40, 1, 12, 2},
-	{0xe3, -44, 1, 12, 3},
-	{0xe4, -48, 1, 12, 4},
-	{0xe5, -52, 1, 12, 5},
-	{0xe6, -56, 1, 12, 6},
-	{0xe7, -60, 1, 12, 7},
-	{0xe8, -64, 1, 13, 0},
-	{0xe9, -72, 1, 13, 1},
-	{0xea, -80, 1, 13, 2},
-	{0xeb, -88, 1, 13, 3},
-	{0xec, -96, 1, 13, 4},
-	{0xed, -104, 1, 13, 5},
-	{0xee, -112, 1, 13, 6},
-	{0xef, -120, 1, 13, 7},
-	{0xf0, -128, 1, 14, 0},
-	{0xf1, -144, 1, 14, 1},
-	{0xf2, -160, 1, 14, 2},
-	{0xf3, -176, 1, 14, 3},
-	{0xf4, -192, 1, 14, 4},
-	{0xf5, -208, 1, 14, 5},
-	{0xf6, -224, 1, 14, 6},
-	{0xf7, -240, 1, 14, 7},
-	{0xf8, -256, 1, 15, 0},
-	{0xf9, -288, 1, 15, 1},
-	{0xfa, -320, 1, 15, 2},
-	{0xfb, -352, 1, 15, 3},
-	{0xfc, -384, 1, 15, 4},
-	{0xfd, -416, 1, 15, 5},
-	{0xfe, -448, 1, 15, 6},
-	{0xff, float32(math.NaN()), 1, 15, 7},
+	{0x00, 0, 0, 0, 0, "0"},
+	{0x01, 0.001953125, 0, 0, 1, "1/512"},
+	{0x02, 0.00390625, 0, 0, 2, "1/256"},
+	{0x03, 0.005859375, 0, 0, 3, "3/512"},
+	{0x04, 0.0078125, 0, 0, 4, "1/128"},
+	{0x05, 0.009765625, 0, 0, 5, "5/512"},
+	{0x06, 0.01171875, 0, 0, 6, "3/256"},
+	{0x07, 0.013671875, 0, 0, 7, "7/512"},
+	{0x08, 0.015625, 0, 1, 0, "1/64"},
+	{0x09, 0.017578125, 0, 1, 1, "9/512"},
+	{0x0a, 0.01953125, 0, 1, 2, "5/256"},
+	{0x0b, 0.021484375, 0, 1, 3, "11/512"},
+	{0x0c, 0.0234375, 0, 1, 4, "3/128"},
+	{0x0d, 0.025390625, 0, 1, 5, "13/512"},
+	{0x0e, 0.02734375, 0, 1, 6, "7/256"},
+	{0x0f, 0.029296875, 0, 1, 7, "15/512"},
+	{0x10, 0.03125, 0, 2, 0, "1/32"},
+	{0x11, 0.03515625, 0, 2, 1, "9/256"},
+	{0x12, 0.0390625, 0, 2, 2, "5/128"},
+	{0x13, 0.04296875, 0, 2, 3, "11/256"},
+	{0x14, 0.046875, 0, 2, 4, "3/64"},
+	{0x15, 0.05078125, 0, 2, 5, "13/256"},
+	{0x16, 0.0546875, 0, 2, 6, "7/128"},
+	{0x17, 0.05859375, 0, 2, 7, "15/256"},
+	{0x18, 0.0625, 0, 3, 0, "1/16"},
+	{0x19, 0.0703125, 0, 3, 1, "9/128"},
+	{0x1a, 0.078125, 0, 3, 2, "5/64"},
+	{0x1b, 0.0859375, 0, 3, 3, "11/128"},
+	{0x1c, 0.09375, 0, 3, 4, "3/32"},
+	{0x1d, 0.1015625, 0, 3, 5, "13/128"},
+	{0x1e, 0.109375, 0, 3, 6, "7/64"},
+	{0x1f, 0.1171875, 0, 3, 7, "15/128"},
+	{0x20, 0.125, 0, 4, 0, "1/8"},
+	{0x21, 0.140625, 0, 4, 1, "9/64"},
+	{0x22, 0.15625, 0, 4, 2, "5/32"},
+	{0x23, 0.171875, 0, 4, 3, "11/64"},
+	{0x24, 0.1875, 0, 4, 4, "3/16"},
+	{0x25, 0.203125, 0, 4, 5, "13/64"},
+	{0x26, 0.21875, 0, 4, 6, "7/32"},
+	{0x27, 0.234375, 0, 4, 7, "15/64"},
+	{0x28, 0.25, 0, 5, 0, "1/4"},
+	{0x29, 0.28125, 0, 5, 1, "9/32"},
+	{0x2a, 0.3125, 0, 5, 2, "5/16"},
+	{0x2b, 0.34375, 0, 5, 3, "11/32"},
+	{0x2c, 0.375, 0, 5, 4, "3/8"},
+	{0x2d, 0.40625, 0, 5, 5, "13/32"},
+	{0x2e, 0.4375, 0, 5, 6, "7/16"},
+	{0x2f, 0.46875, 0, 5, 7, "15/32"},
+	{0x30, 0.5, 0, 6, 0, "1/2"},
+	{0x31, 0.5625, 0, 6, 1, "9/16"},
+	{0x32, 0.625, 0, 6, 2, "5/8"},
+	{0x33, 0.6875, 0, 6, 3, "11/16"},
+	{0x34, 0.75, 0, 6, 4, "3/4"},
+	{0x35, 0.8125, 0, 6, 5, "13/16"},
+	{0x36, 0.875, 0, 6, 6, "7/8"},
+	{0x37, 0.9375, 0, 6, 7, "15/16"},
+	{0x38, 1, 0, 7, 0, "1"},
+	{0x39, 1.125, 0, 7, 1, "9/8"},
+	{0x3a, 1.25, 0, 7, 2, "5/4"},
+	{0x3b, 1.375, 0, 7, 3, "11/8"},
+	{0x3c, 1.5, 0, 7, 4, "3/2"},
+	{0x3d, 1.625, 0, 7, 5, "13/8"},
+	{0x3e, 1.75, 0, 7, 6, "7/4"},
+	{0x3f, 1.875, 0, 7, 7, "15/8"},
+	{0x40, 2, 0, 8, 0, "2"},
+	{0x41, 2.25, 0, 8, 1, "9/4"},
+	{0x42, 2.5, 0, 8, 2, "5/2"},
+	{0x43, 2.75, 0, 8, 3, "11/4"},
+	{0x44, 3, 0, 8, 4, "3"},
+	{0x45, 3.25, 0, 8, 5, "13/4"},
+	{0x46, 3.5, 0, 8, 6, "7/2"},
+	{0x47, 3.75, 0, 8, 7, "15/4"},
+	{0x48, 4, 0, 9, 0, "4"},
+	{0x49, 4.5, 0, 9, 1, "9/2"},
+	{0x4a, 5, 0, 9, 2, "5"},
+	{0x4b, 5.5, 0, 9, 3, "11/2"},
+	{0x4c, 6, 0, 9, 4, "6"},
+	{0x4d, 6.5, 0, 9, 5, "13/2"},
+	{0x4e, 7, 0, 9, 6, "7"},
+	{0x4f, 7.5, 0, 9, 7, "15/2"},
+	{0x50, 8, 0, 10, 0, "8"},
+	{0x51, 9, 0, 10, 1, "9"},
+	{0x52, 10, 0, 10, 2, "10"},
+	{0x53, 11, 0, 10, 3, "11"},
+	{0x54, 12, 0, 10, 4, "12"},
+	{0x55, 13, 0, 10, 5, "13"},
+	{0x56, 14, 0, 10, 6, "14"},
+	{0x57, 15, 0, 10, 7, "15"},
+	{0x58, 16, 0, 11, 0, "16"},
+	{0x59, 18, 0, 11, 1, "18"},
+	{0x5a, 20, 0, 11, 2, "20"},
+	{0x5b, 22, 0, 11, 3, "22"},
+	{0x5c, 24, 0, 11, 4, "24"},
+	{0x5d, 26, 0, 11, 5, "26"},
+	{0x5e, 28, 0, 11, 6, "28"},
+	{0x5f, 30, 0, 11, 7, "30"},
+	{0x60, 32, 0, 12, 0, "32"},
+	{0x61, 36, 0, 12, 1, "36"},
+	{0x62, 40, 0, 12, 2, "40"},
+	{0x63, 44, 0, 12, 3, "44"},
+	{0x64, 48, 0, 12, 4, "48"},
+	{0x65, 52, 0, 12, 5, "52"},
+	{0x66, 56, 0, 12, 6, "56"},
+	{0x67, 60, 0, 12, 7, "60"},
+	{0x68, 64, 0, 13, 0, "64"},
+	{0x69, 72, 0, 13, 1, "72"},
+	{0x6a, 80, 0, 13, 2, "80"},
+	{0x6b, 88, 0, 13, 3, "88"},
+	{0x6c, 96, 0, 13, 4, "96"},
+	{0x6d, 104, 0, 13, 5, "104"},
+	{0x6e, 112, 0, 13, 6, "112"},
+	{0x6f, 120, 0, 13, 7, "120"},
+	{0x70, 128, 0, 14, 0, "128"},
+	{0x71, 144, 0, 14, 1, "144"},
+	{0x72, 160, 0, 14, 2, "160"},
+	{0x73, 176, 0, 14, 3, "176"},
+	{0x74, 192, 0, 14, 4, "192"},
+	{0x75, 208, 0, 14, 5, "208"},
+	{0x76, 224, 0, 14, 6, "224"},
+	{0x77, 240, 0, 14, 7, "240"},
+	{0x78, 256, 0, 15, 0, "256"},
+	{0x79, 288, 0, 15, 1, "288"},
+	{0x7a, 320, 0, 15, 2, "320"},
+	{0x7b, 352, 0, 15, 3, "352"},
+	{0x7c, 384, 0, 15, 4, "384"},
+	{0x7d, 416, 0, 15, 5, "416"},
+	{0x7e, 448, 0, 15, 6, "448"},
+	{0x7f, float32(math.NaN()), 0, 15, 7, "NaN"},
+	{0x80, -0, 1, 0, 0, "-0"},
+	{0x81, -0.001953125, 1, 0, 1, "-1/512"},
+	{0x82, -0.00390625, 1, 0, 2, "-1/256"},
+	{0x83, -0.005859375, 1, 0, 3, "-3/512"},
+	{0x84, -0.0078125, 1, 0, 4, "-1/128"},
+	{0x85, -0.009765625, 1, 0, 5, "-5/512"},
+	{0x86, -0.01171875, 1, 0, 6, "-3/256"},
+	{0x87, -0.013671875, 1, 0, 7, "-7/512"},
+	{0x88, -0.015625, 1, 1, 0, "-1/64"},
+	{0x89, -0.017578125, 1, 1, 1, "-9/512"},
+	{0x8a, -0.01953125, 1, 1, 2, "-5/256"},
+	{0x8b, -0.021484375, 1, 1, 3, "-11/512"},
+	{0x8c, -0.0234375, 1, 1, 4, "-3/128"},
+	{0x8d, -0.025390625, 1, 1, 5, "-13/512"},
+	{0x8e, -0.02734375, 1, 1, 6, "-7/256"},
+	{0x8f, -0.029296875, 1, 1, 7, "-15/512"},
+	{0x90, -0.03125, 1, 2, 0, "-1/32"},
+	{0x91, -0.03515625, 1, 2, 1, "-9/256"},
+	{0x92, -0.0390625, 1, 2, 2, "-5/128"},
+	{0x93, -0.04296875, 1, 2, 3, "-11/256"},
+	{0x94, -0.046875, 1, 2, 4, "-3/64"},
+	{0x95, -0.05078125, 1, 2, 5, "-13/256"},
+	{0x96, -0.0546875, 1, 2, 6, "-7/128"},
+	{0x97, -0.05859375, 1, 2, 7, "-15/256"},
+	{0x98, -0.0625, 1, 3, 0, "-1/16"},
+	{0x99, -0.0703125, 1, 3, 1, "-9/128"},
+	{0x9a, -0.078125, 1, 3, 2, "-5/64"},
+	{0x9b, -0.0859375, 1, 3, 3, "-11/128"},
+	{0x9c, -0.09375, 1, 3, 4, "-3/32"},
+	{0x9d, -0.1015625, 1, 3, 5, "-13/128"},
+	{0x9e, -0.109375, 1, 3, 6, "-7/64"},
+	{0x9f, -0.1171875, 1, 3, 7, "-15/128"},
+	{0xa0, -0.125, 1, 4, 0, "-1/8"},
+	{0xa1, -0.140625, 1, 4, 1, "-9/64"},
+	{0xa2, -0.15625, 1, 4, 2, "-5/32"},
+	{0xa3, -0.171875, 1, 4, 3, "-11/64"},
+	{0xa4, -0.1875, 1, 4, 4, "-3/16"},
+	{0xa5, -0.203125, 1, 4, 5, "-13/64"},
+	{0xa6, -0.21875, 1, 4, 6, "-7/32"},
+	{0xa7, -0.234375, 1, 4, 7, "-15/64"},
+	{0xa8, -0.25, 1, 5, 0, "-1/4"},
+	{0xa9, -0.28125, 1, 5, 1, "-9/32"},
+	{0xaa, -0.3125, 1, 5, 2, "-5/16"},
+	{0xab, -0.34375, 1, 5, 3, "-11/32"},
+	{0xac, -0.375, 1, 5, 4, "-3/8"},
+	{0xad, -0.40625, 1, 5, 5, "-13/32"},
+	{0xae, -0.4375, 1, 5, 6, "-7/16"},
+	{0xaf, -0.46875, 1, 5, 7, "-15/32"},
+	{0xb0, -0.5, 1, 6, 0, "-1/2"},
+	{0xb1, -0.5625, 1, 6, 1, "-9/16"},
+	{0xb2, -0.625, 1, 6, 2, "-5/8"},
+	{0xb3, -0.6875, 1, 6, 3, "-11/16"},
+	{0xb4, -0.75, 1, 6, 4, "-3/4"},
+	{0xb5, -0.8125, 1, 6, 5, "-13/16"},
+	{0xb6, -0.875, 1, 6, 6, "-7/8"},
+	{0xb7, -0.9375, 1, 6, 7, "-15/16"},
+	{0xb8, -1, 1, 7, 0, "-1"},
+	{0xb9, -1.125, 1, 7, 1, "-9/8"},
+	{0xba, -1.25, 1, 7, 2, "-5/4"},
+	{0xbb, -1.375, 1, 7, 3, "-11/8"},
+	{0xbc, -1.5, 1, 7, 4, "-3/2"},
+	{0xbd, -1.625, 1, 7, 5, "-13/8"},
+	{0xbe, -1.75, 1, 7, 6, "-7/4"},
+	{0xbf, -1.875, 1, 7, 7, "-15/8"},
+	{0xc0, -2, 1, 8, 0, "-2"},
+	{0xc1, -2.25, 1, 8, 1, "-9/4"},
+	{0xc2, -2.5, 1, 8, 2, "-5/2"},
+	{0xc3, -2.75, 1, 8, 3, "-11/4"},
+	{0xc4, -3, 1, 8, 4, "-3"},
+	{0xc5, -3.25, 1, 8, 5, "-13/4"},
+	{0xc6, -3.5, 1, 8, 6, "-7/2"},
+	{0xc7, -3.75, 1, 8, 7, "-15/4"},
+	{0xc8, -4, 1, 9, 0, "-4"},
+	{0xc9, -4.5, 1, 9, 1, "-9/2"},
+	{0xca, -5, 1, 9, 2, "-5"},
+	{0xcb, -5.5, 1, 9, 3, "-11/2"},
+	{0xcc, -6, 1, 9, 4, "-6"},
+	{0xcd, -6.5, 1, 9, 5, "-13/2"},
+	{0xce, -7, 1, 9, 6, "-7"},
+	{0xcf, -7.5, 1, 9, 7, "-15/2"},
+	{0xd0, -8, 1, 10, 0, "-8"},
+	{0xd1, -9, 1, 10, 1, "-9"},
+	{0xd2, -10, 1, 10, 2, "-10"},
+	{0xd3, -11, 1, 10, 3, "-11"},
+	{0xd4, -12, 1, 10, 4, "-12"},
+	{0xd5, -13, 1, 10, 5, "-13"},
+	{0xd6, -14, 1, 10, 6, "-14"},
+	{0xd7, -15, 1, 10, 7, "-15"},
+	{0xd8, -16, 1, 11, 0, "-16"},
+	{0xd9, -18, 1, 11, 1, "-18"},
+	{0xda, -20, 1, 11, 2, "-20"},
+	{0xdb, -22, 1, 11, 3, "-22"},
+	{0xdc, -24, 1, 11, 4, "-24"},
+	{0xdd, -26, 1, 11, 5, "-26"},
+	{0xde, -28, 1, 11, 6, "-28"},
+	{0xdf, -30, 1, 11, 7, "-30"},
+	{0xe0, -32, 1, 12, 0, "-32"},
+	{0xe1, -36, 1, 12, 1, "-36"},
+	{0xe2, -40, 1, 12, 2, "-40"},
+	{0xe3, -44, 1, 12, 3, "-44"},
+	{0xe4, -48, 1, 12, 4, "-48"},
+	{0xe5, -52, 1, 12, 5, "-52"},
+	{0xe6, -56, 1, 12, 6, "-56"},
+	{0xe7, -60, 1, 12, 7, "-60"},
+	{0xe8, -64, 1, 13, 0, "-64"},
+	{0xe9, -72, 1, 13, 1, "-72"},
+	{0xea, -80, 1, 13, 2, "-80"},
+	{0xeb, -88, 1, 13, 3, "-88"},
+	{0xec, -96, 1, 13, 4, "-96"},
+	{0xed, -104, 1, 13, 5, "-104"},
+	{0xee, -112, 1, 13, 6, "-112"},
+	{0xef, -120, 1, 13, 7, "-120"},
+	{0xf0, -128, 1, 14, 0, "-128"},
+	{0xf1, -144, 1, 14, 1, "-144"},
+	{0xf2, -160, 1, 14, 2, "-160"},
+	{0xf3, -176, 1, 14, 3, "-176"},
+	{0xf4, -192, 1, 14, 4, "-192"},
+	{0xf5, -208, 1, 14, 5, "-208"},
+	{0xf6, -224, 1, 14, 6, "-224"},
+	{0xf7, -240, 1, 14, 7, "-240"},
+	{0xf8, -256, 1, 15, 0, "-256"},
+	{0xf9, -288, 1, 15, 1, "-288"},
+	{0xfa, -320, 1, 15, 2, "-320"},
+	{0xfb, -352, 1, 15, 3, "-352"},
+	{0xfc, -384, 1, 15, 4, "-384"},
+	{0xfd, -416, 1, 15, 5, "-416"},
+	{0xfe, -448, 1, 15, 6, "-448"},
+	{0xff, float32(math.NaN()), 1, 15, 7, "NaN"},
 }
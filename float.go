@@ -0,0 +1,75 @@
+// Copyright 2024 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package floatx
+
+import (
+	"math"
+	"math/big"
+)
+
+// Float is the constraint satisfied by the floating point types of this
+// package. It enables writing kernels once for all the formats:
+//
+//	func Sum[T floatx.Float](s []T) float32 {
+//		var sum float32
+//		for _, v := range s {
+//			sum += v.Float32()
+//		}
+//		return sum
+//	}
+//
+// The Components methods return a mantissa of the width of each type. Use the
+// Components function to get them generically.
+type Float interface {
+	F32 | BF16 | F16 | F8E4M3 | F8E4M3Fn | F8E5M2
+	// Bits returns the encoding in the least significant bits.
+	Bits() uint32
+	// Float32 returns the float32 equivalent.
+	Float32() float32
+	// BigFloat returns the exact value, or nil for NaN.
+	BigFloat() *big.Float
+	// Rat returns the exact value, or nil for NaN and infinities.
+	Rat() *big.Rat
+}
+
+// FromFloat32 returns the T nearest to f, rounding ties to even, like the
+// XFromFloat32 functions.
+func FromFloat32[T Float](f float32) T {
+	var z T
+	dst, _ := toBits(z)
+	return FromBits[T](dst.fromFloat64(float64(f)))
+}
+
+// FromBits returns the T encoded by the least significant bits of bits.
+//
+// It is the reverse of Bits.
+func FromBits[T Float](bits uint32) T {
+	var v any
+	var z T
+	switch any(z).(type) {
+	case F32:
+		v = F32(math.Float32frombits(bits))
+	case BF16:
+		v = BF16(bits)
+	case F16:
+		v = F16(bits)
+	case F8E4M3:
+		v = F8E4M3(bits)
+	case F8E4M3Fn:
+		v = F8E4M3Fn(bits)
+	default:
+		v = F8E5M2(bits)
+	}
+	return v.(T)
+}
+
+// Components returns the sign, exponent and mantissa bits of v separated, like
+// the Components methods.
+func Components[T Float](v T) (sign, exponent uint8, mantissa uint32) {
+	f, bits := toBits(v)
+	sign = uint8(bits >> (f.exponentBits + f.mantissaBits))
+	exponent = uint8((bits & f.exponentMask()) >> f.mantissaBits)
+	return sign, exponent, bits & f.mantissaMask()
+}
@@ -0,0 +1,92 @@
+// Copyright 2024 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package floatx_test
+
+import (
+	"math"
+	"math/bits"
+	"slices"
+	"testing"
+
+	"github.com/maruel/floatx"
+)
+
+func Test_Float_All(t *testing.T) {
+	t.Run("BF16", func(t *testing.T) {
+		testFloat(t, slices.Collect(floatx.All[floatx.BF16]()), floatx.BF16FromFloat32, func(v floatx.BF16) uint32 {
+			_, _, m := v.Components()
+			return uint32(m)
+		})
+	})
+	t.Run("F16", func(t *testing.T) {
+		testFloat(t, slices.Collect(floatx.All[floatx.F16]()), floatx.F16FromFloat32, func(v floatx.F16) uint32 {
+			_, _, m := v.Components()
+			return uint32(m)
+		})
+	})
+	t.Run("F8E4M3", func(t *testing.T) {
+		testFloat(t, slices.Collect(floatx.All[floatx.F8E4M3]()), floatx.F8E4M3FromFloat32, func(v floatx.F8E4M3) uint32 {
+			_, _, m := v.Components()
+			return uint32(m)
+		})
+	})
+	t.Run("F8E4M3Fn", func(t *testing.T) {
+		testFloat(t, slices.Collect(floatx.All[floatx.F8E4M3Fn]()), floatx.F8E4M3FnFromFloat32, func(v floatx.F8E4M3Fn) uint32 {
+			_, _, m := v.Components()
+			return uint32(m)
+		})
+	})
+	t.Run("F8E5M2", func(t *testing.T) {
+		testFloat(t, slices.Collect(floatx.All[floatx.F8E5M2]()), floatx.F8E5M2FromFloat32, func(v floatx.F8E5M2) uint32 {
+			_, _, m := v.Components()
+			return uint32(m)
+		})
+	})
+}
+
+func Test_Float_F32(t *testing.T) {
+	for _, f := range []float32{0, -1, 1.5, float32(math.Inf(-1)), math.SmallestNonzeroFloat32} {
+		v := floatx.F32(f)
+		if v.Float32() != f || v.Bits() != math.Float32bits(f) || floatx.FromBits[floatx.F32](v.Bits()) != v || floatx.FromFloat32[floatx.F32](f) != v {
+			t.Fatal(f)
+		}
+		s, e, m := v.Components()
+		if gs, ge, gm := floatx.Components(v); gs != s || ge != e || gm != m {
+			t.Fatal(f)
+		}
+	}
+}
+
+// testFloat verifies the generic functions against the type specific ones.
+func testFloat[T interface {
+	~uint8 | ~uint16
+	floatx.Float
+}](t *testing.T, values []T, fromFloat32 func(float32) T, mantissa func(T) uint32) {
+	for _, v := range values {
+		if got := v.Bits(); got != uint32(v) {
+			t.Fatalf("Bits(%#x) = %#x", v, got)
+		}
+		if got := floatx.FromBits[T](uint32(v)); got != v {
+			t.Fatalf("FromBits(%#x) = %#x", v, got)
+		}
+		f := v.Float32()
+		if got, want := floatx.FromFloat32[T](f), fromFloat32(f); got != want {
+			t.Fatalf("FromFloat32(%g) = %#x; want %#x", f, got, want)
+		}
+		sign, exponent, m := floatx.Components(v)
+		want := floatx.FromBits[T](uint32(sign)<<(8*sizeof(v)-1) | uint32(exponent)<<bits.Len32(mantissa(^T(0))) | m)
+		if want != v || m != mantissa(v) {
+			t.Fatalf("Components(%#x) = %d, %d, %#x", v, sign, exponent, m)
+		}
+	}
+}
+
+func Benchmark_FromFloat32_BF16(b *testing.B) {
+	var dummy floatx.BF16
+	for i := range b.N {
+		dummy += floatx.FromFloat32[floatx.BF16](float32(i))
+	}
+	benchmarkResultBF16 = dummy
+}
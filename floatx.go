@@ -5,6 +5,32 @@
 //go:generate go run gen.go
 
 // Package floatx implements various floating encodings with 100% code coverage.
+//
+// # Text encoding
+//
+// The MarshalText and MarshalJSON methods write the shortest decimal
+// representation that parses back to the same value. NaN is written as "NaN"
+// or "-NaN": the sign is kept but the payload is not, so UnmarshalText reads
+// it back as the quiet NaN of the same sign. Use MarshalBinary to keep the
+// exact bits.
+//
+// UnmarshalText accepts decimal numbers like "-1.5e3", "Inf" and "NaN", each
+// with an optional sign. Hexadecimal values, underscores and other spellings
+// accepted by strconv.ParseFloat are rejected.
+//
+// # Ordering
+//
+// Compare, SortSlice and Ordered follow the IEEE 754 totalOrder:
+//
+//	-NaN < -Inf < negative values < -0 < +0 < positive values < +Inf < +NaN
+//
+// The NaNs of the same sign are ordered by their payload. F8E4M3Fn has no
+// infinity and a single NaN per sign, so its order is
+// -NaN < -448 < … < -0 < +0 < … < 448 < +NaN.
+//
+// Less and Equal follow the IEEE 754 comparisons instead, like < and == on
+// float32: -0 equals +0 and NaN is neither less than nor equal to anything,
+// including itself. Less is consistent with Equal.
 package floatx
 
 import (
@@ -26,11 +52,22 @@ const (
 
 // F32 is a float32.
 //
-// The only use case is to call Components() on it.
+// The only use case is to call Components(), the representable neighbour
+// methods like NextUp() or the NaN predicates on it.
 //
 // https://en.wikipedia.org/wiki/Single-precision_floating-point_format
 type F32 float32
 
+// Bits returns the IEEE 754 encoding.
+func (f F32) Bits() uint32 {
+	return math.Float32bits(float32(f))
+}
+
+// Float32 returns f as a float32.
+func (f F32) Float32() float32 {
+	return float32(f)
+}
+
 // Components returns the sign, exponent and mantissa bits separated.
 func (f F32) Components() (uint8, uint8, uint32) {
 	b := math.Float32bits(float32(f))
@@ -40,6 +77,48 @@ func (f F32) Components() (uint8, uint8, uint32) {
 	return uint8(sign), uint8(exponent), uint32(mantissa)
 }
 
+// NextUp returns the smallest F32 larger than f.
+//
+// The value after both zeros is the smallest positive subnormal and the value
+// after the largest finite value is +Inf. NaN and +Inf are returned unchanged.
+func (f F32) NextUp() F32 {
+	return F32(math.Float32frombits(f32Format.nextUp(math.Float32bits(float32(f)))))
+}
+
+// NextDown returns the largest F32 smaller than f.
+//
+// The value before both zeros is the smallest negative subnormal and the value
+// before the smallest finite value is -Inf. NaN and -Inf are returned
+// unchanged.
+func (f F32) NextDown() F32 {
+	return F32(math.Float32frombits(f32Format.nextDown(math.Float32bits(float32(f)))))
+}
+
+// NextAfter returns the next F32 after f toward to.
+//
+// f is returned when both are equal, including -0 and +0, and NaN if either
+// is NaN.
+func (f F32) NextAfter(to F32) F32 {
+	return F32(math.Float32frombits(f32Format.nextAfter(math.Float32bits(float32(f)), math.Float32bits(float32(to)))))
+}
+
+// ULP returns the unit in the last place of f, the spacing between the
+// values with the same exponent.
+//
+// It is the smallest positive subnormal for zeros and subnormals, +Inf for
+// infinities and NaN for NaN.
+func (f F32) ULP() float32 {
+	return float32(f32Format.ulp(math.Float32bits(float32(f))))
+}
+
+// ULPDistance returns how many calls to NextUp or NextDown go from f to o.
+//
+// -0 and +0 are 0 apart. It returns 0 if both are NaN and math.MaxUint32 if
+// only one is.
+func (f F32) ULPDistance(o F32) uint32 {
+	return f32Format.ulpDistance(math.Float32bits(float32(f)), math.Float32bits(float32(o)))
+}
+
 // BF16
 
 // BF16 bit allocation.
@@ -64,6 +143,44 @@ func DecodeBF16(b []byte) BF16 {
 	return BF16(binary.LittleEndian.Uint16(b))
 }
 
+// DecodeBF16Order decodes a value stored in the specified byte order, e.g.
+// binary.BigEndian.
+func DecodeBF16Order(order binary.ByteOrder, b []byte) BF16 {
+	return BF16(order.Uint16(b))
+}
+
+// PutBF16 encodes a little endian value. It is the reverse of DecodeBF16.
+func PutBF16(b []byte, v BF16) {
+	binary.LittleEndian.PutUint16(b, uint16(v))
+}
+
+// PutBF16Order encodes a value in the specified byte order.
+func PutBF16Order(order binary.ByteOrder, b []byte, v BF16) {
+	order.PutUint16(b, uint16(v))
+}
+
+// AppendBF16 appends a little endian value.
+func AppendBF16(b []byte, v BF16) []byte {
+	return binary.LittleEndian.AppendUint16(b, uint16(v))
+}
+
+// AppendBF16Order appends a value in the specified byte order.
+func AppendBF16Order(order binary.AppendByteOrder, b []byte, v BF16) []byte {
+	return order.AppendUint16(b, uint16(v))
+}
+
+// BF16FromFloat32 returns the BF16 nearest to f, rounding ties to even.
+//
+// Values too large to be represented become infinity.
+func BF16FromFloat32(f float32) BF16 {
+	return BF16(bf16Format.fromFloat64(float64(f)))
+}
+
+// Bits returns the encoding.
+func (b BF16) Bits() uint32 {
+	return uint32(b)
+}
+
 // Components returns the sign, exponent and mantissa bits separated.
 func (b BF16) Components() (uint8, uint8, uint8) {
 	sign := b >> BF16SignOffset
@@ -86,24 +203,54 @@ func (b BF16) Float32() float32 {
 		// https://en.wikipedia.org/wiki/Bfloat16_floating-point_format#Not_a_Number
 		return math.Float32frombits(sign | (F32ExponentMask << F32ExponentOffset) | mantissa)
 	}
-	// If no exponent.
-	if exponent == 0 {
-		if mantissa == 0 {
-			return math.Float32frombits(sign)
-		}
-		// Normalize subnormal numbers.
-		// https://en.wikipedia.org/wiki/Bfloat16_floating-point_format#Exponent_encoding
-		exponent++
-		for mantissa&(BF16ExponentMask<<F32ExponentOffset) == 0 {
-			mantissa <<= 1
-			exponent--
-		}
-		mantissa &= F32MantissaMask
-	}
-	exponent += F32ExponentBias - BF16ExponentBias
+	// bfloat16 has the same exponent bias as float32 so subnormal numbers
+	// stay subnormal and only the mantissa needs to be realigned.
+	// https://en.wikipedia.org/wiki/Bfloat16_floating-point_format#Exponent_encoding
 	return math.Float32frombits(sign | (exponent << F32ExponentOffset) | mantissa)
 }
 
+// NextUp returns the smallest BF16 larger than b.
+//
+// The value after both zeros is the smallest positive subnormal and the value
+// after the largest finite value is +Inf. NaN and +Inf are returned unchanged.
+func (b BF16) NextUp() BF16 {
+	return BF16(bf16Format.nextUp(uint32(b)))
+}
+
+// NextDown returns the largest BF16 smaller than b.
+//
+// The value before both zeros is the smallest negative subnormal and the value
+// before the smallest finite value is -Inf. NaN and -Inf are returned
+// unchanged.
+func (b BF16) NextDown() BF16 {
+	return BF16(bf16Format.nextDown(uint32(b)))
+}
+
+// NextAfter returns the next BF16 after b toward to.
+//
+// b is returned when both are equal, including -0 and +0, and NaN if either
+// is NaN.
+func (b BF16) NextAfter(to BF16) BF16 {
+	return BF16(bf16Format.nextAfter(uint32(b), uint32(to)))
+}
+
+// ULP returns the unit in the last place of b, the spacing between the
+// values with the same exponent.
+//
+// It is the smallest positive subnormal for zeros and subnormals, +Inf for
+// infinities and NaN for NaN.
+func (b BF16) ULP() float32 {
+	return float32(bf16Format.ulp(uint32(b)))
+}
+
+// ULPDistance returns how many calls to NextUp or NextDown go from b to o.
+//
+// -0 and +0 are 0 apart. It returns 0 if both are NaN and math.MaxUint32 if
+// only one is.
+func (b BF16) ULPDistance(o BF16) uint32 {
+	return bf16Format.ulpDistance(uint32(b), uint32(o))
+}
+
 // F16
 
 // F16 bit allocation.
@@ -127,6 +274,44 @@ func DecodeF16(b []byte) F16 {
 	return F16(binary.LittleEndian.Uint16(b))
 }
 
+// DecodeF16Order decodes a value stored in the specified byte order, e.g.
+// binary.BigEndian.
+func DecodeF16Order(order binary.ByteOrder, b []byte) F16 {
+	return F16(order.Uint16(b))
+}
+
+// PutF16 encodes a little endian value. It is the reverse of DecodeF16.
+func PutF16(b []byte, v F16) {
+	binary.LittleEndian.PutUint16(b, uint16(v))
+}
+
+// PutF16Order encodes a value in the specified byte order.
+func PutF16Order(order binary.ByteOrder, b []byte, v F16) {
+	order.PutUint16(b, uint16(v))
+}
+
+// AppendF16 appends a little endian value.
+func AppendF16(b []byte, v F16) []byte {
+	return binary.LittleEndian.AppendUint16(b, uint16(v))
+}
+
+// AppendF16Order appends a value in the specified byte order.
+func AppendF16Order(order binary.AppendByteOrder, b []byte, v F16) []byte {
+	return order.AppendUint16(b, uint16(v))
+}
+
+// F16FromFloat32 returns the F16 nearest to f, rounding ties to even.
+//
+// Values too large to be represented become infinity.
+func F16FromFloat32(f float32) F16 {
+	return F16(f16Format.fromFloat64(float64(f)))
+}
+
+// Bits returns the encoding.
+func (f F16) Bits() uint32 {
+	return uint32(f)
+}
+
 // Components returns the sign, exponent and mantissa bits separated.
 func (f F16) Components() (uint8, uint8, uint16) {
 	sign := f >> F16SignOffset
@@ -165,6 +350,48 @@ func (f F16) Float32() float32 {
 	return math.Float32frombits(sign | (exponent << F32ExponentOffset) | mantissa)
 }
 
+// NextUp returns the smallest F16 larger than f.
+//
+// The value after both zeros is the smallest positive subnormal and the value
+// after the largest finite value is +Inf. NaN and +Inf are returned unchanged.
+func (f F16) NextUp() F16 {
+	return F16(f16Format.nextUp(uint32(f)))
+}
+
+// NextDown returns the largest F16 smaller than f.
+//
+// The value before both zeros is the smallest negative subnormal and the value
+// before the smallest finite value is -Inf. NaN and -Inf are returned
+// unchanged.
+func (f F16) NextDown() F16 {
+	return F16(f16Format.nextDown(uint32(f)))
+}
+
+// NextAfter returns the next F16 after f toward to.
+//
+// f is returned when both are equal, including -0 and +0, and NaN if either
+// is NaN.
+func (f F16) NextAfter(to F16) F16 {
+	return F16(f16Format.nextAfter(uint32(f), uint32(to)))
+}
+
+// ULP returns the unit in the last place of f, the spacing between the
+// values with the same exponent.
+//
+// It is the smallest positive subnormal for zeros and subnormals, +Inf for
+// infinities and NaN for NaN.
+func (f F16) ULP() float32 {
+	return float32(f16Format.ulp(uint32(f)))
+}
+
+// ULPDistance returns how many calls to NextUp or NextDown go from f to o.
+//
+// -0 and +0 are 0 apart. It returns 0 if both are NaN and math.MaxUint32 if
+// only one is.
+func (f F16) ULPDistance(o F16) uint32 {
+	return f16Format.ulpDistance(uint32(f), uint32(o))
+}
+
 // F8E4M3
 
 // F8E4M3 and F8E4M3Fn bit allocation.
@@ -185,6 +412,18 @@ const (
 // See https://en.wikipedia.org/wiki/Minifloat
 type F8E4M3 uint8
 
+// F8E4M3FromFloat32 returns the F8E4M3 nearest to f, rounding ties to even.
+//
+// Values too large to be represented become infinity.
+func F8E4M3FromFloat32(f float32) F8E4M3 {
+	return F8E4M3(f8e4m3Format.fromFloat64(float64(f)))
+}
+
+// Bits returns the encoding.
+func (f F8E4M3) Bits() uint32 {
+	return uint32(f)
+}
+
 // Components returns the sign, exponent and mantissa bits separated.
 func (f F8E4M3) Components() (uint8, uint8, uint8) {
 	sign := f >> F8E4M3SignOffset
@@ -222,6 +461,48 @@ func (f F8E4M3) Float32() float32 {
 	return math.Float32frombits(sign | (exponent << F32ExponentOffset) | mantissa)
 }
 
+// NextUp returns the smallest F8E4M3 larger than f.
+//
+// The value after both zeros is the smallest positive subnormal and the value
+// after the largest finite value is +Inf. NaN and +Inf are returned unchanged.
+func (f F8E4M3) NextUp() F8E4M3 {
+	return F8E4M3(f8e4m3Format.nextUp(uint32(f)))
+}
+
+// NextDown returns the largest F8E4M3 smaller than f.
+//
+// The value before both zeros is the smallest negative subnormal and the value
+// before the smallest finite value is -Inf. NaN and -Inf are returned
+// unchanged.
+func (f F8E4M3) NextDown() F8E4M3 {
+	return F8E4M3(f8e4m3Format.nextDown(uint32(f)))
+}
+
+// NextAfter returns the next F8E4M3 after f toward to.
+//
+// f is returned when both are equal, including -0 and +0, and NaN if either
+// is NaN.
+func (f F8E4M3) NextAfter(to F8E4M3) F8E4M3 {
+	return F8E4M3(f8e4m3Format.nextAfter(uint32(f), uint32(to)))
+}
+
+// ULP returns the unit in the last place of f, the spacing between the
+// values with the same exponent.
+//
+// It is the smallest positive subnormal for zeros and subnormals, +Inf for
+// infinities and NaN for NaN.
+func (f F8E4M3) ULP() float32 {
+	return float32(f8e4m3Format.ulp(uint32(f)))
+}
+
+// ULPDistance returns how many calls to NextUp or NextDown go from f to o.
+//
+// -0 and +0 are 0 apart. It returns 0 if both are NaN and math.MaxUint32 if
+// only one is.
+func (f F8E4M3) ULPDistance(o F8E4M3) uint32 {
+	return f8e4m3Format.ulpDistance(uint32(f), uint32(o))
+}
+
 // F8E4M3Fn
 
 // F8E4M3Fn represents a float8 with 4 exponent bits and 3 mantissa bits.
@@ -231,6 +512,19 @@ func (f F8E4M3) Float32() float32 {
 // See https://github.com/jax-ml/ml_dtypes#float8_e4m3fn
 type F8E4M3Fn uint8
 
+// F8E4M3FnFromFloat32 returns the F8E4M3Fn nearest to f, rounding ties to
+// even.
+//
+// Values too large to be represented, including infinities, become NaN.
+func F8E4M3FnFromFloat32(f float32) F8E4M3Fn {
+	return F8E4M3Fn(f8e4m3fnFormat.fromFloat64(float64(f)))
+}
+
+// Bits returns the encoding.
+func (f F8E4M3Fn) Bits() uint32 {
+	return uint32(f)
+}
+
 // Components returns the sign, exponent and mantissa bits separated.
 func (f F8E4M3Fn) Components() (uint8, uint8, uint8) {
 	sign := f >> F8E4M3SignOffset
@@ -248,8 +542,8 @@ func (f F8E4M3Fn) Float32() float32 {
 	// Realign mantissa right away. The fraction is 3 bits in float8 E4M3 and 23 bits in float32.
 	mantissa := uint32(mantissa8) << (F32ExponentOffset - F8E4M3ExponentOffset)
 	if f == 0x7F || f == 0xFF {
-		// Positive and negative NaN
-		return float32(math.NaN())
+		// Positive and negative quiet NaN without payload.
+		return math.Float32frombits(sign | (F32ExponentMask << F32ExponentOffset) | 1<<(F32ExponentOffset-1))
 	}
 	// If no exponent.
 	if exponent == 0 {
@@ -268,6 +562,47 @@ func (f F8E4M3Fn) Float32() float32 {
 	return math.Float32frombits(sign | (exponent << F32ExponentOffset) | mantissa)
 }
 
+// NextUp returns the smallest F8E4M3Fn larger than f.
+//
+// The value after both zeros is the smallest positive subnormal. NaN and the
+// largest finite value are returned unchanged since there is no infinity.
+func (f F8E4M3Fn) NextUp() F8E4M3Fn {
+	return F8E4M3Fn(f8e4m3fnFormat.nextUp(uint32(f)))
+}
+
+// NextDown returns the largest F8E4M3Fn smaller than f.
+//
+// The value before both zeros is the smallest negative subnormal. NaN and the
+// smallest finite value are returned unchanged since there is no infinity.
+func (f F8E4M3Fn) NextDown() F8E4M3Fn {
+	return F8E4M3Fn(f8e4m3fnFormat.nextDown(uint32(f)))
+}
+
+// NextAfter returns the next F8E4M3Fn after f toward to.
+//
+// f is returned when both are equal, including -0 and +0, and NaN if either
+// is NaN.
+func (f F8E4M3Fn) NextAfter(to F8E4M3Fn) F8E4M3Fn {
+	return F8E4M3Fn(f8e4m3fnFormat.nextAfter(uint32(f), uint32(to)))
+}
+
+// ULP returns the unit in the last place of f, the spacing between the
+// values with the same exponent.
+//
+// It is the smallest positive subnormal for zeros and subnormals, +Inf for
+// infinities and NaN for NaN.
+func (f F8E4M3Fn) ULP() float32 {
+	return float32(f8e4m3fnFormat.ulp(uint32(f)))
+}
+
+// ULPDistance returns how many calls to NextUp or NextDown go from f to o.
+//
+// -0 and +0 are 0 apart. It returns 0 if both are NaN and math.MaxUint32 if
+// only one is.
+func (f F8E4M3Fn) ULPDistance(o F8E4M3Fn) uint32 {
+	return f8e4m3fnFormat.ulpDistance(uint32(f), uint32(o))
+}
+
 // F8E5M2
 
 // F8E5M2 bit allocation.
@@ -286,6 +621,18 @@ const (
 // See https://docs.nvidia.com/deeplearning/transformer-engine/user-guide/examples/fp8_primer.html
 type F8E5M2 uint8
 
+// F8E5M2FromFloat32 returns the F8E5M2 nearest to f, rounding ties to even.
+//
+// Values too large to be represented become infinity.
+func F8E5M2FromFloat32(f float32) F8E5M2 {
+	return F8E5M2(f8e5m2Format.fromFloat64(float64(f)))
+}
+
+// Bits returns the encoding.
+func (f F8E5M2) Bits() uint32 {
+	return uint32(f)
+}
+
 // Components returns the sign, exponent and mantissa bits separated.
 func (f F8E5M2) Components() (uint8, uint8, uint8) {
 	sign := f >> F8E5M2SignOffset
@@ -322,3 +669,45 @@ func (f F8E5M2) Float32() float32 {
 	exponent += F32ExponentBias - F8E5M2ExponentBias
 	return math.Float32frombits(sign | (exponent << F32ExponentOffset) | mantissa)
 }
+
+// NextUp returns the smallest F8E5M2 larger than f.
+//
+// The value after both zeros is the smallest positive subnormal and the value
+// after the largest finite value is +Inf. NaN and +Inf are returned unchanged.
+func (f F8E5M2) NextUp() F8E5M2 {
+	return F8E5M2(f8e5m2Format.nextUp(uint32(f)))
+}
+
+// NextDown returns the largest F8E5M2 smaller than f.
+//
+// The value before both zeros is the smallest negative subnormal and the value
+// before the smallest finite value is -Inf. NaN and -Inf are returned
+// unchanged.
+func (f F8E5M2) NextDown() F8E5M2 {
+	return F8E5M2(f8e5m2Format.nextDown(uint32(f)))
+}
+
+// NextAfter returns the next F8E5M2 after f toward to.
+//
+// f is returned when both are equal, including -0 and +0, and NaN if either
+// is NaN.
+func (f F8E5M2) NextAfter(to F8E5M2) F8E5M2 {
+	return F8E5M2(f8e5m2Format.nextAfter(uint32(f), uint32(to)))
+}
+
+// ULP returns the unit in the last place of f, the spacing between the
+// values with the same exponent.
+//
+// It is the smallest positive subnormal for zeros and subnormals, +Inf for
+// infinities and NaN for NaN.
+func (f F8E5M2) ULP() float32 {
+	return float32(f8e5m2Format.ulp(uint32(f)))
+}
+
+// ULPDistance returns how many calls to NextUp or NextDown go from f to o.
+//
+// -0 and +0 are 0 apart. It returns 0 if both are NaN and math.MaxUint32 if
+// only one is.
+func (f F8E5M2) ULPDistance(o F8E5M2) uint32 {
+	return f8e5m2Format.ulpDistance(uint32(f), uint32(o))
+}
@@ -5,8 +5,11 @@
 package floatx_test
 
 import (
+	"bytes"
+	"encoding/binary"
 	"fmt"
 	"math"
+	"slices"
 	"testing"
 
 	"github.com/maruel/floatx"
@@ -18,30 +21,32 @@ type testData struct {
 	Sign     uint8
 	Exponent uint8
 	Mantissa uint32
+	// Exact is the exact value as a rational, "-0", "+Inf", "-Inf" or "NaN".
+	Exact string
 }
 
 func Test_F32_SpotCheck(t *testing.T) {
 	// Spot check a few values to not take any chance.
 	data := []testData{
-		{0x00000000, 0., 0, 0, 0},
-		{0x80000000, -0., 1, 0, 0},
-		{0x3F800000, 1., 0, 127, 0},
-		{0x3F800001, 1.00000011920928955, 0, 127, 1},
-		{0x41C80000, 25., 0, 131, 0x480000},
-		{0x00000001, 1.4012984643e-45, 0, 0, 1},
-		{0x007FFFFF, 1.1754942107e-38, 0, 0, 0x7FFFFF},
-		{0x7F7FFFFF, 3.4028234664e38, 0, 254, 0x7FFFFF},
-		{0x7F800000, float32(math.Inf(0)), 0, 255, 0},
-		{0x7F800001, float32(math.NaN()), 0, 255, 1},
-		{0xFF7FFFFF, -3.4028234664e38, 1, 254, 0x7FFFFF},
-		{0xFF800000, float32(math.Inf(-1)), 1, 255, 0},
-		{0xFF800001, float32(math.NaN()), 1, 255, 1},
-		{0xFFFFFFFF, float32(math.NaN()), 1, 0xFF, 0x7FFFFF},
+		{0x00000000, 0., 0, 0, 0, "0"},
+		{0x80000000, -0., 1, 0, 0, "-0"},
+		{0x3F800000, 1., 0, 127, 0, "1"},
+		{0x3F800001, 1.00000011920928955, 0, 127, 1, "8388609/8388608"},
+		{0x41C80000, 25., 0, 131, 0x480000, "25"},
+		{0x00000001, 1.4012984643e-45, 0, 0, 1, "1/713623846352979940529142984724747568191373312"},
+		{0x007FFFFF, 1.1754942107e-38, 0, 0, 0x7FFFFF, "8388607/713623846352979940529142984724747568191373312"},
+		{0x7F7FFFFF, 3.4028234664e38, 0, 254, 0x7FFFFF, "340282346638528859811704183484516925440"},
+		{0x7F800000, float32(math.Inf(0)), 0, 255, 0, "+Inf"},
+		{0x7F800001, float32(math.NaN()), 0, 255, 1, "NaN"},
+		{0xFF7FFFFF, -3.4028234664e38, 1, 254, 0x7FFFFF, "-340282346638528859811704183484516925440"},
+		{0xFF800000, float32(math.Inf(-1)), 1, 255, 0, "-Inf"},
+		{0xFF800001, float32(math.NaN()), 1, 255, 1, "NaN"},
+		{0xFFFFFFFF, float32(math.NaN()), 1, 0xFF, 0x7FFFFF, "NaN"},
 	}
 	for i, line := range data {
 		t.Run(fmt.Sprintf("#%d: %g", i, line.F), func(t *testing.T) {
 			f := floatx.F32(math.Float32frombits(line.V))
-			testOne32(t, f, line)
+			testOne(t, f, line)
 			if got := float32(f); got != line.F {
 				if !math.IsNaN(float64(got)) && !math.IsNaN(float64(line.F)) {
 					t.Errorf("%g != %g", got, line.F)
@@ -54,11 +59,11 @@ func Test_F32_SpotCheck(t *testing.T) {
 func Test_BF16_All(t *testing.T) {
 	for i, line := range bf16TestData {
 		t.Run(fmt.Sprintf("#%d: %g", i, line.F), func(t *testing.T) {
-			if i == 32 {
-				t.Skip("bug in denormalized value for bfloat32")
-			}
 			f := floatx.BF16(line.V)
-			testOne8(t, f, line)
+			testOne(t, f, line)
+			if got := floatx.BF16FromFloat32(f.Float32()); got != f && !math.IsNaN(float64(line.F)) {
+				t.Errorf("%v != %v", got, f)
+			}
 			// little endian forever.
 			b := [2]byte{byte(line.V), byte(line.V >> 8)}
 			if got := floatx.DecodeBF16(b[:]); got != f {
@@ -99,7 +104,10 @@ func Test_F16_All(t *testing.T) {
 	for i, line := range f16TestData {
 		t.Run(fmt.Sprintf("#%d: %g", i, line.F), func(t *testing.T) {
 			f := floatx.F16(line.V)
-			testOne16(t, f, line)
+			testOne(t, f, line)
+			if got := floatx.F16FromFloat32(f.Float32()); got != f && !math.IsNaN(float64(line.F)) {
+				t.Errorf("%v != %v", got, f)
+			}
 			// little endian forever.
 			b := [2]byte{byte(line.V), byte(line.V >> 8)}
 			if got := floatx.DecodeF16(b[:]); got != f {
@@ -109,6 +117,36 @@ func Test_F16_All(t *testing.T) {
 	}
 }
 
+func Test_BF16_ByteOrder(t *testing.T) {
+	f := floatx.BF16(0x3F80)
+	le := floatx.AppendBF16(nil, f)
+	if want := []byte{0x80, 0x3F}; !bytes.Equal(le, want) {
+		t.Fatalf("%x != %x", le, want)
+	}
+	be := floatx.AppendBF16Order(binary.BigEndian, []byte{0xFF}, f)
+	if want := []byte{0xFF, 0x3F, 0x80}; !bytes.Equal(be, want) {
+		t.Fatalf("%x != %x", be, want)
+	}
+	if got := floatx.DecodeBF16Order(binary.BigEndian, be[1:]); got != f {
+		t.Errorf("%v != %v", got, f)
+	}
+	if got := floatx.DecodeBF16Order(binary.LittleEndian, le); got != f {
+		t.Errorf("%v != %v", got, f)
+	}
+	var b [2]byte
+	floatx.PutBF16(b[:], f)
+	if got := floatx.DecodeBF16(b[:]); got != f {
+		t.Errorf("%v != %v", got, f)
+	}
+	floatx.PutBF16Order(binary.BigEndian, b[:], f)
+	if !bytes.Equal(b[:], be[1:]) {
+		t.Errorf("%x != %x", b, be[1:])
+	}
+	if got := floatx.AppendBF16Order(binary.LittleEndian, nil, f); !bytes.Equal(got, le) {
+		t.Errorf("%x != %x", got, le)
+	}
+}
+
 func Test_F16_SpotCheck(t *testing.T) {
 	// Spot check a few values to not take any chance from:
 	// https://en.wikipedia.org/wiki/Half-precision_floating-point_format#Half_precision_examples
@@ -136,10 +174,44 @@ func Test_F16_SpotCheck(t *testing.T) {
 	}
 }
 
+func Test_F16_ByteOrder(t *testing.T) {
+	f := floatx.F16(0x3C00)
+	le := floatx.AppendF16(nil, f)
+	if want := []byte{0x00, 0x3C}; !bytes.Equal(le, want) {
+		t.Fatalf("%x != %x", le, want)
+	}
+	be := floatx.AppendF16Order(binary.BigEndian, []byte{0xFF}, f)
+	if want := []byte{0xFF, 0x3C, 0x00}; !bytes.Equal(be, want) {
+		t.Fatalf("%x != %x", be, want)
+	}
+	if got := floatx.DecodeF16Order(binary.BigEndian, be[1:]); got != f {
+		t.Errorf("%v != %v", got, f)
+	}
+	if got := floatx.DecodeF16Order(binary.LittleEndian, le); got != f {
+		t.Errorf("%v != %v", got, f)
+	}
+	var b [2]byte
+	floatx.PutF16(b[:], f)
+	if got := floatx.DecodeF16(b[:]); got != f {
+		t.Errorf("%v != %v", got, f)
+	}
+	floatx.PutF16Order(binary.BigEndian, b[:], f)
+	if !bytes.Equal(b[:], be[1:]) {
+		t.Errorf("%x != %x", b, be[1:])
+	}
+	if got := floatx.AppendF16Order(binary.LittleEndian, nil, f); !bytes.Equal(got, le) {
+		t.Errorf("%x != %x", got, le)
+	}
+}
+
 func Test_F8E4M3_All(t *testing.T) {
 	for i, line := range f8E4M3TestData {
 		t.Run(fmt.Sprintf("#%d: %g", i, line.F), func(t *testing.T) {
-			testOne8(t, floatx.F8E4M3(line.V), line)
+			f := floatx.F8E4M3(line.V)
+			testOne(t, f, line)
+			if got := floatx.F8E4M3FromFloat32(f.Float32()); got != f && !math.IsNaN(float64(line.F)) {
+				t.Errorf("%v != %v", got, f)
+			}
 		})
 	}
 }
@@ -174,7 +246,11 @@ func Test_F8E4M3_SpotCheck(t *testing.T) {
 func Test_F8E4M3Fn_All(t *testing.T) {
 	for i, line := range f8E4M3FnTestData {
 		t.Run(fmt.Sprintf("#%d: %g", i, line.F), func(t *testing.T) {
-			testOne8(t, floatx.F8E4M3Fn(line.V), line)
+			f := floatx.F8E4M3Fn(line.V)
+			testOne(t, f, line)
+			if got := floatx.F8E4M3FnFromFloat32(f.Float32()); got != f && !math.IsNaN(float64(line.F)) {
+				t.Errorf("%v != %v", got, f)
+			}
 		})
 	}
 }
@@ -206,7 +282,11 @@ func Test_F8E4M3Fn_SpotCheck(t *testing.T) {
 func Test_F8E5M2_All(t *testing.T) {
 	for i, line := range f8E5M2TestData {
 		t.Run(fmt.Sprintf("#%d: %g", i, line.F), func(t *testing.T) {
-			testOne8(t, floatx.F8E5M2(line.V), line)
+			f := floatx.F8E5M2(line.V)
+			testOne(t, f, line)
+			if got := floatx.F8E5M2FromFloat32(f.Float32()); got != f && !math.IsNaN(float64(line.F)) {
+				t.Errorf("%v != %v", got, f)
+			}
 		})
 	}
 }
@@ -238,67 +318,310 @@ func Test_F8E5M2_SpotCheck(t *testing.T) {
 	}
 }
 
-type fn8 interface {
-	Components() (uint8, uint8, uint8)
-	Float32() float32
+func Test_FromFloat32_Rounding(t *testing.T) {
+	data := []struct {
+		in   float32
+		bf16 floatx.BF16
+		f16  floatx.F16
+		e4m3 floatx.F8E4M3
+		e4fn floatx.F8E4M3Fn
+		e5m2 floatx.F8E5M2
+	}{
+		{0, 0x0000, 0x0000, 0x00, 0x00, 0x00},
+		{float32(math.Copysign(0, -1)), 0x8000, 0x8000, 0x80, 0x80, 0x80},
+		{1, 0x3F80, 0x3C00, 0x38, 0x38, 0x3C},
+		// Halfway between 1 and 1.125 in float8 E4M3 rounds to even.
+		{1.0625, 0x3F88, 0x3C40, 0x38, 0x38, 0x3C},
+		// Slightly above halfway rounds up.
+		{1.0626, 0x3F88, 0x3C40, 0x39, 0x39, 0x3C},
+		// Halfway between 1.125 and 1.25 rounds to even.
+		{1.1875, 0x3F98, 0x3CC0, 0x3A, 0x3A, 0x3D},
+		{-2, 0xC000, 0xC000, 0xC0, 0xC0, 0xC0},
+		{240, 0x4370, 0x5B80, 0x77, 0x77, 0x5C},
+		// Larger than the largest F8E4M3 value but rounds down to it.
+		{247, 0x4377, 0x5BB8, 0x77, 0x77, 0x5C},
+		{248, 0x4378, 0x5BC0, 0x78, 0x78, 0x5C},
+		{448, 0x43E0, 0x5F00, 0x78, 0x7E, 0x5F},
+		{480, 0x43F0, 0x5F80, 0x78, 0x7F, 0x60},
+		{65504, 0x4780, 0x7BFF, 0x78, 0x7F, 0x7C},
+		{65520, 0x4780, 0x7C00, 0x78, 0x7F, 0x7C},
+		{float32(math.Inf(1)), 0x7F80, 0x7C00, 0x78, 0x7F, 0x7C},
+		{float32(math.Inf(-1)), 0xFF80, 0xFC00, 0xF8, 0xFF, 0xFC},
+		// Smallest subnormal values.
+		{0x1p-133, 0x0001, 0x0000, 0x00, 0x00, 0x00},
+		{0x1p-24, 0x3380, 0x0001, 0x00, 0x00, 0x00},
+		{0x1p-9, 0x3B00, 0x1800, 0x01, 0x01, 0x18},
+		{0x1p-16, 0x3780, 0x0100, 0x00, 0x00, 0x01},
+		// Half of the smallest float8 E4M3 subnormal rounds to even, which is zero.
+		{0x1p-10, 0x3A80, 0x1400, 0x00, 0x00, 0x14},
+		{0x3p-11, 0x3AC0, 0x1600, 0x01, 0x01, 0x16},
+	}
+	for i, line := range data {
+		t.Run(fmt.Sprintf("#%d: %g", i, line.in), func(t *testing.T) {
+			if got := floatx.BF16FromFloat32(line.in); got != line.bf16 {
+				t.Errorf("BF16: want=0x%04X got=0x%04X", line.bf16, got)
+			}
+			if got := floatx.F16FromFloat32(line.in); got != line.f16 {
+				t.Errorf("F16: want=0x%04X got=0x%04X", line.f16, got)
+			}
+			if got := floatx.F8E4M3FromFloat32(line.in); got != line.e4m3 {
+				t.Errorf("F8E4M3: want=0x%02X got=0x%02X", line.e4m3, got)
+			}
+			if got := floatx.F8E4M3FnFromFloat32(line.in); got != line.e4fn {
+				t.Errorf("F8E4M3Fn: want=0x%02X got=0x%02X", line.e4fn, got)
+			}
+			if got := floatx.F8E5M2FromFloat32(line.in); got != line.e5m2 {
+				t.Errorf("F8E5M2: want=0x%02X got=0x%02X", line.e5m2, got)
+			}
+		})
+	}
 }
 
-type fn16 interface {
-	Components() (uint8, uint8, uint16)
-	Float32() float32
+func Test_FromFloat32_NaN(t *testing.T) {
+	nan := float32(math.NaN())
+	if got := floatx.BF16FromFloat32(nan); !math.IsNaN(float64(got.Float32())) {
+		t.Errorf("BF16: %v", got)
+	}
+	if got := floatx.F16FromFloat32(nan); !math.IsNaN(float64(got.Float32())) {
+		t.Errorf("F16: %v", got)
+	}
+	if got := floatx.F8E4M3FromFloat32(nan); !math.IsNaN(float64(got.Float32())) {
+		t.Errorf("F8E4M3: %v", got)
+	}
+	if got := floatx.F8E4M3FnFromFloat32(nan); got != 0x7F {
+		t.Errorf("F8E4M3Fn: %v", got)
+	}
+	if got := floatx.F8E5M2FromFloat32(nan); !math.IsNaN(float64(got.Float32())) {
+		t.Errorf("F8E5M2: %v", got)
+	}
 }
 
-type fn32 interface {
-	Components() (uint8, uint8, uint32)
+func Test_Neighbours_All(t *testing.T) {
+	t.Run("BF16", func(t *testing.T) { testNeighbours(t, slices.Collect(floatx.All[floatx.BF16]())) })
+	t.Run("F16", func(t *testing.T) { testNeighbours(t, slices.Collect(floatx.All[floatx.F16]())) })
+	t.Run("F8E4M3", func(t *testing.T) { testNeighbours(t, slices.Collect(floatx.All[floatx.F8E4M3]())) })
+	t.Run("F8E4M3Fn", func(t *testing.T) { testNeighbours(t, slices.Collect(floatx.All[floatx.F8E4M3Fn]())) })
+	t.Run("F8E5M2", func(t *testing.T) { testNeighbours(t, slices.Collect(floatx.All[floatx.F8E5M2]())) })
 }
 
-func testOne8[T fn8](t *testing.T, f T, line testData) {
-	sign, exponent, mantissa := f.Components()
-	if sign != line.Sign {
-		t.Errorf("sign: want=%d  got=%d", line.Sign, sign)
+func Test_Neighbours_SpotCheck(t *testing.T) {
+	inf := float32(math.Inf(1))
+	data := []struct {
+		v, up, down float32
+		ulp         float32
+	}{
+		{0, 1. / 512, -1. / 512, 1. / 512},
+		{float32(math.Copysign(0, -1)), 1. / 512, -1. / 512, 1. / 512},
+		{1. / 512, 2. / 512, 0, 1. / 512},
+		{-1. / 512, 0, -2. / 512, 1. / 512},
+		{1, 1.125, 0.9375, 0.125},
+		{-1, -0.9375, -1.125, 0.125},
+		{448, 448, 416, 32},
+		{-448, -416, -448, 32},
+	}
+	for i, l := range data {
+		f := floatx.F8E4M3FnFromFloat32(l.v)
+		if got := f.NextUp().Float32(); got != l.up {
+			t.Errorf("#%d: NextUp(%g) = %g; want %g", i, l.v, got, l.up)
+		}
+		if got := f.NextDown().Float32(); got != l.down {
+			t.Errorf("#%d: NextDown(%g) = %g; want %g", i, l.v, got, l.down)
+		}
+		if got := f.ULP(); got != l.ulp {
+			t.Errorf("#%d: ULP(%g) = %g; want %g", i, l.v, got, l.ulp)
+		}
 	}
-	if exponent != line.Exponent {
-		t.Errorf("exponent: want=%d  got=%d", line.Exponent, exponent)
+	// The value after the largest finite value is infinity.
+	if got := floatx.F8E4M3FromFloat32(240).NextUp().Float32(); got != inf {
+		t.Fatal(got)
 	}
-	if uint32(mantissa) != line.Mantissa {
-		t.Errorf("mantissa: want=%d  got=%d", line.Mantissa, mantissa)
+	if got := floatx.F8E5M2FromFloat32(-57344).NextDown().Float32(); got != -inf {
+		t.Fatal(got)
 	}
-	if got := f.Float32(); got != line.F {
-		if !math.IsNaN(float64(got)) && !math.IsNaN(float64(line.F)) {
-			t.Errorf("%g != %g", got, line.F)
+	if got := floatx.F16FromFloat32(inf).NextDown().Float32(); got != 65504 {
+		t.Fatal(got)
+	}
+	if got := floatx.BF16FromFloat32(1).ULP(); got != 1./128 {
+		t.Fatal(got)
+	}
+	if got := floatx.F16FromFloat32(-inf).ULP(); got != inf {
+		t.Fatal(got)
+	}
+	if got := floatx.F16(0x7E00).ULP(); !math.IsNaN(float64(got)) {
+		t.Fatal(got)
+	}
+	nan := floatx.F8E4M3Fn(0x7F)
+	if nan.NextUp() != nan || nan.NextDown() != nan {
+		t.Fatal("NaN must be unchanged")
+	}
+	one := floatx.F8E4M3FnFromFloat32(1)
+	if one.NextAfter(nan) != nan || nan.NextAfter(one) != nan {
+		t.Fatal("NaN must propagate")
+	}
+	if d := one.ULPDistance(nan); d != math.MaxUint32 {
+		t.Fatal(d)
+	}
+	if d := nan.ULPDistance(nan | 0x80); d != 0 {
+		t.Fatal(d)
+	}
+	if d := floatx.F8E4M3FnFromFloat32(448).ULPDistance(floatx.F8E4M3FnFromFloat32(-448)); d != 2*0x7E {
+		t.Fatal(d)
+	}
+}
+
+func Test_F32_Neighbours(t *testing.T) {
+	inf := float32(math.Inf(1))
+	data := []float32{
+		0, float32(math.Copysign(0, -1)), math.SmallestNonzeroFloat32, -math.SmallestNonzeroFloat32,
+		1.1754942107e-38, 1.17549435e-38, 1, -1, 25, 1e-10, -3e20,
+		math.MaxFloat32, -math.MaxFloat32, inf, -inf,
+	}
+	for _, v := range data {
+		f := floatx.F32(v)
+		if got, want := float32(f.NextUp()), math.Nextafter32(v, inf); got != want && v != inf {
+			t.Errorf("NextUp(%g) = %g; want %g", v, got, want)
+		}
+		if got, want := float32(f.NextDown()), math.Nextafter32(v, -inf); got != want && v != -inf {
+			t.Errorf("NextDown(%g) = %g; want %g", v, got, want)
+		}
+		if got, want := float32(f.NextAfter(2)), math.Nextafter32(v, 2); got != want {
+			t.Errorf("NextAfter(%g, 2) = %g; want %g", v, got, want)
+		}
+		if v != inf && v != -inf {
+			if d := f.ULPDistance(f.NextUp()); d != 1 {
+				t.Errorf("ULPDistance(%g, NextUp) = %d", v, d)
+			}
+			_, exponent, _ := f.Components()
+			want := float32(math.Ldexp(1, max(int(exponent), 1)-127-23))
+			if got := f.ULP(); got != want {
+				t.Errorf("ULP(%g) = %g; want %g", v, got, want)
+			}
 		}
 	}
+	if got := floatx.F32(inf).NextUp(); float32(got) != inf {
+		t.Fatal(got)
+	}
+	if got := floatx.F32(-inf).NextDown(); float32(got) != -inf {
+		t.Fatal(got)
+	}
+	if d := floatx.F32(-inf).ULPDistance(floatx.F32(inf)); d != 0xFF000000 {
+		t.Fatalf("%#x", d)
+	}
+}
+
+type neighbours[T any] interface {
+	~uint8 | ~uint16
+	Float32() float32
+	NextUp() T
+	NextDown() T
+	NextAfter(to T) T
+	ULP() float32
+	ULPDistance(o T) uint32
 }
 
-func testOne16[T fn16](t *testing.T, f T, line testData) {
-	sign, exponent, mantissa := f.Components()
+// testNeighbours verifies the neighbours of every value against the sorted
+// list of all values.
+func testNeighbours[T neighbours[T]](t *testing.T, values []T) {
+	var sorted []float32
+	for _, v := range values {
+		if f := v.Float32(); !math.IsNaN(float64(f)) {
+			sorted = append(sorted, f)
+		}
+	}
+	slices.Sort(sorted)
+	sorted = slices.Compact(sorted)
+	for _, v := range values {
+		f := v.Float32()
+		up := v.NextUp()
+		down := v.NextDown()
+		if math.IsNaN(float64(f)) {
+			if up != v || down != v || v.NextAfter(0) != v || v.ULPDistance(v) != 0 || !math.IsNaN(float64(v.ULP())) {
+				t.Fatalf("%#x: NaN must be unchanged", v)
+			}
+			continue
+		}
+		i, _ := slices.BinarySearch(sorted, f)
+		wantUp, wantDown := f, f
+		if i+1 < len(sorted) {
+			wantUp = sorted[i+1]
+		}
+		if i > 0 {
+			wantDown = sorted[i-1]
+		}
+		if got := up.Float32(); got != wantUp {
+			t.Fatalf("NextUp(%g) = %g; want %g", f, got, wantUp)
+		}
+		if got := down.Float32(); got != wantDown {
+			t.Fatalf("NextDown(%g) = %g; want %g", f, got, wantDown)
+		}
+		if got := v.NextAfter(up); got != up {
+			t.Fatalf("NextAfter(%g, %g) = %g", f, up.Float32(), got.Float32())
+		}
+		if got := v.NextAfter(down); got != down {
+			t.Fatalf("NextAfter(%g, %g) = %g", f, down.Float32(), got.Float32())
+		}
+		if got := v.NextAfter(v); got != v {
+			t.Fatalf("NextAfter(%g, %g) = %g", f, f, got.Float32())
+		}
+		if d := v.ULPDistance(up); d != 1 && up != v {
+			t.Fatalf("ULPDistance(%g, %g) = %d", f, up.Float32(), d)
+		}
+		if d := up.ULPDistance(v); d != 1 && up != v {
+			t.Fatalf("ULPDistance(%g, %g) = %d", up.Float32(), f, d)
+		}
+		if u := v.ULP(); !math.IsInf(float64(f), 0) && (u <= 0 || float32(math.Abs(float64(f)))/u != float32(int64(math.Abs(float64(f))/float64(u)))) {
+			t.Fatalf("ULP(%g) = %g is not a quantum of the value", f, u)
+		}
+	}
+}
+
+func testOne[T floatx.Float](t *testing.T, f T, line testData) {
+	sign, exponent, mantissa := floatx.Components(f)
 	if sign != line.Sign {
 		t.Errorf("sign: want=%d  got=%d", line.Sign, sign)
 	}
 	if exponent != line.Exponent {
 		t.Errorf("exponent: want=%d  got=%d", line.Exponent, exponent)
 	}
-	if uint32(mantissa) != line.Mantissa {
-		t.Errorf("mantissa: want=%d  got=%d", line.Mantissa, mantissa)
+	if mantissa != line.Mantissa {
+		t.Errorf("mantissa: want=%x  got=%x", line.Mantissa, mantissa)
 	}
 	if got := f.Float32(); got != line.F {
 		if !math.IsNaN(float64(got)) && !math.IsNaN(float64(line.F)) {
 			t.Errorf("%g != %g", got, line.F)
 		}
 	}
+	if got := exact(f); got != line.Exact {
+		t.Errorf("exact: want=%s  got=%s", line.Exact, got)
+	}
 }
 
-func testOne32[T fn32](t *testing.T, f T, line testData) {
-	sign, exponent, mantissa := f.Components()
-	if sign != line.Sign {
-		t.Errorf("sign: want=%d  got=%d", line.Sign, sign)
+// exact returns the exact value of f in the format of testData.Exact, checking
+// that BigFloat and Rat agree.
+func exact[T floatx.Float](f T) string {
+	b := f.BigFloat()
+	r := f.Rat()
+	switch {
+	case b == nil:
+		if r != nil {
+			return "Rat: " + r.RatString()
+		}
+		return "NaN"
+	case b.IsInf():
+		if r != nil {
+			return "Rat: " + r.RatString()
+		}
+		return b.String()
+	case r == nil:
+		return "Rat: nil"
 	}
-	if exponent != line.Exponent {
-		t.Errorf("exponent: want=%d  got=%d", line.Exponent, exponent)
+	if x, _ := b.Rat(nil); x.Cmp(r) != 0 {
+		return "BigFloat: " + x.RatString()
 	}
-	if uint32(mantissa) != line.Mantissa {
-		t.Errorf("mantissa: want=%x  got=%x", line.Mantissa, mantissa)
+	if b.Sign() == 0 && b.Signbit() {
+		return "-0"
 	}
+	return r.RatString()
 }
 
 // Not too large so it doesn't trash the cache.
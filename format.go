@@ -0,0 +1,309 @@
+// Copyright 2024 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package floatx
+
+import "math"
+
+// format describes the bit layout of a binary floating point encoding.
+//
+// It is used to share the encoding logic between all the types.
+type format struct {
+	exponentBits uint
+	mantissaBits uint
+	// finite is true when the format has no infinity and uses the all ones
+	// exponent and mantissa for NaN, like F8E4M3Fn.
+	finite bool
+}
+
+var (
+	f32Format      = format{exponentBits: 8, mantissaBits: 23}
+	bf16Format     = format{exponentBits: 8, mantissaBits: 7}
+	f16Format      = format{exponentBits: 5, mantissaBits: 10}
+	f8e4m3Format   = format{exponentBits: 4, mantissaBits: 3}
+	f8e4m3fnFormat = format{exponentBits: 4, mantissaBits: 3, finite: true}
+	f8e5m2Format   = format{exponentBits: 5, mantissaBits: 2}
+)
+
+func (f *format) bias() int {
+	return 1<<(f.exponentBits-1) - 1
+}
+
+func (f *format) signBit() uint32 {
+	return 1 << (f.exponentBits + f.mantissaBits)
+}
+
+func (f *format) exponentMask() uint32 {
+	return (1<<f.exponentBits - 1) << f.mantissaBits
+}
+
+func (f *format) mantissaMask() uint32 {
+	return 1<<f.mantissaBits - 1
+}
+
+// inf returns the positive infinity encoding, or NaN for finite formats.
+func (f *format) inf() uint32 {
+	if f.finite {
+		return f.nan()
+	}
+	return f.exponentMask()
+}
+
+// nan returns the positive canonical quiet NaN encoding.
+func (f *format) nan() uint32 {
+	if f.finite {
+		return f.exponentMask() | f.mantissaMask()
+	}
+	return f.exponentMask() | 1<<(f.mantissaBits-1)
+}
+
+// maxFinite returns the encoding of the largest positive finite value.
+func (f *format) maxFinite() uint32 {
+	if f.finite {
+		return f.nan() - 1
+	}
+	return f.exponentMask() - 1
+}
+
+// fromFloat64 returns the encoding nearest to v, rounding ties to even.
+//
+// Values too large to be represented become infinity, or NaN for finite
+// formats.
+func (f *format) fromFloat64(v float64) uint32 {
+	bits, _ := f.round(v, RoundNearestEven, false)
+	return bits
+}
+
+// round returns the encoding of v rounded with mode and the exceptions it
+// raised.
+//
+// Finite values too large to be represented become the largest finite value
+// when saturate is true or when mode rounds them toward zero, otherwise they
+// become infinity, or NaN for finite formats. Infinities become the largest
+// finite value of finite formats when saturate is true.
+func (f *format) round(v float64, mode RoundingMode, saturate bool) (uint32, Flags) {
+	var sign uint32
+	negative := math.Signbit(v)
+	if negative {
+		sign = f.signBit()
+	}
+	if math.IsNaN(v) {
+		return sign | f.nan(), 0
+	}
+	if math.IsInf(v, 0) {
+		if !f.finite {
+			return sign | f.inf(), 0
+		}
+		if saturate {
+			return sign | f.maxFinite(), FlagInvalid
+		}
+		return sign | f.nan(), FlagInvalid
+	}
+	a := math.Abs(v)
+	if a == 0 {
+		return sign, 0
+	}
+	// a = frac * 2^exp with frac in [0.5, 1), so a = 1.m * 2^(exp-1).
+	_, exp := math.Frexp(a)
+	biased, e := f.quantum(exp - 1)
+	// Count of quanta. It is exact since it fits in mantissaBits+1 bits and
+	// only the rounding discards information.
+	return f.roundQuanta(negative, biased, math.Ldexp(a, int(f.mantissaBits)-e), mode, saturate)
+}
+
+// quantum returns the biased exponent minus one and the exponent of the
+// quantum, the value of the least significant mantissa bit, of the values in
+// [2^e, 2^(e+1)).
+func (f *format) quantum(e int) (int, int) {
+	// Smallest normal exponent. Smaller values are subnormal and share the
+	// same quantum.
+	emin := 1 - f.bias()
+	if e < emin {
+		return 0, emin
+	}
+	return e + f.bias() - 1, e
+}
+
+// roundQuanta returns the encoding of x quanta rounded with mode and the
+// exceptions it raised. x is ignored when biased is too large.
+//
+// x must be exact unless its fractional part only serves to select the
+// rounding direction.
+func (f *format) roundQuanta(negative bool, biased int, x float64, mode RoundingMode, saturate bool) (uint32, Flags) {
+	var sign uint32
+	if negative {
+		sign = f.signBit()
+	}
+	if biased > int(f.exponentMask()>>f.mantissaBits) {
+		return sign | f.overflow(mode, negative, saturate), FlagOverflow | FlagInexact
+	}
+	q := mode.round(x, negative)
+	// A carry out of the mantissa naturally increments the exponent.
+	bits := uint32(biased)<<f.mantissaBits + uint32(q)
+	if bits > f.maxFinite() {
+		return sign | f.overflow(mode, negative, saturate), FlagOverflow | FlagInexact
+	}
+	var flags Flags
+	if q != x {
+		flags = FlagInexact
+		if bits&f.exponentMask() == 0 {
+			// Tininess is detected after rounding.
+			flags |= FlagUnderflow
+		}
+	}
+	return sign | bits, flags
+}
+
+// overflow returns the unsigned encoding of a finite value too large to be
+// represented.
+func (f *format) overflow(mode RoundingMode, negative, saturate bool) uint32 {
+	if saturate || !mode.awayFromZero(negative) {
+		return f.maxFinite()
+	}
+	return f.inf()
+}
+
+// isNaN returns true if bits encodes a NaN.
+func (f *format) isNaN(bits uint32) bool {
+	bits &^= f.signBit()
+	if f.finite {
+		return bits == f.nan()
+	}
+	return bits&f.exponentMask() == f.exponentMask() && bits&f.mantissaMask() != 0
+}
+
+// toFloat64 returns the exact value encoded by bits.
+func (f *format) toFloat64(bits uint32) float64 {
+	sign := 1.
+	if bits&f.signBit() != 0 {
+		sign = -1.
+	}
+	if f.isNaN(bits) {
+		return math.Copysign(math.NaN(), sign)
+	}
+	bits &^= f.signBit()
+	exponent := int(bits >> f.mantissaBits)
+	mantissa := bits & f.mantissaMask()
+	if !f.finite && bits&f.exponentMask() == f.exponentMask() {
+		return math.Inf(int(sign))
+	}
+	if exponent == 0 {
+		// Subnormal.
+		exponent = 1
+	} else {
+		mantissa |= 1 << f.mantissaBits
+	}
+	return sign * math.Ldexp(float64(mantissa), exponent-f.bias()-int(f.mantissaBits))
+}
+
+// isSubnormal returns true if bits encodes a subnormal value, excluding zero.
+func (f *format) isSubnormal(bits uint32) bool {
+	return bits&f.exponentMask() == 0 && bits&f.mantissaMask() != 0
+}
+
+// isInf returns true if bits encodes an infinity.
+func (f *format) isInf(bits uint32) bool {
+	return !f.finite && bits&^f.signBit() == f.exponentMask()
+}
+
+// nextUp returns the encoding of the smallest value larger than the one
+// encoded by bits.
+//
+// NaN and the largest value, which is +Inf or the largest finite value of
+// finite formats, are returned unchanged.
+func (f *format) nextUp(bits uint32) uint32 {
+	if f.isNaN(bits) {
+		return bits
+	}
+	var next uint32
+	switch {
+	case bits&^f.signBit() == 0:
+		// Both zeros.
+		next = 1
+	case bits&f.signBit() != 0:
+		next = bits - 1
+	default:
+		next = bits + 1
+	}
+	if f.isNaN(next) {
+		return bits
+	}
+	return next
+}
+
+// nextDown returns the encoding of the largest value smaller than the one
+// encoded by bits.
+func (f *format) nextDown(bits uint32) uint32 {
+	return f.nextUp(bits^f.signBit()) ^ f.signBit()
+}
+
+// nextAfter returns the encoding of the next value after x toward y.
+//
+// x is returned when both are equal, like math.Nextafter. NaN is returned if
+// either is NaN.
+func (f *format) nextAfter(x, y uint32) uint32 {
+	if f.isNaN(x) {
+		return x
+	}
+	if f.isNaN(y) {
+		return y
+	}
+	vx := f.toFloat64(x)
+	vy := f.toFloat64(y)
+	switch {
+	case vx < vy:
+		return f.nextUp(x)
+	case vx > vy:
+		return f.nextDown(x)
+	default:
+		return x
+	}
+}
+
+// ulp returns the spacing between the values of the binade of the value
+// encoded by bits.
+//
+// It is the smallest subnormal for zero, +Inf for infinities and NaN for NaN.
+func (f *format) ulp(bits uint32) float64 {
+	if f.isNaN(bits) {
+		return math.NaN()
+	}
+	if f.isInf(bits) {
+		return math.Inf(1)
+	}
+	exponent := int((bits &^ f.signBit()) >> f.mantissaBits)
+	if exponent == 0 {
+		exponent = 1
+	}
+	return math.Ldexp(1, exponent-f.bias()-int(f.mantissaBits))
+}
+
+// ulpDistance returns the number of steps of nextUp or nextDown to go from a to
+// b.
+//
+// Both zeros are 0 steps apart. It is 0 if both are NaN and math.MaxUint32 if
+// only one is NaN.
+func (f *format) ulpDistance(a, b uint32) uint32 {
+	if an, bn := f.isNaN(a), f.isNaN(b); an || bn {
+		if an && bn {
+			return 0
+		}
+		return math.MaxUint32
+	}
+	oa := f.ordinal(a)
+	ob := f.ordinal(b)
+	if oa > ob {
+		return uint32(oa - ob)
+	}
+	return uint32(ob - oa)
+}
+
+// ordinal returns the position of the non-NaN value encoded by bits in the
+// ordered values of the format, where both zeros are 0.
+func (f *format) ordinal(bits uint32) int64 {
+	if bits&f.signBit() != 0 {
+		return -int64(bits &^ f.signBit())
+	}
+	return int64(bits)
+}
@@ -8,12 +8,10 @@ package main
 
 import (
 	"fmt"
-	"math"
+	"math/big"
 	"os"
 	"os/exec"
 	"text/template"
-
-	"github.com/maruel/floatx"
 )
 
 const srcTmpl = `// Code generated "go run gen.go" DO NOT EDIT.
@@ -24,7 +22,7 @@ import "math"
 
 // See floatx_test.go
 var {{.Name}} = []testData{
-{{range .Data}}{ {{.V}}, {{.F}}, {{.Sign}}, {{.Exponent}}, {{.Mantissa}}, },
+{{range .Data}}{ {{.V}}, {{.F}}, {{.Sign}}, {{.Exponent}}, {{.Mantissa}}, {{printf "%q" .Exact}} },
 {{end}} }
 `
 
@@ -34,145 +32,75 @@ type testData struct {
 	Sign     uint8
 	Exponent uint8
 	Mantissa uint16
+	Exact    string
 }
 
-func genBF16() []testData {
-	const (
-		signOffset     = 15
-		exponentOffset = 7
-		exponentMask   = (1 << (signOffset - exponentOffset)) - 1
-		mantissaMask   = (1 << exponentOffset) - 1
-	)
-	var out [1 << 16]testData
-	for i := range out {
-		x := testData{
-			V:        fmt.Sprintf("0x%04x", i),
-			Sign:     uint8(i >> signOffset),
-			Exponent: uint8((i >> exponentOffset) & exponentMask),
-			Mantissa: uint16(i & mantissaMask),
-		}
-		f := floatx.BF16(i).Float32()
-		// There's a problem here with denormalized values
-		if sign := int(x.Sign) * -1; math.IsInf(float64(f), sign) {
-			x.F = fmt.Sprintf("float32(math.Inf(%d))", sign)
-		} else if math.IsNaN(float64(f)) {
-			x.F = "float32(math.NaN())"
-		} else {
-			x.F = fmt.Sprintf("%g", f)
-		}
-		out[i] = x
-	}
-	return out[:]
-}
-
-func genF16() []testData {
-	const (
-		signOffset     = 15
-		exponentOffset = 10
-		exponentMask   = (1 << (signOffset - exponentOffset)) - 1
-		mantissaMask   = (1 << exponentOffset) - 1
-	)
-	var out [1 << 16]testData
-	for i := range out {
-		x := testData{
-			V:        fmt.Sprintf("0x%04x", i),
-			Sign:     uint8(i >> signOffset),
-			Exponent: uint8((i >> exponentOffset) & exponentMask),
-			Mantissa: uint16(i & mantissaMask),
-		}
-		f := floatx.F16(i).Float32()
-		if sign := int(x.Sign) * -1; math.IsInf(float64(f), sign) {
-			x.F = fmt.Sprintf("float32(math.Inf(%d))", sign)
-		} else if math.IsNaN(float64(f)) {
-			x.F = "float32(math.NaN())"
-		} else {
-			x.F = fmt.Sprintf("%g", f)
-		}
-		out[i] = x
-	}
-	return out[:]
-}
-
-func genF8E4M3() []testData {
-	const (
-		signOffset     = 7
-		exponentOffset = 3
-		exponentMask   = (1 << (signOffset - exponentOffset)) - 1
-		mantissaMask   = (1 << exponentOffset) - 1
-	)
-	var out [1 << 8]testData
-	for i := range out {
-		x := testData{
-			V:        fmt.Sprintf("0x%02x", i),
-			Sign:     uint8(i >> signOffset),
-			Exponent: uint8((i >> exponentOffset) & exponentMask),
-			Mantissa: uint16(i & mantissaMask),
-		}
-		f := floatx.F8E4M3(i).Float32()
-		if sign := int(x.Sign) * -1; math.IsInf(float64(f), sign) {
-			x.F = fmt.Sprintf("float32(math.Inf(%d))", sign)
-		} else if math.IsNaN(float64(f)) {
-			x.F = "float32(math.NaN())"
-		} else {
-			x.F = fmt.Sprintf("%g", f)
-		}
-		out[i] = x
-	}
-	return out[:]
-}
-
-func genF8E4M3Fn() []testData {
-	const (
-		signOffset     = 7
-		exponentOffset = 3
-		exponentMask   = (1 << (signOffset - exponentOffset)) - 1
-		mantissaMask   = (1 << exponentOffset) - 1
-	)
-	var out [1 << 8]testData
-	for i := range out {
-		x := testData{
-			V:        fmt.Sprintf("0x%02x", i),
-			Sign:     uint8(i >> signOffset),
-			Exponent: uint8((i >> exponentOffset) & exponentMask),
-			Mantissa: uint16(i & mantissaMask),
-		}
-		f := floatx.F8E4M3Fn(i).Float32()
-		if math.IsNaN(float64(f)) {
-			x.F = "float32(math.NaN())"
-		} else {
-			x.F = fmt.Sprintf("%g", f)
-		}
-		out[i] = x
-	}
-	return out[:]
-}
-
-func genF8E5M2() []testData {
-	const (
-		signOffset     = 7
-		exponentOffset = 2
-		exponentMask   = (1 << (signOffset - exponentOffset)) - 1
-		mantissaMask   = (1 << exponentOffset) - 1
-	)
-	var out [1 << 8]testData
-	for i := range out {
+// gen returns the test data of every encoding of a type with the specified
+// layout, formatted with digits hexadecimal digits.
+//
+// The values are decoded from the bit fields here instead of with the package
+// so they can serve as the oracle. finite is true for the types without
+// infinity, which use the largest encoding for NaN.
+func gen(exponentBits, mantissaBits uint, finite bool, digits int) []testData {
+	bias := 1<<(exponentBits-1) - 1
+	maxExponent := uint32(1)<<exponentBits - 1
+	maxMantissa := uint32(1)<<mantissaBits - 1
+	var out []testData
+	for v := uint32(0); v < 1<<(1+exponentBits+mantissaBits); v++ {
+		sign := v >> (exponentBits + mantissaBits)
+		exponent := (v >> mantissaBits) & maxExponent
+		mantissa := v & maxMantissa
 		x := testData{
-			V:        fmt.Sprintf("0x%02x", i),
-			Sign:     uint8(i >> signOffset),
-			Exponent: uint8((i >> exponentOffset) & exponentMask),
-			Mantissa: uint16(i & mantissaMask),
+			V:        fmt.Sprintf("0x%0*x", digits, v),
+			Sign:     uint8(sign),
+			Exponent: uint8(exponent),
+			Mantissa: uint16(mantissa),
 		}
-		f := floatx.F8E5M2(i).Float32()
-		if sign := int(x.Sign) * -1; math.IsInf(float64(f), sign) {
-			x.F = fmt.Sprintf("float32(math.Inf(%d))", sign)
-		} else if math.IsNaN(float64(f)) {
+		switch {
+		case exponent == maxExponent && (finite && mantissa == maxMantissa || !finite && mantissa != 0):
 			x.F = "float32(math.NaN())"
-		} else {
-			x.F = fmt.Sprintf("%g", f)
+			x.Exact = "NaN"
+		case exponent == maxExponent && !finite:
+			x.F = fmt.Sprintf("float32(math.Inf(%d))", -int(sign))
+			x.Exact = "+Inf"
+			if sign != 0 {
+				x.Exact = "-Inf"
+			}
+		default:
+			// value = m × 2^e.
+			m := int64(mantissa)
+			e := int(exponent) - bias - int(mantissaBits)
+			if exponent == 0 {
+				e++
+			} else {
+				m |= 1 << mantissaBits
+			}
+			if sign != 0 {
+				m = -m
+			}
+			r := new(big.Rat).SetInt64(m)
+			p := new(big.Rat).SetInt(new(big.Int).Lsh(big.NewInt(1), uint(max(e, -e))))
+			if e >= 0 {
+				r.Mul(r, p)
+			} else {
+				r.Quo(r, p)
+			}
+			f, exact := r.Float32()
+			if !exact {
+				panic(fmt.Sprintf("%s is not exact as float32", r.RatString()))
+			}
+			x.Exact = r.RatString()
+			if m == 0 && sign != 0 {
+				// big.Rat has no -0.
+				x.F = "-0"
+				x.Exact = "-0"
+			} else {
+				x.F = fmt.Sprintf("%g", f)
+			}
 		}
-		out[i] = x
+		out = append(out, x)
 	}
-	return out[:]
+	return out
 }
 
 func generateTest(name, filename string, td []testData) {
@@ -195,9 +123,9 @@ func generateTest(name, filename string, td []testData) {
 }
 
 func main() {
-	generateTest("bf16TestData", "bf16_data_test.go", genBF16())
-	generateTest("f16TestData", "f16_data_test.go", genF16())
-	generateTest("f8E4M3TestData", "f8e4m3_data_test.go", genF8E4M3())
-	generateTest("f8E4M3FnTestData", "f8e4m3fn_data_test.go", genF8E4M3Fn())
-	generateTest("f8E5M2TestData", "f8e5m2_data_test.go", genF8E5M2())
+	generateTest("bf16TestData", "bf16_data_test.go", gen(8, 7, false, 4))
+	generateTest("f16TestData", "f16_data_test.go", gen(5, 10, false, 4))
+	generateTest("f8E4M3TestData", "f8e4m3_data_test.go", gen(4, 3, false, 2))
+	generateTest("f8E4M3FnTestData", "f8e4m3fn_data_test.go", gen(4, 3, true, 2))
+	generateTest("f8E5M2TestData", "f8e5m2_data_test.go", gen(5, 2, false, 2))
 }
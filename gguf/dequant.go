@@ -0,0 +1,291 @@
+// Copyright 2024 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package gguf
+
+import (
+	"encoding/binary"
+	"math"
+
+	"github.com/maruel/floatx"
+)
+
+// dequantizers decode whole blocks of data into dst.
+//
+// The implementations follow ggml-quants.c. Block scales are stored as F16.
+var dequantizers = map[Type]func(dst []float32, b []byte){
+	TypeF32:  dequantF32,
+	TypeF16:  dequantF16,
+	TypeBF16: dequantBF16,
+	TypeQ4_0: dequantQ4_0,
+	TypeQ4_1: dequantQ4_1,
+	TypeQ5_0: dequantQ5_0,
+	TypeQ5_1: dequantQ5_1,
+	TypeQ8_0: dequantQ8_0,
+	TypeQ2_K: dequantQ2_K,
+	TypeQ3_K: dequantQ3_K,
+	TypeQ4_K: dequantQ4_K,
+	TypeQ5_K: dequantQ5_K,
+	TypeQ6_K: dequantQ6_K,
+	TypeQ8_K: dequantQ8_K,
+}
+
+func dequantF32(dst []float32, b []byte) {
+	for i := range dst {
+		dst[i] = math.Float32frombits(binary.LittleEndian.Uint32(b[4*i:]))
+	}
+}
+
+func dequantF16(dst []float32, b []byte) {
+	for i := range dst {
+		dst[i] = floatx.DecodeF16(b[2*i:]).Float32()
+	}
+}
+
+func dequantBF16(dst []float32, b []byte) {
+	for i := range dst {
+		dst[i] = floatx.DecodeBF16(b[2*i:]).Float32()
+	}
+}
+
+// f16 decodes a F16 scale.
+func f16(b []byte) float32 {
+	return floatx.DecodeF16(b).Float32()
+}
+
+// Q4_0: d F16, qs [16]uint8.
+func dequantQ4_0(dst []float32, b []byte) {
+	for ; len(dst) != 0; dst, b = dst[32:], b[18:] {
+		d := f16(b)
+		qs := b[2:18]
+		for j := range 16 {
+			dst[j] = float32(int(qs[j]&0xF)-8) * d
+			dst[j+16] = float32(int(qs[j]>>4)-8) * d
+		}
+	}
+}
+
+// Q4_1: d F16, m F16, qs [16]uint8.
+func dequantQ4_1(dst []float32, b []byte) {
+	for ; len(dst) != 0; dst, b = dst[32:], b[20:] {
+		d := f16(b)
+		m := f16(b[2:])
+		qs := b[4:20]
+		for j := range 16 {
+			dst[j] = float32(qs[j]&0xF)*d + m
+			dst[j+16] = float32(qs[j]>>4)*d + m
+		}
+	}
+}
+
+// Q5_0: d F16, qh uint32, qs [16]uint8.
+func dequantQ5_0(dst []float32, b []byte) {
+	for ; len(dst) != 0; dst, b = dst[32:], b[22:] {
+		d := f16(b)
+		qh := binary.LittleEndian.Uint32(b[2:])
+		qs := b[6:22]
+		for j := range 16 {
+			xh0 := byte((qh>>j)<<4) & 0x10
+			xh1 := byte(qh>>(j+12)) & 0x10
+			dst[j] = float32(int(qs[j]&0xF|xh0)-16) * d
+			dst[j+16] = float32(int(qs[j]>>4|xh1)-16) * d
+		}
+	}
+}
+
+// Q5_1: d F16, m F16, qh uint32, qs [16]uint8.
+func dequantQ5_1(dst []float32, b []byte) {
+	for ; len(dst) != 0; dst, b = dst[32:], b[24:] {
+		d := f16(b)
+		m := f16(b[2:])
+		qh := binary.LittleEndian.Uint32(b[4:])
+		qs := b[8:24]
+		for j := range 16 {
+			xh0 := byte((qh>>j)<<4) & 0x10
+			xh1 := byte(qh>>(j+12)) & 0x10
+			dst[j] = float32(qs[j]&0xF|xh0)*d + m
+			dst[j+16] = float32(qs[j]>>4|xh1)*d + m
+		}
+	}
+}
+
+// Q8_0: d F16, qs [32]int8.
+func dequantQ8_0(dst []float32, b []byte) {
+	for ; len(dst) != 0; dst, b = dst[32:], b[34:] {
+		d := f16(b)
+		for j, q := range b[2:34] {
+			dst[j] = float32(int8(q)) * d
+		}
+	}
+}
+
+// Q2_K: scales [16]uint8, qs [64]uint8, d F16, dmin F16.
+func dequantQ2_K(dst []float32, b []byte) {
+	for ; len(dst) != 0; dst, b = dst[256:], b[84:] {
+		scales := b[:16]
+		q := b[16:80]
+		d := f16(b[80:])
+		dmin := f16(b[82:])
+		y := dst
+		is := 0
+		for range 2 {
+			for shift := 0; shift < 8; shift += 2 {
+				for _, off := range [2]int{0, 16} {
+					sc := scales[is]
+					is++
+					dl := d * float32(sc&0xF)
+					ml := dmin * float32(sc>>4)
+					for l := range 16 {
+						y[l] = dl*float32((q[l+off]>>shift)&3) - ml
+					}
+					y = y[16:]
+				}
+			}
+			q = q[32:]
+		}
+	}
+}
+
+// Q3_K: hmask [32]uint8, qs [64]uint8, scales [12]uint8, d F16.
+func dequantQ3_K(dst []float32, b []byte) {
+	for ; len(dst) != 0; dst, b = dst[256:], b[110:] {
+		hm := b[:32]
+		q := b[32:96]
+		d := f16(b[108:])
+		// Unpack the 16 6-bit scales.
+		const kmask1, kmask2 = 0x03030303, 0x0f0f0f0f
+		a0 := binary.LittleEndian.Uint32(b[96:])
+		a1 := binary.LittleEndian.Uint32(b[100:])
+		tmp := binary.LittleEndian.Uint32(b[104:])
+		var scales [16]byte
+		binary.LittleEndian.PutUint32(scales[0:], (a0&kmask2)|((tmp>>0)&kmask1)<<4)
+		binary.LittleEndian.PutUint32(scales[4:], (a1&kmask2)|((tmp>>2)&kmask1)<<4)
+		binary.LittleEndian.PutUint32(scales[8:], ((a0>>4)&kmask2)|((tmp>>4)&kmask1)<<4)
+		binary.LittleEndian.PutUint32(scales[12:], ((a1>>4)&kmask2)|((tmp>>6)&kmask1)<<4)
+		y := dst
+		is := 0
+		m := byte(1)
+		for range 2 {
+			for shift := 0; shift < 8; shift += 2 {
+				for _, off := range [2]int{0, 16} {
+					dl := d * float32(int(int8(scales[is]))-32)
+					is++
+					for l := range 16 {
+						v := int((q[l+off] >> shift) & 3)
+						if hm[l+off]&m == 0 {
+							v -= 4
+						}
+						y[l] = dl * float32(v)
+					}
+					y = y[16:]
+				}
+				m <<= 1
+			}
+			q = q[32:]
+		}
+	}
+}
+
+// scaleMinK4 unpacks the 6-bit scale and min j used by Q4_K and Q5_K.
+func scaleMinK4(j int, q []byte) (byte, byte) {
+	if j < 4 {
+		return q[j] & 63, q[j+4] & 63
+	}
+	return (q[j+4] & 0xF) | ((q[j-4] >> 6) << 4), (q[j+4] >> 4) | ((q[j] >> 6) << 4)
+}
+
+// Q4_K: d F16, dmin F16, scales [12]uint8, qs [128]uint8.
+func dequantQ4_K(dst []float32, b []byte) {
+	for ; len(dst) != 0; dst, b = dst[256:], b[144:] {
+		d := f16(b)
+		dmin := f16(b[2:])
+		scales := b[4:16]
+		q := b[16:144]
+		y := dst
+		for is := 0; is < 8; is += 2 {
+			sc, m := scaleMinK4(is, scales)
+			d1, m1 := d*float32(sc), dmin*float32(m)
+			sc, m = scaleMinK4(is+1, scales)
+			d2, m2 := d*float32(sc), dmin*float32(m)
+			for l := range 32 {
+				y[l] = d1*float32(q[l]&0xF) - m1
+				y[l+32] = d2*float32(q[l]>>4) - m2
+			}
+			q = q[32:]
+			y = y[64:]
+		}
+	}
+}
+
+// Q5_K: d F16, dmin F16, scales [12]uint8, qh [32]uint8, qs [128]uint8.
+func dequantQ5_K(dst []float32, b []byte) {
+	for ; len(dst) != 0; dst, b = dst[256:], b[176:] {
+		d := f16(b)
+		dmin := f16(b[2:])
+		scales := b[4:16]
+		qh := b[16:48]
+		ql := b[48:176]
+		y := dst
+		u1, u2 := byte(1), byte(2)
+		for is := 0; is < 8; is += 2 {
+			sc, m := scaleMinK4(is, scales)
+			d1, m1 := d*float32(sc), dmin*float32(m)
+			sc, m = scaleMinK4(is+1, scales)
+			d2, m2 := d*float32(sc), dmin*float32(m)
+			for l := range 32 {
+				lo, hi := ql[l]&0xF, ql[l]>>4
+				if qh[l]&u1 != 0 {
+					lo += 16
+				}
+				if qh[l]&u2 != 0 {
+					hi += 16
+				}
+				y[l] = d1*float32(lo) - m1
+				y[l+32] = d2*float32(hi) - m2
+			}
+			ql = ql[32:]
+			y = y[64:]
+			u1 <<= 2
+			u2 <<= 2
+		}
+	}
+}
+
+// Q6_K: ql [128]uint8, qh [64]uint8, scales [16]int8, d F16.
+func dequantQ6_K(dst []float32, b []byte) {
+	for ; len(dst) != 0; dst, b = dst[256:], b[210:] {
+		ql := b[:128]
+		qh := b[128:192]
+		sc := b[192:208]
+		d := f16(b[208:])
+		y := dst
+		for range 2 {
+			for l := range 32 {
+				is := l / 16
+				q1 := int(ql[l]&0xF|((qh[l]>>0)&3)<<4) - 32
+				q2 := int(ql[l+32]&0xF|((qh[l]>>2)&3)<<4) - 32
+				q3 := int(ql[l]>>4|((qh[l]>>4)&3)<<4) - 32
+				q4 := int(ql[l+32]>>4|((qh[l]>>6)&3)<<4) - 32
+				y[l] = d * float32(int8(sc[is])) * float32(q1)
+				y[l+32] = d * float32(int8(sc[is+2])) * float32(q2)
+				y[l+64] = d * float32(int8(sc[is+4])) * float32(q3)
+				y[l+96] = d * float32(int8(sc[is+6])) * float32(q4)
+			}
+			y = y[128:]
+			ql = ql[64:]
+			qh = qh[32:]
+			sc = sc[8:]
+		}
+	}
+}
+
+// Q8_K: d float32, qs [256]int8, bsums [16]int16.
+func dequantQ8_K(dst []float32, b []byte) {
+	for ; len(dst) != 0; dst, b = dst[256:], b[292:] {
+		d := math.Float32frombits(binary.LittleEndian.Uint32(b))
+		for j, q := range b[4:260] {
+			dst[j] = float32(int8(q)) * d
+		}
+	}
+}
@@ -0,0 +1,474 @@
+// Copyright 2024 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+// Package gguf reads llama.cpp's GGUF files.
+//
+// F16 and BF16 tensors are exposed as typed views over the floatx types and
+// the common quantized block types can be dequantized to float32.
+//
+// See https://github.com/ggerganov/ggml/blob/master/docs/gguf.md
+package gguf
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+
+	"github.com/maruel/floatx"
+)
+
+// magic is "GGUF" in little endian.
+const magic = 0x46554747
+
+// defaultAlignment is used when general.alignment is not specified.
+const defaultAlignment = 32
+
+// maxArrayDepth limits the recursion on corrupted files with nested metadata
+// arrays.
+const maxArrayDepth = 8
+
+// Type is a ggml tensor type.
+type Type uint32
+
+// Tensor types.
+const (
+	TypeF32  Type = 0
+	TypeF16  Type = 1
+	TypeQ4_0 Type = 2
+	TypeQ4_1 Type = 3
+	TypeQ5_0 Type = 6
+	TypeQ5_1 Type = 7
+	TypeQ8_0 Type = 8
+	TypeQ8_1 Type = 9
+	TypeQ2_K Type = 10
+	TypeQ3_K Type = 11
+	TypeQ4_K Type = 12
+	TypeQ5_K Type = 13
+	TypeQ6_K Type = 14
+	TypeQ8_K Type = 15
+	TypeI8   Type = 24
+	TypeI16  Type = 25
+	TypeI32  Type = 26
+	TypeI64  Type = 27
+	TypeF64  Type = 28
+	TypeBF16 Type = 30
+)
+
+// typeInfo is the number of values in a block and its size in bytes.
+var typeInfo = map[Type]struct {
+	name      string
+	blockLen  int
+	blockSize int
+}{
+	TypeF32:  {"F32", 1, 4},
+	TypeF16:  {"F16", 1, 2},
+	TypeQ4_0: {"Q4_0", 32, 2 + 16},
+	TypeQ4_1: {"Q4_1", 32, 2 + 2 + 16},
+	TypeQ5_0: {"Q5_0", 32, 2 + 4 + 16},
+	TypeQ5_1: {"Q5_1", 32, 2 + 2 + 4 + 16},
+	TypeQ8_0: {"Q8_0", 32, 2 + 32},
+	TypeQ8_1: {"Q8_1", 32, 2 + 2 + 32},
+	TypeQ2_K: {"Q2_K", 256, 16 + 64 + 2 + 2},
+	TypeQ3_K: {"Q3_K", 256, 32 + 64 + 12 + 2},
+	TypeQ4_K: {"Q4_K", 256, 2 + 2 + 12 + 128},
+	TypeQ5_K: {"Q5_K", 256, 2 + 2 + 12 + 32 + 128},
+	TypeQ6_K: {"Q6_K", 256, 128 + 64 + 16 + 2},
+	TypeQ8_K: {"Q8_K", 256, 4 + 256 + 32},
+	TypeI8:   {"I8", 1, 1},
+	TypeI16:  {"I16", 1, 2},
+	TypeI32:  {"I32", 1, 4},
+	TypeI64:  {"I64", 1, 8},
+	TypeF64:  {"F64", 1, 8},
+	TypeBF16: {"BF16", 1, 2},
+}
+
+func (t Type) String() string {
+	if i, ok := typeInfo[t]; ok {
+		return i.name
+	}
+	return "Type(" + strconv.Itoa(int(t)) + ")"
+}
+
+// File is the content of a GGUF file.
+type File struct {
+	Version uint32
+	// Metadata is in the order of the file.
+	Metadata []KeyValue
+	// Tensors is in the order of the file.
+	Tensors []Tensor
+	// Alignment is the alignment of the tensors' data.
+	Alignment uint64
+}
+
+// KeyValue is a metadata entry.
+//
+// Value is one of uint8, int8, uint16, int16, uint32, int32, uint64, int64,
+// float32, float64, bool, string, or a slice of one of them for arrays.
+// Arrays of arrays are []any.
+type KeyValue struct {
+	Key   string
+	Value any
+}
+
+// Tensor is one tensor in a File.
+type Tensor struct {
+	Name string
+	// Dims is the shape of the tensor, with the fastest varying dimension
+	// first, as in ggml.
+	Dims []uint64
+	Type Type
+	// Offset is the offset of Data relative to the start of the tensors data.
+	Offset uint64
+	// Data is the raw little endian data.
+	//
+	// It is nil for the types not supported by this package, like the IQ
+	// types, since their size is unknown. Decoding them fails.
+	Data []byte
+}
+
+// Parse parses a GGUF file loaded in memory.
+//
+// Only versions 2 and 3 are supported. The tensors' Data alias b, so b can be
+// a memory mapped file.
+func Parse(b []byte) (*File, error) {
+	r := reader{b: b}
+	if m := r.u32(); r.err == nil && m != magic {
+		return nil, errors.New("gguf: invalid magic")
+	}
+	f := &File{Version: r.u32(), Alignment: defaultAlignment}
+	if r.err == nil && f.Version != 2 && f.Version != 3 {
+		return nil, fmt.Errorf("gguf: unsupported version %d", f.Version)
+	}
+	tensorCount := r.count(8)
+	kvCount := r.count(8)
+	for i := uint64(0); i < kvCount && r.err == nil; i++ {
+		kv := KeyValue{Key: r.str()}
+		kv.Value = r.value(r.u32())
+		if kv.Key == "general.alignment" && r.err == nil {
+			a, ok := kv.Value.(uint32)
+			if !ok || a == 0 || a&(a-1) != 0 {
+				return nil, fmt.Errorf("gguf: invalid general.alignment %v", kv.Value)
+			}
+			f.Alignment = uint64(a)
+		}
+		f.Metadata = append(f.Metadata, kv)
+	}
+	for i := uint64(0); i < tensorCount && r.err == nil; i++ {
+		t := Tensor{Name: r.str()}
+		n := r.u32()
+		if r.err == nil && n > 4 {
+			return nil, fmt.Errorf("gguf: tensor %q: too many dimensions: %d", t.Name, n)
+		}
+		for range n {
+			t.Dims = append(t.Dims, r.u64())
+		}
+		t.Type = Type(r.u32())
+		t.Offset = r.u64()
+		f.Tensors = append(f.Tensors, t)
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	start := (uint64(r.off) + f.Alignment - 1) &^ (f.Alignment - 1)
+	if start > uint64(len(b)) {
+		return nil, errors.New("gguf: file too short")
+	}
+	data := b[start:]
+	for i := range f.Tensors {
+		t := &f.Tensors[i]
+		if t.Offset%f.Alignment != 0 {
+			return nil, fmt.Errorf("gguf: tensor %q: offset %d is not aligned on %d", t.Name, t.Offset, f.Alignment)
+		}
+		info, ok := typeInfo[t.Type]
+		if !ok {
+			// Keep the tensors of newer types so the rest of the file can be
+			// read.
+			if t.Offset > uint64(len(data)) {
+				return nil, fmt.Errorf("gguf: tensor %q: offset %d beyond the %d bytes of data", t.Name, t.Offset, len(data))
+			}
+			continue
+		}
+		size, err := t.size(info.blockLen, info.blockSize)
+		if err != nil {
+			return nil, err
+		}
+		if t.Offset > uint64(len(data)) || size > uint64(len(data))-t.Offset {
+			return nil, fmt.Errorf("gguf: tensor %q: %d bytes at offset %d beyond the %d bytes of data", t.Name, size, t.Offset, len(data))
+		}
+		t.Data = data[t.Offset : t.Offset+size : t.Offset+size]
+	}
+	return f, nil
+}
+
+// ReadFile reads and parses a GGUF file.
+func ReadFile(name string) (*File, error) {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(b)
+}
+
+// Get returns the value of a metadata key.
+func (f *File) Get(key string) (any, bool) {
+	for _, kv := range f.Metadata {
+		if kv.Key == key {
+			return kv.Value, true
+		}
+	}
+	return nil, false
+}
+
+// Tensor returns the tensor with the specified name, or nil.
+func (f *File) Tensor(name string) *Tensor {
+	for i := range f.Tensors {
+		if f.Tensors[i].Name == name {
+			return &f.Tensors[i]
+		}
+	}
+	return nil
+}
+
+// Len returns the number of elements in the tensor.
+func (t *Tensor) Len() uint64 {
+	l := uint64(1)
+	for _, d := range t.Dims {
+		l *= d
+	}
+	return l
+}
+
+// F16 returns the data of a F16 tensor.
+//
+// The slice aliases Data when possible. See floatx.ViewF16.
+func (t *Tensor) F16() ([]floatx.F16, error) {
+	if err := t.check(TypeF16); err != nil {
+		return nil, err
+	}
+	v, _, err := floatx.ViewF16(t.Data)
+	return v, err
+}
+
+// BF16 returns the data of a BF16 tensor.
+//
+// The slice aliases Data when possible. See floatx.ViewBF16.
+func (t *Tensor) BF16() ([]floatx.BF16, error) {
+	if err := t.check(TypeBF16); err != nil {
+		return nil, err
+	}
+	v, _, err := floatx.ViewBF16(t.Data)
+	return v, err
+}
+
+// F32 returns the data of a F32 tensor.
+//
+// The slice aliases Data when possible. See floatx.ViewF32.
+func (t *Tensor) F32() ([]floatx.F32, error) {
+	if err := t.check(TypeF32); err != nil {
+		return nil, err
+	}
+	v, _, err := floatx.ViewF32(t.Data)
+	return v, err
+}
+
+// Float32s decodes or dequantizes the data of the tensor into a new slice.
+//
+// Supported types are F32, F16, BF16, Q4_0, Q4_1, Q5_0, Q5_1, Q8_0, Q2_K,
+// Q3_K, Q4_K, Q5_K, Q6_K and Q8_K.
+func (t *Tensor) Float32s() ([]float32, error) {
+	info, ok := typeInfo[t.Type]
+	dequant := dequantizers[t.Type]
+	if !ok || dequant == nil {
+		return nil, fmt.Errorf("gguf: tensor %q: can't decode %s as float32", t.Name, t.Type)
+	}
+	if len(t.Data)%info.blockSize != 0 {
+		return nil, fmt.Errorf("gguf: tensor %q: %d bytes is not a multiple of %d", t.Name, len(t.Data), info.blockSize)
+	}
+	out := make([]float32, len(t.Data)/info.blockSize*info.blockLen)
+	dequant(out, t.Data)
+	return out, nil
+}
+
+func (t *Tensor) check(want Type) error {
+	if t.Type != want {
+		return fmt.Errorf("gguf: tensor %q is %s, not %s", t.Name, t.Type, want)
+	}
+	return nil
+}
+
+// size returns the size in bytes of the tensor's data.
+func (t *Tensor) size(blockLen, blockSize int) (uint64, error) {
+	l := uint64(1)
+	for _, d := range t.Dims {
+		if d != 0 && l > math.MaxUint64/d {
+			return 0, fmt.Errorf("gguf: tensor %q: dims %v overflow", t.Name, t.Dims)
+		}
+		l *= d
+	}
+	if len(t.Dims) != 0 && t.Dims[0]%uint64(blockLen) != 0 {
+		return 0, fmt.Errorf("gguf: tensor %q: first dimension %d is not a multiple of the %s block size %d", t.Name, t.Dims[0], t.Type, blockLen)
+	}
+	blocks := l / uint64(blockLen)
+	if blocks > math.MaxUint64/uint64(blockSize) {
+		return 0, fmt.Errorf("gguf: tensor %q: dims %v overflow", t.Name, t.Dims)
+	}
+	return blocks * uint64(blockSize), nil
+}
+
+// reader decodes little endian values and stops at the first error.
+type reader struct {
+	b   []byte
+	off int
+	err error
+	// depth is the nesting level of the array being read.
+	depth int
+}
+
+func (r *reader) next(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n > len(r.b)-r.off {
+		r.err = fmt.Errorf("gguf: unexpected end of file at offset %d", r.off)
+		return nil
+	}
+	b := r.b[r.off : r.off+n]
+	r.off += n
+	return b
+}
+
+func (r *reader) u8() uint8 {
+	if b := r.next(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (r *reader) u16() uint16 {
+	if b := r.next(2); b != nil {
+		return binary.LittleEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (r *reader) u32() uint32 {
+	if b := r.next(4); b != nil {
+		return binary.LittleEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (r *reader) u64() uint64 {
+	if b := r.next(8); b != nil {
+		return binary.LittleEndian.Uint64(b)
+	}
+	return 0
+}
+
+// count reads an item count and verifies it is plausible, given that each
+// item takes at least minSize bytes. It prevents huge allocations on corrupted
+// files.
+func (r *reader) count(minSize int) uint64 {
+	n := r.u64()
+	if r.err == nil && n > uint64(len(r.b)-r.off)/uint64(minSize) {
+		r.err = fmt.Errorf("gguf: invalid count %d at offset %d", n, r.off-8)
+		return 0
+	}
+	return n
+}
+
+func (r *reader) str() string {
+	return string(r.next(int(r.count(1))))
+}
+
+// valueSizes is the minimum size in bytes of each value type.
+var valueSizes = [...]int{1, 1, 2, 2, 4, 4, 4, 1, 8, 12, 8, 8, 8}
+
+// value reads a metadata value of the specified type.
+func (r *reader) value(typ uint32) any {
+	if r.err != nil {
+		return nil
+	}
+	switch typ {
+	case 0:
+		return r.u8()
+	case 1:
+		return int8(r.u8())
+	case 2:
+		return r.u16()
+	case 3:
+		return int16(r.u16())
+	case 4:
+		return r.u32()
+	case 5:
+		return int32(r.u32())
+	case 6:
+		return math.Float32frombits(r.u32())
+	case 7:
+		return r.u8() != 0
+	case 8:
+		return r.str()
+	case 9:
+		if r.depth == maxArrayDepth {
+			r.err = fmt.Errorf("gguf: arrays nested deeper than %d at offset %d", maxArrayDepth, r.off)
+			return nil
+		}
+		r.depth++
+		defer func() { r.depth-- }()
+		elem := r.u32()
+		if r.err == nil && int(elem) >= len(valueSizes) {
+			r.err = fmt.Errorf("gguf: invalid value type %d at offset %d", elem, r.off-4)
+			return nil
+		}
+		n := r.count(valueSizes[elem%uint32(len(valueSizes))])
+		switch elem {
+		case 0:
+			return readArray(r, n, (*reader).u8)
+		case 1:
+			return readArray(r, n, func(r *reader) int8 { return int8(r.u8()) })
+		case 2:
+			return readArray(r, n, (*reader).u16)
+		case 3:
+			return readArray(r, n, func(r *reader) int16 { return int16(r.u16()) })
+		case 4:
+			return readArray(r, n, (*reader).u32)
+		case 5:
+			return readArray(r, n, func(r *reader) int32 { return int32(r.u32()) })
+		case 6:
+			return readArray(r, n, func(r *reader) float32 { return math.Float32frombits(r.u32()) })
+		case 7:
+			return readArray(r, n, func(r *reader) bool { return r.u8() != 0 })
+		case 8:
+			return readArray(r, n, (*reader).str)
+		case 9:
+			return readArray(r, n, func(r *reader) any { return r.value(9) })
+		case 10:
+			return readArray(r, n, (*reader).u64)
+		case 11:
+			return readArray(r, n, func(r *reader) int64 { return int64(r.u64()) })
+		default:
+			return readArray(r, n, func(r *reader) float64 { return math.Float64frombits(r.u64()) })
+		}
+	case 10:
+		return r.u64()
+	case 11:
+		return int64(r.u64())
+	case 12:
+		return math.Float64frombits(r.u64())
+	default:
+		r.err = fmt.Errorf("gguf: invalid value type %d at offset %d", typ, r.off-4)
+		return nil
+	}
+}
+
+func readArray[T any](r *reader, n uint64, fn func(r *reader) T) []T {
+	out := make([]T, n)
+	for i := range out {
+		out[i] = fn(r)
+	}
+	return out
+}
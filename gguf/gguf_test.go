@@ -0,0 +1,376 @@
+// Copyright 2024 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package gguf_test
+
+import (
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/maruel/floatx"
+	"github.com/maruel/floatx/gguf"
+)
+
+// builder writes a GGUF file for testing.
+type builder struct {
+	b []byte
+}
+
+func (w *builder) u8(v uint8) *builder   { w.b = append(w.b, v); return w }
+func (w *builder) u16(v uint16) *builder { w.b = binary.LittleEndian.AppendUint16(w.b, v); return w }
+func (w *builder) u32(v uint32) *builder { w.b = binary.LittleEndian.AppendUint32(w.b, v); return w }
+func (w *builder) u64(v uint64) *builder { w.b = binary.LittleEndian.AppendUint64(w.b, v); return w }
+func (w *builder) str(s string) *builder { w.u64(uint64(len(s))); w.b = append(w.b, s...); return w }
+
+type tensor struct {
+	name string
+	dims []uint64
+	typ  gguf.Type
+	data []byte
+}
+
+// file builds a file. kvs must write the key value pairs.
+func file(kvCount int, kvs func(w *builder), tensors []tensor) []byte {
+	w := &builder{}
+	w.u32(0x46554747).u32(3).u64(uint64(len(tensors))).u64(uint64(kvCount))
+	kvs(w)
+	var offset uint64
+	for _, t := range tensors {
+		w.str(t.name).u32(uint32(len(t.dims)))
+		for _, d := range t.dims {
+			w.u64(d)
+		}
+		w.u32(uint32(t.typ)).u64(offset)
+		offset += (uint64(len(t.data)) + 31) &^ 31
+	}
+	for len(w.b)%32 != 0 {
+		w.u8(0)
+	}
+	for _, t := range tensors {
+		w.b = append(w.b, t.data...)
+		for len(w.b)%32 != 0 {
+			w.u8(0)
+		}
+	}
+	return w.b
+}
+
+func f16(v float32) []byte {
+	return floatx.AppendF16(nil, floatx.F16FromFloat32(v))
+}
+
+func Test_Parse(t *testing.T) {
+	kvs := func(w *builder) {
+		w.str("u8").u32(0).u8(1)
+		w.str("i8").u32(1).u8(0xFF)
+		w.str("u16").u32(2).u16(2)
+		w.str("i16").u32(3).u16(0xFFFE)
+		w.str("u32").u32(4).u32(3)
+		w.str("i32").u32(5).u32(0xFFFFFFFD)
+		w.str("f32").u32(6).u32(math.Float32bits(1.5))
+		w.str("bool").u32(7).u8(1)
+		w.str("general.name").u32(8).str("test")
+		w.str("u64").u32(10).u64(4)
+		w.str("i64").u32(11).u64(math.MaxUint64)
+		w.str("f64").u32(12).u64(math.Float64bits(2.5))
+		w.str("general.alignment").u32(4).u32(32)
+		w.str("a.u8").u32(9).u32(0).u64(2).u8(1).u8(2)
+		w.str("a.i8").u32(9).u32(1).u64(1).u8(0xFF)
+		w.str("a.u16").u32(9).u32(2).u64(1).u16(1)
+		w.str("a.i16").u32(9).u32(3).u64(1).u16(0xFFFF)
+		w.str("a.u32").u32(9).u32(4).u64(1).u32(1)
+		w.str("a.i32").u32(9).u32(5).u64(1).u32(0xFFFFFFFF)
+		w.str("a.f32").u32(9).u32(6).u64(1).u32(math.Float32bits(0.5))
+		w.str("a.bool").u32(9).u32(7).u64(2).u8(0).u8(1)
+		w.str("a.str").u32(9).u32(8).u64(2).str("a").str("bc")
+		w.str("a.arr").u32(9).u32(9).u64(1).u32(0).u64(1).u8(7)
+		w.str("a.u64").u32(9).u32(10).u64(1).u64(1)
+		w.str("a.i64").u32(9).u32(11).u64(1).u64(math.MaxUint64)
+		w.str("a.f64").u32(9).u32(12).u64(1).u64(math.Float64bits(0.25))
+	}
+	tensors := []tensor{
+		{"f16", []uint64{2}, gguf.TypeF16, append(f16(1), f16(-2)...)},
+		{"bf16", []uint64{2}, gguf.TypeBF16, []byte{0x80, 0x3F, 0x00, 0xC0}},
+		{"f32", []uint64{1, 1}, gguf.TypeF32, binary.LittleEndian.AppendUint32(nil, math.Float32bits(3))},
+		{"i8", []uint64{1}, gguf.TypeI8, []byte{1}},
+	}
+	f, err := gguf.Parse(file(26, kvs, tensors))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Version != 3 || f.Alignment != 32 || len(f.Metadata) != 26 || len(f.Tensors) != 4 {
+		t.Fatalf("%+v", f)
+	}
+	want := map[string]any{
+		"u8": uint8(1), "i8": int8(-1), "u16": uint16(2), "i16": int16(-2),
+		"u32": uint32(3), "i32": int32(-3), "f32": float32(1.5), "bool": true,
+		"general.name": "test", "u64": uint64(4), "i64": int64(-1), "f64": 2.5,
+		"a.u8": []uint8{1, 2}, "a.i8": []int8{-1}, "a.u16": []uint16{1},
+		"a.i16": []int16{-1}, "a.u32": []uint32{1}, "a.i32": []int32{-1},
+		"a.f32": []float32{0.5}, "a.bool": []bool{false, true},
+		"a.str": []string{"a", "bc"}, "a.arr": []any{[]uint8{7}},
+		"a.u64": []uint64{1}, "a.i64": []int64{-1}, "a.f64": []float64{0.25},
+	}
+	for k, v := range want {
+		if got, ok := f.Get(k); !ok || !reflect.DeepEqual(got, v) {
+			t.Errorf("%s: want=%#v got=%#v", k, v, got)
+		}
+	}
+	if _, ok := f.Get("missing"); ok {
+		t.Fatal("unexpected")
+	}
+	if f.Tensor("missing") != nil {
+		t.Fatal("unexpected")
+	}
+
+	tf16 := f.Tensor("f16")
+	if v, err := tf16.F16(); err != nil || len(v) != 2 || v[1].Float32() != -2 {
+		t.Fatal(v, err)
+	}
+	if _, err := tf16.BF16(); err == nil {
+		t.Fatal("expected error")
+	}
+	if v, err := tf16.Float32s(); err != nil || !slices.Equal(v, []float32{1, -2}) {
+		t.Fatal(v, err)
+	}
+	tbf16 := f.Tensor("bf16")
+	if v, err := tbf16.BF16(); err != nil || len(v) != 2 || v[0].Float32() != 1 {
+		t.Fatal(v, err)
+	}
+	if _, err := tbf16.F32(); err == nil {
+		t.Fatal("expected error")
+	}
+	if v, err := tbf16.Float32s(); err != nil || !slices.Equal(v, []float32{1, -2}) {
+		t.Fatal(v, err)
+	}
+	tf32 := f.Tensor("f32")
+	if v, err := tf32.F32(); err != nil || len(v) != 1 || v[0] != 3 {
+		t.Fatal(v, err)
+	}
+	if _, err := tf32.F16(); err == nil {
+		t.Fatal("expected error")
+	}
+	if v, err := tf32.Float32s(); err != nil || !slices.Equal(v, []float32{3}) {
+		t.Fatal(v, err)
+	}
+	if tf32.Len() != 1 {
+		t.Fatal(tf32.Len())
+	}
+	if _, err := f.Tensor("i8").Float32s(); err == nil || err.Error() != `gguf: tensor "i8": can't decode I8 as float32` {
+		t.Fatal(err)
+	}
+	if _, err := (&gguf.Tensor{Type: gguf.TypeQ8_0, Data: make([]byte, 3)}).Float32s(); err == nil {
+		t.Fatal("expected error")
+	}
+
+	p := filepath.Join(t.TempDir(), "a.gguf")
+	if err = os.WriteFile(p, file(0, func(*builder) {}, tensors[:1]), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if f, err = gguf.ReadFile(p); err != nil || len(f.Tensors) != 1 {
+		t.Fatal(f, err)
+	}
+	if _, err = gguf.ReadFile(p + "x"); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func Test_Parse_UnsupportedType(t *testing.T) {
+	// A tensor of a type not supported doesn't prevent reading the others.
+	b := file(0, func(*builder) {}, []tensor{
+		{"iq", []uint64{256}, 100, make([]byte, 66)},
+		{"f32", []uint64{1}, gguf.TypeF32, []byte{0, 0, 0x40, 0x40}},
+	})
+	f, err := gguf.Parse(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	iq := f.Tensor("iq")
+	if iq.Type != 100 || iq.Data != nil {
+		t.Fatal(iq)
+	}
+	if _, err = iq.Float32s(); err == nil || err.Error() != `gguf: tensor "iq": can't decode Type(100) as float32` {
+		t.Fatal(err)
+	}
+	if v, err := f.Tensor("f32").F32(); err != nil || len(v) != 1 || v[0] != 3 {
+		t.Fatal(v, err)
+	}
+}
+
+func Test_Type_String(t *testing.T) {
+	if s := gguf.TypeQ4_K.String(); s != "Q4_K" {
+		t.Fatal(s)
+	}
+	if s := gguf.Type(100).String(); s != "Type(100)" {
+		t.Fatal(s)
+	}
+}
+
+func Test_Parse_Errors(t *testing.T) {
+	none := func(*builder) {}
+	data := []struct {
+		b    []byte
+		want string
+	}{
+		{nil, "gguf: unexpected end of file at offset 0"},
+		{[]byte("GGML0000"), "gguf: invalid magic"},
+		{(&builder{}).u32(0x46554747).u32(1).b, "gguf: unsupported version 1"},
+		{(&builder{}).u32(0x46554747).u32(3).u64(1 << 60).u64(0).b, "gguf: invalid count 1152921504606846976 at offset 8"},
+		{(&builder{}).u32(0x46554747).u32(3).u64(0).u64(1).str("x").u32(0).b, "gguf: unexpected end of file at offset 37"},
+		{(&builder{}).u32(0x46554747).u32(3).u64(0).u64(1).str("x").u32(2).b, "gguf: unexpected end of file at offset 37"},
+		{(&builder{}).u32(0x46554747).u32(3).u64(0).u64(1).str("x").b, "gguf: unexpected end of file at offset 33"},
+		{file(1, func(w *builder) { w.str("x").u32(13) }, nil), "gguf: invalid value type 13 at offset 33"},
+		{file(1, func(w *builder) { w.str("x").u32(9).u32(13).u64(0) }, nil), "gguf: invalid value type 13 at offset 37"},
+		{file(1, func(w *builder) {
+			w.str("x").u32(9)
+			for range 9 {
+				w.u32(9).u64(1)
+			}
+		}, nil), "gguf: arrays nested deeper than 8 at offset 133"},
+		{file(1, func(w *builder) { w.str("general.alignment").u32(4).u32(3) }, nil), "gguf: invalid general.alignment 3"},
+		{file(1, func(w *builder) { w.str("general.alignment").u32(10).u64(32) }, nil), "gguf: invalid general.alignment 32"},
+		{file(0, none, []tensor{{"x", []uint64{1, 1, 1, 1, 1}, gguf.TypeF32, nil}}), `gguf: tensor "x": too many dimensions: 5`},
+		{file(0, none, []tensor{{"x", []uint64{1 << 32, 1 << 32}, gguf.TypeF32, nil}}), `gguf: tensor "x": dims [4294967296 4294967296] overflow`},
+		{file(0, none, []tensor{{"x", []uint64{1 << 32, 1 << 31}, gguf.TypeF32, nil}}), `gguf: tensor "x": dims [4294967296 2147483648] overflow`},
+		{file(0, none, []tensor{{"x", []uint64{16}, gguf.TypeQ8_0, nil}}), `gguf: tensor "x": first dimension 16 is not a multiple of the Q8_0 block size 32`},
+		{file(0, none, []tensor{{"x", []uint64{16}, gguf.TypeF32, []byte{0, 0, 0, 0}}}), `gguf: tensor "x": 64 bytes at offset 0 beyond the 32 bytes of data`},
+	}
+	for i, line := range data {
+		if _, err := gguf.Parse(line.b); err == nil || err.Error() != line.want {
+			t.Errorf("#%d: want=%q got=%v", i, line.want, err)
+		}
+	}
+	// Header truncated before the data.
+	b := file(0, none, []tensor{{"x", []uint64{1}, gguf.TypeF32, []byte{0, 0, 0, 0}}})
+	if _, err := gguf.Parse(b[:60]); err == nil || err.Error() != "gguf: file too short" {
+		t.Fatal(err)
+	}
+	// Misaligned offset.
+	b = file(0, none, []tensor{{"x", []uint64{1}, gguf.TypeF32, []byte{0, 0, 0, 0}}})
+	b[24+8+1+4+8+4] = 4
+	if _, err := gguf.Parse(b); err == nil || !strings.Contains(err.Error(), "is not aligned on 32") {
+		t.Fatal(err)
+	}
+	// Unsupported type at an offset beyond the data.
+	b = file(0, none, []tensor{{"x", []uint64{1}, 100, nil}})
+	b[24+8+1+4+8+4+1] = 1
+	if _, err := gguf.Parse(b); err == nil || err.Error() != `gguf: tensor "x": offset 256 beyond the 0 bytes of data` {
+		t.Fatal(err)
+	}
+}
+
+func Test_Dequantize(t *testing.T) {
+	// Each block is built with simple scales so the expected values are easy
+	// to compute by hand.
+	seq := func(n int, fn func(i int) byte) []byte {
+		out := make([]byte, n)
+		for i := range out {
+			out[i] = fn(i)
+		}
+		return out
+	}
+	cat := func(b ...[]byte) []byte {
+		var out []byte
+		for _, x := range b {
+			out = append(out, x...)
+		}
+		return out
+	}
+	q4_0 := make([]float32, 32)
+	q4_1 := make([]float32, 32)
+	q5_0 := make([]float32, 32)
+	q5_1 := make([]float32, 32)
+	for j := range 16 {
+		q4_0[j], q4_0[j+16] = float32(j-8)*0.5, float32(15-j-8)*0.5
+		q4_1[j], q4_1[j+16] = float32(j)*0.5+1, float32(15-j)*0.5+1
+		// Only the odd elements have their high bit set.
+		q5_0[j], q5_0[j+16] = float32(j+16*(j&1)-16)*2, float32(15-j+16*(j&1)-16)*2
+		q5_1[j], q5_1[j+16] = float32(j+16*(j&1))*2-1, float32(15-j+16*(j&1))*2-1
+	}
+	nibbles := seq(16, func(i int) byte { return byte(i | (15-i)<<4) })
+	odd := binary.LittleEndian.AppendUint32(nil, 0xAAAAAAAA)
+	q8_0 := make([]float32, 32)
+	q8_k := make([]float32, 256)
+	for j := range q8_0 {
+		q8_0[j] = float32(j-16) * 0.25
+	}
+	for j := range q8_k {
+		q8_k[j] = float32(int8(j)) * 3
+	}
+	// K quants: all the sub-block scales are 1 and mins are 2.
+	scalesK4 := []byte{1, 1, 1, 1, 2, 2, 2, 2, 0x21, 0x21, 0x21, 0x21}
+	q4_k := make([]float32, 256)
+	q5_k := make([]float32, 256)
+	for j := range 4 {
+		for l := range 32 {
+			q4_k[64*j+l] = float32((32*j+l)&0xF) - 1
+			q4_k[64*j+l+32] = float32(((32*j+l)&0xFF)>>4) - 1
+			// The high bit is set for the low nibble on even values and the high
+			// nibble on odd values.
+			q5_k[64*j+l] = float32((32*j+l)&0xF+16*(1-l&1)) - 1
+			q5_k[64*j+l+32] = float32(((32*j+l)&0xFF)>>4+16*(l&1)) - 1
+		}
+	}
+	q6_k := make([]float32, 256)
+	for j := range q6_k {
+		// ql is 0, qh is 0xFF so every quant is 48-32.
+		q6_k[j] = 16 * 2
+	}
+	q2_k := make([]float32, 256)
+	for j := range q2_k {
+		// All the 2 bits quants are 3, scale 1 and min 1.
+		q2_k[j] = 3*0.5 - 1
+	}
+	q3_k := make([]float32, 256)
+	for j := range q3_k {
+		// Quants are 3, the high bit is set for the first 128 values, scales are
+		// 33.
+		v := float32(3)
+		if j >= 128 {
+			v = -1
+		}
+		q3_k[j] = v * 0.5
+	}
+	scalesK3 := slices.Repeat([]byte{0x11}, 8)
+	scalesK3 = append(scalesK3, 0xAA, 0xAA, 0xAA, 0xAA)
+	hmask := slices.Repeat([]byte{0x0F}, 32)
+	data := []struct {
+		typ  gguf.Type
+		data []byte
+		want []float32
+	}{
+		{gguf.TypeQ4_0, cat(f16(0.5), nibbles), q4_0},
+		{gguf.TypeQ4_1, cat(f16(0.5), f16(1), nibbles), q4_1},
+		{gguf.TypeQ5_0, cat(f16(2), odd, nibbles), q5_0},
+		{gguf.TypeQ5_1, cat(f16(2), f16(-1), odd, nibbles), q5_1},
+		{gguf.TypeQ8_0, cat(f16(0.25), seq(32, func(i int) byte { return byte(i - 16) })), q8_0},
+		{gguf.TypeQ8_K, cat(binary.LittleEndian.AppendUint32(nil, math.Float32bits(3)), seq(256, func(i int) byte { return byte(i) }), make([]byte, 32)), q8_k},
+		{gguf.TypeQ4_K, cat(f16(1), f16(0.5), scalesK4, seq(128, func(i int) byte { return byte(i) })), q4_k},
+		{gguf.TypeQ5_K, cat(f16(1), f16(0.5), scalesK4, slices.Repeat([]byte{0x55, 0xAA}, 16), seq(128, func(i int) byte { return byte(i) })), q5_k},
+		{gguf.TypeQ6_K, cat(make([]byte, 128), slices.Repeat([]byte{0xFF}, 64), slices.Repeat([]byte{2}, 16), f16(1)), q6_k},
+		{gguf.TypeQ2_K, cat(slices.Repeat([]byte{0x11}, 16), slices.Repeat([]byte{0xFF}, 64), f16(0.5), f16(1)), q2_k},
+		{gguf.TypeQ3_K, cat(hmask, slices.Repeat([]byte{0xFF}, 64), scalesK3, f16(0.5)), q3_k},
+	}
+	for _, line := range data {
+		t.Run(line.typ.String(), func(t *testing.T) {
+			// Two blocks.
+			b := append(slices.Clone(line.data), line.data...)
+			tt := gguf.Tensor{Name: "x", Type: line.typ, Data: b}
+			got, err := tt.Float32s()
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := append(slices.Clone(line.want), line.want...)
+			if !slices.Equal(got, want) {
+				t.Fatalf("want=%v\ngot= %v", want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+// Copyright 2024 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package floatx
+
+import (
+	"math"
+	"math/big"
+)
+
+// Integer is the constraint of the integer types supported by ToInt and
+// FromInt.
+type Integer interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 | ~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64
+}
+
+// ToInt converts v to an integer rounded as selected by opts and returns the
+// exceptions raised. opts can be nil to round to nearest even without
+// saturation. Use RoundTowardZero to truncate like a Go conversion.
+//
+// NaN and the values out of the range of I, including infinities, raise
+// FlagInvalid. NaN becomes 0. The values out of range become the closest
+// bound of I when opts.Saturate is true, like the ONNX QuantizeLinear
+// operator, and 0 otherwise. FlagInexact is raised when an in range value is
+// rounded.
+//
+// The integer type is specified explicitly and the source type is inferred:
+//
+//	i, _ := floatx.ToInt[int8](floatx.F16(0x4900), nil)
+func ToInt[I Integer, T Float](v T, opts *ConvertOptions) (I, Flags) {
+	var o ConvertOptions
+	if opts != nil {
+		o = *opts
+	}
+	f, bits := toBits(v)
+	x := f.toFloat64(bits)
+	if math.IsNaN(x) {
+		return 0, FlagInvalid
+	}
+	negative := math.Signbit(x)
+	q := math.Copysign(o.Rounding.round(math.Abs(x), negative), x)
+	lo, hi, minI, maxI := intRange[I]()
+	switch {
+	case q < lo:
+		if o.Saturate {
+			return minI, FlagInvalid
+		}
+		return 0, FlagInvalid
+	case q >= hi:
+		if o.Saturate {
+			return maxI, FlagInvalid
+		}
+		return 0, FlagInvalid
+	}
+	var flags Flags
+	if q != x {
+		flags = FlagInexact
+	}
+	if q < 0 {
+		return I(int64(q)), flags
+	}
+	return I(uint64(q)), flags
+}
+
+// ToInt32 is ToInt[int32].
+func ToInt32[T Float](v T, opts *ConvertOptions) (int32, Flags) {
+	return ToInt[int32](v, opts)
+}
+
+// ToInt8 is ToInt[int8].
+func ToInt8[T Float](v T, opts *ConvertOptions) (int8, Flags) {
+	return ToInt[int8](v, opts)
+}
+
+// ToUint8 is ToInt[uint8].
+func ToUint8[T Float](v T, opts *ConvertOptions) (uint8, Flags) {
+	return ToInt[uint8](v, opts)
+}
+
+// FromInt returns i rounded to T as selected by opts and the exceptions
+// raised. i is rounded once, exactly, e.g. an int32 larger than 2^8 may not
+// be representable as a BF16.
+//
+// opts can be nil to round to nearest even without saturation.
+// DenormalsAreZero, FlushToZero and NaN are ignored.
+//
+// The destination type is specified explicitly and the source type is
+// inferred:
+//
+//	b, _ := floatx.FromInt[floatx.BF16](int32(257), nil)
+func FromInt[T Float, I Integer](i I, opts *ConvertOptions) (T, Flags) {
+	var o ConvertOptions
+	if opts != nil {
+		o = *opts
+	}
+	var z T
+	dst, _ := toBits(z)
+	var r uint32
+	var flags Flags
+	switch {
+	case i < 0 && int64(i) > -1<<53:
+		r, flags = dst.round(float64(i), o.Rounding, o.Saturate)
+	case i >= 0 && uint64(i) < 1<<53:
+		r, flags = dst.round(float64(uint64(i)), o.Rounding, o.Saturate)
+	case i < 0:
+		// float64 can't represent it exactly.
+		r, flags = dst.roundRat(new(big.Rat).SetInt64(int64(i)), o.Rounding, o.Saturate)
+	default:
+		r, flags = dst.roundRat(new(big.Rat).SetUint64(uint64(i)), o.Rounding, o.Saturate)
+	}
+	return FromBits[T](r), flags
+}
+
+// Internal
+
+// intRange returns the range [lo, hi) of I as float64, which are exact since
+// they are powers of 2 or 0, and the smallest and largest values of I.
+func intRange[I Integer]() (lo, hi float64, minI, maxI I) {
+	n := 0
+	for v := I(1); v != 0; v <<= 1 {
+		n++
+	}
+	if ^I(0) < 0 {
+		maxI = I(1)<<(n-1) - 1
+		return -math.Ldexp(1, n-1), math.Ldexp(1, n-1), -maxI - 1, maxI
+	}
+	return 0, math.Ldexp(1, n), 0, ^I(0)
+}
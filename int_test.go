@@ -0,0 +1,212 @@
+// Copyright 2024 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package floatx_test
+
+import (
+	"math"
+	"math/big"
+	"math/rand/v2"
+	"slices"
+	"testing"
+
+	"github.com/maruel/floatx"
+)
+
+func Test_ToInt_All(t *testing.T) {
+	t.Run("BF16", func(t *testing.T) { testToIntFrom(t, slices.Collect(floatx.All[floatx.BF16]())) })
+	t.Run("F16", func(t *testing.T) { testToIntFrom(t, slices.Collect(floatx.All[floatx.F16]())) })
+	t.Run("F8E4M3", func(t *testing.T) { testToIntFrom(t, slices.Collect(floatx.All[floatx.F8E4M3]())) })
+	t.Run("F8E4M3Fn", func(t *testing.T) { testToIntFrom(t, slices.Collect(floatx.All[floatx.F8E4M3Fn]())) })
+	t.Run("F8E5M2", func(t *testing.T) { testToIntFrom(t, slices.Collect(floatx.All[floatx.F8E5M2]())) })
+	t.Run("F32", func(t *testing.T) {
+		r := rand.New(rand.NewPCG(1, 2))
+		src := []floatx.F32{0, floatx.F32(math.Copysign(0, -1)), 0.5, -0.5, 1.5, 2.5, -2.5, 127.5, -128.5, 255.5, 2147483520, -2147483648, 2147483648}
+		for range 1 << 12 {
+			// Mostly in the integer ranges.
+			src = append(src, floatx.F32(r.NormFloat64()*float64(int(1)<<r.IntN(34))))
+		}
+		testToIntFrom(t, src)
+	})
+}
+
+func testToIntFrom[T floatx.Float](t *testing.T, src []T) {
+	t.Run("int8", func(t *testing.T) { testToInt[int8](t, src, math.MinInt8, math.MaxInt8) })
+	t.Run("uint8", func(t *testing.T) { testToInt[uint8](t, src, 0, math.MaxUint8) })
+	t.Run("int32", func(t *testing.T) { testToInt[int32](t, src, math.MinInt32, math.MaxInt32) })
+	t.Run("uint64", func(t *testing.T) { testToInt[uint64](t, src, 0, 1<<64) })
+}
+
+// testToInt verifies ToInt against the math package.
+func testToInt[I floatx.Integer, T floatx.Float](t *testing.T, src []T, lo, hi float64) {
+	for _, s := range src {
+		x := valueOf(s)
+		for _, mode := range roundingModes {
+			var q float64
+			switch mode {
+			case floatx.RoundNearestEven:
+				q = math.RoundToEven(x)
+			case floatx.RoundNearestAway:
+				q = math.Round(x)
+			case floatx.RoundTowardZero:
+				q = math.Trunc(x)
+			case floatx.RoundAwayFromZero:
+				q = math.Trunc(x)
+				if q != x {
+					q += math.Copysign(1, x)
+				}
+			case floatx.RoundTowardNegative:
+				q = math.Floor(x)
+			case floatx.RoundTowardPositive:
+				q = math.Ceil(x)
+			}
+			for _, saturate := range []bool{false, true} {
+				var want I
+				var wantFlags floatx.Flags
+				switch {
+				case math.IsNaN(x):
+					wantFlags = floatx.FlagInvalid
+				case q < lo:
+					wantFlags = floatx.FlagInvalid
+					if saturate {
+						want = I(lo)
+					}
+				case q > hi || q == hi && hi == 1<<64:
+					// float64(math.MaxUint64) is 2^64.
+					wantFlags = floatx.FlagInvalid
+					if saturate {
+						want = ^I(0)
+						if want < 0 {
+							want = I(hi)
+						}
+					}
+				default:
+					want = I(q)
+					if q != x {
+						wantFlags = floatx.FlagInexact
+					}
+				}
+				got, flags := floatx.ToInt[I](s, &floatx.ConvertOptions{Rounding: mode, Saturate: saturate})
+				if got != want || flags != wantFlags {
+					t.Fatalf("ToInt(%g, %s, %t) = %d, %s; want %d, %s", x, mode, saturate, got, flags, want, wantFlags)
+				}
+			}
+		}
+	}
+}
+
+func Test_ToInt_SpotCheck(t *testing.T) {
+	sat := &floatx.ConvertOptions{Saturate: true}
+	if got, flags := floatx.ToInt8(floatx.BF16FromFloat32(300), sat); got != 127 || flags != floatx.FlagInvalid {
+		t.Fatal(got, flags)
+	}
+	if got, flags := floatx.ToInt8(floatx.BF16FromFloat32(-300), nil); got != 0 || flags != floatx.FlagInvalid {
+		t.Fatal(got, flags)
+	}
+	if got, flags := floatx.ToUint8(floatx.F16FromFloat32(-0.25), nil); got != 0 || flags != floatx.FlagInexact {
+		t.Fatal(got, flags)
+	}
+	if got, flags := floatx.ToUint8(floatx.F8E4M3Fn(0x7F), sat); got != 0 || flags != floatx.FlagInvalid {
+		t.Fatal(got, flags)
+	}
+	if got, flags := floatx.ToInt32(floatx.F8E5M2(0xFC), sat); got != math.MinInt32 || flags != floatx.FlagInvalid {
+		t.Fatal(got, flags)
+	}
+	if got, flags := floatx.ToInt32(floatx.F8E4M3(0x45), &floatx.ConvertOptions{Rounding: floatx.RoundTowardZero}); got != 3 || flags != floatx.FlagInexact {
+		t.Fatal(got, flags)
+	}
+	if got, flags := floatx.ToInt[int64](floatx.F32(-1<<63), nil); got != math.MinInt64 || flags != 0 {
+		t.Fatal(got, flags)
+	}
+	if got, flags := floatx.ToInt[int64](floatx.F32(1<<63), sat); got != math.MaxInt64 || flags != floatx.FlagInvalid {
+		t.Fatal(got, flags)
+	}
+	type myInt int16
+	if got, flags := floatx.ToInt[myInt](floatx.F16FromFloat32(-1000.5), nil); got != -1000 || flags != floatx.FlagInexact {
+		t.Fatal(got, flags)
+	}
+}
+
+func Test_FromInt_All(t *testing.T) {
+	var src []int16
+	for i := math.MinInt16; i <= math.MaxInt16; i++ {
+		src = append(src, int16(i))
+	}
+	t.Run("int16", func(t *testing.T) { testFromIntTo(t, src) })
+	r := rand.New(rand.NewPCG(1, 2))
+	var src64 []int64
+	var srcU64 []uint64
+	for range 1 << 10 {
+		src64 = append(src64, r.Int64()>>r.IntN(63), -r.Int64()>>r.IntN(63))
+		srcU64 = append(srcU64, r.Uint64()>>r.IntN(64))
+	}
+	src64 = append(src64, math.MinInt64, math.MaxInt64, -1<<53, 1<<53, 1<<53+1, -1<<53-1)
+	srcU64 = append(srcU64, math.MaxUint64, 1<<53, 1<<53+1)
+	t.Run("int64", func(t *testing.T) { testFromIntTo(t, src64) })
+	t.Run("uint64", func(t *testing.T) { testFromIntTo(t, srcU64) })
+}
+
+func testFromIntTo[I floatx.Integer](t *testing.T, src []I) {
+	t.Run("F32", func(t *testing.T) { testFromInt[floatx.F32](t, src) })
+	t.Run("BF16", func(t *testing.T) { testFromInt[floatx.BF16](t, src) })
+	t.Run("F16", func(t *testing.T) { testFromInt[floatx.F16](t, src) })
+	t.Run("F8E4M3", func(t *testing.T) { testFromInt[floatx.F8E4M3](t, src) })
+	t.Run("F8E4M3Fn", func(t *testing.T) { testFromInt[floatx.F8E4M3Fn](t, src) })
+	t.Run("F8E5M2", func(t *testing.T) { testFromInt[floatx.F8E5M2](t, src) })
+}
+
+// testFromInt verifies FromInt against FromRat.
+func testFromInt[T floatx.Float, I floatx.Integer](t *testing.T, src []I) {
+	for _, i := range src {
+		r := new(big.Rat)
+		if i < 0 {
+			r.SetInt64(int64(i))
+		} else {
+			r.SetUint64(uint64(i))
+		}
+		for _, mode := range roundingModes {
+			for _, saturate := range []bool{false, true} {
+				opts := floatx.ConvertOptions{Rounding: mode, Saturate: saturate}
+				want, wantFlags := floatx.FromRat[T](r, &opts)
+				if got, flags := floatx.FromInt[T](i, &opts); got != want || flags != wantFlags {
+					t.Fatalf("FromInt(%d, %s, %t) = %#x, %s; want %#x, %s", i, mode, saturate, got.Bits(), flags, want.Bits(), wantFlags)
+				}
+			}
+		}
+	}
+}
+
+func Test_FromInt_SpotCheck(t *testing.T) {
+	data := []struct {
+		i     int64
+		want  floatx.BF16
+		flags floatx.Flags
+	}{
+		{0, 0, 0},
+		{-1, 0xBF80, 0},
+		{256, 0x4380, 0},
+		// BF16 has 8 bits of precision so ties round to even.
+		{257, 0x4380, floatx.FlagInexact},
+		{259, 0x4382, floatx.FlagInexact},
+		{math.MinInt64, 0xDF00, 0},
+		{math.MaxInt64, 0x5F00, floatx.FlagInexact},
+	}
+	for i, l := range data {
+		if got, flags := floatx.FromInt[floatx.BF16](l.i, nil); got != l.want || flags != l.flags {
+			t.Errorf("#%d: FromInt(%d) = %#x, %s; want %#x, %s", i, l.i, got, flags, l.want, l.flags)
+		}
+	}
+	if got, flags := floatx.FromInt[floatx.F32](int32(1<<24+1), nil); got != 1<<24 || flags != floatx.FlagInexact {
+		t.Fatal(got, flags)
+	}
+	if got, flags := floatx.FromInt[floatx.F16](int32(100000), nil); got != 0x7C00 || flags != floatx.FlagOverflow|floatx.FlagInexact {
+		t.Fatalf("%#x %s", got, flags)
+	}
+	if got, _ := floatx.FromInt[floatx.F16](int32(100000), &floatx.ConvertOptions{Saturate: true}); got.Float32() != 65504 {
+		t.Fatal(got.Float32())
+	}
+	if got, _ := floatx.FromInt[floatx.F8E4M3Fn](uint8(255), &floatx.ConvertOptions{Rounding: floatx.RoundTowardZero}); got.Float32() != 240 {
+		t.Fatal(got.Float32())
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright 2024 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package floatx
+
+import (
+	"encoding/binary"
+	"iter"
+)
+
+// All returns every encoding of T in increasing bit order, including the
+// NaNs and the infinities.
+//
+// F32 has 2^32 encodings.
+func All[T Float]() iter.Seq[T] {
+	var z T
+	f, _ := toBits(z)
+	n := uint64(1) << (f.exponentBits + f.mantissaBits + 1)
+	return func(yield func(T) bool) {
+		for bits := uint64(0); bits < n; bits++ {
+			if !yield(FromBits[T](uint32(bits))) {
+				return
+			}
+		}
+	}
+}
+
+// Ordered returns every encoding of T in IEEE 754 totalOrder, the order of
+// Compare. See the package documentation for the order of each type.
+func Ordered[T Float]() iter.Seq[T] {
+	var z T
+	f, _ := toBits(z)
+	n := uint64(f.signBit()) << 1
+	return func(yield func(T) bool) {
+		for key := uint64(0); key < n; key++ {
+			if !yield(FromBits[T](f.fromTotalOrderKey(uint32(key)))) {
+				return
+			}
+		}
+	}
+}
+
+// Finite returns the finite values of T in increasing numeric order. -0 is
+// returned before +0.
+func Finite[T Float]() iter.Seq[T] {
+	var z T
+	f, _ := toBits(z)
+	return func(yield func(T) bool) {
+		for v := range Ordered[T]() {
+			if bits := v.Bits(); f.isNaN(bits) || f.isInf(bits) {
+				continue
+			}
+			if !yield(v) {
+				return
+			}
+		}
+	}
+}
+
+// Values returns an iterator decoding the little endian values of type T in
+// b, e.g. Values[BF16](b).
+//
+// It stops after the last complete value, so trailing bytes shorter than the
+// size of T are ignored. Compare len(b) with the size of T beforehand to
+// report truncated data.
+func Values[T Float](b []byte) iter.Seq[float32] {
+	var z T
+	f, _ := toBits(z)
+	size := int(f.exponentBits+f.mantissaBits+1) / 8
+	return func(yield func(float32) bool) {
+		for i := 0; i+size <= len(b); i += size {
+			var bits uint32
+			switch size {
+			case 1:
+				bits = uint32(b[i])
+			case 2:
+				bits = uint32(binary.LittleEndian.Uint16(b[i:]))
+			default:
+				bits = binary.LittleEndian.Uint32(b[i:])
+			}
+			if !yield(FromBits[T](bits).Float32()) {
+				return
+			}
+		}
+	}
+}
+
+// Internal
+
+// fromTotalOrderKey returns the encoding whose totalOrderKey is key.
+func (f *format) fromTotalOrderKey(key uint32) uint32 {
+	if key&f.signBit() != 0 {
+		return key &^ f.signBit()
+	}
+	return ^key & (f.signBit()<<1 - 1)
+}
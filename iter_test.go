@@ -0,0 +1,138 @@
+// Copyright 2024 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package floatx_test
+
+import (
+	"math"
+	"slices"
+	"testing"
+
+	"github.com/maruel/floatx"
+)
+
+func Test_All(t *testing.T) {
+	t.Run("BF16", func(t *testing.T) { testAll[floatx.BF16](t, 1<<16) })
+	t.Run("F16", func(t *testing.T) { testAll[floatx.F16](t, 1<<16) })
+	t.Run("F8E4M3", func(t *testing.T) { testAll[floatx.F8E4M3](t, 1<<8) })
+	t.Run("F8E4M3Fn", func(t *testing.T) { testAll[floatx.F8E4M3Fn](t, 1<<8) })
+	t.Run("F8E5M2", func(t *testing.T) { testAll[floatx.F8E5M2](t, 1<<8) })
+	t.Run("F32", func(t *testing.T) {
+		// Too many to enumerate, verify the start and the ends.
+		var got []uint32
+		for v := range floatx.All[floatx.F32]() {
+			if got = append(got, v.Bits()); len(got) == 3 {
+				break
+			}
+		}
+		if !slices.Equal(got, []uint32{0, 1, 2}) {
+			t.Fatal(got)
+		}
+		var ordered []uint32
+		for v := range floatx.Ordered[floatx.F32]() {
+			if ordered = append(ordered, v.Bits()); len(ordered) == 2 {
+				break
+			}
+		}
+		if !slices.Equal(ordered, []uint32{0xFFFFFFFF, 0xFFFFFFFE}) {
+			t.Fatalf("%#x", ordered)
+		}
+		for v := range floatx.Finite[floatx.F32]() {
+			if v.Float32() != -math.MaxFloat32 {
+				t.Fatal(v)
+			}
+			break
+		}
+	})
+}
+
+// testAll verifies All, Ordered and Finite against each other.
+func testAll[T floatx.Float](t *testing.T, n int) {
+	all := slices.Collect(floatx.All[T]())
+	if len(all) != n {
+		t.Fatalf("%d values; want %d", len(all), n)
+	}
+	for i, v := range all {
+		if v.Bits() != uint32(i) {
+			t.Fatalf("#%d: %#x", i, v.Bits())
+		}
+	}
+	ordered := slices.Collect(floatx.Ordered[T]())
+	// Widening keeps the order.
+	opts := floatx.ConvertOptions{NaN: floatx.NaNPreservePayload}
+	want := slices.SortedFunc(slices.Values(all), func(a, b T) int {
+		return floatx.Convert[floatx.F32](a, &opts).Compare(floatx.Convert[floatx.F32](b, &opts))
+	})
+	if len(ordered) != n {
+		t.Fatalf("%d ordered values; want %d", len(ordered), n)
+	}
+	for i := range ordered {
+		if ordered[i] != want[i] && !(isNaN(ordered[i]) && isNaN(want[i])) {
+			t.Fatalf("#%d: %#x; want %#x", i, ordered[i].Bits(), want[i].Bits())
+		}
+	}
+	finite := slices.Collect(floatx.Finite[T]())
+	info := dtypeOf(finite[0]).Info()
+	if finite[0].Float32() != -info.Max || finite[len(finite)-1].Float32() != info.Max {
+		t.Fatalf("%g..%g", finite[0].Float32(), finite[len(finite)-1].Float32())
+	}
+	count := 0
+	for i, v := range finite {
+		f := float64(v.Float32())
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			t.Fatalf("#%d: %g", i, f)
+		}
+		if i > 0 && (f < float64(finite[i-1].Float32()) || f == 0 && !math.Signbit(float64(finite[i-1].Float32())) && math.Signbit(f)) {
+			t.Fatalf("#%d: %g after %g", i, f, finite[i-1].Float32())
+		}
+		count++
+	}
+	for _, v := range all {
+		if f := float64(v.Float32()); math.IsNaN(f) || math.IsInf(f, 0) {
+			count++
+		}
+	}
+	if count != n {
+		t.Fatalf("%d finite and non-finite values; want %d", count, n)
+	}
+	for range floatx.Finite[T]() {
+		break
+	}
+}
+
+func Test_Values(t *testing.T) {
+	b := []byte{0x80, 0x3F, 0x00, 0xC0, 0x80, 0x7F}
+	if got := slices.Collect(floatx.Values[floatx.BF16](b)); !slices.Equal(got, []float32{1, -2, float32(math.Inf(1))}) {
+		t.Fatal(got)
+	}
+	if got := slices.Collect(floatx.Values[floatx.F8E4M3Fn]([]byte{0x38, 0xC0})); !slices.Equal(got, []float32{1, -2}) {
+		t.Fatal(got)
+	}
+	if got := slices.Collect(floatx.Values[floatx.F32]([]byte{0, 0, 0x80, 0x3F})); !slices.Equal(got, []float32{1}) {
+		t.Fatal(got)
+	}
+	for v := range floatx.Values[floatx.F16](b) {
+		if v != 1.875 {
+			t.Fatal(v)
+		}
+		break
+	}
+	// The trailing partial value is ignored.
+	if got := slices.Collect(floatx.Values[floatx.BF16](b[:5])); !slices.Equal(got, []float32{1, -2}) {
+		t.Fatal(got)
+	}
+	if got := slices.Collect(floatx.Values[floatx.F32](b[:3])); len(got) != 0 {
+		t.Fatal(got)
+	}
+}
+
+func Benchmark_Values_BF16(b *testing.B) {
+	var sum float32
+	for range b.N {
+		for v := range floatx.Values[floatx.BF16](largeArray) {
+			sum += v
+		}
+	}
+	benchmarkResultFloat = sum
+}
@@ -0,0 +1,469 @@
+// Copyright 2024 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package floatx
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// JSONNonFinite wraps a value so MarshalJSON encodes NaN and infinities as the
+// JSON strings "NaN", "-NaN", "+Inf" and "-Inf".
+//
+// JSON numbers cannot represent them so the MarshalJSON methods of the types
+// return an error like encoding/json does for float64. Their UnmarshalJSON
+// methods always accept these strings.
+//
+//	b, err := json.Marshal(floatx.JSONNonFinite[floatx.BF16]{V: v})
+type JSONNonFinite[T Float] struct {
+	V T
+}
+
+// MarshalJSON implements json.Marshaler.
+func (j JSONNonFinite[T]) MarshalJSON() ([]byte, error) {
+	f, bits := toBits(j.V)
+	b := f.marshalText(bits)
+	if f.isNaN(bits) || f.isInf(bits) {
+		return strconv.AppendQuote(nil, string(b)), nil
+	}
+	return b, nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (j *JSONNonFinite[T]) UnmarshalJSON(data []byte) error {
+	f, bits := toBits(j.V)
+	v, err := f.unmarshalJSON(data, bits)
+	if err != nil {
+		return err
+	}
+	j.V = FromBits[T](v)
+	return nil
+}
+
+// F32
+
+// MarshalText implements encoding.TextMarshaler.
+func (f F32) MarshalText() ([]byte, error) {
+	return f32Format.marshalText(math.Float32bits(float32(f))), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (f *F32) UnmarshalText(text []byte) error {
+	v, err := f32Format.unmarshalText(text)
+	if err != nil {
+		return err
+	}
+	*f = F32(math.Float32frombits(v))
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler.
+func (f F32) MarshalJSON() ([]byte, error) {
+	return f32Format.marshalJSON(math.Float32bits(float32(f)))
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (f *F32) UnmarshalJSON(data []byte) error {
+	v, err := f32Format.unmarshalJSON(data, math.Float32bits(float32(*f)))
+	if err != nil {
+		return err
+	}
+	*f = F32(math.Float32frombits(v))
+	return nil
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler.
+//
+// The value is encoded as 4 bytes in little endian.
+func (f F32) MarshalBinary() ([]byte, error) {
+	return binary.LittleEndian.AppendUint32(nil, math.Float32bits(float32(f))), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+func (f *F32) UnmarshalBinary(data []byte) error {
+	if len(data) != 4 {
+		return errBinaryLength("F32", 4, len(data))
+	}
+	*f = F32(math.Float32frombits(binary.LittleEndian.Uint32(data)))
+	return nil
+}
+
+// BF16
+
+// MarshalText implements encoding.TextMarshaler.
+//
+// It returns the shortest decimal representation that parses back to the
+// same value, like numpy does. For example 0x3DCD is printed as 0.1 instead
+// of 0.10009765625.
+func (b BF16) MarshalText() ([]byte, error) {
+	return bf16Format.marshalText(uint32(b)), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (b *BF16) UnmarshalText(text []byte) error {
+	v, err := bf16Format.unmarshalText(text)
+	if err != nil {
+		return err
+	}
+	*b = BF16(v)
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler.
+func (b BF16) MarshalJSON() ([]byte, error) {
+	return bf16Format.marshalJSON(uint32(b))
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (b *BF16) UnmarshalJSON(data []byte) error {
+	v, err := bf16Format.unmarshalJSON(data, uint32(*b))
+	if err != nil {
+		return err
+	}
+	*b = BF16(v)
+	return nil
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler.
+//
+// The value is encoded as 2 bytes in little endian, like DecodeBF16 expects.
+func (b BF16) MarshalBinary() ([]byte, error) {
+	return AppendBF16(nil, b), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+func (b *BF16) UnmarshalBinary(data []byte) error {
+	if len(data) != 2 {
+		return errBinaryLength("BF16", 2, len(data))
+	}
+	*b = DecodeBF16(data)
+	return nil
+}
+
+// F16
+
+// MarshalText implements encoding.TextMarshaler.
+//
+// It returns the shortest decimal representation that parses back to the
+// same value, like numpy does. For example the largest value is printed as
+// 65500 instead of 65504.
+func (f F16) MarshalText() ([]byte, error) {
+	return f16Format.marshalText(uint32(f)), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (f *F16) UnmarshalText(text []byte) error {
+	v, err := f16Format.unmarshalText(text)
+	if err != nil {
+		return err
+	}
+	*f = F16(v)
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler.
+func (f F16) MarshalJSON() ([]byte, error) {
+	return f16Format.marshalJSON(uint32(f))
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (f *F16) UnmarshalJSON(data []byte) error {
+	v, err := f16Format.unmarshalJSON(data, uint32(*f))
+	if err != nil {
+		return err
+	}
+	*f = F16(v)
+	return nil
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler.
+//
+// The value is encoded as 2 bytes in little endian, like DecodeF16 expects.
+func (f F16) MarshalBinary() ([]byte, error) {
+	return AppendF16(nil, f), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+func (f *F16) UnmarshalBinary(data []byte) error {
+	if len(data) != 2 {
+		return errBinaryLength("F16", 2, len(data))
+	}
+	*f = DecodeF16(data)
+	return nil
+}
+
+// F8E4M3
+
+// MarshalText implements encoding.TextMarshaler.
+//
+// It returns the shortest decimal representation that parses back to the
+// same value.
+func (f F8E4M3) MarshalText() ([]byte, error) {
+	return f8e4m3Format.marshalText(uint32(f)), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (f *F8E4M3) UnmarshalText(text []byte) error {
+	v, err := f8e4m3Format.unmarshalText(text)
+	if err != nil {
+		return err
+	}
+	*f = F8E4M3(v)
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler.
+func (f F8E4M3) MarshalJSON() ([]byte, error) {
+	return f8e4m3Format.marshalJSON(uint32(f))
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (f *F8E4M3) UnmarshalJSON(data []byte) error {
+	v, err := f8e4m3Format.unmarshalJSON(data, uint32(*f))
+	if err != nil {
+		return err
+	}
+	*f = F8E4M3(v)
+	return nil
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler.
+//
+// The value is encoded as a single byte.
+func (f F8E4M3) MarshalBinary() ([]byte, error) {
+	return []byte{byte(f)}, nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+func (f *F8E4M3) UnmarshalBinary(data []byte) error {
+	if len(data) != 1 {
+		return errBinaryLength("F8E4M3", 1, len(data))
+	}
+	*f = F8E4M3(data[0])
+	return nil
+}
+
+// F8E4M3Fn
+
+// MarshalText implements encoding.TextMarshaler.
+//
+// It returns the shortest decimal representation that parses back to the
+// same value.
+func (f F8E4M3Fn) MarshalText() ([]byte, error) {
+	return f8e4m3fnFormat.marshalText(uint32(f)), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+//
+// Infinities and values too large to be represented become NaN.
+func (f *F8E4M3Fn) UnmarshalText(text []byte) error {
+	v, err := f8e4m3fnFormat.unmarshalText(text)
+	if err != nil {
+		return err
+	}
+	*f = F8E4M3Fn(v)
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler.
+func (f F8E4M3Fn) MarshalJSON() ([]byte, error) {
+	return f8e4m3fnFormat.marshalJSON(uint32(f))
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (f *F8E4M3Fn) UnmarshalJSON(data []byte) error {
+	v, err := f8e4m3fnFormat.unmarshalJSON(data, uint32(*f))
+	if err != nil {
+		return err
+	}
+	*f = F8E4M3Fn(v)
+	return nil
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler.
+//
+// The value is encoded as a single byte.
+func (f F8E4M3Fn) MarshalBinary() ([]byte, error) {
+	return []byte{byte(f)}, nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+func (f *F8E4M3Fn) UnmarshalBinary(data []byte) error {
+	if len(data) != 1 {
+		return errBinaryLength("F8E4M3Fn", 1, len(data))
+	}
+	*f = F8E4M3Fn(data[0])
+	return nil
+}
+
+// F8E5M2
+
+// MarshalText implements encoding.TextMarshaler.
+//
+// It returns the shortest decimal representation that parses back to the
+// same value.
+func (f F8E5M2) MarshalText() ([]byte, error) {
+	return f8e5m2Format.marshalText(uint32(f)), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (f *F8E5M2) UnmarshalText(text []byte) error {
+	v, err := f8e5m2Format.unmarshalText(text)
+	if err != nil {
+		return err
+	}
+	*f = F8E5M2(v)
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler.
+func (f F8E5M2) MarshalJSON() ([]byte, error) {
+	return f8e5m2Format.marshalJSON(uint32(f))
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (f *F8E5M2) UnmarshalJSON(data []byte) error {
+	v, err := f8e5m2Format.unmarshalJSON(data, uint32(*f))
+	if err != nil {
+		return err
+	}
+	*f = F8E5M2(v)
+	return nil
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler.
+//
+// The value is encoded as a single byte.
+func (f F8E5M2) MarshalBinary() ([]byte, error) {
+	return []byte{byte(f)}, nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+func (f *F8E5M2) UnmarshalBinary(data []byte) error {
+	if len(data) != 1 {
+		return errBinaryLength("F8E5M2", 1, len(data))
+	}
+	*f = F8E5M2(data[0])
+	return nil
+}
+
+// Internal
+
+func errBinaryLength(name string, want, got int) error {
+	return fmt.Errorf("floatx: %s requires %d bytes, got %d", name, want, got)
+}
+
+// marshalText returns the shortest decimal representation of bits that
+// parses back to the same encoding, or the sign and the name of NaN.
+func (f *format) marshalText(bits uint32) []byte {
+	if f.isNaN(bits) {
+		if bits&f.signBit() != 0 {
+			return []byte("-NaN")
+		}
+		return []byte("NaN")
+	}
+	v := f.toFloat64(bits)
+	if math.IsInf(v, 0) {
+		return strconv.AppendFloat(nil, v, 'g', -1, 64)
+	}
+	// The loop always terminates since 9 digits is enough for float32, so it
+	// is enough for all the formats.
+	for prec := 1; ; prec++ {
+		s := strconv.AppendFloat(nil, v, 'g', prec, 64)
+		if p, err := strconv.ParseFloat(string(s), 64); err == nil && f.fromFloat64(p) == bits {
+			// Reformat to not use an exponent for values like 240.
+			return strconv.AppendFloat(nil, p, 'g', -1, 64)
+		}
+	}
+}
+
+// unmarshalText parses a decimal value, "Inf" or "NaN" with an optional sign
+// and rounds it to the format. NaN becomes the quiet NaN of the same sign.
+//
+// Values out of range become infinity, or NaN for finite formats.
+func (f *format) unmarshalText(text []byte) (uint32, error) {
+	s := string(text)
+	unsigned := s
+	if s != "" && (s[0] == '+' || s[0] == '-') {
+		unsigned = s[1:]
+	}
+	switch {
+	case unsigned == "NaN":
+		if s[0] == '-' {
+			return f.signBit() | f.nan(), nil
+		}
+		return f.nan(), nil
+	case unsigned == "Inf" || isDecimal(unsigned):
+		// The syntax is valid so the only possible error is ErrRange, for
+		// which v is ±Inf or ±0 as needed.
+		v, _ := strconv.ParseFloat(s, 64)
+		return f.fromFloat64(v), nil
+	default:
+		return 0, fmt.Errorf("floatx: invalid value %q", text)
+	}
+}
+
+// isDecimal returns true if s is an unsigned decimal number with an optional
+// fraction and exponent, like "1", ".5", "1." or "2.5e-3".
+func isDecimal(s string) bool {
+	i := skipDigits(s, 0)
+	n := i
+	if i < len(s) && s[i] == '.' {
+		j := skipDigits(s, i+1)
+		n += j - i - 1
+		i = j
+	}
+	if n == 0 {
+		return false
+	}
+	if i < len(s) && (s[i] == 'e' || s[i] == 'E') {
+		i++
+		if i < len(s) && (s[i] == '+' || s[i] == '-') {
+			i++
+		}
+		j := skipDigits(s, i)
+		if j == i {
+			return false
+		}
+		i = j
+	}
+	return i == len(s)
+}
+
+// skipDigits returns the index of the first byte of s after i that is not a
+// decimal digit.
+func skipDigits(s string, i int) int {
+	for i < len(s) && '0' <= s[i] && s[i] <= '9' {
+		i++
+	}
+	return i
+}
+
+func (f *format) marshalJSON(bits uint32) ([]byte, error) {
+	b := f.marshalText(bits)
+	if f.isNaN(bits) || f.isInf(bits) {
+		return nil, fmt.Errorf("floatx: unsupported value %s", b)
+	}
+	return b, nil
+}
+
+// unmarshalJSON parses a JSON number or string. old is returned on null, as
+// encoding/json leaves the value unchanged.
+func (f *format) unmarshalJSON(data []byte, old uint32) (uint32, error) {
+	if bytes.Equal(data, []byte("null")) {
+		return old, nil
+	}
+	if len(data) != 0 && data[0] == '"' {
+		s, err := strconv.Unquote(string(data))
+		if err != nil {
+			return old, fmt.Errorf("floatx: invalid JSON value %s", data)
+		}
+		return f.unmarshalText([]byte(s))
+	}
+	return f.unmarshalText(data)
+}
@@ -0,0 +1,267 @@
+// Copyright 2024 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package floatx_test
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/maruel/floatx"
+)
+
+type marshaler interface {
+	floatx.Float
+	encoding.TextMarshaler
+	encoding.BinaryMarshaler
+	json.Marshaler
+}
+
+type unmarshaler[T any] interface {
+	*T
+	encoding.TextUnmarshaler
+	encoding.BinaryUnmarshaler
+	json.Unmarshaler
+}
+
+// testRoundTrip verifies that all the encodings of a type round trip through
+// text, JSON and binary encoding.
+func testRoundTrip[T marshaler, PT unmarshaler[T]](t *testing.T, values []T, toFloat32 func(T) float32) {
+	var zero T
+	if err := PT(&zero).UnmarshalText([]byte("foo")); err == nil {
+		t.Fatal("expected error")
+	}
+	if err := PT(&zero).UnmarshalJSON([]byte("foo")); err == nil {
+		t.Fatal("expected error")
+	}
+	if err := new(floatx.JSONNonFinite[T]).UnmarshalJSON([]byte("foo")); err == nil {
+		t.Fatal("expected error")
+	}
+	if err := PT(&zero).UnmarshalBinary(make([]byte, 5)); err == nil {
+		t.Fatal("expected error")
+	}
+	for _, v := range values {
+		isNaN := math.IsNaN(float64(toFloat32(v)))
+		// Text and JSON keep the sign of NaN but not its payload.
+		want := v
+		if isNaN {
+			want = floatx.Convert[T](v, nil)
+		}
+		equal := func(got T) bool {
+			return got.Bits() == want.Bits()
+		}
+
+		text, err := v.MarshalText()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got T
+		if err = PT(&got).UnmarshalText(text); err != nil {
+			t.Fatal(err)
+		}
+		if !equal(got) {
+			t.Fatalf("text %v: %q -> %v", v, text, got)
+		}
+
+		b, err := v.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = *new(T)
+		if err = PT(&got).UnmarshalBinary(b); err != nil {
+			t.Fatal(err)
+		}
+		if got.Bits() != v.Bits() {
+			t.Fatalf("binary %v: %x -> %v", v, b, got)
+		}
+
+		nonFinite := isNaN || math.IsInf(float64(toFloat32(v)), 0)
+		j, err := json.Marshal(v)
+		if nonFinite {
+			if err == nil {
+				t.Fatalf("json %v: expected error", v)
+			}
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		// The wrapper encodes non-finite values as strings and is otherwise
+		// identical.
+		jw, err := json.Marshal(floatx.JSONNonFinite[T]{V: v})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if nonFinite {
+			if jw[0] != '"' {
+				t.Fatalf("json %v: expected string, got %s", v, jw)
+			}
+			j = jw
+		} else if string(jw) != string(j) {
+			t.Fatalf("json %v: %s != %s", v, jw, j)
+		}
+		got = *new(T)
+		if err = json.Unmarshal(j, PT(&got)); err != nil {
+			t.Fatal(err)
+		}
+		if !equal(got) {
+			t.Fatalf("json %v: %s -> %v", v, j, got)
+		}
+		var w floatx.JSONNonFinite[T]
+		if err = json.Unmarshal(j, &w); err != nil {
+			t.Fatal(err)
+		}
+		if !equal(w.V) {
+			t.Fatalf("json %v: %s -> %v", v, j, w.V)
+		}
+	}
+}
+
+func Test_Marshal_F32(t *testing.T) {
+	testRoundTrip(t, []floatx.F32{
+		0,
+		floatx.F32(math.Copysign(0, -1)),
+		1,
+		-2.5,
+		math.SmallestNonzeroFloat32,
+		math.MaxFloat32,
+		floatx.F32(math.Inf(1)),
+		floatx.F32(math.Inf(-1)),
+		floatx.F32(math.NaN()),
+	}, func(f floatx.F32) float32 { return float32(f) })
+}
+
+func Test_Marshal_BF16(t *testing.T) {
+	values := make([]floatx.BF16, 1<<16)
+	for i := range values {
+		values[i] = floatx.BF16(i)
+	}
+	testRoundTrip(t, values, floatx.BF16.Float32)
+}
+
+func Test_Marshal_F16(t *testing.T) {
+	values := make([]floatx.F16, 1<<16)
+	for i := range values {
+		values[i] = floatx.F16(i)
+	}
+	testRoundTrip(t, values, floatx.F16.Float32)
+}
+
+func Test_Marshal_F8E4M3(t *testing.T) {
+	values := make([]floatx.F8E4M3, 1<<8)
+	for i := range values {
+		values[i] = floatx.F8E4M3(i)
+	}
+	testRoundTrip(t, values, floatx.F8E4M3.Float32)
+}
+
+func Test_Marshal_F8E4M3Fn(t *testing.T) {
+	values := make([]floatx.F8E4M3Fn, 1<<8)
+	for i := range values {
+		values[i] = floatx.F8E4M3Fn(i)
+	}
+	testRoundTrip(t, values, floatx.F8E4M3Fn.Float32)
+}
+
+func Test_Marshal_F8E5M2(t *testing.T) {
+	values := make([]floatx.F8E5M2, 1<<8)
+	for i := range values {
+		values[i] = floatx.F8E5M2(i)
+	}
+	testRoundTrip(t, values, floatx.F8E5M2.Float32)
+}
+
+func Test_Marshal_Text(t *testing.T) {
+	data := []struct {
+		v    encoding.TextMarshaler
+		want string
+	}{
+		{floatx.BF16(0x3DCD), "0.1"},
+		{floatx.BF16(0x8000), "-0"},
+		{floatx.BF16(0x7F80), "+Inf"},
+		{floatx.F16(0x3C00), "1"},
+		{floatx.F16(0x7BFF), "65500"},
+		{floatx.F8E4M3(0x77), "240"},
+		{floatx.F8E4M3Fn(0x7E), "450"},
+		{floatx.F8E4M3Fn(0x7F), "NaN"},
+		{floatx.F8E4M3Fn(0xFF), "-NaN"},
+		{floatx.BF16(0xFFC1), "-NaN"},
+		{floatx.F8E5M2(0x01), "2e-05"},
+		{floatx.F32(0.1), "0.1"},
+	}
+	for i, line := range data {
+		got, err := line.v.MarshalText()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != line.want {
+			t.Errorf("#%d: want=%q got=%q", i, line.want, got)
+		}
+	}
+}
+
+func Test_Unmarshal_Text(t *testing.T) {
+	data := []struct {
+		in   string
+		want floatx.BF16
+	}{
+		{"1", 0x3F80},
+		{"+1", 0x3F80},
+		{"-1.", 0xBF80},
+		{".5", 0x3F00},
+		{"1E2", 0x42C8},
+		{"2.5e-1", 0x3E80},
+		{"1e-400", 0},
+		{"-1e400", 0xFF80},
+		{"Inf", 0x7F80},
+		{"+Inf", 0x7F80},
+		{"-Inf", 0xFF80},
+		{"NaN", 0x7FC0},
+		{"+NaN", 0x7FC0},
+		{"-NaN", 0xFFC0},
+	}
+	for i, line := range data {
+		var got floatx.BF16
+		if err := got.UnmarshalText([]byte(line.in)); err != nil || got != line.want {
+			t.Errorf("#%d: %q: want=%#x got=%#x %v", i, line.in, line.want, got, err)
+		}
+	}
+	// Syntaxes accepted by strconv.ParseFloat but not by UnmarshalText.
+	for _, in := range []string{"", "+", "-", ".", "e1", "1e", "1e+", "+-1", "--1", "1.2.3", "1 ", "0x1p-3", "1_0", "inf", "infinity", "+Infinity", "nan", "-nan", "NaN1"} {
+		var got floatx.BF16
+		if err := got.UnmarshalText([]byte(in)); err == nil || err.Error() != fmt.Sprintf("floatx: invalid value %q", in) {
+			t.Errorf("%q: %v", in, err)
+		}
+	}
+}
+
+func Test_Unmarshal_Errors(t *testing.T) {
+	var b floatx.BF16
+	if err := b.UnmarshalText([]byte("foo")); err == nil {
+		t.Error("expected error")
+	}
+	if err := b.UnmarshalBinary([]byte{1}); err == nil {
+		t.Error("expected error")
+	}
+	if err := json.Unmarshal([]byte(`"1`), &b); err == nil {
+		t.Error("expected error")
+	}
+	if err := b.UnmarshalJSON([]byte(`"1`)); err == nil {
+		t.Error("expected error")
+	}
+	if err := json.Unmarshal([]byte(`"Inf"`), &b); err != nil || b != 0x7F80 {
+		t.Errorf("%v %v", b, err)
+	}
+	if err := json.Unmarshal([]byte(`null`), &b); err != nil || b != 0x7F80 {
+		t.Errorf("%v %v", b, err)
+	}
+	var e4fn floatx.F8E4M3Fn
+	if err := e4fn.UnmarshalText([]byte("1e6")); err != nil || e4fn != 0x7F {
+		t.Errorf("%v %v", e4fn, err)
+	}
+	if err := e4fn.UnmarshalText([]byte("1e400")); err != nil || e4fn != 0x7F {
+		t.Errorf("%v %v", e4fn, err)
+	}
+}
@@ -0,0 +1,13 @@
+// Copyright 2024 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package mmap
+
+// SetLittleEndian overrides the host endianness and returns a function to
+// restore it.
+func SetLittleEndian(v bool) func() {
+	old := isLittleEndian
+	isLittleEndian = v
+	return func() { isLittleEndian = old }
+}
@@ -0,0 +1,171 @@
+// Copyright 2024 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+// Package mmap maps tensor files read-only in memory and hands out zero-copy
+// views of their values.
+//
+// The mapped bytes can be passed to the parsers of the file format packages,
+// e.g. safetensors.Parse or gguf.Parse, whose tensors alias it, so large
+// models can be inspected without reading them into the heap.
+//
+// Files are mapped read-only with mmap(2) on unix and MapViewOfFile on
+// Windows, so writing to the slices crashes the process. On other platforms,
+// they are read into memory instead.
+package mmap
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/maruel/floatx"
+)
+
+// isLittleEndian is true when the host stores values in little endian, like
+// the files.
+var isLittleEndian = binary.NativeEndian.Uint16([]byte{1, 0}) == 1
+
+// File is a read-only memory mapped file.
+//
+// The slices returned by its methods are read-only and must not be modified;
+// writing to them crashes the process. They are invalid once the file is
+// closed. Accessing them after Close crashes the process.
+type File struct {
+	data   []byte
+	closed bool
+}
+
+// Open maps a file read-only.
+func Open(name string) (*File, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	st, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	size := st.Size()
+	if size != int64(int(size)) {
+		return nil, fmt.Errorf("mmap: %s is too large: %d bytes", name, size)
+	}
+	if size == 0 {
+		return &File{}, nil
+	}
+	b, err := mmap(f, int(size))
+	if err != nil {
+		return nil, fmt.Errorf("mmap: %s: %w", name, err)
+	}
+	return &File{data: b}, nil
+}
+
+// Close unmaps the file.
+func (f *File) Close() error {
+	if f.closed {
+		return os.ErrClosed
+	}
+	f.closed = true
+	b := f.data
+	f.data = nil
+	if len(b) == 0 {
+		return nil
+	}
+	return munmap(b)
+}
+
+// Bytes returns the content of the file. The slice must not be modified.
+func (f *File) Bytes() []byte {
+	return f.data
+}
+
+// Len returns the size of the file.
+func (f *File) Len() int {
+	return len(f.data)
+}
+
+// BF16 returns n values at offset off in the file. The returned slice
+// must not be modified.
+func (f *File) BF16(off int64, n int) ([]floatx.BF16, error) {
+	b, err := f.region(off, n, 2)
+	if err != nil {
+		return nil, err
+	}
+	v, _, _ := floatx.ViewBF16(b)
+	return v, nil
+}
+
+// F16 returns n values at offset off in the file. The returned slice
+// must not be modified.
+func (f *File) F16(off int64, n int) ([]floatx.F16, error) {
+	b, err := f.region(off, n, 2)
+	if err != nil {
+		return nil, err
+	}
+	v, _, _ := floatx.ViewF16(b)
+	return v, nil
+}
+
+// F32 returns n values at offset off in the file. The returned slice
+// must not be modified.
+func (f *File) F32(off int64, n int) ([]floatx.F32, error) {
+	b, err := f.region(off, n, 4)
+	if err != nil {
+		return nil, err
+	}
+	v, _, _ := floatx.ViewF32(b)
+	return v, nil
+}
+
+// F8E4M3 returns n values at offset off in the file. The returned slice
+// must not be modified.
+func (f *File) F8E4M3(off int64, n int) ([]floatx.F8E4M3, error) {
+	b, err := f.region(off, n, 1)
+	if err != nil {
+		return nil, err
+	}
+	return floatx.ViewF8E4M3(b), nil
+}
+
+// F8E4M3Fn returns n values at offset off in the file. The returned slice
+// must not be modified.
+func (f *File) F8E4M3Fn(off int64, n int) ([]floatx.F8E4M3Fn, error) {
+	b, err := f.region(off, n, 1)
+	if err != nil {
+		return nil, err
+	}
+	return floatx.ViewF8E4M3Fn(b), nil
+}
+
+// F8E5M2 returns n values at offset off in the file. The returned slice
+// must not be modified.
+func (f *File) F8E5M2(off int64, n int) ([]floatx.F8E5M2, error) {
+	b, err := f.region(off, n, 1)
+	if err != nil {
+		return nil, err
+	}
+	return floatx.ViewF8E5M2(b), nil
+}
+
+// region returns the bytes of n values of size bytes at offset off.
+//
+// The mapping starts on a page boundary, so the values are aligned in memory
+// when off is aligned.
+func (f *File) region(off int64, n, size int) ([]byte, error) {
+	if f.closed {
+		return nil, os.ErrClosed
+	}
+	if size > 1 && !isLittleEndian {
+		return nil, errors.New("mmap: zero-copy views require a little endian CPU")
+	}
+	if off < 0 || n < 0 || off > int64(len(f.data)) || n > (len(f.data)-int(off))/size {
+		return nil, fmt.Errorf("mmap: %d values of %d bytes at offset %d are beyond the %d bytes of the file", n, size, off, len(f.data))
+	}
+	if off%int64(size) != 0 {
+		return nil, fmt.Errorf("mmap: offset %d is not aligned on %d bytes", off, size)
+	}
+	end := int(off) + n*size
+	return f.data[off:end:end], nil
+}
@@ -0,0 +1,22 @@
+// Copyright 2024 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+//go:build !unix && !windows
+
+package mmap
+
+import (
+	"io"
+	"os"
+)
+
+func mmap(f *os.File, size int) ([]byte, error) {
+	b := make([]byte, size)
+	_, err := io.ReadFull(f, b)
+	return b, err
+}
+
+func munmap(b []byte) error {
+	return nil
+}
@@ -0,0 +1,160 @@
+// Copyright 2024 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package mmap_test
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/maruel/floatx/mmap"
+	"github.com/maruel/floatx/safetensors"
+)
+
+func write(t *testing.T, b []byte) string {
+	p := filepath.Join(t.TempDir(), "a.bin")
+	if err := os.WriteFile(p, b, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func Test_Views(t *testing.T) {
+	// 1, -2 in F32, then in BF16 and F16, then in the float8 types.
+	b := []byte{0, 0, 0x80, 0x3F, 0, 0, 0, 0xC0, 0x80, 0x3F, 0x00, 0xC0, 0x00, 0x3C, 0x00, 0xC0, 0x38, 0xC0, 0x3C, 0xC0}
+	f, err := mmap.Open(write(t, b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if !bytes.Equal(f.Bytes(), b) || f.Len() != len(b) {
+		t.Fatal(f.Bytes())
+	}
+	check := func(got []float32, err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != 2 || got[0] != 1 || got[1] != -2 {
+			t.Fatal(got)
+		}
+	}
+	f32, err := f.F32(0, 2)
+	check([]float32{float32(f32[0]), float32(f32[1])}, err)
+	bf16, err := f.BF16(8, 2)
+	check([]float32{bf16[0].Float32(), bf16[1].Float32()}, err)
+	f16, err := f.F16(12, 2)
+	check([]float32{f16[0].Float32(), f16[1].Float32()}, err)
+	e4m3fn, err := f.F8E4M3Fn(16, 2)
+	check([]float32{e4m3fn[0].Float32(), e4m3fn[1].Float32()}, err)
+	e4m3, err := f.F8E4M3(16, 2)
+	check([]float32{e4m3[0].Float32(), e4m3[1].Float32()}, err)
+	e5m2, err := f.F8E5M2(18, 2)
+	check([]float32{e5m2[0].Float32(), e5m2[1].Float32()}, err)
+	if v, err := f.F16(20, 0); err != nil || len(v) != 0 {
+		t.Fatal(v, err)
+	}
+}
+
+func Test_Errors(t *testing.T) {
+	if _, err := mmap.Open(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Fatal("expected error")
+	}
+	if _, err := mmap.Open(t.TempDir()); err == nil {
+		t.Fatal("expected error")
+	}
+	f, err := mmap.Open(write(t, make([]byte, 8)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := []struct {
+		err  error
+		want string
+	}{
+		{second(f.F32(0, 3)), "mmap: 3 values of 4 bytes at offset 0 are beyond the 8 bytes of the file"},
+		{second(f.F32(4, 2)), "mmap: 2 values of 4 bytes at offset 4 are beyond the 8 bytes of the file"},
+		{second(f.F16(-2, 1)), "mmap: 1 values of 2 bytes at offset -2 are beyond the 8 bytes of the file"},
+		{second(f.BF16(10, 0)), "mmap: 0 values of 2 bytes at offset 10 are beyond the 8 bytes of the file"},
+		{second(f.F8E5M2(0, -1)), "mmap: -1 values of 1 bytes at offset 0 are beyond the 8 bytes of the file"},
+		{second(f.F8E4M3(0, 9)), "mmap: 9 values of 1 bytes at offset 0 are beyond the 8 bytes of the file"},
+		{second(f.F8E4M3Fn(9, 0)), "mmap: 0 values of 1 bytes at offset 9 are beyond the 8 bytes of the file"},
+		{second(f.F16(1, 1)), "mmap: offset 1 is not aligned on 2 bytes"},
+		{second(f.BF16(3, 1)), "mmap: offset 3 is not aligned on 2 bytes"},
+		{second(f.F32(2, 1)), "mmap: offset 2 is not aligned on 4 bytes"},
+	}
+	for i, line := range data {
+		if line.err == nil || line.err.Error() != line.want {
+			t.Errorf("#%d: want=%q got=%v", i, line.want, line.err)
+		}
+	}
+	restore := mmap.SetLittleEndian(false)
+	if _, err = f.F16(0, 1); err == nil || err.Error() != "mmap: zero-copy views require a little endian CPU" {
+		t.Fatal(err)
+	}
+	// float8 doesn't care.
+	if _, err = f.F8E5M2(0, 1); err != nil {
+		t.Fatal(err)
+	}
+	restore()
+	if err = f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err = f.Close(); !errors.Is(err, os.ErrClosed) {
+		t.Fatal(err)
+	}
+	if _, err = f.F8E5M2(0, 0); !errors.Is(err, os.ErrClosed) {
+		t.Fatal(err)
+	}
+	if f.Bytes() != nil {
+		t.Fatal("expected nil")
+	}
+}
+
+func Test_Empty(t *testing.T) {
+	f, err := mmap.Open(write(t, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Len() != 0 {
+		t.Fatal(f.Len())
+	}
+	if v, err := f.F32(0, 0); err != nil || len(v) != 0 {
+		t.Fatal(v, err)
+	}
+	if err = f.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_Safetensors(t *testing.T) {
+	buf := bytes.Buffer{}
+	tensors := []safetensors.Tensor{{Name: "w", DType: "BF16", Shape: []int{2}, Data: []byte{0x80, 0x3F, 0x00, 0xC0}}}
+	if err := safetensors.Write(&buf, nil, tensors); err != nil {
+		t.Fatal(err)
+	}
+	f, err := mmap.Open(write(t, buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	st, err := safetensors.Parse(f.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := st.Tensor("w").BF16()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The tensor aliases the mapping.
+	if v[0].Float32() != 1 || v[1].Float32() != -2 || &st.Tensors[0].Data[0] != &f.Bytes()[f.Len()-4] {
+		t.Fatal(v)
+	}
+}
+
+func second[T any](_ T, err error) error {
+	return err
+}
@@ -0,0 +1,47 @@
+// Copyright 2024 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+//go:build unix || windows
+
+package mmap_test
+
+import (
+	"runtime/debug"
+	"testing"
+
+	"github.com/maruel/floatx/mmap"
+)
+
+func Test_ReadOnly(t *testing.T) {
+	f, err := mmap.Open(write(t, []byte{0x38, 0xC0}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	v, err := f.F8E4M3(0, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, fn := range map[string]func(){
+		"Bytes":  func() { f.Bytes()[0] = 0 },
+		"F8E4M3": func() { v[1] = 0 },
+	} {
+		t.Run(name, func(t *testing.T) {
+			if !faults(fn) {
+				t.Fatal("writing to a read-only mapping must fault")
+			}
+		})
+	}
+	if b := f.Bytes(); b[0] != 0x38 || b[1] != 0xC0 {
+		t.Fatal(b)
+	}
+}
+
+// faults returns true if fn accessed memory it can't.
+func faults(fn func()) (faulted bool) {
+	defer debug.SetPanicOnFault(debug.SetPanicOnFault(true))
+	defer func() { faulted = recover() != nil }()
+	fn()
+	return false
+}
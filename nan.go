@@ -0,0 +1,163 @@
+// Copyright 2024 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package floatx
+
+import (
+	"math"
+	"strconv"
+)
+
+// NaNPolicy selects the NaN encoding produced when converting a NaN.
+//
+// The XFromFloat32 functions use NaNPreserveSign. The Float32 methods use
+// NaNPreservePayload.
+//
+// F8E4M3Fn has a single NaN per sign, without payload. It is read as a quiet
+// NaN without payload and all the NaNs are converted to it.
+type NaNPolicy uint8
+
+// Supported NaN policies.
+const (
+	// NaNPreserveSign returns the canonical quiet NaN with the sign of the
+	// source.
+	NaNPreserveSign NaNPolicy = iota
+	// NaNCanonical returns the positive canonical quiet NaN.
+	NaNCanonical
+	// NaNPreservePayload keeps the sign, the quiet bit and the most significant
+	// bits of the payload. A signaling NaN whose remaining payload would be
+	// empty gets a payload of 1 so it stays a signaling NaN.
+	//
+	// Widening and narrowing back returns the original bits.
+	NaNPreservePayload
+)
+
+// String returns the name of the constant, e.g. "NaNPreserveSign".
+func (n NaNPolicy) String() string {
+	switch n {
+	case NaNPreserveSign:
+		return "NaNPreserveSign"
+	case NaNCanonical:
+		return "NaNCanonical"
+	case NaNPreservePayload:
+		return "NaNPreservePayload"
+	default:
+		return "NaNPolicy(" + strconv.Itoa(int(n)) + ")"
+	}
+}
+
+// F32
+
+// IsNaN returns true if f is a NaN.
+func (f F32) IsNaN() bool {
+	return f32Format.isNaN(math.Float32bits(float32(f)))
+}
+
+// IsSignalingNaN returns true if f is a NaN with the quiet bit, the most
+// significant bit of the mantissa, cleared.
+func (f F32) IsSignalingNaN() bool {
+	return f32Format.isSignalingNaN(math.Float32bits(float32(f)))
+}
+
+// BF16
+
+// IsNaN returns true if b is a NaN.
+func (b BF16) IsNaN() bool {
+	return bf16Format.isNaN(uint32(b))
+}
+
+// IsSignalingNaN returns true if b is a NaN with the quiet bit, the most
+// significant bit of the mantissa, cleared.
+func (b BF16) IsSignalingNaN() bool {
+	return bf16Format.isSignalingNaN(uint32(b))
+}
+
+// F16
+
+// IsNaN returns true if f is a NaN.
+func (f F16) IsNaN() bool {
+	return f16Format.isNaN(uint32(f))
+}
+
+// IsSignalingNaN returns true if f is a NaN with the quiet bit, the most
+// significant bit of the mantissa, cleared.
+func (f F16) IsSignalingNaN() bool {
+	return f16Format.isSignalingNaN(uint32(f))
+}
+
+// F8E4M3
+
+// IsNaN returns true if f is a NaN.
+func (f F8E4M3) IsNaN() bool {
+	return f8e4m3Format.isNaN(uint32(f))
+}
+
+// IsSignalingNaN returns true if f is a NaN with the quiet bit, the most
+// significant bit of the mantissa, cleared.
+func (f F8E4M3) IsSignalingNaN() bool {
+	return f8e4m3Format.isSignalingNaN(uint32(f))
+}
+
+// F8E4M3Fn
+
+// IsNaN returns true if f is 0x7F or 0xFF.
+func (f F8E4M3Fn) IsNaN() bool {
+	return f8e4m3fnFormat.isNaN(uint32(f))
+}
+
+// IsSignalingNaN always returns false since the NaNs of F8E4M3Fn are quiet.
+func (f F8E4M3Fn) IsSignalingNaN() bool {
+	return f8e4m3fnFormat.isSignalingNaN(uint32(f))
+}
+
+// F8E5M2
+
+// IsNaN returns true if f is a NaN.
+func (f F8E5M2) IsNaN() bool {
+	return f8e5m2Format.isNaN(uint32(f))
+}
+
+// IsSignalingNaN returns true if f is a NaN with the quiet bit, the most
+// significant bit of the mantissa, cleared.
+func (f F8E5M2) IsSignalingNaN() bool {
+	return f8e5m2Format.isSignalingNaN(uint32(f))
+}
+
+// Internal
+
+// quietBit returns the bit of the mantissa set for quiet NaNs.
+func (f *format) quietBit() uint32 {
+	return 1 << (f.mantissaBits - 1)
+}
+
+// isSignalingNaN returns true if bits encodes a NaN with the quiet bit
+// cleared.
+func (f *format) isSignalingNaN(bits uint32) bool {
+	return !f.finite && f.isNaN(bits) && bits&f.quietBit() == 0
+}
+
+// payload returns the mantissa of the NaN encoded by bits, including the quiet
+// bit, aligned on the most significant bit.
+func (f *format) payload(bits uint32) uint32 {
+	if f.finite {
+		return 1 << 31
+	}
+	return bits << (32 - f.mantissaBits)
+}
+
+// convertNaN returns the encoding in f of the NaN encoded by bits in src.
+func (f *format) convertNaN(src *format, bits uint32, policy NaNPolicy) uint32 {
+	var sign uint32
+	if policy != NaNCanonical && bits&src.signBit() != 0 {
+		sign = f.signBit()
+	}
+	if policy != NaNPreservePayload || f.finite {
+		return sign | f.nan()
+	}
+	mantissa := src.payload(bits) >> (32 - f.mantissaBits)
+	if mantissa == 0 {
+		mantissa = 1
+	}
+	return sign | f.exponentMask() | mantissa
+}
@@ -0,0 +1,153 @@
+// Copyright 2024 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package npy
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// parseHeader parses the Python dict literal describing the array, e.g.
+// "{'descr': '<f2', 'fortran_order': False, 'shape': (3, 4), }".
+func parseHeader(h string) (*Array, bool, error) {
+	p := parser{s: strings.TrimRight(h, " \n\x00")}
+	a := &Array{}
+	var swap bool
+	seen := map[string]bool{}
+	p.expect('{')
+	for p.err == nil && !p.accept('}') {
+		key := p.str()
+		p.expect(':')
+		if p.err != nil {
+			break
+		}
+		seen[key] = true
+		switch key {
+		case "descr":
+			descr := p.str()
+			if p.err == nil {
+				a.DType, swap, p.err = parseDescr(descr)
+			}
+		case "fortran_order":
+			a.FortranOrder = p.boolean()
+		case "shape":
+			a.Shape = p.tuple()
+		default:
+			p.fail("unknown key %q", key)
+		}
+		if !p.accept(',') {
+			p.expect('}')
+			break
+		}
+	}
+	if p.err == nil && p.pos != len(p.s) {
+		p.fail("trailing data")
+	}
+	if p.err == nil && (!seen["descr"] || !seen["fortran_order"] || !seen["shape"]) {
+		p.fail("missing key")
+	}
+	if p.err != nil {
+		return nil, false, p.err
+	}
+	return a, swap, nil
+}
+
+// parser is a minimal parser for the subset of Python literals used in .npy
+// headers.
+type parser struct {
+	s   string
+	pos int
+	err error
+}
+
+func (p *parser) fail(format string, args ...any) {
+	if p.err == nil {
+		p.err = fmt.Errorf("npy: invalid header at offset %d: %s", p.pos, fmt.Sprintf(format, args...))
+	}
+}
+
+func (p *parser) skipSpaces() {
+	for p.pos < len(p.s) && p.s[p.pos] == ' ' {
+		p.pos++
+	}
+}
+
+// accept consumes c if it is the next non space character.
+func (p *parser) accept(c byte) bool {
+	if p.err != nil {
+		return false
+	}
+	p.skipSpaces()
+	if p.pos < len(p.s) && p.s[p.pos] == c {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(c byte) {
+	if !p.accept(c) {
+		p.fail("expected %q", c)
+	}
+}
+
+// str parses a single or double quoted string without escapes.
+func (p *parser) str() string {
+	p.skipSpaces()
+	if p.err != nil || p.pos == len(p.s) || (p.s[p.pos] != '\'' && p.s[p.pos] != '"') {
+		p.fail("expected string")
+		return ""
+	}
+	q := p.s[p.pos]
+	end := strings.IndexByte(p.s[p.pos+1:], q)
+	if end == -1 {
+		p.fail("unterminated string")
+		return ""
+	}
+	s := p.s[p.pos+1 : p.pos+1+end]
+	p.pos += end + 2
+	return s
+}
+
+func (p *parser) boolean() bool {
+	p.skipSpaces()
+	switch {
+	case strings.HasPrefix(p.s[p.pos:], "True"):
+		p.pos += 4
+		return true
+	case strings.HasPrefix(p.s[p.pos:], "False"):
+		p.pos += 5
+	default:
+		p.fail("expected boolean")
+	}
+	return false
+}
+
+// tuple parses a tuple of non negative integers.
+func (p *parser) tuple() []int {
+	p.expect('(')
+	out := []int{}
+	for p.err == nil && !p.accept(')') {
+		p.skipSpaces()
+		start := p.pos
+		for p.pos < len(p.s) && p.s[p.pos] >= '0' && p.s[p.pos] <= '9' {
+			p.pos++
+		}
+		v, err := strconv.Atoi(p.s[start:p.pos])
+		if err != nil {
+			p.fail("expected integer")
+			break
+		}
+		// Python 2 wrote longs as 3L.
+		p.accept('L')
+		out = append(out, v)
+		if !p.accept(',') {
+			p.expect(')')
+			break
+		}
+	}
+	return out
+}
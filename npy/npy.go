@@ -0,0 +1,318 @@
+// Copyright 2024 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+// Package npy reads and writes NumPy .npy and .npz files.
+//
+// Supported dtypes are "<f2", "<f4" and the ml_dtypes names "bfloat16",
+// "float8_e4m3", "float8_e4m3fn" and "float8_e5m2". Big endian ">f2" and ">f4"
+// are converted to little endian when read.
+//
+// See https://numpy.org/doc/stable/reference/generated/numpy.lib.format.html
+package npy
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/maruel/floatx"
+)
+
+// magic starts every .npy file.
+const magic = "\x93NUMPY"
+
+// maxHeaderSize limits the memory used by corrupted files.
+const maxHeaderSize = 1024 * 1024
+
+// Array is a n-dimensional array.
+type Array struct {
+	DType floatx.DType
+	Shape []int
+	// FortranOrder is true when Data is stored in column-major order.
+	FortranOrder bool
+	// Data is the raw little endian data.
+	Data []byte
+}
+
+// Len returns the number of elements in the array.
+func (a *Array) Len() int {
+	if a.DType.Size() == 0 {
+		return 0
+	}
+	return len(a.Data) / a.DType.Size()
+}
+
+// BF16 returns the data of a bfloat16 array.
+//
+// The slice aliases Data when possible. See floatx.ViewBF16.
+func (a *Array) BF16() ([]floatx.BF16, error) {
+	if err := a.check(floatx.DTypeBF16); err != nil {
+		return nil, err
+	}
+	v, _, err := floatx.ViewBF16(a.Data)
+	return v, err
+}
+
+// F16 returns the data of a "<f2" array.
+//
+// The slice aliases Data when possible. See floatx.ViewF16.
+func (a *Array) F16() ([]floatx.F16, error) {
+	if err := a.check(floatx.DTypeF16); err != nil {
+		return nil, err
+	}
+	v, _, err := floatx.ViewF16(a.Data)
+	return v, err
+}
+
+// F32 returns the data of a "<f4" array.
+//
+// The slice aliases Data when possible. See floatx.ViewF32.
+func (a *Array) F32() ([]floatx.F32, error) {
+	if err := a.check(floatx.DTypeF32); err != nil {
+		return nil, err
+	}
+	v, _, err := floatx.ViewF32(a.Data)
+	return v, err
+}
+
+// F8E4M3 returns the data of a float8_e4m3 array. The slice aliases Data.
+func (a *Array) F8E4M3() ([]floatx.F8E4M3, error) {
+	if err := a.check(floatx.DTypeF8E4M3); err != nil {
+		return nil, err
+	}
+	return floatx.ViewF8E4M3(a.Data), nil
+}
+
+// F8E4M3Fn returns the data of a float8_e4m3fn array. The slice aliases
+// Data.
+func (a *Array) F8E4M3Fn() ([]floatx.F8E4M3Fn, error) {
+	if err := a.check(floatx.DTypeF8E4M3Fn); err != nil {
+		return nil, err
+	}
+	return floatx.ViewF8E4M3Fn(a.Data), nil
+}
+
+// F8E5M2 returns the data of a float8_e5m2 array. The slice aliases Data.
+func (a *Array) F8E5M2() ([]floatx.F8E5M2, error) {
+	if err := a.check(floatx.DTypeF8E5M2); err != nil {
+		return nil, err
+	}
+	return floatx.ViewF8E5M2(a.Data), nil
+}
+
+// Float32s decodes the data into a new slice.
+func (a *Array) Float32s() ([]float32, error) {
+	if err := a.validate(); err != nil {
+		return nil, err
+	}
+	decode, n := a.DType.Info().Decode, a.DType.Size()
+	out := make([]float32, a.Len())
+	for i := range out {
+		out[i] = decode(a.Data[i*n:])
+	}
+	return out, nil
+}
+
+func (a *Array) check(want floatx.DType) error {
+	if a.DType != want {
+		return fmt.Errorf("npy: array is %s, not %s", a.DType, want)
+	}
+	return nil
+}
+
+// validate verifies that the shape matches the data.
+func (a *Array) validate() error {
+	size := a.DType.Size()
+	if size == 0 {
+		return fmt.Errorf("npy: unsupported %s", a.DType)
+	}
+	l := uint64(1)
+	for _, d := range a.Shape {
+		if d < 0 {
+			return fmt.Errorf("npy: invalid shape %v", a.Shape)
+		}
+		if d != 0 && l > math.MaxUint64/uint64(d) {
+			return fmt.Errorf("npy: shape %v overflows", a.Shape)
+		}
+		l *= uint64(d)
+	}
+	if l > math.MaxUint64/uint64(size) || l*uint64(size) != uint64(len(a.Data)) {
+		return fmt.Errorf("npy: shape %v of %s doesn't match %d bytes", a.Shape, a.DType, len(a.Data))
+	}
+	return nil
+}
+
+// Parse parses a .npy file loaded in memory.
+//
+// The array's Data aliases b unless it had to be converted from big endian.
+func Parse(b []byte) (*Array, error) {
+	if len(b) < len(magic)+2 || string(b[:len(magic)]) != magic {
+		return nil, errors.New("npy: invalid magic")
+	}
+	major := b[len(magic)]
+	var n, start int
+	switch major {
+	case 1:
+		if len(b) < 10 {
+			return nil, errors.New("npy: file too short")
+		}
+		n, start = int(binary.LittleEndian.Uint16(b[8:])), 10
+	case 2, 3:
+		if len(b) < 12 {
+			return nil, errors.New("npy: file too short")
+		}
+		if v := binary.LittleEndian.Uint32(b[8:]); v > maxHeaderSize {
+			return nil, fmt.Errorf("npy: header too large: %d bytes", v)
+		}
+		n, start = int(binary.LittleEndian.Uint32(b[8:])), 12
+	default:
+		return nil, fmt.Errorf("npy: unsupported version %d.%d", major, b[len(magic)+1])
+	}
+	if n > len(b)-start {
+		return nil, errors.New("npy: file too short")
+	}
+	a, swap, err := parseHeader(string(b[start : start+n]))
+	if err != nil {
+		return nil, err
+	}
+	a.Data = b[start+n:]
+	if err = a.validate(); err != nil {
+		return nil, err
+	}
+	if swap {
+		a.Data = toLittleEndian(a.DType, a.Data)
+	}
+	return a, nil
+}
+
+// Read reads a .npy file from r.
+func Read(r io.Reader) (*Array, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(b)
+}
+
+// ReadFile reads and parses a .npy file.
+func ReadFile(name string) (*Array, error) {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(b)
+}
+
+// Write writes a as a .npy file.
+func Write(w io.Writer, a *Array) error {
+	if err := a.validate(); err != nil {
+		return err
+	}
+	h := &strings.Builder{}
+	h.WriteString("{'descr': '")
+	h.WriteString(descrs[a.DType])
+	h.WriteString("', 'fortran_order': ")
+	if a.FortranOrder {
+		h.WriteString("True")
+	} else {
+		h.WriteString("False")
+	}
+	h.WriteString(", 'shape': (")
+	for i, d := range a.Shape {
+		if i != 0 {
+			h.WriteString(", ")
+		}
+		h.WriteString(strconv.Itoa(d))
+	}
+	if len(a.Shape) == 1 {
+		h.WriteString(",")
+	}
+	h.WriteString("), }")
+	// Pad with spaces and a trailing newline so the data is aligned on 64
+	// bytes, like numpy does.
+	prefix := len(magic) + 2 + 2
+	if h.Len()+prefix+1 > math.MaxUint16 {
+		prefix += 2
+	}
+	for (prefix+h.Len()+1)%64 != 0 {
+		h.WriteByte(' ')
+	}
+	h.WriteByte('\n')
+	b := make([]byte, 0, prefix+h.Len())
+	b = append(b, magic...)
+	if prefix == len(magic)+4 {
+		b = append(b, 1, 0)
+		b = binary.LittleEndian.AppendUint16(b, uint16(h.Len()))
+	} else {
+		b = append(b, 2, 0)
+		b = binary.LittleEndian.AppendUint32(b, uint32(h.Len()))
+	}
+	b = append(b, h.String()...)
+	if _, err := w.Write(b); err != nil {
+		return err
+	}
+	_, err := w.Write(a.Data)
+	return err
+}
+
+// WriteFile writes a as a .npy file.
+func WriteFile(name string, a *Array) error {
+	buf := bytes.Buffer{}
+	if err := Write(&buf, a); err != nil {
+		return err
+	}
+	return os.WriteFile(name, buf.Bytes(), 0o666)
+}
+
+// descrs maps the dtypes to the numpy descr written.
+var descrs = map[floatx.DType]string{
+	floatx.DTypeF32:      "<f4",
+	floatx.DTypeF16:      "<f2",
+	floatx.DTypeBF16:     "bfloat16",
+	floatx.DTypeF8E4M3:   "float8_e4m3",
+	floatx.DTypeF8E4M3Fn: "float8_e4m3fn",
+	floatx.DTypeF8E5M2:   "float8_e5m2",
+}
+
+// parseDescr returns the dtype of a numpy descr and whether it is big endian.
+func parseDescr(descr string) (floatx.DType, bool, error) {
+	switch descr {
+	case "<f4":
+		return floatx.DTypeF32, false, nil
+	case ">f4":
+		return floatx.DTypeF32, true, nil
+	case "<f2":
+		return floatx.DTypeF16, false, nil
+	case ">f2":
+		return floatx.DTypeF16, true, nil
+	case "bfloat16", "<bfloat16":
+		return floatx.DTypeBF16, false, nil
+	case "float8_e4m3", "|float8_e4m3":
+		return floatx.DTypeF8E4M3, false, nil
+	case "float8_e4m3fn", "|float8_e4m3fn":
+		return floatx.DTypeF8E4M3Fn, false, nil
+	case "float8_e5m2", "|float8_e5m2":
+		return floatx.DTypeF8E5M2, false, nil
+	default:
+		return 0, false, fmt.Errorf("npy: unsupported dtype %q", descr)
+	}
+}
+
+// toLittleEndian returns a little endian copy of big endian data.
+func toLittleEndian(dtype floatx.DType, b []byte) []byte {
+	out := make([]byte, len(b))
+	size := dtype.Size()
+	for i := 0; i < len(b); i += size {
+		for j := range size {
+			out[i+j] = b[i+size-1-j]
+		}
+	}
+	return out
+}
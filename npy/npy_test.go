@@ -0,0 +1,372 @@
+// Copyright 2024 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package npy_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/maruel/floatx"
+	"github.com/maruel/floatx/npy"
+)
+
+// header returns a version 1.0 .npy header.
+func header(h string) []byte {
+	for (10+len(h)+1)%64 != 0 {
+		h += " "
+	}
+	h += "\n"
+	b := append([]byte("\x93NUMPY\x01\x00"), byte(len(h)), byte(len(h)>>8))
+	return append(b, h...)
+}
+
+func Test_Write_NumPy(t *testing.T) {
+	// What numpy.save(f, numpy.array([1, 2, 3], dtype="<f2")) writes.
+	want := header("{'descr': '<f2', 'fortran_order': False, 'shape': (3,), }")
+	want = append(want, 0x00, 0x3C, 0x00, 0x40, 0x00, 0x42)
+	buf := bytes.Buffer{}
+	a := &npy.Array{DType: floatx.DTypeF16, Shape: []int{3}, Data: want[128:]}
+	if err := npy.Write(&buf, a); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Fatalf("want=%q\ngot= %q", want, buf.Bytes())
+	}
+	got, err := npy.Parse(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, err := got.Float32s(); err != nil || !slices.Equal(v, []float32{1, 2, 3}) {
+		t.Fatal(v, err)
+	}
+}
+
+func Test_RoundTrip(t *testing.T) {
+	data := []*npy.Array{
+		{DType: floatx.DTypeF32, Shape: []int{2, 1}, Data: []byte{0, 0, 0x80, 0x3F, 0, 0, 0, 0xC0}},
+		{DType: floatx.DTypeF16, Shape: []int{}, Data: []byte{0x00, 0x3C}},
+		{DType: floatx.DTypeBF16, Shape: []int{1, 2}, FortranOrder: true, Data: []byte{0x80, 0x3F, 0x00, 0xC0}},
+		{DType: floatx.DTypeF8E4M3, Shape: []int{2}, Data: []byte{0x38, 0xC0}},
+		{DType: floatx.DTypeF8E4M3Fn, Shape: []int{2}, Data: []byte{0x38, 0xC0}},
+		{DType: floatx.DTypeF8E5M2, Shape: []int{2}, Data: []byte{0x3C, 0xC0}},
+		{DType: floatx.DTypeF8E5M2, Shape: []int{0, 3}, Data: []byte{}},
+	}
+	for _, a := range data {
+		t.Run(a.DType.String(), func(t *testing.T) {
+			buf := bytes.Buffer{}
+			if err := npy.Write(&buf, a); err != nil {
+				t.Fatal(err)
+			}
+			if (buf.Len()-len(a.Data))%64 != 0 {
+				t.Fatalf("not aligned: %d", buf.Len())
+			}
+			got, err := npy.Read(&buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.DType != a.DType || !slices.Equal(got.Shape, a.Shape) || got.FortranOrder != a.FortranOrder || !bytes.Equal(got.Data, a.Data) {
+				t.Fatalf("want=%+v got=%+v", a, got)
+			}
+			if got.Len() != len(a.Data)/a.DType.Size() {
+				t.Fatal(got.Len())
+			}
+			v, err := got.Float32s()
+			if err != nil || len(v) != got.Len() {
+				t.Fatal(v, err)
+			}
+			if len(v) == 2 && (v[0] != 1 || v[1] != -2) {
+				t.Fatal(v)
+			}
+		})
+	}
+}
+
+func Test_Typed(t *testing.T) {
+	bf16 := &npy.Array{DType: floatx.DTypeBF16, Data: []byte{0x80, 0x3F}}
+	if v, err := bf16.BF16(); err != nil || v[0].Float32() != 1 {
+		t.Fatal(v, err)
+	}
+	if _, err := bf16.F16(); err == nil {
+		t.Fatal("expected error")
+	}
+	f16 := &npy.Array{DType: floatx.DTypeF16, Data: []byte{0x00, 0x3C}}
+	if v, err := f16.F16(); err != nil || v[0].Float32() != 1 {
+		t.Fatal(v, err)
+	}
+	if _, err := f16.F32(); err == nil {
+		t.Fatal("expected error")
+	}
+	f32 := &npy.Array{DType: floatx.DTypeF32, Data: []byte{0, 0, 0x80, 0x3F}}
+	if v, err := f32.F32(); err != nil || v[0] != 1 {
+		t.Fatal(v, err)
+	}
+	if _, err := f32.BF16(); err == nil {
+		t.Fatal("expected error")
+	}
+	f8 := &npy.Array{DType: floatx.DTypeF8E4M3, Data: []byte{0x38}}
+	if v, err := f8.F8E4M3(); err != nil || v[0].Float32() != 1 {
+		t.Fatal(v, err)
+	}
+	if _, err := f8.F8E4M3Fn(); err == nil {
+		t.Fatal("expected error")
+	}
+	f8.DType = floatx.DTypeF8E4M3Fn
+	if v, err := f8.F8E4M3Fn(); err != nil || v[0].Float32() != 1 {
+		t.Fatal(v, err)
+	}
+	if _, err := f8.F8E5M2(); err == nil {
+		t.Fatal("expected error")
+	}
+	f8.DType = floatx.DTypeF8E5M2
+	if v, err := f8.F8E5M2(); err != nil || v[0].Float32() != 0.5 {
+		t.Fatal(v, err)
+	}
+	if _, err := f8.F8E4M3(); err == nil {
+		t.Fatal("expected error")
+	}
+	if l := (&npy.Array{Data: []byte{1}}).Len(); l != 0 {
+		t.Fatal(l)
+	}
+}
+
+func Test_Parse_Variants(t *testing.T) {
+	data := []struct {
+		header string
+		data   []byte
+		dtype  floatx.DType
+		shape  []int
+		want   []float32
+	}{
+		{`{"descr": ">f2", "fortran_order": False, "shape": (2L,)}`, []byte{0x3C, 0x00, 0xC0, 0x00}, floatx.DTypeF16, []int{2}, []float32{1, -2}},
+		{`{'shape': (1,), 'fortran_order': False, 'descr': '>f4'}`, []byte{0x3F, 0x80, 0, 0}, floatx.DTypeF32, []int{1}, []float32{1}},
+		{`{'descr': '<bfloat16', 'fortran_order': False, 'shape': (1, ), }`, []byte{0x80, 0x3F}, floatx.DTypeBF16, []int{1}, []float32{1}},
+		{`{'descr': '|float8_e4m3', 'fortran_order': False, 'shape': ()}`, []byte{0x38}, floatx.DTypeF8E4M3, []int{}, []float32{1}},
+		{`{'descr': '|float8_e4m3fn', 'fortran_order': False, 'shape': ()}`, []byte{0x38}, floatx.DTypeF8E4M3Fn, []int{}, []float32{1}},
+		{`{'descr': '|float8_e5m2', 'fortran_order': False, 'shape': ()}`, []byte{0x3C}, floatx.DTypeF8E5M2, []int{}, []float32{1}},
+	}
+	for i, line := range data {
+		a, err := npy.Parse(append(header(line.header), line.data...))
+		if err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		if a.DType != line.dtype || !slices.Equal(a.Shape, line.shape) {
+			t.Fatalf("#%d: %+v", i, a)
+		}
+		if v, err := a.Float32s(); err != nil || !slices.Equal(v, line.want) {
+			t.Fatalf("#%d: %v %v", i, v, err)
+		}
+	}
+	// Version 2.0.
+	h := "{'descr': '<f2', 'fortran_order': False, 'shape': (1,), }\n"
+	b := append([]byte("\x93NUMPY\x02\x00"), binary.LittleEndian.AppendUint32(nil, uint32(len(h)))...)
+	b = append(append(b, h...), 0x00, 0x3C)
+	if a, err := npy.Parse(b); err != nil || a.Len() != 1 {
+		t.Fatal(a, err)
+	}
+}
+
+func Test_Parse_Errors(t *testing.T) {
+	data := []struct {
+		header string
+		data   int
+		want   string
+	}{
+		{`{'descr': '<f8', 'fortran_order': False, 'shape': (1,), }`, 8, `npy: unsupported dtype "<f8"`},
+		{`{'descr': '<f2', 'fortran_order': False, 'shape': (2,), }`, 2, `npy: shape [2] of F16 doesn't match 2 bytes`},
+		{`{'descr': '<f2', 'fortran_order': False, 'shape': (2147483647, 2147483647, 2147483647), }`, 2, `npy: shape [2147483647 2147483647 2147483647] overflows`},
+		{`{'descr': '<f2', 'fortran_order': False, 'shape': (2147483647, 2147483647, 3), }`, 2, `npy: shape [2147483647 2147483647 3] of F16 doesn't match 2 bytes`},
+		{`{'descr': '<f2', 'fortran_order': False}`, 2, `npy: invalid header at offset 40: missing key`},
+		{`{'descr': '<f2', 'fortran_order': Maybe, 'shape': ()}`, 2, `npy: invalid header at offset 34: expected boolean`},
+		{`{'descr': '<f2', 'foo': 1}`, 2, `npy: invalid header at offset 23: unknown key "foo"`},
+		{`{'descr': '<f2' 'shape': ()}`, 2, `npy: invalid header at offset 16: expected '}'`},
+		{`{'descr': '<f2', 'shape': (a,)}`, 2, `npy: invalid header at offset 27: expected integer`},
+		{`{'descr': '<f2', 'shape': (1 2)}`, 2, `npy: invalid header at offset 29: expected ')'`},
+		{`{'descr': '<f2', 'shape': 1}`, 2, `npy: invalid header at offset 26: expected '('`},
+		{`{'descr': 1}`, 2, `npy: invalid header at offset 10: expected string`},
+		{`{'descr: 1}`, 2, `npy: invalid header at offset 1: unterminated string`},
+		{`{1: 1}`, 2, `npy: invalid header at offset 1: expected string`},
+		{`{'descr' 1}`, 2, `npy: invalid header at offset 9: expected ':'`},
+		{`{} {}`, 2, `npy: invalid header at offset 2: trailing data`},
+		{`[]`, 2, `npy: invalid header at offset 0: expected '{'`},
+	}
+	for i, line := range data {
+		if _, err := npy.Parse(append(header(line.header), make([]byte, line.data)...)); err == nil || err.Error() != line.want {
+			t.Errorf("#%d: want=%q got=%v", i, line.want, err)
+		}
+	}
+	bad := []struct {
+		b    string
+		want string
+	}{
+		{"", "npy: invalid magic"},
+		{"\x93NUMPX\x01\x00", "npy: invalid magic"},
+		{"\x93NUMPY\x04\x00", "npy: unsupported version 4.0"},
+		{"\x93NUMPY\x01\x00\x01", "npy: file too short"},
+		{"\x93NUMPY\x01\x00\x01\x00", "npy: file too short"},
+		{"\x93NUMPY\x02\x00\x01\x00\x00", "npy: file too short"},
+		{"\x93NUMPY\x03\x00\x00\x00\x00\x01", "npy: header too large: 16777216 bytes"},
+	}
+	for i, line := range bad {
+		if _, err := npy.Parse([]byte(line.b)); err == nil || err.Error() != line.want {
+			t.Errorf("#%d: want=%q got=%v", i, line.want, err)
+		}
+	}
+}
+
+type failWriter struct {
+	n int
+}
+
+func (f *failWriter) Write(p []byte) (int, error) {
+	if f.n == 0 {
+		return 0, errors.New("oh")
+	}
+	f.n--
+	return len(p), nil
+}
+
+func Test_Write_Errors(t *testing.T) {
+	data := []struct {
+		a    *npy.Array
+		want string
+	}{
+		{&npy.Array{}, "npy: unsupported DType(0)"},
+		{&npy.Array{DType: floatx.DTypeF16, Shape: []int{-1}}, "npy: invalid shape [-1]"},
+		{&npy.Array{DType: floatx.DTypeF16, Shape: []int{2}}, "npy: shape [2] of F16 doesn't match 0 bytes"},
+	}
+	for i, line := range data {
+		if err := npy.Write(&bytes.Buffer{}, line.a); err == nil || err.Error() != line.want {
+			t.Errorf("#%d: want=%q got=%v", i, line.want, err)
+		}
+		if _, err := line.a.Float32s(); err == nil || err.Error() != line.want {
+			t.Errorf("#%d: want=%q got=%v", i, line.want, err)
+		}
+	}
+	a := &npy.Array{DType: floatx.DTypeF16, Data: []byte{0, 0}}
+	if err := npy.Write(&failWriter{}, a); err == nil {
+		t.Fatal("expected error")
+	}
+	if err := npy.Write(&failWriter{n: 1}, a); err == nil {
+		t.Fatal("expected error")
+	}
+	// Large headers use version 2.0.
+	shape := slices.Repeat([]int{1}, 30000)
+	buf := bytes.Buffer{}
+	if err := npy.Write(&buf, &npy.Array{DType: floatx.DTypeF16, Shape: shape, Data: []byte{0, 0}}); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Bytes()[6] != 2 {
+		t.Fatal(buf.Bytes()[:8])
+	}
+	if got, err := npy.Parse(buf.Bytes()); err != nil || len(got.Shape) != len(shape) {
+		t.Fatal(err)
+	}
+}
+
+func Test_Files(t *testing.T) {
+	dir := t.TempDir()
+	p := filepath.Join(dir, "a.npy")
+	a := &npy.Array{DType: floatx.DTypeBF16, Shape: []int{1}, Data: []byte{0x80, 0x3F}}
+	if err := npy.WriteFile(p, a); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := npy.ReadFile(p); err != nil || !bytes.Equal(got.Data, a.Data) {
+		t.Fatal(got, err)
+	}
+	if _, err := npy.ReadFile(p + "x"); err == nil {
+		t.Fatal("expected error")
+	}
+	if err := npy.WriteFile(p, &npy.Array{}); err == nil {
+		t.Fatal("expected error")
+	}
+	if _, err := npy.Read(&failReader{}); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+type failReader struct{}
+
+func (failReader) Read(p []byte) (int, error) {
+	return 0, errors.New("oh")
+}
+
+func Test_NPZ(t *testing.T) {
+	arrays := map[string]*npy.Array{
+		"b": {DType: floatx.DTypeBF16, Shape: []int{1}, Data: []byte{0x80, 0x3F}},
+		"a": {DType: floatx.DTypeF8E4M3Fn, Shape: []int{2}, Data: []byte{0x38, 0x7E}},
+	}
+	dir := t.TempDir()
+	for _, compress := range []bool{false, true} {
+		buf := bytes.Buffer{}
+		if err := npy.WriteNPZ(&buf, arrays, compress); err != nil {
+			t.Fatal(err)
+		}
+		got, err := npy.ReadNPZ(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != 2 || !bytes.Equal(got["a"].Data, arrays["a"].Data) || got["b"].DType != floatx.DTypeBF16 {
+			t.Fatal(got)
+		}
+		p := filepath.Join(dir, "a.npz")
+		if err = os.WriteFile(p, buf.Bytes(), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if got, err = npy.ReadNPZFile(p); err != nil || len(got) != 2 {
+			t.Fatal(got, err)
+		}
+	}
+	if _, err := npy.ReadNPZFile(filepath.Join(dir, "missing.npz")); err == nil {
+		t.Fatal("expected error")
+	}
+	if _, err := npy.ReadNPZ(strings.NewReader("foo"), 3); err == nil {
+		t.Fatal("expected error")
+	}
+	if err := npy.WriteNPZ(&bytes.Buffer{}, map[string]*npy.Array{"x": {}}, false); err == nil {
+		t.Fatal("expected error")
+	}
+	if err := npy.WriteNPZ(&failWriter{}, arrays, false); err == nil {
+		t.Fatal("expected error")
+	}
+	// A corrupted member and an unsupported compression method.
+	for _, line := range []struct {
+		method uint16
+		want   string
+	}{
+		{zip.Store, `npy: invalid magic in "x.npy"`},
+		{99, `zip: unsupported compression algorithm in "x.npy"`},
+	} {
+		buf := bytes.Buffer{}
+		z := zip.NewWriter(&buf)
+		f, err := z.CreateRaw(&zip.FileHeader{Name: "x.npy", Method: line.method})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = f.Write([]byte("\x93NUMPX")); err != nil {
+			t.Fatal(err)
+		}
+		if err = z.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if _, err = npy.ReadNPZ(bytes.NewReader(buf.Bytes()), int64(buf.Len())); err == nil || err.Error() != line.want {
+			t.Fatal(err)
+		}
+	}
+	// Flushing the first member fails when creating the second one.
+	big := map[string]*npy.Array{
+		"a": {DType: floatx.DTypeF8E5M2, Shape: []int{3900}, Data: make([]byte, 3900)},
+		"b": {DType: floatx.DTypeF8E5M2, Shape: []int{}, Data: []byte{0}},
+	}
+	if err := npy.WriteNPZ(&failWriter{}, big, false); err == nil {
+		t.Fatal("expected error")
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright 2024 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package npy
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// ReadNPZ reads all the arrays in a .npz file of the specified size.
+//
+// The keys are the names of the arrays, without the .npy extension, like
+// numpy.load() returns them.
+func ReadNPZ(r io.ReaderAt, size int64) (map[string]*Array, error) {
+	z, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("npy: %w", err)
+	}
+	return readNPZ(z)
+}
+
+// ReadNPZFile reads all the arrays in a .npz file.
+func ReadNPZFile(name string) (map[string]*Array, error) {
+	z, err := zip.OpenReader(name)
+	if err != nil {
+		return nil, fmt.Errorf("npy: %w", err)
+	}
+	defer z.Close()
+	return readNPZ(&z.Reader)
+}
+
+func readNPZ(z *zip.Reader) (map[string]*Array, error) {
+	out := make(map[string]*Array, len(z.File))
+	for _, f := range z.File {
+		a, err := readZipFile(f)
+		if err != nil {
+			return nil, fmt.Errorf("%w in %q", err, f.Name)
+		}
+		out[strings.TrimSuffix(f.Name, ".npy")] = a
+	}
+	return out, nil
+}
+
+// WriteNPZ writes the arrays as a .npz file, sorted by name.
+//
+// When compress is true, the arrays are compressed with deflate like
+// numpy.savez_compressed() does, otherwise they are stored like numpy.savez()
+// does.
+func WriteNPZ(w io.Writer, arrays map[string]*Array, compress bool) error {
+	names := make([]string, 0, len(arrays))
+	for name := range arrays {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	method := zip.Store
+	if compress {
+		method = zip.Deflate
+	}
+	z := zip.NewWriter(w)
+	for _, name := range names {
+		f, err := z.CreateHeader(&zip.FileHeader{Name: name + ".npy", Method: method})
+		if err != nil {
+			return err
+		}
+		if err = Write(f, arrays[name]); err != nil {
+			return fmt.Errorf("%w in %q", err, name)
+		}
+	}
+	return z.Close()
+}
+
+func readZipFile(f *zip.File) (*Array, error) {
+	r, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return Read(r)
+}
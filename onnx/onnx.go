@@ -0,0 +1,461 @@
+// Copyright 2024 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+// Package onnx reads the initializers of .onnx models without a protobuf
+// dependency.
+//
+// Only the fields of ModelProto, GraphProto and TensorProto needed to extract
+// the tensors are decoded. Tensors are exposed as typed views over the floatx
+// types without copying when possible. FLOAT8E4M3FNUZ and FLOAT8E5M2FNUZ
+// have no equivalent type in floatx but can be decoded to float32.
+//
+// See https://github.com/onnx/onnx/blob/main/onnx/onnx.proto
+package onnx
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+
+	"github.com/maruel/floatx"
+)
+
+// DataType is the TensorProto.DataType enum.
+type DataType int32
+
+// Data types.
+const (
+	Undefined      DataType = 0
+	Float          DataType = 1
+	Uint8          DataType = 2
+	Int8           DataType = 3
+	Uint16         DataType = 4
+	Int16          DataType = 5
+	Int32          DataType = 6
+	Int64          DataType = 7
+	String         DataType = 8
+	Bool           DataType = 9
+	Float16        DataType = 10
+	Double         DataType = 11
+	Uint32         DataType = 12
+	Uint64         DataType = 13
+	Complex64      DataType = 14
+	Complex128     DataType = 15
+	BFloat16       DataType = 16
+	Float8E4M3FN   DataType = 17
+	Float8E4M3FNUZ DataType = 18
+	Float8E5M2     DataType = 19
+	Float8E5M2FNUZ DataType = 20
+)
+
+// dataTypeInfo is the name and the size in bytes of the fixed size data
+// types.
+var dataTypeInfo = map[DataType]struct {
+	name string
+	size int
+}{
+	Float:          {"FLOAT", 4},
+	Uint8:          {"UINT8", 1},
+	Int8:           {"INT8", 1},
+	Uint16:         {"UINT16", 2},
+	Int16:          {"INT16", 2},
+	Int32:          {"INT32", 4},
+	Int64:          {"INT64", 8},
+	Bool:           {"BOOL", 1},
+	Float16:        {"FLOAT16", 2},
+	Double:         {"DOUBLE", 8},
+	Uint32:         {"UINT32", 4},
+	Uint64:         {"UINT64", 8},
+	Complex64:      {"COMPLEX64", 8},
+	Complex128:     {"COMPLEX128", 16},
+	BFloat16:       {"BFLOAT16", 2},
+	Float8E4M3FN:   {"FLOAT8E4M3FN", 1},
+	Float8E4M3FNUZ: {"FLOAT8E4M3FNUZ", 1},
+	Float8E5M2:     {"FLOAT8E5M2", 1},
+	Float8E5M2FNUZ: {"FLOAT8E5M2FNUZ", 1},
+}
+
+func (d DataType) String() string {
+	switch d {
+	case Undefined:
+		return "UNDEFINED"
+	case String:
+		return "STRING"
+	}
+	if i, ok := dataTypeInfo[d]; ok {
+		return i.name
+	}
+	return "DataType(" + strconv.Itoa(int(d)) + ")"
+}
+
+// File is the content of a .onnx file.
+type File struct {
+	IRVersion       int64
+	ProducerName    string
+	ProducerVersion string
+	// Tensors are the initializers of the main graph, in the order of the
+	// file. Constant nodes and subgraphs are not included.
+	Tensors []Tensor
+}
+
+// Tensor is a TensorProto.
+type Tensor struct {
+	Name     string
+	Dims     []int64
+	DataType DataType
+	// Data is the raw little endian data.
+	//
+	// It aliases the parsed buffer when the tensor is stored as raw_data,
+	// otherwise the typed fields are converted. It is nil when the data is
+	// stored externally.
+	Data []byte
+	// External is the external_data key value pairs, e.g. "location", "offset"
+	// and "length", when the data is stored in another file.
+	External map[string]string
+}
+
+// Parse parses a .onnx file loaded in memory.
+//
+// The tensors' Data alias b, so b can be a memory mapped file.
+func Parse(b []byte) (*File, error) {
+	f := &File{}
+	err := fields(b, func(fl *field) error {
+		switch fl.num {
+		case 1: // ir_version
+			if fl.typ != wireVarint {
+				return fl.errWireType()
+			}
+			f.IRVersion = int64(fl.v)
+		case 2, 3: // producer_name, producer_version
+			if fl.typ != wireBytes {
+				return fl.errWireType()
+			}
+			if fl.num == 2 {
+				f.ProducerName = string(fl.data)
+			} else {
+				f.ProducerVersion = string(fl.data)
+			}
+		case 7: // graph
+			if fl.typ != wireBytes {
+				return fl.errWireType()
+			}
+			return f.parseGraph(fl.data)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// ReadFile reads and parses a .onnx file.
+func ReadFile(name string) (*File, error) {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(b)
+}
+
+// ParseTensor parses a serialized TensorProto, like the .pb files of the ONNX
+// test data.
+//
+// The tensor's Data aliases b when stored as raw_data.
+func ParseTensor(b []byte) (*Tensor, error) {
+	t := &Tensor{}
+	if err := t.parse(b); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Tensor returns the tensor with the specified name, or nil.
+func (f *File) Tensor(name string) *Tensor {
+	for i := range f.Tensors {
+		if f.Tensors[i].Name == name {
+			return &f.Tensors[i]
+		}
+	}
+	return nil
+}
+
+func (f *File) parseGraph(b []byte) error {
+	return fields(b, func(fl *field) error {
+		if fl.num != 5 { // initializer
+			return nil
+		}
+		if fl.typ != wireBytes {
+			return fl.errWireType()
+		}
+		t := Tensor{}
+		if err := t.parse(fl.data); err != nil {
+			return err
+		}
+		f.Tensors = append(f.Tensors, t)
+		return nil
+	})
+}
+
+// Len returns the number of elements in the tensor.
+func (t *Tensor) Len() int {
+	l := 1
+	for _, d := range t.Dims {
+		l *= int(d)
+	}
+	return l
+}
+
+// FloatxDType returns the floatx type of the tensor, if supported.
+func (t *Tensor) FloatxDType() (floatx.DType, bool) {
+	return floatx.LookupONNX(int32(t.DataType))
+}
+
+// BF16 returns the data of a BFLOAT16 tensor.
+//
+// The slice aliases Data when possible. See floatx.ViewBF16.
+func (t *Tensor) BF16() ([]floatx.BF16, error) {
+	if err := t.check(BFloat16); err != nil {
+		return nil, err
+	}
+	v, _, err := floatx.ViewBF16(t.Data)
+	return v, err
+}
+
+// F16 returns the data of a FLOAT16 tensor.
+//
+// The slice aliases Data when possible. See floatx.ViewF16.
+func (t *Tensor) F16() ([]floatx.F16, error) {
+	if err := t.check(Float16); err != nil {
+		return nil, err
+	}
+	v, _, err := floatx.ViewF16(t.Data)
+	return v, err
+}
+
+// F32 returns the data of a FLOAT tensor.
+//
+// The slice aliases Data when possible. See floatx.ViewF32.
+func (t *Tensor) F32() ([]floatx.F32, error) {
+	if err := t.check(Float); err != nil {
+		return nil, err
+	}
+	v, _, err := floatx.ViewF32(t.Data)
+	return v, err
+}
+
+// F8E4M3Fn returns the data of a FLOAT8E4M3FN tensor. The slice aliases Data.
+func (t *Tensor) F8E4M3Fn() ([]floatx.F8E4M3Fn, error) {
+	if err := t.check(Float8E4M3FN); err != nil {
+		return nil, err
+	}
+	return floatx.ViewF8E4M3Fn(t.Data), nil
+}
+
+// F8E5M2 returns the data of a FLOAT8E5M2 tensor. The slice aliases Data.
+func (t *Tensor) F8E5M2() ([]floatx.F8E5M2, error) {
+	if err := t.check(Float8E5M2); err != nil {
+		return nil, err
+	}
+	return floatx.ViewF8E5M2(t.Data), nil
+}
+
+// Float32s decodes the data of a floating point tensor into a new slice.
+//
+// Supported types are FLOAT, FLOAT16, BFLOAT16, FLOAT8E4M3FN,
+// FLOAT8E4M3FNUZ, FLOAT8E5M2 and FLOAT8E5M2FNUZ.
+func (t *Tensor) Float32s() ([]float32, error) {
+	if t.External != nil {
+		return nil, fmt.Errorf("onnx: tensor %q: data is stored in %q", t.Name, t.External["location"])
+	}
+	switch t.DataType {
+	case Float8E4M3FNUZ:
+		return decodeFNUZ(t.Data, 3, 8), nil
+	case Float8E5M2FNUZ:
+		return decodeFNUZ(t.Data, 2, 16), nil
+	}
+	d, ok := t.FloatxDType()
+	if !ok {
+		return nil, fmt.Errorf("onnx: tensor %q: can't decode %s as float32", t.Name, t.DataType)
+	}
+	decode, n := d.Info().Decode, d.Size()
+	out := make([]float32, len(t.Data)/d.Size())
+	for i := range out {
+		out[i] = decode(t.Data[i*n:])
+	}
+	return out, nil
+}
+
+func (t *Tensor) check(want DataType) error {
+	if t.DataType != want {
+		return fmt.Errorf("onnx: tensor %q is %s, not %s", t.Name, t.DataType, want)
+	}
+	return nil
+}
+
+// parse decodes a TensorProto.
+func (t *Tensor) parse(b []byte) error {
+	// The typed fields, with their values as bits.
+	var floats, int32s, int64s, doubles, uint64s []uint64
+	var raw []byte
+	hasRaw, external := false, false
+	err := fields(b, func(f *field) error {
+		var err error
+		switch f.num {
+		case 1: // dims
+			var dims []uint64
+			if dims, err = appendVarints(nil, f); err == nil {
+				for _, d := range dims {
+					t.Dims = append(t.Dims, int64(d))
+				}
+			}
+		case 2: // data_type
+			if f.typ != wireVarint {
+				return f.errWireType()
+			}
+			t.DataType = DataType(f.v)
+		case 3: // segment
+			return errors.New("onnx: segmented tensors are not supported")
+		case 4: // float_data
+			floats, err = appendFixed(floats, f, wireFixed32)
+		case 5: // int32_data
+			int32s, err = appendVarints(int32s, f)
+		case 7: // int64_data
+			int64s, err = appendVarints(int64s, f)
+		case 8: // name
+			if f.typ != wireBytes {
+				return f.errWireType()
+			}
+			t.Name = string(f.data)
+		case 9: // raw_data
+			if f.typ != wireBytes {
+				return f.errWireType()
+			}
+			raw, hasRaw = f.data, true
+		case 10: // double_data
+			doubles, err = appendFixed(doubles, f, wireFixed64)
+		case 11: // uint64_data
+			uint64s, err = appendVarints(uint64s, f)
+		case 13: // external_data
+			if f.typ != wireBytes {
+				return f.errWireType()
+			}
+			if t.External == nil {
+				t.External = map[string]string{}
+			}
+			return parseStringString(f.data, t.External)
+		case 14: // data_location
+			if f.typ != wireVarint {
+				return f.errWireType()
+			}
+			external = f.v == 1
+		}
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("%w in tensor %q", err, t.Name)
+	}
+	l := uint64(1)
+	for _, d := range t.Dims {
+		if d < 0 {
+			return fmt.Errorf("onnx: tensor %q: invalid dims %v", t.Name, t.Dims)
+		}
+		if d != 0 && l > math.MaxInt/uint64(d) {
+			return fmt.Errorf("onnx: tensor %q: dims %v overflow", t.Name, t.Dims)
+		}
+		l *= uint64(d)
+	}
+	if external {
+		if t.External == nil {
+			t.External = map[string]string{}
+		}
+		return nil
+	}
+	t.External = nil
+	info, ok := dataTypeInfo[t.DataType]
+	if hasRaw {
+		t.Data = raw
+	} else if ok {
+		var values []uint64
+		switch t.DataType {
+		case Float, Complex64:
+			values = floats
+		case Int64:
+			values = int64s
+		case Double, Complex128:
+			values = doubles
+		case Uint32, Uint64:
+			values = uint64s
+		default:
+			// The types of 16 bits or less are stored in int32_data, and so are
+			// the bits of FLOAT16, BFLOAT16 and the float8 types.
+			values = int32s
+		}
+		size := info.size
+		if t.DataType == Complex64 || t.DataType == Complex128 {
+			size /= 2
+		}
+		t.Data = make([]byte, 0, len(values)*size)
+		var tmp [8]byte
+		for _, v := range values {
+			binary.LittleEndian.PutUint64(tmp[:], v)
+			t.Data = append(t.Data, tmp[:size]...)
+		}
+	}
+	if ok && (l > math.MaxInt/uint64(info.size) || l*uint64(info.size) != uint64(len(t.Data))) {
+		return fmt.Errorf("onnx: tensor %q: dims %v of %s doesn't match %d bytes", t.Name, t.Dims, t.DataType, len(t.Data))
+	}
+	return nil
+}
+
+// parseStringString decodes a StringStringEntryProto into m.
+func parseStringString(b []byte, m map[string]string) error {
+	var key, value string
+	err := fields(b, func(f *field) error {
+		if f.num != 1 && f.num != 2 {
+			return nil
+		}
+		if f.typ != wireBytes {
+			return f.errWireType()
+		}
+		if f.num == 1 {
+			key = string(f.data)
+		} else {
+			value = string(f.data)
+		}
+		return nil
+	})
+	m[key] = value
+	return err
+}
+
+// decodeFNUZ decodes the float8 FNUZ types.
+//
+// They have no infinity, no negative zero and a single NaN encoded as 0x80.
+// See https://onnx.ai/onnx/technical/float8.html
+func decodeFNUZ(b []byte, mantissaBits, bias int) []float32 {
+	out := make([]float32, len(b))
+	for i, v := range b {
+		if v == 0x80 {
+			out[i] = float32(math.NaN())
+			continue
+		}
+		e := int(v&0x7F) >> mantissaBits
+		m := int(v) & (1<<mantissaBits - 1)
+		if e != 0 {
+			m |= 1 << mantissaBits
+		} else {
+			e = 1
+		}
+		f := float32(math.Ldexp(float64(m), e-bias-mantissaBits))
+		if v&0x80 != 0 {
+			f = -f
+		}
+		out[i] = f
+	}
+	return out
+}
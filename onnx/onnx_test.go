@@ -0,0 +1,298 @@
+// Copyright 2024 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package onnx_test
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/maruel/floatx"
+	"github.com/maruel/floatx/onnx"
+)
+
+// msg is a protobuf message encoder.
+type msg []byte
+
+func (m msg) tag(num, typ int) msg {
+	return binary.AppendUvarint(m, uint64(num)<<3|uint64(typ))
+}
+
+func (m msg) varint(num int, v uint64) msg {
+	return binary.AppendUvarint(m.tag(num, 0), v)
+}
+
+func (m msg) fixed64(num int, v uint64) msg {
+	return binary.LittleEndian.AppendUint64(m.tag(num, 1), v)
+}
+
+func (m msg) bytes(num int, b []byte) msg {
+	return append(binary.AppendUvarint(m.tag(num, 2), uint64(len(b))), b...)
+}
+
+func (m msg) fixed32(num int, v uint32) msg {
+	return binary.LittleEndian.AppendUint32(m.tag(num, 5), v)
+}
+
+// packed encodes packed varints.
+func packed(values ...uint64) []byte {
+	var b []byte
+	for _, v := range values {
+		b = binary.AppendUvarint(b, v)
+	}
+	return b
+}
+
+func tensor(name string, typ onnx.DataType, dims ...uint64) msg {
+	m := msg{}
+	for _, d := range dims {
+		m = m.varint(1, d)
+	}
+	return m.varint(2, uint64(typ)).bytes(8, []byte(name))
+}
+
+func Test_Parse(t *testing.T) {
+	minusOne := uint64(math.MaxUint64)
+	graph := msg{}.
+		bytes(1, []byte("a node")).
+		bytes(5, tensor("f16", onnx.Float16, 2).bytes(9, []byte{0x00, 0x3C, 0x00, 0xC0})).
+		bytes(5, msg{}.bytes(1, packed(2)).varint(2, uint64(onnx.BFloat16)).bytes(8, []byte("bf16")).bytes(5, packed(0x3F80, 0xC000))).
+		bytes(5, tensor("f32", onnx.Float, 2).fixed32(4, math.Float32bits(1)).fixed32(4, math.Float32bits(-2))).
+		bytes(5, tensor("f32packed", onnx.Float, 2).bytes(4, binary.LittleEndian.AppendUint32(binary.LittleEndian.AppendUint32(nil, math.Float32bits(1)), math.Float32bits(-2)))).
+		bytes(5, tensor("e4m3fn", onnx.Float8E4M3FN, 2).varint(5, 0x38).varint(5, 0xC0)).
+		bytes(5, tensor("e5m2", onnx.Float8E5M2, 2).bytes(9, []byte{0x3C, 0xC0})).
+		bytes(5, tensor("e4m3fnuz", onnx.Float8E4M3FNUZ, 2).bytes(9, []byte{0x40, 0xC8})).
+		bytes(5, tensor("e5m2fnuz", onnx.Float8E5M2FNUZ, 2).bytes(9, []byte{0x40, 0xC4})).
+		bytes(5, tensor("i64", onnx.Int64, 2).varint(7, 1).varint(7, minusOne)).
+		bytes(5, tensor("i16", onnx.Int16, 1).varint(5, minusOne)).
+		bytes(5, tensor("f64", onnx.Double, 1).fixed64(10, math.Float64bits(1))).
+		bytes(5, tensor("f64packed", onnx.Double, 1).bytes(10, binary.LittleEndian.AppendUint64(nil, math.Float64bits(1)))).
+		bytes(5, tensor("u32", onnx.Uint32, 1).bytes(11, packed(7))).
+		bytes(5, tensor("c64", onnx.Complex64, 1).bytes(4, make([]byte, 8))).
+		bytes(5, tensor("str", onnx.String, 1).bytes(6, []byte("hi"))).
+		bytes(5, tensor("ext", onnx.Float16, 1024).varint(14, 1).bytes(13, msg{}.bytes(1, []byte("location")).bytes(2, []byte("w.bin")).varint(3, 0))).
+		bytes(5, tensor("ext2", onnx.Float16, 1).varint(14, 1)).
+		bytes(5, tensor("scalar", onnx.Float).bytes(9, []byte{0, 0, 0x80, 0x3F})).
+		bytes(5, tensor("empty", onnx.Float, 0, 3))
+	model := msg{}.varint(1, 9).bytes(2, []byte("pytorch")).bytes(3, []byte("2.4")).fixed64(4, 0).fixed32(5, 0).bytes(7, graph)
+	f, err := onnx.Parse(model)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.IRVersion != 9 || f.ProducerName != "pytorch" || f.ProducerVersion != "2.4" || len(f.Tensors) != 19 {
+		t.Fatalf("%+v", f)
+	}
+	for _, name := range []string{"f16", "bf16", "f32", "f32packed", "e4m3fn", "e5m2", "e4m3fnuz", "e5m2fnuz"} {
+		v, err := f.Tensor(name).Float32s()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(v, []float32{1, -2}) {
+			t.Fatalf("%s: %v", name, v)
+		}
+	}
+	data := []struct {
+		name string
+		want []byte
+	}{
+		{"i64", []byte{1, 0, 0, 0, 0, 0, 0, 0, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}},
+		{"i16", []byte{0xFF, 0xFF}},
+		{"f64", []byte{0, 0, 0, 0, 0, 0, 0xF0, 0x3F}},
+		{"f64packed", []byte{0, 0, 0, 0, 0, 0, 0xF0, 0x3F}},
+		{"u32", []byte{7, 0, 0, 0}},
+		{"c64", make([]byte, 8)},
+		{"str", nil},
+		{"scalar", []byte{0, 0, 0x80, 0x3F}},
+		{"empty", []byte{}},
+	}
+	for _, line := range data {
+		if got := f.Tensor(line.name).Data; !slices.Equal(got, line.want) {
+			t.Fatalf("%s: %v", line.name, got)
+		}
+	}
+	ext := f.Tensor("ext")
+	if ext.Data != nil || ext.External["location"] != "w.bin" || ext.Len() != 1024 {
+		t.Fatalf("%+v", ext)
+	}
+	if _, err = ext.Float32s(); err == nil || err.Error() != `onnx: tensor "ext": data is stored in "w.bin"` {
+		t.Fatal(err)
+	}
+	if ext2 := f.Tensor("ext2"); ext2.External == nil {
+		t.Fatalf("%+v", ext2)
+	}
+	if s := f.Tensor("scalar"); s.Len() != 1 || len(s.Dims) != 0 {
+		t.Fatalf("%+v", s)
+	}
+	if f.Tensor("missing") != nil {
+		t.Fatal("unexpected tensor")
+	}
+}
+
+func Test_FNUZ(t *testing.T) {
+	data := []struct {
+		typ  onnx.DataType
+		b    byte
+		want float32
+	}{
+		{onnx.Float8E4M3FNUZ, 0x00, 0},
+		{onnx.Float8E4M3FNUZ, 0x01, 1. / 1024},
+		{onnx.Float8E4M3FNUZ, 0x7F, 240},
+		{onnx.Float8E4M3FNUZ, 0xFF, -240},
+		{onnx.Float8E5M2FNUZ, 0x01, 1. / 131072},
+		{onnx.Float8E5M2FNUZ, 0x7F, 57344},
+	}
+	for i, line := range data {
+		tt := onnx.Tensor{DataType: line.typ, Data: []byte{line.b}}
+		if v, err := tt.Float32s(); err != nil || v[0] != line.want {
+			t.Errorf("#%d: %v %v", i, v, err)
+		}
+	}
+	for _, typ := range []onnx.DataType{onnx.Float8E4M3FNUZ, onnx.Float8E5M2FNUZ} {
+		tt := onnx.Tensor{DataType: typ, Data: []byte{0x80}}
+		if v, err := tt.Float32s(); err != nil || !math.IsNaN(float64(v[0])) {
+			t.Errorf("%s: %v %v", typ, v, err)
+		}
+	}
+}
+
+func Test_Typed(t *testing.T) {
+	bf16 := &onnx.Tensor{DataType: onnx.BFloat16, Data: []byte{0x80, 0x3F}}
+	if v, err := bf16.BF16(); err != nil || v[0].Float32() != 1 {
+		t.Fatal(v, err)
+	}
+	if _, err := bf16.F16(); err == nil || err.Error() != `onnx: tensor "" is BFLOAT16, not FLOAT16` {
+		t.Fatal(err)
+	}
+	f16 := &onnx.Tensor{DataType: onnx.Float16, Data: []byte{0x00, 0x3C}}
+	if v, err := f16.F16(); err != nil || v[0].Float32() != 1 {
+		t.Fatal(v, err)
+	}
+	if _, err := f16.F32(); err == nil {
+		t.Fatal("expected error")
+	}
+	f32 := &onnx.Tensor{DataType: onnx.Float, Data: []byte{0, 0, 0x80, 0x3F}}
+	if v, err := f32.F32(); err != nil || v[0] != 1 {
+		t.Fatal(v, err)
+	}
+	if _, err := f32.BF16(); err == nil {
+		t.Fatal("expected error")
+	}
+	f8 := &onnx.Tensor{DataType: onnx.Float8E4M3FN, Data: []byte{0x38}}
+	if v, err := f8.F8E4M3Fn(); err != nil || v[0].Float32() != 1 {
+		t.Fatal(v, err)
+	}
+	if _, err := f8.F8E5M2(); err == nil {
+		t.Fatal("expected error")
+	}
+	f8.DataType = onnx.Float8E5M2
+	if v, err := f8.F8E5M2(); err != nil || v[0].Float32() != 0.5 {
+		t.Fatal(v, err)
+	}
+	if _, err := f8.F8E4M3Fn(); err == nil {
+		t.Fatal("expected error")
+	}
+	if d, ok := f8.FloatxDType(); !ok || d != floatx.DTypeF8E5M2 {
+		t.Fatal(d, ok)
+	}
+	i64 := &onnx.Tensor{Name: "x", DataType: onnx.Int64}
+	if _, ok := i64.FloatxDType(); ok {
+		t.Fatal("unexpected")
+	}
+	if _, err := i64.Float32s(); err == nil || err.Error() != `onnx: tensor "x": can't decode INT64 as float32` {
+		t.Fatal(err)
+	}
+}
+
+func Test_DataType_String(t *testing.T) {
+	data := map[onnx.DataType]string{
+		onnx.Undefined:      "UNDEFINED",
+		onnx.String:         "STRING",
+		onnx.Float8E5M2FNUZ: "FLOAT8E5M2FNUZ",
+		21:                  "DataType(21)",
+	}
+	for d, want := range data {
+		if got := d.String(); got != want {
+			t.Errorf("want %q, got %q", want, got)
+		}
+	}
+}
+
+func Test_ParseTensor(t *testing.T) {
+	b := tensor("x", onnx.Float16, 1).bytes(9, []byte{0x00, 0x3C})
+	tt, err := onnx.ParseTensor(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, err := tt.F16(); err != nil || v[0].Float32() != 1 {
+		t.Fatal(v, err)
+	}
+	if _, err = onnx.ParseTensor(b[:len(b)-1]); err == nil || err.Error() != `onnx: truncated message in tensor "x"` {
+		t.Fatal(err)
+	}
+}
+
+func Test_Parse_Errors(t *testing.T) {
+	// The number of FLOAT values whose size overflows int.
+	quarter := uint64(math.MaxInt/4 + 1)
+	graph := func(m msg) msg {
+		return msg{}.bytes(7, msg{}.bytes(5, m))
+	}
+	data := []struct {
+		b    msg
+		want string
+	}{
+		{msg{0x80}, "onnx: invalid varint"},
+		{msg{0x08}, "onnx: invalid varint"},
+		{msg{0x09, 0}, "onnx: truncated message"},
+		{msg{0x0A}, "onnx: invalid varint"},
+		{msg{0x0A, 1}, "onnx: truncated message"},
+		{msg{0x0D, 0}, "onnx: truncated message"},
+		{msg{0x0B}, "onnx: field 1 has unsupported wire type 3"},
+		{msg{}.bytes(1, nil), "onnx: field 1 has unexpected wire type 2"},
+		{msg{}.varint(2, 0), "onnx: field 2 has unexpected wire type 0"},
+		{msg{}.varint(7, 0), "onnx: field 7 has unexpected wire type 0"},
+		{msg{}.bytes(7, msg{}.varint(5, 0)), "onnx: field 5 has unexpected wire type 0"},
+		{graph(msg{}.fixed32(1, 0)), `onnx: field 1 has unexpected wire type 5 in tensor ""`},
+		{graph(msg{}.bytes(1, []byte{0x80})), `onnx: invalid varint in tensor ""`},
+		{graph(msg{}.bytes(2, nil)), `onnx: field 2 has unexpected wire type 2 in tensor ""`},
+		{graph(msg{}.bytes(3, nil)), `onnx: segmented tensors are not supported in tensor ""`},
+		{graph(msg{}.varint(4, 0)), `onnx: field 4 has unexpected wire type 0 in tensor ""`},
+		{graph(msg{}.bytes(4, []byte{0})), `onnx: field 4: 1 bytes is not a multiple of 4 in tensor ""`},
+		{graph(msg{}.varint(8, 0)), `onnx: field 8 has unexpected wire type 0 in tensor ""`},
+		{graph(msg{}.varint(9, 0)), `onnx: field 9 has unexpected wire type 0 in tensor ""`},
+		{graph(msg{}.bytes(10, make([]byte, 4))), `onnx: field 10: 4 bytes is not a multiple of 8 in tensor ""`},
+		{graph(msg{}.varint(13, 0)), `onnx: field 13 has unexpected wire type 0 in tensor ""`},
+		{graph(msg{}.bytes(13, msg{}.varint(1, 0))), `onnx: field 1 has unexpected wire type 0 in tensor ""`},
+		{graph(msg{}.bytes(14, nil)), `onnx: field 14 has unexpected wire type 2 in tensor ""`},
+		{graph(tensor("x", onnx.Float).varint(1, math.MaxUint64)), `onnx: tensor "x": invalid dims [-1]`},
+		{graph(tensor("x", onnx.Float, 1<<40, 1<<40)), `onnx: tensor "x": dims [1099511627776 1099511627776] overflow`},
+		{graph(tensor("x", onnx.Float, quarter)), fmt.Sprintf(`onnx: tensor "x": dims [%d] of FLOAT doesn't match 0 bytes`, quarter)},
+		{graph(tensor("x", onnx.Float16, 2).bytes(9, []byte{0, 0})), `onnx: tensor "x": dims [2] of FLOAT16 doesn't match 2 bytes`},
+	}
+	for i, line := range data {
+		if _, err := onnx.Parse(line.b); err == nil || err.Error() != line.want {
+			t.Errorf("#%d: want=%q got=%v", i, line.want, err)
+		}
+	}
+}
+
+func Test_ReadFile(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "a.onnx")
+	if _, err := onnx.ReadFile(p); err == nil {
+		t.Fatal("expected error")
+	}
+	b := msg{}.bytes(7, msg{}.bytes(5, tensor("x", onnx.Float16, 1).bytes(9, []byte{0x00, 0x3C})))
+	if err := os.WriteFile(p, b, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	f, err := onnx.ReadFile(p)
+	if err != nil || len(f.Tensors) != 1 {
+		t.Fatal(f, err)
+	}
+}
@@ -0,0 +1,137 @@
+// Copyright 2024 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package onnx
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Protobuf wire types.
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+// field is one field of a protobuf message.
+type field struct {
+	num  uint64
+	typ  int
+	v    uint64
+	data []byte
+}
+
+// errWireType returns the error for a field with an unexpected wire type.
+func (f *field) errWireType() error {
+	return fmt.Errorf("onnx: field %d has unexpected wire type %d", f.num, f.typ)
+}
+
+// fields calls fn for each field of the protobuf message b.
+//
+// Groups are not supported since ONNX doesn't use them. The data of bytes
+// fields alias b.
+func fields(b []byte, fn func(f *field) error) error {
+	for len(b) != 0 {
+		tag, n := uvarint(b)
+		if n == 0 {
+			return errors.New("onnx: invalid varint")
+		}
+		b = b[n:]
+		f := field{num: tag >> 3, typ: int(tag & 7)}
+		switch f.typ {
+		case wireVarint:
+			if f.v, n = uvarint(b); n == 0 {
+				return errors.New("onnx: invalid varint")
+			}
+		case wireFixed64:
+			if n = 8; len(b) < n {
+				return errors.New("onnx: truncated message")
+			}
+			f.v = binary.LittleEndian.Uint64(b)
+		case wireBytes:
+			l, m := uvarint(b)
+			if m == 0 {
+				return errors.New("onnx: invalid varint")
+			}
+			if l > uint64(len(b)-m) {
+				return errors.New("onnx: truncated message")
+			}
+			n = m + int(l)
+			f.data = b[m:n:n]
+		case wireFixed32:
+			if n = 4; len(b) < n {
+				return errors.New("onnx: truncated message")
+			}
+			f.v = uint64(binary.LittleEndian.Uint32(b))
+		default:
+			return fmt.Errorf("onnx: field %d has unsupported wire type %d", f.num, f.typ)
+		}
+		b = b[n:]
+		if err := fn(&f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// uvarint decodes a varint and returns the number of bytes read, or 0 on
+// error.
+func uvarint(b []byte) (uint64, int) {
+	v, n := binary.Uvarint(b)
+	if n <= 0 {
+		return 0, 0
+	}
+	return v, n
+}
+
+// appendVarints appends the varints of a field that can be packed.
+func appendVarints(dst []uint64, f *field) ([]uint64, error) {
+	switch f.typ {
+	case wireVarint:
+		return append(dst, f.v), nil
+	case wireBytes:
+		for b := f.data; len(b) != 0; {
+			v, n := uvarint(b)
+			if n == 0 {
+				return nil, errors.New("onnx: invalid varint")
+			}
+			dst = append(dst, v)
+			b = b[n:]
+		}
+		return dst, nil
+	default:
+		return nil, f.errWireType()
+	}
+}
+
+// appendFixed appends the values of a fixed32 or fixed64 field that can be
+// packed.
+func appendFixed(dst []uint64, f *field, typ int) ([]uint64, error) {
+	size := 4
+	if typ == wireFixed64 {
+		size = 8
+	}
+	switch f.typ {
+	case typ:
+		return append(dst, f.v), nil
+	case wireBytes:
+		if len(f.data)%size != 0 {
+			return nil, fmt.Errorf("onnx: field %d: %d bytes is not a multiple of %d", f.num, len(f.data), size)
+		}
+		for b := f.data; len(b) != 0; b = b[size:] {
+			if size == 4 {
+				dst = append(dst, uint64(binary.LittleEndian.Uint32(b)))
+			} else {
+				dst = append(dst, binary.LittleEndian.Uint64(b))
+			}
+		}
+		return dst, nil
+	default:
+		return nil, f.errWireType()
+	}
+}
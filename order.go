@@ -0,0 +1,257 @@
+// Copyright 2024 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package floatx
+
+import (
+	"cmp"
+	"math"
+	"slices"
+)
+
+// SortSlice sorts the values in ascending order according to IEEE 754
+// totalOrder, like slices.SortFunc(s, T.Compare) but faster.
+//
+// The 8 and 16 bits types are sorted with a radix sort on their bits.
+func SortSlice[T Float](s []T) {
+	switch v := any(s).(type) {
+	case []F32:
+		slices.SortFunc(v, F32.Compare)
+	case []BF16:
+		radixSort16(v)
+	case []F16:
+		radixSort16(v)
+	case []F8E4M3:
+		countingSort8(v)
+	case []F8E4M3Fn:
+		countingSort8(v)
+	case []F8E5M2:
+		countingSort8(v)
+	}
+}
+
+// F32
+
+// Compare returns -1, 0 or +1 depending on whether f is before, equal to or
+// after o in the IEEE 754 totalOrder described in the package documentation.
+func (f F32) Compare(o F32) int {
+	return f32Format.compare(math.Float32bits(float32(f)), math.Float32bits(float32(o)))
+}
+
+// Less returns true if f is less than o according to IEEE 754, like <.
+func (f F32) Less(o F32) bool {
+	return f32Format.less(math.Float32bits(float32(f)), math.Float32bits(float32(o)))
+}
+
+// Equal returns true if f and o are equal according to IEEE 754, like ==.
+func (f F32) Equal(o F32) bool {
+	return f32Format.equal(math.Float32bits(float32(f)), math.Float32bits(float32(o)))
+}
+
+// BF16
+
+// Compare returns -1, 0 or +1 depending on whether b is before, equal to or
+// after o in the IEEE 754 totalOrder described in the package documentation.
+func (b BF16) Compare(o BF16) int {
+	return bf16Format.compare(uint32(b), uint32(o))
+}
+
+// Less returns true if b is less than o according to IEEE 754, like < on
+// float32.
+func (b BF16) Less(o BF16) bool {
+	return bf16Format.less(uint32(b), uint32(o))
+}
+
+// Equal returns true if b and o are equal according to IEEE 754, like == on
+// float32.
+func (b BF16) Equal(o BF16) bool {
+	return bf16Format.equal(uint32(b), uint32(o))
+}
+
+// F16
+
+// Compare returns -1, 0 or +1 depending on whether f is before, equal to or
+// after o in the IEEE 754 totalOrder described in the package documentation.
+func (f F16) Compare(o F16) int {
+	return f16Format.compare(uint32(f), uint32(o))
+}
+
+// Less returns true if f is less than o according to IEEE 754, like < on
+// float32.
+func (f F16) Less(o F16) bool {
+	return f16Format.less(uint32(f), uint32(o))
+}
+
+// Equal returns true if f and o are equal according to IEEE 754, like == on
+// float32.
+func (f F16) Equal(o F16) bool {
+	return f16Format.equal(uint32(f), uint32(o))
+}
+
+// F8E4M3
+
+// Compare returns -1, 0 or +1 depending on whether f is before, equal to or
+// after o in the IEEE 754 totalOrder described in the package documentation.
+func (f F8E4M3) Compare(o F8E4M3) int {
+	return f8e4m3Format.compare(uint32(f), uint32(o))
+}
+
+// Less returns true if f is less than o according to IEEE 754, like < on
+// float32.
+func (f F8E4M3) Less(o F8E4M3) bool {
+	return f8e4m3Format.less(uint32(f), uint32(o))
+}
+
+// Equal returns true if f and o are equal according to IEEE 754, like == on
+// float32.
+func (f F8E4M3) Equal(o F8E4M3) bool {
+	return f8e4m3Format.equal(uint32(f), uint32(o))
+}
+
+// F8E4M3Fn
+
+// Compare returns -1, 0 or +1 depending on whether f is before, equal to or
+// after o in the totalOrder of the package documentation, without infinity and
+// with a single NaN per sign.
+func (f F8E4M3Fn) Compare(o F8E4M3Fn) int {
+	return f8e4m3fnFormat.compare(uint32(f), uint32(o))
+}
+
+// Less returns true if f is less than o according to IEEE 754, like < on
+// float32.
+func (f F8E4M3Fn) Less(o F8E4M3Fn) bool {
+	return f8e4m3fnFormat.less(uint32(f), uint32(o))
+}
+
+// Equal returns true if f and o are equal according to IEEE 754, like == on
+// float32.
+func (f F8E4M3Fn) Equal(o F8E4M3Fn) bool {
+	return f8e4m3fnFormat.equal(uint32(f), uint32(o))
+}
+
+// F8E5M2
+
+// Compare returns -1, 0 or +1 depending on whether f is before, equal to or
+// after o in the IEEE 754 totalOrder described in the package documentation.
+func (f F8E5M2) Compare(o F8E5M2) int {
+	return f8e5m2Format.compare(uint32(f), uint32(o))
+}
+
+// Less returns true if f is less than o according to IEEE 754, like < on
+// float32.
+func (f F8E5M2) Less(o F8E5M2) bool {
+	return f8e5m2Format.less(uint32(f), uint32(o))
+}
+
+// Equal returns true if f and o are equal according to IEEE 754, like == on
+// float32.
+func (f F8E5M2) Equal(o F8E5M2) bool {
+	return f8e5m2Format.equal(uint32(f), uint32(o))
+}
+
+// Internal
+
+// totalOrderKey returns a key that orders the encodings like IEEE 754
+// totalOrder when compared as unsigned integers.
+//
+// Negative values have their bits flipped so larger magnitudes come first,
+// positive values have their sign bit set so they come after.
+func (f *format) totalOrderKey(bits uint32) uint32 {
+	if bits&f.signBit() != 0 {
+		return ^bits & (f.signBit()<<1 - 1)
+	}
+	return bits | f.signBit()
+}
+
+func (f *format) compare(a, b uint32) int {
+	return cmp.Compare(f.totalOrderKey(a), f.totalOrderKey(b))
+}
+
+func (f *format) less(a, b uint32) bool {
+	if f.isNaN(a) || f.isNaN(b) || (a|b)&^f.signBit() == 0 {
+		return false
+	}
+	return f.compare(a, b) < 0
+}
+
+func (f *format) equal(a, b uint32) bool {
+	if f.isNaN(a) || f.isNaN(b) {
+		return false
+	}
+	return a == b || (a|b)&^f.signBit() == 0
+}
+
+// countingSort8 sorts 8 bits values by counting each encoding.
+//
+// All the types have the sign in the most significant bit.
+func countingSort8[T ~uint8](s []T) {
+	var count [256]int
+	for _, v := range s {
+		count[key8(uint8(v))]++
+	}
+	i := 0
+	for k, n := range count {
+		v := T(unkey8(uint8(k)))
+		for range n {
+			s[i] = v
+			i++
+		}
+	}
+}
+
+func key8(v uint8) uint8 {
+	if v&0x80 != 0 {
+		return ^v
+	}
+	return v | 0x80
+}
+
+func unkey8(k uint8) uint8 {
+	if k&0x80 != 0 {
+		return k &^ 0x80
+	}
+	return ^k
+}
+
+// radixSort16 sorts 16 bits values with a least significant digit radix sort
+// on 8 bits digits of their totalOrder key.
+func radixSort16[T ~uint16](s []T) {
+	if len(s) < 2 {
+		return
+	}
+	for i, v := range s {
+		if v&0x8000 != 0 {
+			s[i] = ^v
+		} else {
+			s[i] = v | 0x8000
+		}
+	}
+	tmp := make([]T, len(s))
+	src, dst := s, tmp
+	for shift := 0; shift < 16; shift += 8 {
+		var offsets [256]int
+		for _, k := range src {
+			offsets[uint8(k>>shift)]++
+		}
+		sum := 0
+		for d, n := range offsets {
+			offsets[d] = sum
+			sum += n
+		}
+		for _, k := range src {
+			d := uint8(k >> shift)
+			dst[offsets[d]] = k
+			offsets[d]++
+		}
+		src, dst = dst, src
+	}
+	// After an even number of passes, the keys are back in s.
+	for i, k := range s {
+		if k&0x8000 != 0 {
+			s[i] = k &^ 0x8000
+		} else {
+			s[i] = ^k
+		}
+	}
+}
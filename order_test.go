@@ -0,0 +1,217 @@
+// Copyright 2024 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package floatx_test
+
+import (
+	"math"
+	"math/rand/v2"
+	"slices"
+	"testing"
+
+	"github.com/maruel/floatx"
+)
+
+func Test_SortSlice_All(t *testing.T) {
+	t.Run("BF16", func(t *testing.T) {
+		testSort(t, slices.Collect(floatx.All[floatx.BF16]()), floatx.SortSlice[floatx.BF16])
+	})
+	t.Run("F16", func(t *testing.T) {
+		testSort(t, slices.Collect(floatx.All[floatx.F16]()), floatx.SortSlice[floatx.F16])
+	})
+	t.Run("F8E4M3", func(t *testing.T) {
+		testSort(t, slices.Collect(floatx.All[floatx.F8E4M3]()), floatx.SortSlice[floatx.F8E4M3])
+	})
+	t.Run("F8E4M3Fn", func(t *testing.T) {
+		testSort(t, slices.Collect(floatx.All[floatx.F8E4M3Fn]()), floatx.SortSlice[floatx.F8E4M3Fn])
+	})
+	t.Run("F8E5M2", func(t *testing.T) {
+		testSort(t, slices.Collect(floatx.All[floatx.F8E5M2]()), floatx.SortSlice[floatx.F8E5M2])
+	})
+}
+
+func Test_SortSlice_F32(t *testing.T) {
+	nan := math.Float32frombits(0x7FC00000)
+	negNaN := math.Float32frombits(0xFFC00000)
+	inf := float32(math.Inf(1))
+	s := []floatx.F32{1, floatx.F32(nan), -2, 0, floatx.F32(math.Copysign(0, -1)), floatx.F32(-inf), floatx.F32(negNaN), 3, floatx.F32(inf)}
+	floatx.SortSlice(s)
+	want := []uint32{0xFFC00000, 0xFF800000, 0xC0000000, 0x80000000, 0, 0x3F800000, 0x40400000, 0x7F800000, 0x7FC00000}
+	for i, v := range s {
+		if got := math.Float32bits(float32(v)); got != want[i] {
+			t.Errorf("#%d: %#x; want %#x", i, got, want[i])
+		}
+	}
+}
+
+func Test_SortSlice_Small(t *testing.T) {
+	s := []floatx.BF16{0x3F80}
+	floatx.SortSlice(s)
+	floatx.SortSlice([]floatx.F8E5M2{})
+	if s[0] != 0x3F80 {
+		t.Fatal(s)
+	}
+}
+
+func Test_Less_All(t *testing.T) {
+	t.Run("F8E4M3", func(t *testing.T) { testLess(t, slices.Collect(floatx.All[floatx.F8E4M3]())) })
+	t.Run("F8E4M3Fn", func(t *testing.T) { testLess(t, slices.Collect(floatx.All[floatx.F8E4M3Fn]())) })
+	t.Run("F8E5M2", func(t *testing.T) { testLess(t, slices.Collect(floatx.All[floatx.F8E5M2]())) })
+}
+
+// testLess verifies that Less and Equal match < and == on float32 for every
+// pair of values.
+func testLess[T interface {
+	floatx.Float
+	Less(T) bool
+	Equal(T) bool
+}](t *testing.T, src []T) {
+	for _, a := range src {
+		for _, b := range src {
+			x, y := a.Float32(), b.Float32()
+			if got := a.Less(b); got != (x < y) {
+				t.Fatalf("Less(%#x, %#x) = %t", a.Bits(), b.Bits(), got)
+			}
+			if got := a.Equal(b); got != (x == y) {
+				t.Fatalf("Equal(%#x, %#x) = %t", a.Bits(), b.Bits(), got)
+			}
+		}
+	}
+}
+
+func Test_Compare(t *testing.T) {
+	negZero := floatx.F16(0x8000)
+	nan := floatx.F16(0x7E00)
+	negNaN := floatx.F16(0xFE00)
+	one := floatx.F16FromFloat32(1)
+	two := floatx.F16FromFloat32(2)
+	data := []struct {
+		a, b    floatx.F16
+		compare int
+		less    bool
+		equal   bool
+	}{
+		{0, 0, 0, false, true},
+		{negZero, 0, -1, false, true},
+		{0, negZero, 1, false, true},
+		{one, two, -1, true, false},
+		{two, one, 1, false, false},
+		{one, one, 0, false, true},
+		{one | 0x8000, one, -1, true, false},
+		{nan, nan, 0, false, false},
+		{negNaN, floatx.F16(0xFC00), -1, false, false},
+		{nan, floatx.F16(0x7C00), 1, false, false},
+		{nan, nan | 1, -1, false, false},
+		{negNaN, negNaN | 1, 1, false, false},
+		{one, nan, -1, false, false},
+	}
+	for i, l := range data {
+		if got := l.a.Compare(l.b); got != l.compare {
+			t.Errorf("#%d: Compare(%#x, %#x) = %d; want %d", i, l.a, l.b, got, l.compare)
+		}
+		if got := l.a.Less(l.b); got != l.less {
+			t.Errorf("#%d: Less(%#x, %#x) = %t; want %t", i, l.a, l.b, got, l.less)
+		}
+		if got := l.a.Equal(l.b); got != l.equal {
+			t.Errorf("#%d: Equal(%#x, %#x) = %t; want %t", i, l.a, l.b, got, l.equal)
+		}
+	}
+	f := floatx.F32(math.Copysign(0, -1))
+	if f.Compare(0) != -1 || f.Less(0) || !f.Equal(0) || !f.Less(1) || floatx.F32(math.NaN()).Equal(floatx.F32(math.NaN())) {
+		t.Fatal("F32")
+	}
+	if b := floatx.BF16FromFloat32(-1); b.Compare(floatx.BF16FromFloat32(1)) != -1 || b.Less(b) || !b.Equal(b) {
+		t.Fatal("BF16")
+	}
+	if f := floatx.F8E4M3Fn(0x7F); f.Compare(0x7E) != 1 || f.Less(0xFF) || f.Equal(f) {
+		t.Fatal("F8E4M3Fn")
+	}
+	if f := floatx.F8E4M3(0x80); f.Compare(0) != -1 || f.Less(0) || !f.Equal(0) {
+		t.Fatal("F8E4M3")
+	}
+	if f := floatx.F8E5M2(0x7C); f.Compare(0x7D) != -1 || f.Less(0x7D) || f.Equal(0x7D) || !f.NextDown().Less(f) {
+		t.Fatal("F8E5M2")
+	}
+}
+
+type ordered[T any] interface {
+	~uint8 | ~uint16
+	Float32() float32
+	Compare(o T) int
+}
+
+// testSort sorts all the encodings twice, shuffled, and verifies the order
+// against the decoded values.
+func testSort[T ordered[T]](t *testing.T, values []T, sortSlice func([]T)) {
+	s := slices.Concat(values, values)
+	r := rand.New(rand.NewPCG(1, 2))
+	r.Shuffle(len(s), func(i, j int) { s[i], s[j] = s[j], s[i] })
+	want := slices.Clone(s)
+	slices.SortFunc(want, T.Compare)
+	sortSlice(s)
+	if !slices.Equal(s, want) {
+		t.Fatal("SortSlice and slices.SortFunc disagree")
+	}
+	// Each encoding is present twice.
+	for i := 0; i < len(s); i += 2 {
+		if s[i] != s[i+1] || i > 0 && s[i] == s[i-1] {
+			t.Fatalf("#%d: %#x %#x", i, s[i], s[i+1])
+		}
+	}
+	// Negative NaNs, then values in order with -0 before +0, then positive
+	// NaNs.
+	signBit := T(1) << (8*sizeof(s[0]) - 1)
+	prev := float32(math.Inf(-1))
+	seenValue := false
+	for i, v := range s {
+		f := v.Float32()
+		if math.IsNaN(float64(f)) {
+			if neg := v&signBit != 0; neg == seenValue {
+				t.Fatalf("#%d: NaN %#x out of place", i, v)
+			}
+			continue
+		}
+		seenValue = true
+		if f < prev || f == 0 && prev == 0 && v&signBit != 0 && s[i-1]&signBit == 0 {
+			t.Fatalf("#%d: %#x (%g) after %g", i, v, f, prev)
+		}
+		prev = f
+	}
+}
+
+// sizeof returns the size of v in bytes.
+func sizeof[T ~uint8 | ~uint16](v T) int {
+	if ^T(0) == 0xFF {
+		return 1
+	}
+	return 2
+}
+
+func Benchmark_SortSlice_BF16(b *testing.B) {
+	r := rand.New(rand.NewPCG(1, 2))
+	src := make([]floatx.BF16, 4096)
+	for i := range src {
+		src[i] = floatx.BF16(r.Uint32())
+	}
+	s := make([]floatx.BF16, len(src))
+	b.ResetTimer()
+	for range b.N {
+		copy(s, src)
+		floatx.SortSlice(s)
+	}
+}
+
+func Benchmark_SortFunc_BF16(b *testing.B) {
+	r := rand.New(rand.NewPCG(1, 2))
+	src := make([]floatx.BF16, 4096)
+	for i := range src {
+		src[i] = floatx.BF16(r.Uint32())
+	}
+	s := make([]floatx.BF16, len(src))
+	b.ResetTimer()
+	for range b.N {
+		copy(s, src)
+		slices.SortFunc(s, floatx.BF16.Compare)
+	}
+}
@@ -0,0 +1,557 @@
+// Copyright 2024 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package pytorch
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+)
+
+// Pickle opcodes understood by the unpickler.
+//
+// See https://github.com/python/cpython/blob/main/Lib/pickletools.py
+const (
+	opMark            = '('
+	opStop            = '.'
+	opPop             = '0'
+	opPopMark         = '1'
+	opDup             = '2'
+	opBinFloat        = 'G'
+	opBinInt          = 'J'
+	opBinInt1         = 'K'
+	opBinInt2         = 'M'
+	opNone            = 'N'
+	opBinPersID       = 'Q'
+	opReduce          = 'R'
+	opBinUnicode      = 'X'
+	opBinBytes        = 'B'
+	opShortBinBytes   = 'C'
+	opEmptyList       = ']'
+	opAppend          = 'a'
+	opBuild           = 'b'
+	opGlobal          = 'c'
+	opAppends         = 'e'
+	opBinGet          = 'h'
+	opLongBinGet      = 'j'
+	opBinPut          = 'q'
+	opLongBinPut      = 'r'
+	opSetItem         = 's'
+	opTuple           = 't'
+	opSetItems        = 'u'
+	opEmptyDict       = '}'
+	opEmptyTuple      = ')'
+	opProto           = 0x80
+	opTuple1          = 0x85
+	opTuple2          = 0x86
+	opTuple3          = 0x87
+	opNewTrue         = 0x88
+	opNewFalse        = 0x89
+	opLong1           = 0x8A
+	opShortBinUnicode = 0x8C
+	opBinUnicode8     = 0x8D
+	opStackGlobal     = 0x93
+	opMemoize         = 0x94
+	opFrame           = 0x95
+)
+
+// global is a reference to a Python object by its module and name.
+type global struct {
+	module, name string
+}
+
+func (g global) String() string {
+	return g.module + "." + g.name
+}
+
+// pyList is a list or a tuple while unpickling.
+//
+// Lists are mutable and can be referenced from the memo before being filled,
+// so they are pointers until the unpickling is done.
+type pyList struct {
+	items []any
+}
+
+// unpickler is a restricted pickle virtual machine.
+//
+// It only knows the opcodes emitted by torch.save() and only resolves the
+// globals needed to rebuild tensors, so loading a malicious file can't
+// execute code.
+type unpickler struct {
+	b     []byte
+	off   int
+	stack []any
+	marks []int
+	memo  map[int]any
+	// persistentLoad resolves a persistent ID, i.e. a storage.
+	persistentLoad func(pid []any) (any, error)
+}
+
+// load runs the pickle program and returns the resulting object.
+func (u *unpickler) load() (any, error) {
+	u.memo = map[int]any{}
+	for {
+		start := u.off
+		op, err := u.next(1)
+		if err != nil {
+			return nil, err
+		}
+		if op[0] == opStop {
+			if len(u.stack) != 1 || len(u.marks) != 0 {
+				return nil, fmt.Errorf("pytorch: invalid pickle stack at offset %d", start)
+			}
+			return u.stack[0], nil
+		}
+		if err = u.step(op[0]); err != nil {
+			return nil, fmt.Errorf("%w at offset %d", err, start)
+		}
+	}
+}
+
+// step executes one opcode.
+func (u *unpickler) step(op byte) error {
+	switch op {
+	case opProto:
+		b, err := u.next(1)
+		if err != nil {
+			return err
+		}
+		if b[0] > 5 {
+			return fmt.Errorf("pytorch: unsupported pickle protocol %d", b[0])
+		}
+	case opFrame:
+		// Frames are only a hint for buffering.
+		_, err := u.next(8)
+		return err
+	case opMark:
+		u.marks = append(u.marks, len(u.stack))
+	case opPop:
+		_, err := u.pop()
+		return err
+	case opPopMark:
+		_, err := u.popMark()
+		return err
+	case opDup:
+		v, err := u.top()
+		if err != nil {
+			return err
+		}
+		u.push(v)
+	case opNone:
+		u.push(nil)
+	case opNewTrue:
+		u.push(true)
+	case opNewFalse:
+		u.push(false)
+	case opBinInt:
+		b, err := u.next(4)
+		if err != nil {
+			return err
+		}
+		u.push(int64(int32(binary.LittleEndian.Uint32(b))))
+	case opBinInt1:
+		b, err := u.next(1)
+		if err != nil {
+			return err
+		}
+		u.push(int64(b[0]))
+	case opBinInt2:
+		b, err := u.next(2)
+		if err != nil {
+			return err
+		}
+		u.push(int64(binary.LittleEndian.Uint16(b)))
+	case opLong1:
+		n, err := u.next(1)
+		if err != nil {
+			return err
+		}
+		if n[0] > 8 {
+			return fmt.Errorf("pytorch: integer of %d bytes is too large", n[0])
+		}
+		b, err := u.next(int(n[0]))
+		if err != nil {
+			return err
+		}
+		// Little endian two's complement.
+		var v int64
+		for i := len(b) - 1; i >= 0; i-- {
+			v = v<<8 | int64(b[i])
+		}
+		if len(b) != 0 && len(b) < 8 && b[len(b)-1]&0x80 != 0 {
+			v -= 1 << (8 * len(b))
+		}
+		u.push(v)
+	case opBinFloat:
+		b, err := u.next(8)
+		if err != nil {
+			return err
+		}
+		u.push(math.Float64frombits(binary.BigEndian.Uint64(b)))
+	case opShortBinUnicode, opShortBinBytes:
+		n, err := u.next(1)
+		if err != nil {
+			return err
+		}
+		return u.pushBytes(op, uint64(n[0]))
+	case opBinUnicode, opBinBytes:
+		n, err := u.next(4)
+		if err != nil {
+			return err
+		}
+		return u.pushBytes(op, uint64(binary.LittleEndian.Uint32(n)))
+	case opBinUnicode8:
+		n, err := u.next(8)
+		if err != nil {
+			return err
+		}
+		return u.pushBytes(op, binary.LittleEndian.Uint64(n))
+	case opEmptyList:
+		u.push(&pyList{})
+	case opEmptyTuple:
+		u.push(&pyList{items: []any{}})
+	case opEmptyDict:
+		u.push(&Dict{})
+	case opTuple:
+		items, err := u.popMark()
+		if err != nil {
+			return err
+		}
+		u.push(&pyList{items: items})
+	case opTuple1, opTuple2, opTuple3:
+		n := int(op-opTuple1) + 1
+		if len(u.stack)-u.lastMark() < n {
+			return errors.New("pytorch: stack underflow")
+		}
+		items := slices.Clone(u.stack[len(u.stack)-n:])
+		u.stack = u.stack[:len(u.stack)-n]
+		u.push(&pyList{items: items})
+	case opAppend:
+		v, err := u.pop()
+		if err != nil {
+			return err
+		}
+		return u.appendTo([]any{v})
+	case opAppends:
+		items, err := u.popMark()
+		if err != nil {
+			return err
+		}
+		return u.appendTo(items)
+	case opSetItem:
+		v, err := u.pop()
+		if err != nil {
+			return err
+		}
+		k, err := u.pop()
+		if err != nil {
+			return err
+		}
+		return u.setItems([]any{k, v})
+	case opSetItems:
+		items, err := u.popMark()
+		if err != nil {
+			return err
+		}
+		if len(items)%2 != 0 {
+			return errors.New("pytorch: odd number of items for SETITEMS")
+		}
+		return u.setItems(items)
+	case opBinPut, opLongBinPut:
+		i, err := u.index(op == opLongBinPut)
+		if err != nil {
+			return err
+		}
+		v, err := u.top()
+		if err != nil {
+			return err
+		}
+		u.memo[i] = v
+	case opMemoize:
+		v, err := u.top()
+		if err != nil {
+			return err
+		}
+		u.memo[len(u.memo)] = v
+	case opBinGet, opLongBinGet:
+		i, err := u.index(op == opLongBinGet)
+		if err != nil {
+			return err
+		}
+		v, ok := u.memo[i]
+		if !ok {
+			return fmt.Errorf("pytorch: memo %d not found", i)
+		}
+		u.push(v)
+	case opGlobal:
+		module, err := u.line()
+		if err != nil {
+			return err
+		}
+		name, err := u.line()
+		if err != nil {
+			return err
+		}
+		return u.pushGlobal(module, name)
+	case opStackGlobal:
+		name, err := u.pop()
+		if err != nil {
+			return err
+		}
+		module, err := u.pop()
+		if err != nil {
+			return err
+		}
+		m, ok1 := module.(string)
+		n, ok2 := name.(string)
+		if !ok1 || !ok2 {
+			return errors.New("pytorch: invalid STACK_GLOBAL arguments")
+		}
+		return u.pushGlobal(m, n)
+	case opReduce:
+		args, err := u.pop()
+		if err != nil {
+			return err
+		}
+		fn, err := u.pop()
+		if err != nil {
+			return err
+		}
+		a, ok := args.(*pyList)
+		if !ok {
+			return errors.New("pytorch: REDUCE arguments are not a tuple")
+		}
+		v, err := reduce(fn, a.items)
+		if err != nil {
+			return err
+		}
+		u.push(v)
+	case opBuild:
+		if _, err := u.pop(); err != nil {
+			return err
+		}
+		obj, err := u.top()
+		if err != nil {
+			return err
+		}
+		// The state of an OrderedDict is its attributes, like the _metadata
+		// of a state_dict, which are ignored.
+		if _, ok := obj.(*Dict); !ok {
+			return fmt.Errorf("pytorch: can't BUILD a %T", obj)
+		}
+	case opBinPersID:
+		pid, err := u.pop()
+		if err != nil {
+			return err
+		}
+		t, ok := pid.(*pyList)
+		if !ok {
+			return errors.New("pytorch: persistent ID is not a tuple")
+		}
+		v, err := u.persistentLoad(t.items)
+		if err != nil {
+			return err
+		}
+		u.push(v)
+	default:
+		return fmt.Errorf("pytorch: unsupported pickle opcode 0x%02X", op)
+	}
+	return nil
+}
+
+func (u *unpickler) next(n int) ([]byte, error) {
+	if n > len(u.b)-u.off {
+		return nil, errors.New("pytorch: truncated pickle")
+	}
+	b := u.b[u.off : u.off+n]
+	u.off += n
+	return b, nil
+}
+
+// line reads up to a newline, for GLOBAL.
+func (u *unpickler) line() (string, error) {
+	i := bytes.IndexByte(u.b[u.off:], '\n')
+	if i < 0 {
+		return "", errors.New("pytorch: truncated pickle")
+	}
+	s := string(u.b[u.off : u.off+i])
+	u.off += i + 1
+	return s, nil
+}
+
+// index reads a memo index.
+func (u *unpickler) index(long bool) (int, error) {
+	if !long {
+		b, err := u.next(1)
+		if err != nil {
+			return 0, err
+		}
+		return int(b[0]), nil
+	}
+	b, err := u.next(4)
+	if err != nil {
+		return 0, err
+	}
+	return int(binary.LittleEndian.Uint32(b)), nil
+}
+
+func (u *unpickler) pushBytes(op byte, n uint64) error {
+	if n > uint64(len(u.b)-u.off) {
+		return errors.New("pytorch: truncated pickle")
+	}
+	b, _ := u.next(int(n))
+	if op == opBinBytes || op == opShortBinBytes {
+		u.push(bytes.Clone(b))
+	} else {
+		u.push(string(b))
+	}
+	return nil
+}
+
+func (u *unpickler) pushGlobal(module, name string) error {
+	g := global{module, name}
+	if module == "torch" && strings.HasSuffix(name, "Storage") {
+		s, ok := storageTypes[name]
+		if !ok {
+			return fmt.Errorf("pytorch: unsupported storage %s", g)
+		}
+		u.push(s)
+		return nil
+	}
+	switch g {
+	case global{"collections", "OrderedDict"},
+		global{"torch._utils", "_rebuild_tensor_v2"},
+		global{"torch._utils", "_rebuild_parameter"},
+		global{"torch._utils", "_rebuild_parameter_with_state"}:
+		u.push(g)
+		return nil
+	}
+	return fmt.Errorf("pytorch: global %s is not allowed", g)
+}
+
+func (u *unpickler) push(v any) {
+	u.stack = append(u.stack, v)
+}
+
+// lastMark returns the stack length at the last mark, or 0.
+func (u *unpickler) lastMark() int {
+	if len(u.marks) == 0 {
+		return 0
+	}
+	return u.marks[len(u.marks)-1]
+}
+
+func (u *unpickler) pop() (any, error) {
+	if len(u.stack) <= u.lastMark() {
+		return nil, errors.New("pytorch: stack underflow")
+	}
+	v := u.stack[len(u.stack)-1]
+	u.stack = u.stack[:len(u.stack)-1]
+	return v, nil
+}
+
+func (u *unpickler) top() (any, error) {
+	if len(u.stack) <= u.lastMark() {
+		return nil, errors.New("pytorch: stack underflow")
+	}
+	return u.stack[len(u.stack)-1], nil
+}
+
+// popMark pops the items up to the last mark and the mark itself.
+func (u *unpickler) popMark() ([]any, error) {
+	if len(u.marks) == 0 {
+		return nil, errors.New("pytorch: mark not found")
+	}
+	m := u.marks[len(u.marks)-1]
+	u.marks = u.marks[:len(u.marks)-1]
+	items := slices.Clone(u.stack[m:])
+	u.stack = u.stack[:m]
+	return items, nil
+}
+
+func (u *unpickler) appendTo(items []any) error {
+	v, err := u.top()
+	if err != nil {
+		return err
+	}
+	l, ok := v.(*pyList)
+	if !ok {
+		return fmt.Errorf("pytorch: can't append to a %T", v)
+	}
+	l.items = append(l.items, items...)
+	return nil
+}
+
+func (u *unpickler) setItems(items []any) error {
+	v, err := u.top()
+	if err != nil {
+		return err
+	}
+	d, ok := v.(*Dict)
+	if !ok {
+		return fmt.Errorf("pytorch: can't set items of a %T", v)
+	}
+	for i := 0; i < len(items); i += 2 {
+		d.set(items[i], items[i+1])
+	}
+	return nil
+}
+
+// reduce calls one of the allowed globals.
+func reduce(fn any, args []any) (any, error) {
+	g, ok := fn.(global)
+	if !ok {
+		return nil, fmt.Errorf("pytorch: can't call a %T", fn)
+	}
+	switch g.name {
+	case "OrderedDict":
+		if len(args) != 0 {
+			return nil, errors.New("pytorch: OrderedDict with arguments is not supported")
+		}
+		return &Dict{}, nil
+	case "_rebuild_tensor_v2":
+		// (storage, storage_offset, size, stride, requires_grad,
+		// backward_hooks[, metadata])
+		if len(args) != 6 && len(args) != 7 {
+			return nil, fmt.Errorf("pytorch: %s: got %d arguments", g, len(args))
+		}
+		return rebuildTensor(args[0], args[1], args[2], args[3])
+	default:
+		// _rebuild_parameter(data, requires_grad, backward_hooks[, state])
+		want := 3
+		if g.name == "_rebuild_parameter_with_state" {
+			want = 4
+		}
+		if len(args) != want {
+			return nil, fmt.Errorf("pytorch: %s: got %d arguments", g, len(args))
+		}
+		t, ok := args[0].(*Tensor)
+		if !ok {
+			return nil, fmt.Errorf("pytorch: %s: expected a tensor, got %T", g, args[0])
+		}
+		return t, nil
+	}
+}
+
+// ints converts a tuple of integers.
+func ints(v any) ([]int, bool) {
+	l, ok := v.(*pyList)
+	if !ok {
+		return nil, false
+	}
+	out := make([]int, len(l.items))
+	for i, x := range l.items {
+		n, ok := x.(int64)
+		if !ok || n < 0 || int64(int(n)) != n {
+			return nil, false
+		}
+		out[i] = int(n)
+	}
+	return out, true
+}
@@ -0,0 +1,536 @@
+// Copyright 2024 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+// Package pytorch reads PyTorch checkpoints saved by torch.save() in the zip
+// format, usually .pt or .pth files.
+//
+// data.pkl is decoded by a restricted pickle interpreter that only knows the
+// opcodes and globals used to rebuild tensors and state dicts. It never
+// executes code and refuses anything it doesn't recognise. The legacy non-zip
+// format used before PyTorch 1.6 is not supported.
+//
+// Tensors are exposed as typed views over the floatx types without copying
+// when they are contiguous.
+package pytorch
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/maruel/floatx"
+)
+
+// maxPickleSize limits the memory used by corrupted files.
+const maxPickleSize = 1024 * 1024 * 1024
+
+// storageType is a torch.<Type>Storage class.
+type storageType struct {
+	// dtype is the torch.dtype name.
+	dtype string
+	size  int
+}
+
+// storageTypes are the storage classes that can be loaded.
+var storageTypes = map[string]storageType{
+	"DoubleStorage":        {"float64", 8},
+	"FloatStorage":         {"float32", 4},
+	"HalfStorage":          {"float16", 2},
+	"BFloat16Storage":      {"bfloat16", 2},
+	"Float8_e4m3fnStorage": {"float8_e4m3fn", 1},
+	"Float8_e5m2Storage":   {"float8_e5m2", 1},
+	"LongStorage":          {"int64", 8},
+	"IntStorage":           {"int32", 4},
+	"ShortStorage":         {"int16", 2},
+	"CharStorage":          {"int8", 1},
+	"ByteStorage":          {"uint8", 1},
+	"BoolStorage":          {"bool", 1},
+}
+
+// storage is a loaded storage.
+type storage struct {
+	typ  storageType
+	data []byte
+}
+
+// File is the content of a checkpoint.
+type File struct {
+	// Object is the unpickled object. It is a tree of nil, bool, int64,
+	// float64, string, []byte, []any for lists and tuples, *Dict for dicts and
+	// OrderedDicts and *Tensor.
+	Object any
+	// Tensors are the tensors found in Object, in the order of the pickle.
+	//
+	// Their name is the path of dict keys and list indices joined with dots,
+	// e.g. "model.layers.0.weight". A tensor referenced multiple times, like
+	// tied weights, has one entry per name. Dicts and lists referenced
+	// multiple times are only visited once.
+	Tensors []Tensor
+}
+
+// Dict is a Python dict, in insertion order.
+type Dict struct {
+	Items []KeyValue
+
+	// index maps the string and integer keys to their position in Items while
+	// unpickling.
+	index map[any]int
+}
+
+// KeyValue is an item of a Dict.
+type KeyValue struct {
+	Key   any
+	Value any
+}
+
+// Get returns the value of a string key.
+func (d *Dict) Get(key string) (any, bool) {
+	for _, kv := range d.Items {
+		if k, ok := kv.Key.(string); ok && k == key {
+			return kv.Value, true
+		}
+	}
+	return nil, false
+}
+
+// set sets an item, replacing the value of an existing string or integer key.
+func (d *Dict) set(k, v any) {
+	switch k.(type) {
+	case string, int64:
+		if i, ok := d.index[k]; ok {
+			d.Items[i].Value = v
+			return
+		}
+		if d.index == nil {
+			d.index = map[any]int{}
+		}
+		d.index[k] = len(d.Items)
+	}
+	d.Items = append(d.Items, KeyValue{k, v})
+}
+
+// Tensor is a tensor rebuilt from a storage.
+type Tensor struct {
+	Name string
+	// DType is the torch.dtype name, e.g. "bfloat16" or "float8_e4m3fn".
+	DType string
+	Shape []int
+	// Stride is the number of elements to skip in Storage to move by one in
+	// each dimension.
+	Stride []int
+	// Offset is the offset in elements of the first value in Storage.
+	Offset int
+	// Storage is the raw little endian data of the whole storage. It can be
+	// shared by multiple tensors.
+	Storage []byte
+}
+
+// Read reads a checkpoint of the specified size.
+func Read(r io.ReaderAt, size int64) (*File, error) {
+	z, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("pytorch: %w", err)
+	}
+	return read(z)
+}
+
+// ReadFile reads a checkpoint.
+func ReadFile(name string) (*File, error) {
+	z, err := zip.OpenReader(name)
+	if err != nil {
+		return nil, fmt.Errorf("pytorch: %w", err)
+	}
+	defer z.Close()
+	return read(&z.Reader)
+}
+
+func read(z *zip.Reader) (*File, error) {
+	// All the files are in a directory named after the checkpoint, usually
+	// "archive".
+	files := map[string]*zip.File{}
+	prefix := ""
+	for _, f := range z.File {
+		files[f.Name] = f
+		if p, ok := strings.CutSuffix(f.Name, "/data.pkl"); ok && !strings.Contains(p, "/") {
+			prefix = p + "/"
+		}
+	}
+	if prefix == "" {
+		return nil, errors.New("pytorch: data.pkl not found")
+	}
+	bigEndian := false
+	if f := files[prefix+"byteorder"]; f != nil {
+		b, err := readZipFile(f, 16)
+		if err != nil {
+			return nil, err
+		}
+		switch string(b) {
+		case "little":
+		case "big":
+			bigEndian = true
+		default:
+			return nil, fmt.Errorf("pytorch: invalid byteorder %q", b)
+		}
+	}
+	pkl, err := readZipFile(files[prefix+"data.pkl"], maxPickleSize)
+	if err != nil {
+		return nil, err
+	}
+	storages := map[string]*storage{}
+	u := unpickler{b: pkl}
+	u.persistentLoad = func(pid []any) (any, error) {
+		// ('storage', storage_type, key, location, numel)
+		if len(pid) != 5 || pid[0] != "storage" {
+			return nil, errors.New("pytorch: unsupported persistent ID")
+		}
+		typ, ok1 := pid[1].(storageType)
+		key, ok2 := pid[2].(string)
+		numel, ok3 := pid[4].(int64)
+		if !ok1 || !ok2 || !ok3 || numel < 0 {
+			return nil, errors.New("pytorch: invalid storage persistent ID")
+		}
+		if s := storages[key]; s != nil {
+			if s.typ != typ {
+				return nil, fmt.Errorf("pytorch: storage %q loaded as %s and %s", key, s.typ.dtype, typ.dtype)
+			}
+			return s, nil
+		}
+		f := files[prefix+"data/"+key]
+		if f == nil {
+			return nil, fmt.Errorf("pytorch: storage %q not found", key)
+		}
+		if numel > math.MaxInt/int64(typ.size) {
+			return nil, fmt.Errorf("pytorch: storage %q: %d %s is too large", key, numel, typ.dtype)
+		}
+		size := int(numel) * typ.size
+		b, err := readZipFile(f, size)
+		if err != nil {
+			return nil, err
+		}
+		if len(b) != size {
+			return nil, fmt.Errorf("pytorch: storage %q: %d bytes doesn't match %d %s", key, len(b), numel, typ.dtype)
+		}
+		if bigEndian {
+			swap(b, typ.size)
+		}
+		s := &storage{typ: typ, data: b}
+		storages[key] = s
+		return s, nil
+	}
+	obj, err := u.load()
+	if err != nil {
+		return nil, err
+	}
+	w := walker{lists: map[*pyList][]any{}, dicts: map[*Dict]bool{}}
+	f := &File{Object: w.walk("", obj)}
+	f.Tensors = w.tensors
+	return f, nil
+}
+
+// Tensor returns the tensor with the specified name, or nil.
+func (f *File) Tensor(name string) *Tensor {
+	for i := range f.Tensors {
+		if f.Tensors[i].Name == name {
+			return &f.Tensors[i]
+		}
+	}
+	return nil
+}
+
+// Len returns the number of elements in the tensor.
+func (t *Tensor) Len() int {
+	l := 1
+	for _, d := range t.Shape {
+		l *= d
+	}
+	return l
+}
+
+// FloatxDType returns the floatx type of the tensor, if supported.
+func (t *Tensor) FloatxDType() (floatx.DType, bool) {
+	if storageName(t.DType) == "" {
+		return 0, false
+	}
+	return floatx.LookupDType(t.DType)
+}
+
+// IsContiguous returns true if the tensor's values are consecutive in
+// Storage, in row-major order.
+func (t *Tensor) IsContiguous() bool {
+	want := 1
+	for i := len(t.Shape) - 1; i >= 0; i-- {
+		if t.Shape[i] == 0 {
+			return true
+		}
+		// Strides of dimensions of size 1 don't matter.
+		if t.Shape[i] != 1 && t.Stride[i] != want {
+			return false
+		}
+		want *= t.Shape[i]
+	}
+	return true
+}
+
+// Data returns the tensor's raw little endian data in row-major order.
+//
+// It aliases Storage when the tensor is contiguous, otherwise the values are
+// copied into a new slice.
+func (t *Tensor) Data() ([]byte, error) {
+	st, ok := storageTypes[storageName(t.DType)]
+	if !ok {
+		return nil, fmt.Errorf("pytorch: tensor %q: unsupported dtype %q", t.Name, t.DType)
+	}
+	size := st.size
+	if err := t.validate(size); err != nil {
+		return nil, err
+	}
+	n := t.Len()
+	if n == 0 {
+		return []byte{}, nil
+	}
+	if t.IsContiguous() {
+		start := t.Offset * size
+		end := start + n*size
+		return t.Storage[start:end:end], nil
+	}
+	out := make([]byte, 0, n*size)
+	idx := make([]int, len(t.Shape))
+	for range n {
+		off := t.Offset
+		for i, j := range idx {
+			off += j * t.Stride[i]
+		}
+		out = append(out, t.Storage[off*size:(off+1)*size]...)
+		// Increment the index, last dimension first.
+		for i := len(idx) - 1; i >= 0; i-- {
+			if idx[i]++; idx[i] < t.Shape[i] {
+				break
+			}
+			idx[i] = 0
+		}
+	}
+	return out, nil
+}
+
+// BF16 returns the data of a bfloat16 tensor.
+//
+// The slice aliases Storage when possible. See Data and floatx.ViewBF16.
+func (t *Tensor) BF16() ([]floatx.BF16, error) {
+	b, err := t.typed("bfloat16")
+	if err != nil {
+		return nil, err
+	}
+	v, _, err := floatx.ViewBF16(b)
+	return v, err
+}
+
+// F16 returns the data of a float16 tensor.
+//
+// The slice aliases Storage when possible. See Data and floatx.ViewF16.
+func (t *Tensor) F16() ([]floatx.F16, error) {
+	b, err := t.typed("float16")
+	if err != nil {
+		return nil, err
+	}
+	v, _, err := floatx.ViewF16(b)
+	return v, err
+}
+
+// F32 returns the data of a float32 tensor.
+//
+// The slice aliases Storage when possible. See Data and floatx.ViewF32.
+func (t *Tensor) F32() ([]floatx.F32, error) {
+	b, err := t.typed("float32")
+	if err != nil {
+		return nil, err
+	}
+	v, _, err := floatx.ViewF32(b)
+	return v, err
+}
+
+// F8E4M3Fn returns the data of a float8_e4m3fn tensor.
+//
+// The slice aliases Storage when the tensor is contiguous.
+func (t *Tensor) F8E4M3Fn() ([]floatx.F8E4M3Fn, error) {
+	b, err := t.typed("float8_e4m3fn")
+	if err != nil {
+		return nil, err
+	}
+	return floatx.ViewF8E4M3Fn(b), nil
+}
+
+// F8E5M2 returns the data of a float8_e5m2 tensor.
+//
+// The slice aliases Storage when the tensor is contiguous.
+func (t *Tensor) F8E5M2() ([]floatx.F8E5M2, error) {
+	b, err := t.typed("float8_e5m2")
+	if err != nil {
+		return nil, err
+	}
+	return floatx.ViewF8E5M2(b), nil
+}
+
+// Float32s decodes the data of a floating point tensor into a new slice, in
+// row-major order.
+func (t *Tensor) Float32s() ([]float32, error) {
+	d, ok := t.FloatxDType()
+	if !ok {
+		return nil, fmt.Errorf("pytorch: tensor %q: can't decode %s as float32", t.Name, t.DType)
+	}
+	b, err := t.Data()
+	if err != nil {
+		return nil, err
+	}
+	decode, n := d.Info().Decode, d.Size()
+	out := make([]float32, len(b)/d.Size())
+	for i := range out {
+		out[i] = decode(b[i*n:])
+	}
+	return out, nil
+}
+
+func (t *Tensor) typed(dtype string) ([]byte, error) {
+	if t.DType != dtype {
+		return nil, fmt.Errorf("pytorch: tensor %q is %s, not %s", t.Name, t.DType, dtype)
+	}
+	return t.Data()
+}
+
+// validate checks that the tensor's values are within Storage.
+func (t *Tensor) validate(size int) error {
+	if len(t.Stride) != len(t.Shape) || t.Offset < 0 {
+		return fmt.Errorf("pytorch: tensor %q: invalid layout", t.Name)
+	}
+	// The number of values and the offset of the last one.
+	n, last := 1, t.Offset
+	for i, d := range t.Shape {
+		if d < 0 || t.Stride[i] < 0 {
+			return fmt.Errorf("pytorch: tensor %q: invalid layout", t.Name)
+		}
+		if d == 0 {
+			return nil
+		}
+		if n > math.MaxInt/size/d || t.Stride[i] != 0 && d-1 > (math.MaxInt-last)/t.Stride[i] {
+			return fmt.Errorf("pytorch: tensor %q: shape %v and stride %v overflow", t.Name, t.Shape, t.Stride)
+		}
+		n *= d
+		last += (d - 1) * t.Stride[i]
+	}
+	if last >= len(t.Storage)/size {
+		return fmt.Errorf("pytorch: tensor %q: shape %v, stride %v and offset %d beyond the %d values of the storage", t.Name, t.Shape, t.Stride, t.Offset, len(t.Storage)/size)
+	}
+	return nil
+}
+
+// rebuildTensor implements torch._utils._rebuild_tensor_v2.
+func rebuildTensor(s, offset, shape, stride any) (*Tensor, error) {
+	st, ok := s.(*storage)
+	if !ok {
+		return nil, fmt.Errorf("pytorch: expected a storage, got %T", s)
+	}
+	o, ok1 := offset.(int64)
+	sh, ok2 := ints(shape)
+	str, ok3 := ints(stride)
+	if !ok1 || !ok2 || !ok3 || o < 0 || int64(int(o)) != o {
+		return nil, errors.New("pytorch: invalid tensor layout")
+	}
+	t := &Tensor{DType: st.typ.dtype, Shape: sh, Stride: str, Offset: int(o), Storage: st.data}
+	if err := t.validate(st.typ.size); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// storageName returns the storage class name of a dtype.
+func storageName(dtype string) string {
+	for name, st := range storageTypes {
+		if st.dtype == dtype {
+			return name
+		}
+	}
+	return ""
+}
+
+// walker converts the unpickled lists and tuples to []any and collects the
+// tensors.
+//
+// Each list and dict is visited once, so shared and recursive references
+// can't make it loop.
+type walker struct {
+	lists   map[*pyList][]any
+	dicts   map[*Dict]bool
+	tensors []Tensor
+}
+
+func (w *walker) walk(name string, v any) any {
+	switch v := v.(type) {
+	case *Tensor:
+		t := *v
+		t.Name = name
+		w.tensors = append(w.tensors, t)
+	case *pyList:
+		if out, ok := w.lists[v]; ok {
+			return out
+		}
+		out := make([]any, len(v.items))
+		w.lists[v] = out
+		for i, x := range v.items {
+			out[i] = w.walk(join(name, strconv.Itoa(i)), x)
+		}
+		return out
+	case *Dict:
+		if w.dicts[v] {
+			return v
+		}
+		w.dicts[v] = true
+		for i := range v.Items {
+			kv := &v.Items[i]
+			kv.Key = w.walk(name, kv.Key)
+			kv.Value = w.walk(join(name, fmt.Sprint(kv.Key)), kv.Value)
+		}
+	}
+	return v
+}
+
+func join(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "." + name
+}
+
+// readZipFile reads a file up to maxSize bytes.
+//
+// The size in the header is not trusted to allocate memory since it can be
+// crafted; the buffer grows with the data actually read.
+func readZipFile(f *zip.File, maxSize int) ([]byte, error) {
+	r, err := f.Open()
+	if err != nil {
+		return nil, fmt.Errorf("pytorch: %w", err)
+	}
+	defer r.Close()
+	// Read until EOF so the checksum is verified.
+	buf := bytes.Buffer{}
+	if _, err = buf.ReadFrom(io.LimitReader(r, int64(maxSize)+1)); err != nil {
+		return nil, fmt.Errorf("pytorch: %s: %w", f.Name, err)
+	}
+	if buf.Len() > maxSize {
+		return nil, fmt.Errorf("pytorch: %s is too large", f.Name)
+	}
+	return buf.Bytes(), nil
+}
+
+// swap converts big endian values of size bytes to little endian in place.
+func swap(b []byte, size int) {
+	for i := 0; i+size <= len(b); i += size {
+		for j := range size / 2 {
+			b[i+j], b[i+size-1-j] = b[i+size-1-j], b[i+j]
+		}
+	}
+}
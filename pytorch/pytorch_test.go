@@ -0,0 +1,563 @@
+// Copyright 2024 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package pytorch_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"math"
+	"math/bits"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/maruel/floatx"
+	"github.com/maruel/floatx/pytorch"
+)
+
+// Pickle builders, emitting what torch.save() does with protocol 2.
+
+func global(module, name string) string {
+	return "c" + module + "\n" + name + "\n"
+}
+
+func str(s string) string {
+	return "X" + string(binary.LittleEndian.AppendUint32(nil, uint32(len(s)))) + s
+}
+
+func integer(n int) string {
+	return "J" + string(binary.LittleEndian.AppendUint32(nil, uint32(n)))
+}
+
+func tuple(values ...int) string {
+	s := "("
+	for _, v := range values {
+		s += integer(v)
+	}
+	return s + "t"
+}
+
+func storage(typ, key string, numel int) string {
+	return "(" + str("storage") + global("torch", typ) + str(key) + str("cpu") + integer(numel) + "tQ"
+}
+
+func orderedDict() string {
+	return global("collections", "OrderedDict") + ")R"
+}
+
+// tensor rebuilds a tensor from a storage.
+func tensor(storage string, offset int, shape, stride []int) string {
+	return global("torch._utils", "_rebuild_tensor_v2") + "(" + storage + integer(offset) + tuple(shape...) + tuple(stride...) + "\x89" + orderedDict() + "tR"
+}
+
+// checkpoint returns a zip file as written by torch.save().
+func checkpoint(t testing.TB, pkl string, files map[string][]byte) []byte {
+	buf := bytes.Buffer{}
+	z := zip.NewWriter(&buf)
+	add := func(name string, b []byte) {
+		f, err := z.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = f.Write(b); err != nil {
+			t.Fatal(err)
+		}
+	}
+	add("archive/data.pkl", []byte(pkl))
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		add("archive/"+name, files[name])
+	}
+	if err := z.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func read(t testing.TB, b []byte) (*pytorch.File, error) {
+	return pytorch.Read(bytes.NewReader(b), int64(len(b)))
+}
+
+func Test_Read(t *testing.T) {
+	sd := "\x80\x02" + orderedDict() + "q\x01(" +
+		str("bf16") + tensor(storage("BFloat16Storage", "0", 4), 0, []int{2, 2}, []int{2, 1}) +
+		str("bf16.T") + tensor(storage("BFloat16Storage", "0", 4), 0, []int{2, 2}, []int{1, 2}) +
+		str("f16") + tensor(storage("HalfStorage", "1", 3), 1, []int{2}, []int{1}) +
+		str("f32") + global("torch._utils", "_rebuild_parameter") + "(" + tensor(storage("FloatStorage", "2", 2), 0, []int{2}, []int{1}) + "\x88" + orderedDict() + "tR" + "q\x02" +
+		str("f32.tied") + "h\x02" +
+		str("e4m3fn") + tensor(storage("Float8_e4m3fnStorage", "3", 2), 0, []int{2}, []int{1}) +
+		str("e5m2") + tensor(storage("Float8_e5m2Storage", "4", 2), 0, []int{2}, []int{1}) +
+		str("steps") + tensor(storage("LongStorage", "5", 1), 0, nil, nil) +
+		str("empty") + tensor(storage("HalfStorage", "6", 0), 0, []int{0, 3}, []int{3, 1}) +
+		"u}" + str("_metadata") + "}sb"
+	pkl := "}q\x00(" + str("model") + sd +
+		str("tied") + "h\x01" +
+		str("epoch") + "K\x03" +
+		str("lr") + "G" + string(binary.BigEndian.AppendUint64(nil, math.Float64bits(0.5))) +
+		str("hist") + "](K\x01M\x00\x01e" +
+		str("neg") + "\x8a\x01\xff" +
+		str("big") + "\x8a\x08\x00\x00\x00\x00\x00\x00\x00\x80" +
+		str("zero") + "\x8a\x00" +
+		str("none") + "N" +
+		str("bytes") + "C\x02hi" +
+		"u" + integer(7) + "\x88s" + str("epoch") + "K\x04s."
+	b := checkpoint(t, pkl, map[string][]byte{
+		"byteorder": []byte("little"),
+		"data/0":    {0x80, 0x3F, 0x00, 0x40, 0x40, 0x40, 0x80, 0x40},
+		"data/1":    {0xFF, 0xFF, 0x00, 0x3C, 0x00, 0xC0},
+		"data/2":    {0, 0, 0x80, 0x3F, 0, 0, 0, 0xC0},
+		"data/3":    {0x38, 0xC0},
+		"data/4":    {0x3C, 0xC0},
+		"data/5":    {7, 0, 0, 0, 0, 0, 0, 0},
+		"data/6":    {},
+		"version":   []byte("3\n"),
+	})
+	f, err := read(t, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, tt := range f.Tensors {
+		names = append(names, tt.Name)
+	}
+	// The dict referenced as "tied" is only visited once.
+	want := []string{"model.bf16", "model.bf16.T", "model.f16", "model.f32", "model.f32.tied", "model.e4m3fn", "model.e5m2", "model.steps", "model.empty"}
+	if !slices.Equal(names, want) {
+		t.Fatal(names)
+	}
+	floats := []struct {
+		name string
+		want []float32
+	}{
+		{"model.bf16", []float32{1, 2, 3, 4}},
+		{"model.bf16.T", []float32{1, 3, 2, 4}},
+		{"model.f16", []float32{1, -2}},
+		{"model.f32", []float32{1, -2}},
+		{"model.f32.tied", []float32{1, -2}},
+		{"model.e4m3fn", []float32{1, -2}},
+		{"model.e5m2", []float32{1, -2}},
+		{"model.empty", []float32{}},
+	}
+	for _, line := range floats {
+		v, err := f.Tensor(line.name).Float32s()
+		if err != nil || !slices.Equal(v, line.want) {
+			t.Fatalf("%s: %v %v", line.name, v, err)
+		}
+	}
+	if tt := f.Tensor("model.bf16"); !tt.IsContiguous() || tt.Len() != 4 || tt.DType != "bfloat16" {
+		t.Fatalf("%+v", tt)
+	}
+	if tt := f.Tensor("model.empty"); !tt.IsContiguous() || tt.Len() != 0 {
+		t.Fatalf("%+v", tt)
+	}
+	if tt := f.Tensor("model.bf16.T"); tt.IsContiguous() {
+		t.Fatalf("%+v", tt)
+	}
+	steps := f.Tensor("model.steps")
+	if d, err := steps.Data(); err != nil || !bytes.Equal(d, []byte{7, 0, 0, 0, 0, 0, 0, 0}) || steps.Len() != 1 {
+		t.Fatal(d, err)
+	}
+	if _, err = steps.Float32s(); err == nil || err.Error() != `pytorch: tensor "model.steps": can't decode int64 as float32` {
+		t.Fatal(err)
+	}
+	if f.Tensor("missing") != nil {
+		t.Fatal("unexpected tensor")
+	}
+	root := f.Object.(*pytorch.Dict)
+	get := func(key string) any {
+		v, ok := root.Get(key)
+		if !ok {
+			t.Fatalf("missing %q", key)
+		}
+		return v
+	}
+	if get("epoch") != int64(4) || get("lr") != 0.5 || get("neg") != int64(-1) || get("big") != int64(math.MinInt64) || get("zero") != int64(0) || get("none") != nil {
+		t.Fatalf("%+v", root)
+	}
+	if v := get("hist").([]any); !slices.Equal(v, []any{int64(1), int64(256)}) {
+		t.Fatal(v)
+	}
+	if v := get("bytes").([]byte); string(v) != "hi" {
+		t.Fatal(v)
+	}
+	if get("model") != get("tied") {
+		t.Fatal("expected the same dict")
+	}
+	if _, ok := root.Get("missing"); ok {
+		t.Fatal("unexpected key")
+	}
+	if kv := root.Items[len(root.Items)-1]; kv.Key != int64(7) || kv.Value != true {
+		t.Fatal(kv)
+	}
+}
+
+func Test_Read_ManyKeys(t *testing.T) {
+	// A dict with many keys loads in linear time.
+	const n = 200000
+	pkl := strings.Builder{}
+	pkl.WriteString("}(")
+	for i := range n {
+		pkl.WriteString(str(strconv.Itoa(i)) + "N")
+	}
+	pkl.WriteString("u" + str("0") + "K\x01s.")
+	f, err := read(t, checkpoint(t, pkl.String(), nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := f.Object.(*pytorch.Dict)
+	if len(root.Items) != n || root.Items[0].Value != int64(1) || root.Items[n-1].Key != strconv.Itoa(n-1) {
+		t.Fatal(len(root.Items), root.Items[0], root.Items[n-1])
+	}
+}
+
+func Test_Typed(t *testing.T) {
+	storage := []byte{0x00, 0x3C, 0x00, 0xC0, 0x00, 0x40, 0x00, 0x42, 0, 0, 0, 0, 0, 0, 0, 0}
+	data := []struct {
+		dtype string
+		fn    func(tt *pytorch.Tensor) (float32, error)
+	}{
+		{"bfloat16", func(tt *pytorch.Tensor) (float32, error) {
+			v, err := tt.BF16()
+			if err != nil {
+				return 0, err
+			}
+			return v[1].Float32(), nil
+		}},
+		{"float16", func(tt *pytorch.Tensor) (float32, error) {
+			v, err := tt.F16()
+			if err != nil {
+				return 0, err
+			}
+			return v[1].Float32(), nil
+		}},
+		{"float32", func(tt *pytorch.Tensor) (float32, error) {
+			v, err := tt.F32()
+			if err != nil {
+				return 0, err
+			}
+			return float32(v[1]), nil
+		}},
+		{"float8_e4m3fn", func(tt *pytorch.Tensor) (float32, error) {
+			v, err := tt.F8E4M3Fn()
+			if err != nil {
+				return 0, err
+			}
+			return v[1].Float32(), nil
+		}},
+		{"float8_e5m2", func(tt *pytorch.Tensor) (float32, error) {
+			v, err := tt.F8E5M2()
+			if err != nil {
+				return 0, err
+			}
+			return v[1].Float32(), nil
+		}},
+	}
+	for i, line := range data {
+		tt := &pytorch.Tensor{Name: "x", DType: line.dtype, Shape: []int{2}, Stride: []int{2}, Storage: storage}
+		got, err := line.fn(tt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, _ := tt.Float32s()
+		if got != want[1] {
+			t.Fatalf("%s: %v != %v", line.dtype, got, want)
+		}
+		if d, _ := tt.FloatxDType(); d == 0 {
+			t.Fatal(line.dtype)
+		}
+		// Use the accessor of the next type.
+		tt.DType = data[(i+1)%len(data)].dtype
+		if _, err = line.fn(tt); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+	if _, ok := (&pytorch.Tensor{DType: "int64"}).FloatxDType(); ok {
+		t.Fatal("unexpected")
+	}
+	// Contiguous tensors alias the storage.
+	tt := &pytorch.Tensor{Name: "x", DType: "float8_e4m3fn", Shape: []int{2}, Stride: []int{1}, Offset: 1, Storage: []byte{0, 0x38, 0x38}}
+	v, err := tt.F8E4M3Fn()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tt.Storage[2] = 0x40
+	if !slices.Equal(v, []floatx.F8E4M3Fn{0x38, 0x40}) {
+		t.Fatal(v)
+	}
+}
+
+func Test_Data_Errors(t *testing.T) {
+	// Sizes that overflow int when multiplied, whatever its size.
+	quarter := math.MaxInt/4 + 1
+	half := 1 << (bits.UintSize / 2)
+	data := []struct {
+		tt   pytorch.Tensor
+		want string
+	}{
+		{pytorch.Tensor{DType: "complex32"}, `pytorch: tensor "x": unsupported dtype "complex32"`},
+		{pytorch.Tensor{DType: "uint8", Shape: []int{1}}, `pytorch: tensor "x": invalid layout`},
+		{pytorch.Tensor{DType: "uint8", Offset: -1}, `pytorch: tensor "x": invalid layout`},
+		{pytorch.Tensor{DType: "uint8", Shape: []int{-1}, Stride: []int{1}}, `pytorch: tensor "x": invalid layout`},
+		{pytorch.Tensor{DType: "uint8", Shape: []int{1}, Stride: []int{-1}}, `pytorch: tensor "x": invalid layout`},
+		{pytorch.Tensor{DType: "uint8", Shape: []int{quarter, 4}, Stride: []int{0, 0}}, fmt.Sprintf(`pytorch: tensor "x": shape [%d 4] and stride [0 0] overflow`, quarter)},
+		{pytorch.Tensor{DType: "uint8", Shape: []int{half, half}, Stride: []int{half, half}}, fmt.Sprintf(`pytorch: tensor "x": shape [%d %d] and stride [%d %d] overflow`, half, half, half, half)},
+		{pytorch.Tensor{DType: "uint8", Shape: []int{2}, Stride: []int{1}, Offset: 1, Storage: []byte{0, 0}}, `pytorch: tensor "x": shape [2], stride [1] and offset 1 beyond the 2 values of the storage`},
+	}
+	for i, line := range data {
+		line.tt.Name = "x"
+		if _, err := line.tt.Data(); err == nil || err.Error() != line.want {
+			t.Errorf("#%d: want=%q got=%v", i, line.want, err)
+		}
+	}
+	tt := pytorch.Tensor{Name: "x", DType: "float16"}
+	if _, err := tt.Float32s(); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func Test_Protocol4(t *testing.T) {
+	// What pickle protocol 4 emits, with frames, memoize and stack globals.
+	pkl := "\x80\x04\x95\x00\x00\x00\x00\x00\x00\x00\x00" +
+		"\x8c\x0bcollections\x94\x8c\x0bOrderedDict\x94\x93\x94)R\x94" +
+		"\x8c\x01a\x94\x8d\x01\x00\x00\x00\x00\x00\x00\x00b\x94s" +
+		"\x8c\x01c\x94K\x01\x85\x94h\x00\x86\x94s" +
+		"\x8c\x01d\x94]\x94K\x01as" +
+		"\x8c\x01fh\x0as" +
+		"B\x01\x00\x00\x00eNNN\x87s" +
+		"(K\x011N020}b" +
+		"r\x10\x00\x00\x00j\x10\x00\x00\x000."
+	f, err := read(t, checkpoint(t, pkl, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := f.Object.(*pytorch.Dict)
+	want := []pytorch.KeyValue{
+		{"a", "b"},
+		{"c", []any{[]any{int64(1)}, "collections"}},
+		{"d", []any{int64(1)}},
+		{"f", []any{int64(1)}},
+		{[]byte("e"), []any{nil, nil, nil}},
+	}
+	if len(d.Items) != len(want) {
+		t.Fatal(d.Items)
+	}
+	for i := range want {
+		if fmt.Sprint(d.Items[i]) != fmt.Sprint(want[i]) {
+			t.Fatalf("%v != %v", d.Items[i], want[i])
+		}
+	}
+}
+
+func Test_Byteorder(t *testing.T) {
+	pkl := "\x80\x02" + tensor(storage("HalfStorage", "0", 2), 0, []int{2}, []int{1}) + "."
+	b := checkpoint(t, pkl, map[string][]byte{"byteorder": []byte("big"), "data/0": {0x3C, 0x00, 0xC0, 0x00}})
+	f, err := read(t, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, err := f.Tensors[0].Float32s(); err != nil || !slices.Equal(v, []float32{1, -2}) || f.Tensors[0].Name != "" {
+		t.Fatal(v, err)
+	}
+	b = checkpoint(t, pkl, map[string][]byte{"byteorder": []byte("middle"), "data/0": {0x3C, 0x00, 0xC0, 0x00}})
+	if _, err = read(t, b); err == nil || err.Error() != `pytorch: invalid byteorder "middle"` {
+		t.Fatal(err)
+	}
+	b = checkpoint(t, pkl, map[string][]byte{"byteorder": bytes.Repeat([]byte("x"), 17), "data/0": {0x3C, 0x00, 0xC0, 0x00}})
+	if _, err = read(t, b); err == nil || err.Error() != `pytorch: archive/byteorder is too large` {
+		t.Fatal(err)
+	}
+}
+
+func Test_ReadFile(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "a.pt")
+	if _, err := pytorch.ReadFile(p); err == nil {
+		t.Fatal("expected error")
+	}
+	b := checkpoint(t, "\x80\x02N.", nil)
+	if err := os.WriteFile(p, b, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	f, err := pytorch.ReadFile(p)
+	if err != nil || f.Object != nil || len(f.Tensors) != 0 {
+		t.Fatal(f, err)
+	}
+}
+
+func Test_Read_Errors(t *testing.T) {
+	h := storage("HalfStorage", "0", 2)
+	data := []struct {
+		pkl  string
+		want string
+	}{
+		{"", "pytorch: truncated pickle"},
+		{"N", "pytorch: truncated pickle"},
+		{"NN.", "pytorch: invalid pickle stack at offset 2"},
+		{"(N.", "pytorch: invalid pickle stack at offset 2"},
+		{"\x80\x06", "pytorch: unsupported pickle protocol 6 at offset 0"},
+		{"\x80", "pytorch: truncated pickle at offset 0"},
+		{"\x95\x00", "pytorch: truncated pickle at offset 0"},
+		{"0", "pytorch: stack underflow at offset 0"},
+		{"1", "pytorch: mark not found at offset 0"},
+		{"2", "pytorch: stack underflow at offset 0"},
+		{"J\x00", "pytorch: truncated pickle at offset 0"},
+		{"K", "pytorch: truncated pickle at offset 0"},
+		{"M\x00", "pytorch: truncated pickle at offset 0"},
+		{"\x8a", "pytorch: truncated pickle at offset 0"},
+		{"\x8a\x09", "pytorch: integer of 9 bytes is too large at offset 0"},
+		{"\x8a\x02\x00", "pytorch: truncated pickle at offset 0"},
+		{"G\x00", "pytorch: truncated pickle at offset 0"},
+		{"\x8c", "pytorch: truncated pickle at offset 0"},
+		{"\x8c\x01", "pytorch: truncated pickle at offset 0"},
+		{"X\x00", "pytorch: truncated pickle at offset 0"},
+		{"\x8d\x00", "pytorch: truncated pickle at offset 0"},
+		{"\x86", "pytorch: stack underflow at offset 0"},
+		{"t", "pytorch: mark not found at offset 0"},
+		{"a", "pytorch: stack underflow at offset 0"},
+		{"Na", "pytorch: stack underflow at offset 1"},
+		{"NNa", "pytorch: can't append to a <nil> at offset 2"},
+		{"e", "pytorch: mark not found at offset 0"},
+		{"s", "pytorch: stack underflow at offset 0"},
+		{"Ns", "pytorch: stack underflow at offset 1"},
+		{"NNs", "pytorch: stack underflow at offset 2"},
+		{"NNNs", "pytorch: can't set items of a <nil> at offset 3"},
+		{"u", "pytorch: mark not found at offset 0"},
+		{"}(Nu", "pytorch: odd number of items for SETITEMS at offset 3"},
+		{"q", "pytorch: truncated pickle at offset 0"},
+		{"q\x00", "pytorch: stack underflow at offset 0"},
+		{"r\x00", "pytorch: truncated pickle at offset 0"},
+		{"\x94", "pytorch: stack underflow at offset 0"},
+		{"h", "pytorch: truncated pickle at offset 0"},
+		{"h\x00", "pytorch: memo 0 not found at offset 0"},
+		{"ctorch", "pytorch: truncated pickle at offset 0"},
+		{"ctorch\n", "pytorch: truncated pickle at offset 0"},
+		{"cos\nsystem\n", "pytorch: global os.system is not allowed at offset 0"},
+		{"ctorch\nComplexFloatStorage\n", "pytorch: unsupported storage torch.ComplexFloatStorage at offset 0"},
+		{"\x93", "pytorch: stack underflow at offset 0"},
+		{"N\x93", "pytorch: stack underflow at offset 1"},
+		{"NN\x93", "pytorch: invalid STACK_GLOBAL arguments at offset 2"},
+		{"R", "pytorch: stack underflow at offset 0"},
+		{"NR", "pytorch: stack underflow at offset 1"},
+		{"NNR", "pytorch: REDUCE arguments are not a tuple at offset 2"},
+		{"N)R", "pytorch: can't call a <nil> at offset 2"},
+		{global("collections", "OrderedDict") + "(Nt" + "R", "pytorch: OrderedDict with arguments is not supported at offset 28"},
+		{global("torch._utils", "_rebuild_tensor_v2") + ")R", "pytorch: torch._utils._rebuild_tensor_v2: got 0 arguments at offset 34"},
+		{global("torch._utils", "_rebuild_parameter") + ")R", "pytorch: torch._utils._rebuild_parameter: got 0 arguments at offset 34"},
+		{global("torch._utils", "_rebuild_parameter_with_state") + "(NNNt" + "R", "pytorch: torch._utils._rebuild_parameter_with_state: got 3 arguments at offset 49"},
+		{global("torch._utils", "_rebuild_parameter") + "(NNNtR", "pytorch: torch._utils._rebuild_parameter: expected a tensor, got <nil> at offset 38"},
+		{global("torch._utils", "_rebuild_tensor_v2") + "(NNNNNNtR", "pytorch: expected a storage, got <nil> at offset 41"},
+		{global("torch._utils", "_rebuild_tensor_v2") + "(" + h + "N" + tuple(2) + tuple(1) + "NNtR", "pytorch: invalid tensor layout at offset 105"},
+		{global("torch._utils", "_rebuild_tensor_v2") + "(" + h + integer(0) + "N" + tuple(1) + "NNtR", "pytorch: invalid tensor layout at offset 103"},
+		{global("torch._utils", "_rebuild_tensor_v2") + "(" + h + integer(0) + tuple(-1) + tuple(1) + "NNtR", "pytorch: invalid tensor layout at offset 109"},
+		{global("torch._utils", "_rebuild_tensor_v2") + "(" + h + integer(0) + "(Nt" + tuple(1) + "NNtR", "pytorch: invalid tensor layout at offset 105"},
+		{global("torch._utils", "_rebuild_tensor_v2") + "(" + h + integer(1) + tuple(2) + tuple(1) + "NNtR", `pytorch: tensor "": shape [2], stride [1] and offset 1 beyond the 2 values of the storage at offset 109`},
+		{"b", "pytorch: stack underflow at offset 0"},
+		{"Nb", "pytorch: stack underflow at offset 1"},
+		{"NNb", "pytorch: can't BUILD a <nil> at offset 2"},
+		{"Q", "pytorch: stack underflow at offset 0"},
+		{"NQ", "pytorch: persistent ID is not a tuple at offset 1"},
+		{")Q", "pytorch: unsupported persistent ID at offset 1"},
+		{"(" + str("storage") + "NNNNtQ", "pytorch: invalid storage persistent ID at offset 18"},
+		{storage("HalfStorage", "1", 2), `pytorch: storage "1" not found at offset 52`},
+		{storage("HalfStorage", "0", 3), `pytorch: storage "0": 4 bytes doesn't match 3 float16 at offset 52`},
+		{"(" + str("storage") + global("torch", "HalfStorage") + str("0") + str("cpu") + "\x8a\x08\x00\x00\x00\x00\x00\x00\x00\x40tQ", `pytorch: storage "0": 4611686018427387904 float16 is too large at offset 57`},
+		{h + "0" + storage("BFloat16Storage", "0", 2), `pytorch: storage "0" loaded as float16 and bfloat16 at offset 110`},
+		{"\xff", "pytorch: unsupported pickle opcode 0xFF at offset 0"},
+	}
+	for i, line := range data {
+		b := checkpoint(t, line.pkl, map[string][]byte{"data/0": {0, 0, 0, 0}})
+		if _, err := read(t, b); err == nil || err.Error() != line.want {
+			t.Errorf("#%d: want=%q got=%v", i, line.want, err)
+		}
+	}
+}
+
+func Test_Read_Zip_Errors(t *testing.T) {
+	if _, err := read(t, []byte("PK")); err == nil {
+		t.Fatal("expected error")
+	}
+	buf := bytes.Buffer{}
+	z := zip.NewWriter(&buf)
+	if _, err := z.Create("archive/version"); err != nil {
+		t.Fatal(err)
+	}
+	if err := z.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := read(t, buf.Bytes()); err == nil || err.Error() != "pytorch: data.pkl not found" {
+		t.Fatal(err)
+	}
+	// An unsupported compression method and a corrupted file.
+	for i, method := range []uint16{99, zip.Store} {
+		buf.Reset()
+		z = zip.NewWriter(&buf)
+		f, err := z.CreateRaw(&zip.FileHeader{Name: "archive/data.pkl", Method: method, CRC32: 1, UncompressedSize64: 2, CompressedSize64: 2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = f.Write([]byte("N.")); err != nil {
+			t.Fatal(err)
+		}
+		if err = z.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if _, err = read(t, buf.Bytes()); err == nil {
+			t.Fatal(i)
+		}
+	}
+	// A corrupted storage.
+	buf.Reset()
+	z = zip.NewWriter(&buf)
+	f, err := z.Create("archive/data.pkl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = f.Write([]byte(storage("ByteStorage", "0", 1) + ".")); err != nil {
+		t.Fatal(err)
+	}
+	if f, err = z.CreateRaw(&zip.FileHeader{Name: "archive/data/0", CRC32: 1, UncompressedSize64: 1, CompressedSize64: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = f.Write([]byte{0}); err != nil {
+		t.Fatal(err)
+	}
+	if err = z.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = read(t, buf.Bytes()); err == nil || err.Error() != "pytorch: archive/data/0: zip: checksum error at offset 52" {
+		t.Fatal(err)
+	}
+	// A storage whose header lies about its size must not allocate it.
+	buf.Reset()
+	z = zip.NewWriter(&buf)
+	if f, err = z.Create("archive/data.pkl"); err != nil {
+		t.Fatal(err)
+	}
+	numel := "\x8a\x08" + string(binary.LittleEndian.AppendUint64(nil, 1<<30))
+	if _, err = f.Write([]byte("(" + str("storage") + global("torch", "ByteStorage") + str("0") + str("cpu") + numel + "tQ.")); err != nil {
+		t.Fatal(err)
+	}
+	if f, err = z.CreateRaw(&zip.FileHeader{Name: "archive/data/0", CRC32: crc32.ChecksumIEEE([]byte{0}), UncompressedSize64: 1 << 30, CompressedSize64: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = f.Write([]byte{0}); err != nil {
+		t.Fatal(err)
+	}
+	if err = z.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = read(t, buf.Bytes()); err == nil || err.Error() != "pytorch: archive/data/0: unexpected EOF at offset 57" {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,126 @@
+// Copyright 2024 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package floatx
+
+import (
+	"fmt"
+	"io"
+)
+
+// ShortBufferError is returned when a buffer ends in the middle of a value.
+//
+// It wraps io.ErrUnexpectedEOF.
+type ShortBufferError struct {
+	// Type is the name of the type being decoded, e.g. "BF16".
+	Type string
+	// Offset is the offset in bytes of the value that could not be decoded.
+	Offset int
+	// Size is the size in bytes of one value.
+	Size int
+	// Len is the number of bytes available at Offset.
+	Len int
+}
+
+func (e *ShortBufferError) Error() string {
+	return fmt.Sprintf("floatx: %s at offset %d needs %d bytes, got %d", e.Type, e.Offset, e.Size, e.Len)
+}
+
+func (e *ShortBufferError) Unwrap() error {
+	return io.ErrUnexpectedEOF
+}
+
+// BF16
+
+// ReadBF16 decodes a little endian value at the start of b and returns the
+// remaining bytes.
+//
+// Unlike DecodeBF16, it returns a *ShortBufferError instead of panicking when
+// b is too short.
+func ReadBF16(b []byte) (BF16, []byte, error) {
+	if len(b) < 2 {
+		return 0, b, &ShortBufferError{Type: "BF16", Size: 2, Len: len(b)}
+	}
+	return DecodeBF16(b), b[2:], nil
+}
+
+// DecodeBF16Slice decodes all the little endian values in b.
+//
+// If len(b) is odd, it returns the values decoded so far and a
+// *ShortBufferError with the offset of the truncated value.
+func DecodeBF16Slice(b []byte) ([]BF16, error) {
+	out := make([]BF16, len(b)/2)
+	for i := range out {
+		out[i] = DecodeBF16(b[2*i:])
+	}
+	if len(b)%2 != 0 {
+		return out, &ShortBufferError{Type: "BF16", Offset: len(b) - 1, Size: 2, Len: 1}
+	}
+	return out, nil
+}
+
+// ReadBF16From reads one little endian value from r.
+//
+// It returns io.EOF if r has no more data and a *ShortBufferError if r ends in
+// the middle of the value. Its Offset is relative to the current position of r.
+func ReadBF16From(r io.Reader) (BF16, error) {
+	var b [2]byte
+	if err := readFull(r, b[:], "BF16"); err != nil {
+		return 0, err
+	}
+	return DecodeBF16(b[:]), nil
+}
+
+// F16
+
+// ReadF16 decodes a little endian value at the start of b and returns the
+// remaining bytes.
+//
+// Unlike DecodeF16, it returns a *ShortBufferError instead of panicking when
+// b is too short.
+func ReadF16(b []byte) (F16, []byte, error) {
+	if len(b) < 2 {
+		return 0, b, &ShortBufferError{Type: "F16", Size: 2, Len: len(b)}
+	}
+	return DecodeF16(b), b[2:], nil
+}
+
+// DecodeF16Slice decodes all the little endian values in b.
+//
+// If len(b) is odd, it returns the values decoded so far and a
+// *ShortBufferError with the offset of the truncated value.
+func DecodeF16Slice(b []byte) ([]F16, error) {
+	out := make([]F16, len(b)/2)
+	for i := range out {
+		out[i] = DecodeF16(b[2*i:])
+	}
+	if len(b)%2 != 0 {
+		return out, &ShortBufferError{Type: "F16", Offset: len(b) - 1, Size: 2, Len: 1}
+	}
+	return out, nil
+}
+
+// ReadF16From reads one little endian value from r.
+//
+// It returns io.EOF if r has no more data and a *ShortBufferError if r ends in
+// the middle of the value. Its Offset is relative to the current position of r.
+func ReadF16From(r io.Reader) (F16, error) {
+	var b [2]byte
+	if err := readFull(r, b[:], "F16"); err != nil {
+		return 0, err
+	}
+	return DecodeF16(b[:]), nil
+}
+
+// Internal
+
+// readFull is like io.ReadFull but returns a *ShortBufferError on a partial
+// read.
+func readFull(r io.Reader, b []byte, name string) error {
+	n, err := io.ReadFull(r, b)
+	if err == io.ErrUnexpectedEOF {
+		return &ShortBufferError{Type: name, Size: len(b), Len: n}
+	}
+	return err
+}
@@ -0,0 +1,97 @@
+// Copyright 2024 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package floatx_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/maruel/floatx"
+)
+
+func Test_ReadBF16(t *testing.T) {
+	v, rest, err := floatx.ReadBF16([]byte{0x80, 0x3F, 0x01})
+	if err != nil || v != 0x3F80 || !bytes.Equal(rest, []byte{0x01}) {
+		t.Fatalf("%v %x %v", v, rest, err)
+	}
+	_, rest, err = floatx.ReadBF16(rest)
+	var e *floatx.ShortBufferError
+	if !errors.As(err, &e) || e.Size != 2 || e.Len != 1 || len(rest) != 1 {
+		t.Fatalf("%#v %x", err, rest)
+	}
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatal(err)
+	}
+	if want := "floatx: BF16 at offset 0 needs 2 bytes, got 1"; err.Error() != want {
+		t.Fatalf("want=%q got=%q", want, err)
+	}
+}
+
+func Test_ReadF16(t *testing.T) {
+	v, rest, err := floatx.ReadF16([]byte{0x00, 0x3C})
+	if err != nil || v != 0x3C00 || len(rest) != 0 {
+		t.Fatalf("%v %x %v", v, rest, err)
+	}
+	if _, _, err = floatx.ReadF16(rest); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatal(err)
+	}
+}
+
+func Test_DecodeBF16Slice(t *testing.T) {
+	got, err := floatx.DecodeBF16Slice([]byte{0x80, 0x3F, 0x00, 0xC0, 0x01})
+	var e *floatx.ShortBufferError
+	if !errors.As(err, &e) || e.Offset != 4 {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0] != 0x3F80 || got[1] != 0xC000 {
+		t.Fatal(got)
+	}
+	if got, err = floatx.DecodeBF16Slice([]byte{0x80, 0x3F}); err != nil || len(got) != 1 {
+		t.Fatal(got, err)
+	}
+}
+
+func Test_DecodeF16Slice(t *testing.T) {
+	got, err := floatx.DecodeF16Slice([]byte{0x00, 0x3C, 0x00})
+	var e *floatx.ShortBufferError
+	if !errors.As(err, &e) || e.Offset != 2 || e.Type != "F16" {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0] != 0x3C00 {
+		t.Fatal(got)
+	}
+	if got, err = floatx.DecodeF16Slice(nil); err != nil || len(got) != 0 {
+		t.Fatal(got, err)
+	}
+}
+
+func Test_ReadBF16From(t *testing.T) {
+	r := bytes.NewReader([]byte{0x80, 0x3F, 0x01})
+	if v, err := floatx.ReadBF16From(r); err != nil || v != 0x3F80 {
+		t.Fatal(v, err)
+	}
+	if _, err := floatx.ReadBF16From(r); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatal(err)
+	}
+	if _, err := floatx.ReadBF16From(r); err != io.EOF {
+		t.Fatal(err)
+	}
+}
+
+func Test_ReadF16From(t *testing.T) {
+	r := bytes.NewReader([]byte{0x00, 0x3C, 0x01})
+	if v, err := floatx.ReadF16From(r); err != nil || v != 0x3C00 {
+		t.Fatal(v, err)
+	}
+	var e *floatx.ShortBufferError
+	if _, err := floatx.ReadF16From(r); !errors.As(err, &e) || e.Len != 1 {
+		t.Fatal(err)
+	}
+	if _, err := floatx.ReadF16From(r); err != io.EOF {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,349 @@
+// Copyright 2024 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+// Package safetensors reads and writes .safetensors files.
+//
+// Tensors are exposed as typed views over the floatx types without copying
+// when possible.
+//
+// See https://github.com/huggingface/safetensors#format
+package safetensors
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/maruel/floatx"
+)
+
+// maxHeaderSize is the same limit as the reference implementation.
+const maxHeaderSize = 100 * 1024 * 1024
+
+// metadataKey is the special key in the header holding the free form
+// metadata.
+const metadataKey = "__metadata__"
+
+// dtypeSizes is the size in bytes of each dtype supported by the format.
+var dtypeSizes = map[string]int{
+	"BOOL":    1,
+	"U8":      1,
+	"I8":      1,
+	"F8_E5M2": 1,
+	"F8_E4M3": 1,
+	"I16":     2,
+	"U16":     2,
+	"F16":     2,
+	"BF16":    2,
+	"I32":     4,
+	"U32":     4,
+	"F32":     4,
+	"F64":     8,
+	"I64":     8,
+	"U64":     8,
+}
+
+// File is the content of a .safetensors file.
+type File struct {
+	// Metadata is the free form string to string map stored in the header.
+	Metadata map[string]string
+	// Tensors are sorted by their offset in the file.
+	Tensors []Tensor
+}
+
+// Tensor is one tensor in a File.
+type Tensor struct {
+	Name string
+	// DType is the safetensors name of the data type, e.g. "BF16" or
+	// "F8_E4M3".
+	DType string
+	Shape []int
+	// Data is the raw little endian data.
+	Data []byte
+}
+
+// Parse parses a .safetensors file loaded in memory.
+//
+// The tensors' Data alias b, so b can be a memory mapped file.
+func Parse(b []byte) (*File, error) {
+	if len(b) < 8 {
+		return nil, errors.New("safetensors: file too short")
+	}
+	n := binary.LittleEndian.Uint64(b)
+	if n > maxHeaderSize {
+		return nil, fmt.Errorf("safetensors: header too large: %d bytes", n)
+	}
+	if n > uint64(len(b)-8) {
+		return nil, fmt.Errorf("safetensors: header of %d bytes is larger than the file", n)
+	}
+	header := b[8 : 8+n]
+	data := b[8+n:]
+	if len(header) == 0 || header[0] != '{' {
+		return nil, errors.New("safetensors: invalid header")
+	}
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(header, &raw); err != nil {
+		return nil, fmt.Errorf("safetensors: invalid header: %w", err)
+	}
+	f := &File{}
+	if m, ok := raw[metadataKey]; ok {
+		if err := json.Unmarshal(m, &f.Metadata); err != nil {
+			return nil, fmt.Errorf("safetensors: invalid metadata: %w", err)
+		}
+		delete(raw, metadataKey)
+	}
+	type info struct {
+		DType       string   `json:"dtype"`
+		Shape       []int    `json:"shape"`
+		DataOffsets []uint64 `json:"data_offsets"`
+	}
+	type entry struct {
+		info
+		name string
+	}
+	entries := make([]entry, 0, len(raw))
+	for name, m := range raw {
+		e := entry{name: name}
+		d := json.NewDecoder(bytes.NewReader(m))
+		d.DisallowUnknownFields()
+		if err := d.Decode(&e.info); err != nil {
+			return nil, fmt.Errorf("safetensors: tensor %q: %w", name, err)
+		}
+		if len(e.DataOffsets) != 2 || e.DataOffsets[0] > e.DataOffsets[1] {
+			return nil, fmt.Errorf("safetensors: tensor %q: invalid data_offsets %v", name, e.DataOffsets)
+		}
+		entries = append(entries, e)
+	}
+	slices.SortFunc(entries, func(a, b entry) int {
+		// Empty tensors can share their offset with another tensor.
+		if c := cmp.Compare(a.DataOffsets[0], b.DataOffsets[0]); c != 0 {
+			return c
+		}
+		if c := cmp.Compare(a.DataOffsets[1], b.DataOffsets[1]); c != 0 {
+			return c
+		}
+		return strings.Compare(a.name, b.name)
+	})
+	f.Tensors = make([]Tensor, len(entries))
+	var offset uint64
+	for i, e := range entries {
+		if e.DataOffsets[0] != offset {
+			return nil, fmt.Errorf("safetensors: tensor %q: data_offsets %v leave a hole or overlap at %d", e.name, e.DataOffsets, offset)
+		}
+		if e.DataOffsets[1] > uint64(len(data)) {
+			return nil, fmt.Errorf("safetensors: tensor %q: data_offsets %v beyond the %d bytes of data", e.name, e.DataOffsets, len(data))
+		}
+		size, ok := dtypeSizes[e.DType]
+		if !ok {
+			return nil, fmt.Errorf("safetensors: tensor %q: unsupported dtype %q", e.name, e.DType)
+		}
+		l, err := numElements(e.Shape)
+		if err != nil {
+			return nil, fmt.Errorf("safetensors: tensor %q: %w", e.name, err)
+		}
+		if want := e.DataOffsets[1] - e.DataOffsets[0]; l > math.MaxUint64/uint64(size) || l*uint64(size) != want {
+			return nil, fmt.Errorf("safetensors: tensor %q: shape %v of %s doesn't match %d bytes", e.name, e.Shape, e.DType, want)
+		}
+		f.Tensors[i] = Tensor{Name: e.name, DType: e.DType, Shape: e.Shape, Data: data[e.DataOffsets[0]:e.DataOffsets[1]:e.DataOffsets[1]]}
+		offset = e.DataOffsets[1]
+	}
+	if offset != uint64(len(data)) {
+		return nil, fmt.Errorf("safetensors: %d trailing bytes after the tensors", uint64(len(data))-offset)
+	}
+	return f, nil
+}
+
+// ReadFile reads and parses a .safetensors file.
+func ReadFile(name string) (*File, error) {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(b)
+}
+
+// Tensor returns the tensor with the specified name, or nil.
+func (f *File) Tensor(name string) *Tensor {
+	for i := range f.Tensors {
+		if f.Tensors[i].Name == name {
+			return &f.Tensors[i]
+		}
+	}
+	return nil
+}
+
+// Write writes a .safetensors file containing the tensors in order.
+//
+// The tensors' shape, dtype and data length must be consistent.
+func Write(w io.Writer, metadata map[string]string, tensors []Tensor) error {
+	header := make(map[string]any, len(tensors)+1)
+	if len(metadata) != 0 {
+		header[metadataKey] = metadata
+	}
+	var offset uint64
+	for i := range tensors {
+		t := &tensors[i]
+		if t.Name == metadataKey {
+			return fmt.Errorf("safetensors: invalid tensor name %q", t.Name)
+		}
+		if _, ok := header[t.Name]; ok {
+			return fmt.Errorf("safetensors: duplicate tensor %q", t.Name)
+		}
+		size, ok := dtypeSizes[t.DType]
+		if !ok {
+			return fmt.Errorf("safetensors: tensor %q: unsupported dtype %q", t.Name, t.DType)
+		}
+		l, err := numElements(t.Shape)
+		if err != nil {
+			return fmt.Errorf("safetensors: tensor %q: %w", t.Name, err)
+		}
+		if l > math.MaxUint64/uint64(size) || l*uint64(size) != uint64(len(t.Data)) {
+			return fmt.Errorf("safetensors: tensor %q: shape %v of %s doesn't match %d bytes", t.Name, t.Shape, t.DType, len(t.Data))
+		}
+		shape := t.Shape
+		if shape == nil {
+			// Scalars have an empty shape, not null.
+			shape = []int{}
+		}
+		header[t.Name] = map[string]any{
+			"dtype":        t.DType,
+			"shape":        shape,
+			"data_offsets": [2]uint64{offset, offset + uint64(len(t.Data))},
+		}
+		offset += uint64(len(t.Data))
+	}
+	// It can't fail since it only contains strings and numbers.
+	h, _ := json.Marshal(header)
+	// Pad with spaces so the data is aligned on 8 bytes, like the reference
+	// implementation does.
+	if pad := len(h) % 8; pad != 0 {
+		h = append(h, bytes.Repeat([]byte{' '}, 8-pad)...)
+	}
+	b := binary.LittleEndian.AppendUint64(make([]byte, 0, 8+len(h)), uint64(len(h)))
+	if _, err := w.Write(append(b, h...)); err != nil {
+		return err
+	}
+	for i := range tensors {
+		if _, err := w.Write(tensors[i].Data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Len returns the number of elements in the tensor, or 0 if the dtype is
+// unknown.
+func (t *Tensor) Len() int {
+	size := dtypeSizes[t.DType]
+	if size == 0 {
+		return 0
+	}
+	return len(t.Data) / size
+}
+
+// FloatxDType returns the floatx type of the tensor, if supported.
+//
+// Note that F8_E4M3 is torch.float8_e4m3fn, which has no infinity.
+func (t *Tensor) FloatxDType() (floatx.DType, bool) {
+	if _, ok := dtypeSizes[t.DType]; !ok {
+		return 0, false
+	}
+	return floatx.LookupDType(t.DType)
+}
+
+// BF16 returns the data of a BF16 tensor.
+//
+// The slice aliases Data when possible. See floatx.ViewBF16.
+func (t *Tensor) BF16() ([]floatx.BF16, error) {
+	if err := t.check("BF16"); err != nil {
+		return nil, err
+	}
+	v, _, err := floatx.ViewBF16(t.Data)
+	return v, err
+}
+
+// F16 returns the data of a F16 tensor.
+//
+// The slice aliases Data when possible. See floatx.ViewF16.
+func (t *Tensor) F16() ([]floatx.F16, error) {
+	if err := t.check("F16"); err != nil {
+		return nil, err
+	}
+	v, _, err := floatx.ViewF16(t.Data)
+	return v, err
+}
+
+// F32 returns the data of a F32 tensor.
+//
+// The slice aliases Data when possible. See floatx.ViewF32.
+func (t *Tensor) F32() ([]floatx.F32, error) {
+	if err := t.check("F32"); err != nil {
+		return nil, err
+	}
+	v, _, err := floatx.ViewF32(t.Data)
+	return v, err
+}
+
+// F8E4M3Fn returns the data of a F8_E4M3 tensor. The slice aliases Data.
+func (t *Tensor) F8E4M3Fn() ([]floatx.F8E4M3Fn, error) {
+	if err := t.check("F8_E4M3"); err != nil {
+		return nil, err
+	}
+	return floatx.ViewF8E4M3Fn(t.Data), nil
+}
+
+// F8E5M2 returns the data of a F8_E5M2 tensor. The slice aliases Data.
+func (t *Tensor) F8E5M2() ([]floatx.F8E5M2, error) {
+	if err := t.check("F8_E5M2"); err != nil {
+		return nil, err
+	}
+	return floatx.ViewF8E5M2(t.Data), nil
+}
+
+// Float32s decodes the data of a floating point tensor into a new slice.
+func (t *Tensor) Float32s() ([]float32, error) {
+	d, ok := t.FloatxDType()
+	if !ok {
+		return nil, fmt.Errorf("safetensors: tensor %q: can't decode %s as float32", t.Name, t.DType)
+	}
+	if len(t.Data)%d.Size() != 0 {
+		return nil, fmt.Errorf("safetensors: tensor %q: %d bytes is not a multiple of %d", t.Name, len(t.Data), d.Size())
+	}
+	decode, n := d.Info().Decode, d.Size()
+	out := make([]float32, len(t.Data)/n)
+	for i := range out {
+		out[i] = decode(t.Data[i*n:])
+	}
+	return out, nil
+}
+
+func (t *Tensor) check(dtype string) error {
+	if t.DType != dtype {
+		return fmt.Errorf("safetensors: tensor %q is %s, not %s", t.Name, t.DType, dtype)
+	}
+	return nil
+}
+
+// numElements returns the number of elements for a shape.
+func numElements(shape []int) (uint64, error) {
+	l := uint64(1)
+	for _, d := range shape {
+		if d < 0 {
+			return 0, fmt.Errorf("invalid shape %v", shape)
+		}
+		if d != 0 && l > math.MaxUint64/uint64(d) {
+			return 0, fmt.Errorf("shape %v overflows", shape)
+		}
+		l *= uint64(d)
+	}
+	return l, nil
+}
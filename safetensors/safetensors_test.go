@@ -0,0 +1,232 @@
+// Copyright 2024 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package safetensors_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/maruel/floatx"
+	"github.com/maruel/floatx/safetensors"
+)
+
+func Test_RoundTrip(t *testing.T) {
+	tensors := []safetensors.Tensor{
+		{Name: "b", DType: "BF16", Shape: []int{2}, Data: []byte{0x80, 0x3F, 0x00, 0xC0}},
+		{Name: "a", DType: "F16", Shape: []int{1, 2}, Data: []byte{0x00, 0x3C, 0x00, 0xC0}},
+		{Name: "scalar", DType: "F32", Data: []byte{0x00, 0x00, 0x80, 0x3F}},
+		{Name: "e4m3", DType: "F8_E4M3", Shape: []int{2}, Data: []byte{0x38, 0x7E}},
+		{Name: "e5m2", DType: "F8_E5M2", Shape: []int{2}, Data: []byte{0x3C, 0x7C}},
+		{Name: "empty", DType: "F32", Shape: []int{0, 3}, Data: []byte{}},
+		{Name: "empty2", DType: "F16", Shape: []int{0}},
+		{Name: "ids", DType: "I64", Shape: []int{1}, Data: []byte{1, 0, 0, 0, 0, 0, 0, 0}},
+	}
+	buf := bytes.Buffer{}
+	if err := safetensors.Write(&buf, map[string]string{"format": "pt"}, tensors); err != nil {
+		t.Fatal(err)
+	}
+	n := binary.LittleEndian.Uint64(buf.Bytes())
+	if n%8 != 0 {
+		t.Fatalf("header not aligned: %d", n)
+	}
+	f, err := safetensors.Parse(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Metadata["format"] != "pt" || len(f.Metadata) != 1 {
+		t.Fatal(f.Metadata)
+	}
+	if len(f.Tensors) != len(tensors) {
+		t.Fatal(f.Tensors)
+	}
+	for i := range tensors {
+		got := f.Tensor(tensors[i].Name)
+		if got == nil {
+			t.Fatal(tensors[i].Name)
+		}
+		if got.DType != tensors[i].DType || !bytes.Equal(got.Data, tensors[i].Data) || len(got.Shape) != len(tensors[i].Shape) {
+			t.Fatalf("%#v != %#v", got, tensors[i])
+		}
+	}
+	if f.Tensor("missing") != nil {
+		t.Fatal("unexpected")
+	}
+	// Sorted by offsets.
+	var names []string
+	for _, t := range f.Tensors {
+		names = append(names, t.Name)
+	}
+	if want := []string{"b", "a", "scalar", "e4m3", "e5m2", "empty", "empty2", "ids"}; !slices.Equal(names, want) {
+		t.Fatal(names)
+	}
+
+	b := f.Tensor("b")
+	if v, err := b.BF16(); err != nil || len(v) != 2 || v[0].Float32() != 1 || v[1].Float32() != -2 {
+		t.Fatal(v, err)
+	}
+	if _, err := b.F16(); err == nil {
+		t.Fatal("expected error")
+	}
+	if v, err := b.Float32s(); err != nil || !slices.Equal(v, []float32{1, -2}) {
+		t.Fatal(v, err)
+	}
+	if d, ok := b.FloatxDType(); !ok || d != floatx.DTypeBF16 {
+		t.Fatal(d)
+	}
+	a := f.Tensor("a")
+	if v, err := a.F16(); err != nil || len(v) != 2 || v[0].Float32() != 1 {
+		t.Fatal(v, err)
+	}
+	if a.Len() != 2 {
+		t.Fatal(a.Len())
+	}
+	scalar := f.Tensor("scalar")
+	if v, err := scalar.F32(); err != nil || len(v) != 1 || v[0] != 1 {
+		t.Fatal(v, err)
+	}
+	if _, err := scalar.BF16(); err == nil {
+		t.Fatal("expected error")
+	}
+	e4m3 := f.Tensor("e4m3")
+	if v, err := e4m3.F8E4M3Fn(); err != nil || v[1].Float32() != 448 {
+		t.Fatal(v, err)
+	}
+	if _, err := e4m3.F8E5M2(); err == nil {
+		t.Fatal("expected error")
+	}
+	e5m2 := f.Tensor("e5m2")
+	if v, err := e5m2.F8E5M2(); err != nil || v[0].Float32() != 1 {
+		t.Fatal(v, err)
+	}
+	if _, err := e5m2.F8E4M3Fn(); err == nil {
+		t.Fatal("expected error")
+	}
+	if _, err := e5m2.F32(); err == nil {
+		t.Fatal("expected error")
+	}
+	ids := f.Tensor("ids")
+	if _, err := ids.Float32s(); err == nil {
+		t.Fatal("expected error")
+	}
+	if _, ok := ids.FloatxDType(); ok {
+		t.Fatal("unexpected")
+	}
+	if ids.Len() != 1 {
+		t.Fatal(ids.Len())
+	}
+	if (&safetensors.Tensor{DType: "foo", Data: []byte{1}}).Len() != 0 {
+		t.Fatal("expected 0")
+	}
+	if _, err := (&safetensors.Tensor{DType: "BF16", Data: []byte{1}}).Float32s(); err == nil {
+		t.Fatal("expected error")
+	}
+
+	p := filepath.Join(t.TempDir(), "a.safetensors")
+	if err = os.WriteFile(p, buf.Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if f, err = safetensors.ReadFile(p); err != nil || len(f.Tensors) != len(tensors) {
+		t.Fatal(f, err)
+	}
+	if _, err = safetensors.ReadFile(p + "x"); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func Test_Parse_Errors(t *testing.T) {
+	data := []struct {
+		header string
+		data   int
+		want   string
+	}{
+		{``, 0, "safetensors: invalid header"},
+		{`[]`, 0, "safetensors: invalid header"},
+		{`{`, 0, "safetensors: invalid header: unexpected end of JSON input"},
+		{`{"__metadata__":1}`, 0, "safetensors: invalid metadata: json: cannot unmarshal number into Go value of type map[string]string"},
+		{`{"a":{"dtype":"F16","shape":[1],"data_offsets":[0,2],"x":1}}`, 2, `safetensors: tensor "a": json: unknown field "x"`},
+		{`{"a":{"dtype":"F16","shape":[1],"data_offsets":[2,0]}}`, 2, `safetensors: tensor "a": invalid data_offsets [2 0]`},
+		{`{"a":{"dtype":"F16","shape":[1],"data_offsets":[2,4]}}`, 4, `safetensors: tensor "a": data_offsets [2 4] leave a hole or overlap at 0`},
+		{`{"a":{"dtype":"F16","shape":[1],"data_offsets":[0,2]},"b":{"dtype":"F16","shape":[1],"data_offsets":[1,3]}}`, 3, `safetensors: tensor "b": data_offsets [1 3] leave a hole or overlap at 2`},
+		{`{"a":{"dtype":"F16","shape":[1],"data_offsets":[0,2]}}`, 1, `safetensors: tensor "a": data_offsets [0 2] beyond the 1 bytes of data`},
+		{`{"a":{"dtype":"X","shape":[1],"data_offsets":[0,2]}}`, 2, `safetensors: tensor "a": unsupported dtype "X"`},
+		{`{"a":{"dtype":"F16","shape":[-1],"data_offsets":[0,2]}}`, 2, `safetensors: tensor "a": invalid shape [-1]`},
+		{`{"a":{"dtype":"F16","shape":[2147483647,2147483647,2147483647],"data_offsets":[0,2]}}`, 2, `safetensors: tensor "a": shape [2147483647 2147483647 2147483647] overflows`},
+		{`{"a":{"dtype":"F16","shape":[2147483647,2147483647,3],"data_offsets":[0,2]}}`, 2, `safetensors: tensor "a": shape [2147483647 2147483647 3] of F16 doesn't match 2 bytes`},
+		{`{"a":{"dtype":"F16","shape":[2],"data_offsets":[0,2]}}`, 2, `safetensors: tensor "a": shape [2] of F16 doesn't match 2 bytes`},
+		{`{"a":{"dtype":"F16","shape":[1],"data_offsets":[0,2]}}`, 3, `safetensors: 1 trailing bytes after the tensors`},
+	}
+	for i, line := range data {
+		b := binary.LittleEndian.AppendUint64(nil, uint64(len(line.header)))
+		b = append(b, line.header...)
+		b = append(b, make([]byte, line.data)...)
+		if _, err := safetensors.Parse(b); err == nil || err.Error() != line.want {
+			t.Errorf("#%d: want=%q got=%v", i, line.want, err)
+		}
+	}
+	if _, err := safetensors.Parse([]byte{1}); err == nil || err.Error() != "safetensors: file too short" {
+		t.Fatal(err)
+	}
+	if _, err := safetensors.Parse(binary.LittleEndian.AppendUint64(nil, 1<<40)); err == nil || err.Error() != "safetensors: header too large: 1099511627776 bytes" {
+		t.Fatal(err)
+	}
+	if _, err := safetensors.Parse(binary.LittleEndian.AppendUint64(nil, 10)); err == nil || err.Error() != "safetensors: header of 10 bytes is larger than the file" {
+		t.Fatal(err)
+	}
+}
+
+type failWriter struct {
+	n int
+}
+
+func (f *failWriter) Write(p []byte) (int, error) {
+	if f.n == 0 {
+		return 0, errors.New("oh")
+	}
+	f.n--
+	return len(p), nil
+}
+
+func Test_Write_Errors(t *testing.T) {
+	// On 64-bit, 3*huge*2 wraps to 2.
+	huge := math.MaxInt/3 + 1
+	data := []struct {
+		tensors []safetensors.Tensor
+		want    string
+	}{
+		{[]safetensors.Tensor{{Name: "__metadata__", DType: "F16"}}, `safetensors: invalid tensor name "__metadata__"`},
+		{[]safetensors.Tensor{{Name: "a", DType: "U8", Data: []byte{1}}, {Name: "a", DType: "U8", Data: []byte{1}}}, `safetensors: duplicate tensor "a"`},
+		{[]safetensors.Tensor{{Name: "a", DType: "X"}}, `safetensors: tensor "a": unsupported dtype "X"`},
+		{[]safetensors.Tensor{{Name: "a", DType: "F16", Shape: []int{-1}}}, `safetensors: tensor "a": invalid shape [-1]`},
+		{[]safetensors.Tensor{{Name: "a", DType: "F16", Shape: []int{2}, Data: []byte{1, 2}}}, `safetensors: tensor "a": shape [2] of F16 doesn't match 2 bytes`},
+		{[]safetensors.Tensor{{Name: "a", DType: "F16", Shape: []int{3, huge}, Data: []byte{1, 2}}}, fmt.Sprintf(`safetensors: tensor "a": shape [3 %d] of F16 doesn't match 2 bytes`, huge)},
+	}
+	for i, line := range data {
+		if err := safetensors.Write(&bytes.Buffer{}, nil, line.tensors); err == nil || err.Error() != line.want {
+			t.Errorf("#%d: want=%q got=%v", i, line.want, err)
+		}
+	}
+	tensors := []safetensors.Tensor{{Name: "a", DType: "U8", Data: []byte{1}}}
+	if err := safetensors.Write(&failWriter{}, nil, tensors); err == nil {
+		t.Fatal("expected error")
+	}
+	if err := safetensors.Write(&failWriter{n: 1}, nil, tensors); err == nil {
+		t.Fatal("expected error")
+	}
+	buf := bytes.Buffer{}
+	if err := safetensors.Write(&buf, nil, tensors); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), "__metadata__") {
+		t.Fatal(buf.String())
+	}
+}
@@ -0,0 +1,367 @@
+// Copyright 2024 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package floatx
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// streamBufferSize is the size of the internal buffer used by Decoder and
+// Encoder.
+const streamBufferSize = 64 * 1024
+
+// Decoder decodes a stream of little endian values of a single DType.
+//
+// It is meant to process large tensors in chunks without loading them in
+// memory.
+type Decoder struct {
+	r      *bufio.Reader
+	dtype  DType
+	offset int64
+}
+
+// NewDecoder returns a Decoder reading values of type dtype from r.
+//
+// The Decoder buffers data, so it may read more bytes from r than the values
+// decoded.
+func NewDecoder(r io.Reader, dtype DType) *Decoder {
+	if dtype.Size() == 0 {
+		panic("floatx: invalid " + dtype.String())
+	}
+	return &Decoder{r: bufio.NewReaderSize(r, streamBufferSize), dtype: dtype}
+}
+
+// DType returns the type of the values decoded.
+func (d *Decoder) DType() DType {
+	return d.dtype
+}
+
+// InputOffset returns the number of bytes consumed so far, including the bytes
+// of a value truncated by the end of the stream.
+func (d *Decoder) InputOffset() int64 {
+	return d.offset
+}
+
+// Float32s decodes values into dst, converting them to float32.
+//
+// It returns the number of values decoded. If fewer than len(dst) values were
+// decoded, the error is io.EOF if the stream ended on a value boundary, a
+// *ShortBufferError with the absolute offset of the truncated value if it ended
+// in the middle of a value, or the error returned by the underlying reader. In
+// the latter case the bytes of a partial value are kept so the call can be
+// retried.
+func (d *Decoder) Float32s(dst []float32) (int, error) {
+	return decode(d, dst, func(dst []float32, b []byte) {
+		switch d.dtype {
+		case DTypeF32:
+			for i := range dst {
+				dst[i] = math.Float32frombits(binary.LittleEndian.Uint32(b[4*i:]))
+			}
+		case DTypeBF16:
+			for i := range dst {
+				dst[i] = DecodeBF16(b[2*i:]).Float32()
+			}
+		case DTypeF16:
+			for i := range dst {
+				dst[i] = DecodeF16(b[2*i:]).Float32()
+			}
+		case DTypeF8E4M3:
+			for i := range dst {
+				dst[i] = F8E4M3(b[i]).Float32()
+			}
+		case DTypeF8E4M3Fn:
+			for i := range dst {
+				dst[i] = F8E4M3Fn(b[i]).Float32()
+			}
+		case DTypeF8E5M2:
+			for i := range dst {
+				dst[i] = F8E5M2(b[i]).Float32()
+			}
+		}
+	})
+}
+
+// BF16s decodes values into dst. The Decoder's DType must be DTypeBF16.
+//
+// See Float32s for the return values.
+func (d *Decoder) BF16s(dst []BF16) (int, error) {
+	if err := d.check(DTypeBF16); err != nil {
+		return 0, err
+	}
+	return decode(d, dst, func(dst []BF16, b []byte) {
+		for i := range dst {
+			dst[i] = DecodeBF16(b[2*i:])
+		}
+	})
+}
+
+// F16s decodes values into dst. The Decoder's DType must be DTypeF16.
+//
+// See Float32s for the return values.
+func (d *Decoder) F16s(dst []F16) (int, error) {
+	if err := d.check(DTypeF16); err != nil {
+		return 0, err
+	}
+	return decode(d, dst, func(dst []F16, b []byte) {
+		for i := range dst {
+			dst[i] = DecodeF16(b[2*i:])
+		}
+	})
+}
+
+// F8E4M3s decodes values into dst. The Decoder's DType must be DTypeF8E4M3.
+//
+// See Float32s for the return values.
+func (d *Decoder) F8E4M3s(dst []F8E4M3) (int, error) {
+	if err := d.check(DTypeF8E4M3); err != nil {
+		return 0, err
+	}
+	return decode(d, dst, func(dst []F8E4M3, b []byte) {
+		for i := range dst {
+			dst[i] = F8E4M3(b[i])
+		}
+	})
+}
+
+// F8E4M3Fns decodes values into dst. The Decoder's DType must be
+// DTypeF8E4M3Fn.
+//
+// See Float32s for the return values.
+func (d *Decoder) F8E4M3Fns(dst []F8E4M3Fn) (int, error) {
+	if err := d.check(DTypeF8E4M3Fn); err != nil {
+		return 0, err
+	}
+	return decode(d, dst, func(dst []F8E4M3Fn, b []byte) {
+		for i := range dst {
+			dst[i] = F8E4M3Fn(b[i])
+		}
+	})
+}
+
+// F8E5M2s decodes values into dst. The Decoder's DType must be DTypeF8E5M2.
+//
+// See Float32s for the return values.
+func (d *Decoder) F8E5M2s(dst []F8E5M2) (int, error) {
+	if err := d.check(DTypeF8E5M2); err != nil {
+		return 0, err
+	}
+	return decode(d, dst, func(dst []F8E5M2, b []byte) {
+		for i := range dst {
+			dst[i] = F8E5M2(b[i])
+		}
+	})
+}
+
+func (d *Decoder) check(want DType) error {
+	if d.dtype != want {
+		return fmt.Errorf("floatx: can't decode %s as %s", d.dtype, want)
+	}
+	return nil
+}
+
+// decode reads the bytes for dst in chunks from the buffer of the
+// bufio.Reader and calls fn on each of them.
+func decode[T any](d *Decoder, dst []T, fn func(dst []T, b []byte)) (int, error) {
+	size := d.dtype.Size()
+	n := 0
+	for n < len(dst) {
+		want := min((len(dst)-n)*size, d.r.Size()/size*size)
+		b, err := d.r.Peek(want)
+		count := len(b) / size
+		fn(dst[n:n+count], b)
+		n += count
+		if err != nil {
+			if extra := len(b) % size; extra != 0 && err == io.EOF {
+				// Consume the truncated value too since nothing follows.
+				_, _ = d.r.Discard(len(b))
+				offset := d.offset + int64(count*size)
+				d.offset += int64(len(b))
+				return n, &ShortBufferError{Type: d.dtype.String(), Offset: int(offset), Size: size, Len: extra}
+			}
+			// Keep the partial value buffered so a retry after a transient
+			// error stays aligned.
+			_, _ = d.r.Discard(count * size)
+			d.offset += int64(count * size)
+			return n, err
+		}
+		_, _ = d.r.Discard(len(b))
+		d.offset += int64(len(b))
+	}
+	return n, nil
+}
+
+// Encoder encodes values of a single DType as a stream of little endian
+// values.
+//
+// Flush must be called once done.
+type Encoder struct {
+	w      *bufio.Writer
+	dtype  DType
+	offset int64
+	err    error
+}
+
+// NewEncoder returns an Encoder writing values of type dtype to w.
+func NewEncoder(w io.Writer, dtype DType) *Encoder {
+	if dtype.Size() == 0 {
+		panic("floatx: invalid " + dtype.String())
+	}
+	return &Encoder{w: bufio.NewWriterSize(w, streamBufferSize), dtype: dtype}
+}
+
+// DType returns the type of the values encoded.
+func (e *Encoder) DType() DType {
+	return e.dtype
+}
+
+// OutputOffset returns the number of bytes successfully written to the
+// underlying writer so far.
+func (e *Encoder) OutputOffset() int64 {
+	return e.offset - int64(e.w.Buffered())
+}
+
+// Flush writes any buffered data to the underlying writer.
+func (e *Encoder) Flush() error {
+	if e.err != nil {
+		return e.err
+	}
+	e.err = e.w.Flush()
+	return e.err
+}
+
+// Float32s encodes values from src, rounding them to the Encoder's DType.
+//
+// Values are rounded to nearest even, like BF16FromFloat32.
+func (e *Encoder) Float32s(src []float32) error {
+	return encode(e, src, func(b []byte, src []float32) []byte {
+		switch e.dtype {
+		case DTypeF32:
+			for _, v := range src {
+				b = binary.LittleEndian.AppendUint32(b, math.Float32bits(v))
+			}
+		case DTypeBF16:
+			for _, v := range src {
+				b = AppendBF16(b, BF16FromFloat32(v))
+			}
+		case DTypeF16:
+			for _, v := range src {
+				b = AppendF16(b, F16FromFloat32(v))
+			}
+		case DTypeF8E4M3:
+			for _, v := range src {
+				b = append(b, byte(F8E4M3FromFloat32(v)))
+			}
+		case DTypeF8E4M3Fn:
+			for _, v := range src {
+				b = append(b, byte(F8E4M3FnFromFloat32(v)))
+			}
+		case DTypeF8E5M2:
+			for _, v := range src {
+				b = append(b, byte(F8E5M2FromFloat32(v)))
+			}
+		}
+		return b
+	})
+}
+
+// BF16s encodes values from src. The Encoder's DType must be DTypeBF16.
+func (e *Encoder) BF16s(src []BF16) error {
+	if err := e.check(DTypeBF16); err != nil {
+		return err
+	}
+	return encode(e, src, func(b []byte, src []BF16) []byte {
+		for _, v := range src {
+			b = AppendBF16(b, v)
+		}
+		return b
+	})
+}
+
+// F16s encodes values from src. The Encoder's DType must be DTypeF16.
+func (e *Encoder) F16s(src []F16) error {
+	if err := e.check(DTypeF16); err != nil {
+		return err
+	}
+	return encode(e, src, func(b []byte, src []F16) []byte {
+		for _, v := range src {
+			b = AppendF16(b, v)
+		}
+		return b
+	})
+}
+
+// F8E4M3s encodes values from src. The Encoder's DType must be DTypeF8E4M3.
+func (e *Encoder) F8E4M3s(src []F8E4M3) error {
+	if err := e.check(DTypeF8E4M3); err != nil {
+		return err
+	}
+	return encode(e, src, func(b []byte, src []F8E4M3) []byte {
+		for _, v := range src {
+			b = append(b, byte(v))
+		}
+		return b
+	})
+}
+
+// F8E4M3Fns encodes values from src. The Encoder's DType must be
+// DTypeF8E4M3Fn.
+func (e *Encoder) F8E4M3Fns(src []F8E4M3Fn) error {
+	if err := e.check(DTypeF8E4M3Fn); err != nil {
+		return err
+	}
+	return encode(e, src, func(b []byte, src []F8E4M3Fn) []byte {
+		for _, v := range src {
+			b = append(b, byte(v))
+		}
+		return b
+	})
+}
+
+// F8E5M2s encodes values from src. The Encoder's DType must be DTypeF8E5M2.
+func (e *Encoder) F8E5M2s(src []F8E5M2) error {
+	if err := e.check(DTypeF8E5M2); err != nil {
+		return err
+	}
+	return encode(e, src, func(b []byte, src []F8E5M2) []byte {
+		for _, v := range src {
+			b = append(b, byte(v))
+		}
+		return b
+	})
+}
+
+func (e *Encoder) check(want DType) error {
+	if e.dtype != want {
+		return fmt.Errorf("floatx: can't encode %s as %s", want, e.dtype)
+	}
+	return nil
+}
+
+// encode appends the values in chunks directly in the free space of the
+// bufio.Writer.
+func encode[T any](e *Encoder, src []T, fn func(b []byte, src []T) []byte) error {
+	if e.err != nil {
+		return e.err
+	}
+	size := e.dtype.Size()
+	for len(src) != 0 {
+		if e.w.Available() < size {
+			if e.err = e.w.Flush(); e.err != nil {
+				return e.err
+			}
+		}
+		count := min(len(src), e.w.Available()/size)
+		b := fn(e.w.AvailableBuffer(), src[:count])
+		src = src[count:]
+		// Writing to the available buffer never fails and never flushes.
+		n, _ := e.w.Write(b)
+		e.offset += int64(n)
+	}
+	return nil
+}
@@ -0,0 +1,294 @@
+// Copyright 2024 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package floatx_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"testing"
+	"testing/iotest"
+
+	"github.com/maruel/floatx"
+)
+
+func Test_Stream_Float32s(t *testing.T) {
+	// Larger than the internal buffer.
+	src := make([]float32, 100000)
+	for i := range src {
+		src[i] = float32(i%512) / 4
+	}
+	for _, dtype := range []floatx.DType{floatx.DTypeF32, floatx.DTypeBF16, floatx.DTypeF16, floatx.DTypeF8E4M3, floatx.DTypeF8E4M3Fn, floatx.DTypeF8E5M2} {
+		t.Run(dtype.String(), func(t *testing.T) {
+			buf := bytes.Buffer{}
+			e := floatx.NewEncoder(&buf, dtype)
+			if e.DType() != dtype {
+				t.Fatal(e.DType())
+			}
+			if err := e.Float32s(src[:10]); err != nil {
+				t.Fatal(err)
+			}
+			if err := e.Float32s(src[10:]); err != nil {
+				t.Fatal(err)
+			}
+			if err := e.Flush(); err != nil {
+				t.Fatal(err)
+			}
+			if got, want := e.OutputOffset(), int64(len(src)*dtype.Size()); got != want || int64(buf.Len()) != want {
+				t.Fatalf("want=%d got=%d len=%d", want, got, buf.Len())
+			}
+			d := floatx.NewDecoder(iotest.HalfReader(&buf), dtype)
+			if d.DType() != dtype {
+				t.Fatal(d.DType())
+			}
+			got := make([]float32, len(src)+1)
+			n, err := d.Float32s(got)
+			if n != len(src) || err != io.EOF {
+				t.Fatal(n, err)
+			}
+			if d.InputOffset() != int64(len(src)*dtype.Size()) {
+				t.Fatal(d.InputOffset())
+			}
+			for i := range src {
+				want := src[i]
+				switch dtype {
+				case floatx.DTypeBF16:
+					want = floatx.BF16FromFloat32(want).Float32()
+				case floatx.DTypeF16:
+					want = floatx.F16FromFloat32(want).Float32()
+				case floatx.DTypeF8E4M3:
+					want = floatx.F8E4M3FromFloat32(want).Float32()
+				case floatx.DTypeF8E4M3Fn:
+					want = floatx.F8E4M3FnFromFloat32(want).Float32()
+				case floatx.DTypeF8E5M2:
+					want = floatx.F8E5M2FromFloat32(want).Float32()
+				}
+				if got[i] != want {
+					t.Fatalf("#%d: want=%g got=%g", i, want, got[i])
+				}
+			}
+		})
+	}
+}
+
+func Test_Stream_Typed(t *testing.T) {
+	buf := bytes.Buffer{}
+	e := floatx.NewEncoder(&buf, floatx.DTypeBF16)
+	if err := e.F16s([]floatx.F16{1}); err == nil {
+		t.Fatal("expected error")
+	}
+	if err := e.BF16s([]floatx.BF16{0x3F80, 0xC000}); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	d := floatx.NewDecoder(&buf, floatx.DTypeBF16)
+	if _, err := d.F16s(make([]floatx.F16, 1)); err == nil {
+		t.Fatal("expected error")
+	}
+	got := make([]floatx.BF16, 2)
+	if n, err := d.BF16s(got); n != 2 || err != nil || got[0] != 0x3F80 || got[1] != 0xC000 {
+		t.Fatal(n, err, got)
+	}
+
+	data := []floatx.F16{0x3C00, 0x7C00}
+	e = floatx.NewEncoder(&buf, floatx.DTypeF16)
+	if err := e.F16s(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if gotF16, err := floatx.DecodeF16Slice(buf.Bytes()); err != nil || gotF16[0] != data[0] || gotF16[1] != data[1] {
+		t.Fatal(gotF16, err)
+	}
+	d = floatx.NewDecoder(&buf, floatx.DTypeF16)
+	gotF16 := make([]floatx.F16, 2)
+	if n, err := d.F16s(gotF16); n != 2 || err != nil || gotF16[0] != data[0] || gotF16[1] != data[1] {
+		t.Fatal(n, err, gotF16)
+	}
+}
+
+func Test_Stream_Float8(t *testing.T) {
+	b := []byte{0x00, 0x38, 0x7F, 0xFF}
+	buf := bytes.Buffer{}
+	e4m3 := floatx.NewEncoder(&buf, floatx.DTypeF8E4M3)
+	if err := e4m3.F8E4M3s([]floatx.F8E4M3{0x00, 0x38, 0x7F, 0xFF}); err != nil {
+		t.Fatal(err)
+	}
+	e4m3fn := floatx.NewEncoder(&buf, floatx.DTypeF8E4M3Fn)
+	if err := e4m3fn.F8E4M3Fns([]floatx.F8E4M3Fn{0x00, 0x38, 0x7F, 0xFF}); err != nil {
+		t.Fatal(err)
+	}
+	e5m2 := floatx.NewEncoder(&buf, floatx.DTypeF8E5M2)
+	if err := e5m2.F8E5M2s([]floatx.F8E5M2{0x00, 0x38, 0x7F, 0xFF}); err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range []*floatx.Encoder{e4m3, e4m3fn, e5m2} {
+		if err := e.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		if err := e.BF16s(nil); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+	if err := e4m3.F8E5M2s(nil); err == nil {
+		t.Fatal("expected error")
+	}
+	if err := e4m3fn.F8E4M3s(nil); err == nil {
+		t.Fatal("expected error")
+	}
+	if err := e5m2.F8E4M3Fns(nil); err == nil {
+		t.Fatal("expected error")
+	}
+	if !bytes.Equal(buf.Bytes(), bytes.Repeat(b, 3)) {
+		t.Fatalf("%x", buf.Bytes())
+	}
+	d := floatx.NewDecoder(bytes.NewReader(b), floatx.DTypeF8E4M3)
+	if n, err := d.F8E4M3Fns(nil); n != 0 || err == nil {
+		t.Fatal(n, err)
+	}
+	gotE4M3 := make([]floatx.F8E4M3, 4)
+	if n, err := d.F8E4M3s(gotE4M3); n != 4 || err != nil || gotE4M3[1] != 0x38 {
+		t.Fatal(n, err, gotE4M3)
+	}
+	d = floatx.NewDecoder(bytes.NewReader(b), floatx.DTypeF8E4M3Fn)
+	if n, err := d.F8E5M2s(nil); n != 0 || err == nil {
+		t.Fatal(n, err)
+	}
+	gotE4M3Fn := make([]floatx.F8E4M3Fn, 4)
+	if n, err := d.F8E4M3Fns(gotE4M3Fn); n != 4 || err != nil || gotE4M3Fn[3] != 0xFF {
+		t.Fatal(n, err, gotE4M3Fn)
+	}
+	d = floatx.NewDecoder(bytes.NewReader(b), floatx.DTypeF8E5M2)
+	if n, err := d.F8E4M3s(nil); n != 0 || err == nil {
+		t.Fatal(n, err)
+	}
+	gotE5M2 := make([]floatx.F8E5M2, 4)
+	if n, err := d.F8E5M2s(gotE5M2); n != 4 || err != nil || gotE5M2[2] != 0x7F {
+		t.Fatal(n, err, gotE5M2)
+	}
+	d = floatx.NewDecoder(bytes.NewReader(b), floatx.DTypeF16)
+	if n, err := d.BF16s(nil); n != 0 || err == nil {
+		t.Fatal(n, err)
+	}
+}
+
+func Test_Stream_Truncated(t *testing.T) {
+	d := floatx.NewDecoder(bytes.NewReader([]byte{0x80, 0x3F, 0x00, 0x40, 0x01}), floatx.DTypeBF16)
+	got := make([]float32, 1)
+	if n, err := d.Float32s(got); n != 1 || err != nil || got[0] != 1 {
+		t.Fatal(n, err, got)
+	}
+	got = make([]float32, 4)
+	n, err := d.Float32s(got)
+	var e *floatx.ShortBufferError
+	if n != 1 || !errors.As(err, &e) || got[0] != 2 {
+		t.Fatal(n, err, got)
+	}
+	if e.Offset != 4 || e.Len != 1 || e.Size != 2 || e.Type != "BF16" {
+		t.Fatalf("%#v", e)
+	}
+	if d.InputOffset() != 5 {
+		t.Fatal(d.InputOffset())
+	}
+	if n, err = d.Float32s(got); n != 0 || err != io.EOF {
+		t.Fatal(n, err)
+	}
+}
+
+func Test_Stream_ReadError(t *testing.T) {
+	r := io.MultiReader(bytes.NewReader([]byte{0x00, 0x00, 0x80}), iotest.ErrReader(errors.New("oh")))
+	d := floatx.NewDecoder(r, floatx.DTypeF32)
+	got := make([]float32, 2)
+	if n, err := d.Float32s(got); n != 0 || err == nil || err.Error() != "oh" {
+		t.Fatal(n, err)
+	}
+	if d.InputOffset() != 0 {
+		t.Fatal(d.InputOffset())
+	}
+}
+
+// errOnce returns err on the first call, then io.EOF.
+type errOnce struct {
+	err error
+}
+
+func (e *errOnce) Read(p []byte) (int, error) {
+	if err := e.err; err != nil {
+		e.err = nil
+		return 0, err
+	}
+	return 0, io.EOF
+}
+
+func Test_Stream_ReadError_Retry(t *testing.T) {
+	// The error happens in the middle of the second value.
+	r := io.MultiReader(bytes.NewReader([]byte{0x80, 0x3F, 0x00}), &errOnce{errors.New("timeout")}, bytes.NewReader([]byte{0xC0, 0x00, 0x40}))
+	d := floatx.NewDecoder(r, floatx.DTypeBF16)
+	got := make([]float32, 3)
+	if n, err := d.Float32s(got); n != 1 || err == nil || err.Error() != "timeout" || got[0] != 1 {
+		t.Fatal(n, err, got)
+	}
+	if d.InputOffset() != 2 {
+		t.Fatal(d.InputOffset())
+	}
+	if n, err := d.Float32s(got[:2]); n != 2 || err != nil || got[0] != -2 || got[1] != 2 {
+		t.Fatal(n, err, got)
+	}
+	if d.InputOffset() != 6 {
+		t.Fatal(d.InputOffset())
+	}
+}
+
+type failWriter struct{}
+
+func (failWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("oh")
+}
+
+func Test_Stream_WriteError(t *testing.T) {
+	e := floatx.NewEncoder(failWriter{}, floatx.DTypeF16)
+	if err := e.Float32s([]float32{1, float32(math.Inf(1))}); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Flush(); err == nil {
+		t.Fatal("expected error")
+	}
+	if err := e.Flush(); err == nil {
+		t.Fatal("expected error")
+	}
+	if err := e.Float32s([]float32{1}); err == nil {
+		t.Fatal("expected error")
+	}
+	if e.OutputOffset() != 0 {
+		t.Fatal(e.OutputOffset())
+	}
+	// Fill the buffer so it flushes internally.
+	e = floatx.NewEncoder(failWriter{}, floatx.DTypeF8E5M2)
+	if err := e.Float32s(make([]float32, 100000)); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func Test_Stream_InvalidDType(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected panic")
+		}
+	}()
+	floatx.NewDecoder(nil, 0)
+}
+
+func Test_Stream_InvalidDTypeEncoder(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected panic")
+		}
+	}()
+	floatx.NewEncoder(nil, 0)
+}
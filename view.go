@@ -0,0 +1,94 @@
+// Copyright 2024 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package floatx
+
+import (
+	"encoding/binary"
+	"math"
+	"unsafe"
+)
+
+// isLittleEndian is true when the host stores values in little endian, which
+// is the encoding used by the Decode functions.
+var isLittleEndian = binary.NativeEndian.Uint16([]byte{1, 0}) == 1
+
+// ViewBF16 returns the little endian values in b as a []BF16.
+//
+// When possible, the returned slice aliases b so no copy is made and aliased
+// is true. This is not possible on big endian hosts or when b is not aligned
+// on 2 bytes, in which case the values are decoded in a new slice.
+//
+// It returns a *ShortBufferError if len(b) is odd.
+func ViewBF16(b []byte) (v []BF16, aliased bool, err error) {
+	if len(b)%2 != 0 {
+		return nil, false, &ShortBufferError{Type: "BF16", Offset: len(b) - 1, Size: 2, Len: 1}
+	}
+	if canAlias(b, 2) {
+		return unsafe.Slice((*BF16)(unsafe.Pointer(unsafe.SliceData(b))), len(b)/2), true, nil
+	}
+	v, _ = DecodeBF16Slice(b)
+	return v, false, nil
+}
+
+// ViewF16 returns the little endian values in b as a []F16.
+//
+// When possible, the returned slice aliases b so no copy is made and aliased
+// is true. This is not possible on big endian hosts or when b is not aligned
+// on 2 bytes, in which case the values are decoded in a new slice.
+//
+// It returns a *ShortBufferError if len(b) is odd.
+func ViewF16(b []byte) (v []F16, aliased bool, err error) {
+	if len(b)%2 != 0 {
+		return nil, false, &ShortBufferError{Type: "F16", Offset: len(b) - 1, Size: 2, Len: 1}
+	}
+	if canAlias(b, 2) {
+		return unsafe.Slice((*F16)(unsafe.Pointer(unsafe.SliceData(b))), len(b)/2), true, nil
+	}
+	v, _ = DecodeF16Slice(b)
+	return v, false, nil
+}
+
+// ViewF32 returns the little endian values in b as a []F32.
+//
+// When possible, the returned slice aliases b so no copy is made and aliased
+// is true. This is not possible on big endian hosts or when b is not aligned
+// on 4 bytes, in which case the values are decoded in a new slice.
+//
+// It returns a *ShortBufferError if len(b) is not a multiple of 4.
+func ViewF32(b []byte) (v []F32, aliased bool, err error) {
+	if extra := len(b) % 4; extra != 0 {
+		return nil, false, &ShortBufferError{Type: "F32", Offset: len(b) - extra, Size: 4, Len: extra}
+	}
+	if canAlias(b, 4) {
+		return unsafe.Slice((*F32)(unsafe.Pointer(unsafe.SliceData(b))), len(b)/4), true, nil
+	}
+	v = make([]F32, len(b)/4)
+	for i := range v {
+		v[i] = F32(math.Float32frombits(binary.LittleEndian.Uint32(b[4*i:])))
+	}
+	return v, false, nil
+}
+
+// ViewF8E4M3 returns b as a []F8E4M3. The returned slice always aliases b.
+func ViewF8E4M3(b []byte) []F8E4M3 {
+	return unsafe.Slice((*F8E4M3)(unsafe.SliceData(b)), len(b))
+}
+
+// ViewF8E4M3Fn returns b as a []F8E4M3Fn. The returned slice always aliases
+// b.
+func ViewF8E4M3Fn(b []byte) []F8E4M3Fn {
+	return unsafe.Slice((*F8E4M3Fn)(unsafe.SliceData(b)), len(b))
+}
+
+// ViewF8E5M2 returns b as a []F8E5M2. The returned slice always aliases b.
+func ViewF8E5M2(b []byte) []F8E5M2 {
+	return unsafe.Slice((*F8E5M2)(unsafe.SliceData(b)), len(b))
+}
+
+// canAlias returns true if b can be reinterpreted as values of the specified
+// size without copying.
+func canAlias(b []byte, size uintptr) bool {
+	return isLittleEndian && uintptr(unsafe.Pointer(unsafe.SliceData(b)))%size == 0
+}
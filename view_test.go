@@ -0,0 +1,93 @@
+// Copyright 2024 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package floatx_test
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"unsafe"
+
+	"github.com/maruel/floatx"
+)
+
+func Test_ViewBF16(t *testing.T) {
+	b := aligned(0x80, 0x3F, 0x00, 0xC0, 0x00, 0x00)
+	v, aliased, err := floatx.ViewBF16(b[:4])
+	if err != nil || !aliased || len(v) != 2 || v[0] != 0x3F80 || v[1] != 0xC000 {
+		t.Fatal(v, aliased, err)
+	}
+	v[0] = 0x4000
+	if b[0] != 0x00 || b[1] != 0x40 {
+		t.Fatalf("%x", b)
+	}
+	// Not aligned.
+	if v, aliased, err = floatx.ViewBF16(b[1:5]); err != nil || aliased || len(v) != 2 || v[0] != 0x0040 || v[1] != 0x00C0 {
+		t.Fatal(v, aliased, err)
+	}
+	var e *floatx.ShortBufferError
+	if _, _, err = floatx.ViewBF16(b[:3]); !errors.As(err, &e) || e.Offset != 2 {
+		t.Fatal(err)
+	}
+}
+
+func Test_ViewF16(t *testing.T) {
+	b := aligned(0x00, 0x3C, 0x00, 0xC0, 0x00)
+	v, aliased, err := floatx.ViewF16(b[:4])
+	if err != nil || !aliased || len(v) != 2 || v[0] != 0x3C00 || v[1] != 0xC000 {
+		t.Fatal(v, aliased, err)
+	}
+	if v, aliased, err = floatx.ViewF16(b[1:]); err != nil || aliased || len(v) != 2 || v[0] != 0x003C || v[1] != 0x00C0 {
+		t.Fatal(v, aliased, err)
+	}
+	if _, _, err = floatx.ViewF16(b); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func Test_ViewF32(t *testing.T) {
+	b := aligned(0x00, 0x00, 0x80, 0x3F, 0x00, 0x00, 0x00, 0xC0, 0x00)
+	v, aliased, err := floatx.ViewF32(b[:8])
+	if err != nil || !aliased || len(v) != 2 || v[0] != 1 || v[1] != -2 {
+		t.Fatal(v, aliased, err)
+	}
+	if v, aliased, err = floatx.ViewF32(b[1:]); err != nil || aliased || len(v) != 2 || math.Float32bits(float32(v[0])) != 0x003F8000 {
+		t.Fatal(v, aliased, err)
+	}
+	var e *floatx.ShortBufferError
+	if _, _, err = floatx.ViewF32(b[:6]); !errors.As(err, &e) || e.Offset != 4 || e.Len != 2 {
+		t.Fatal(err)
+	}
+}
+
+func Test_ViewF8(t *testing.T) {
+	b := []byte{0x38, 0xC0}
+	if v := floatx.ViewF8E4M3(b); len(v) != 2 || v[0].Float32() != 1 || v[1].Float32() != -2 {
+		t.Fatal(v)
+	}
+	if v := floatx.ViewF8E4M3Fn(b); len(v) != 2 || v[0].Float32() != 1 || v[1].Float32() != -2 {
+		t.Fatal(v)
+	}
+	v := floatx.ViewF8E5M2(b)
+	if len(v) != 2 || v[0].Float32() != 0.5 || v[1].Float32() != -2 {
+		t.Fatal(v)
+	}
+	v[0] = 0
+	if b[0] != 0 {
+		t.Fatal(b)
+	}
+	if v := floatx.ViewF8E5M2(nil); len(v) != 0 {
+		t.Fatal(v)
+	}
+}
+
+// aligned returns a copy of b backed by a []uint64, so it is 8 bytes aligned
+// and b[1:] is not aligned.
+func aligned(b ...byte) []byte {
+	u := make([]uint64, (len(b)+7)/8)
+	a := unsafe.Slice((*byte)(unsafe.Pointer(unsafe.SliceData(u))), len(u)*8)[:len(b)]
+	copy(a, b)
+	return a
+}